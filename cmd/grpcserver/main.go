@@ -2,19 +2,34 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
-	"log"
+	"fmt"
+	"io/fs"
+	"log/slog"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
+	_ "time/tzdata" // the runtime images ship without zoneinfo
 
 	grpcserver "github.com/milad/spectral/internal/transport/grpc"
 
+	"github.com/milad/spectral/internal/config"
+	"github.com/milad/spectral/internal/domain"
+	"github.com/milad/spectral/internal/logging"
+	"github.com/milad/spectral/internal/repo"
 	"github.com/milad/spectral/internal/repo/csvrepo"
+	"github.com/milad/spectral/internal/repo/sqliterepo"
 	"github.com/milad/spectral/internal/service"
+	"github.com/milad/spectral/internal/telemetry"
+	"github.com/milad/spectral/internal/tlsutil"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/health"
 	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 
@@ -22,43 +37,132 @@ import (
 )
 
 func main() {
-	var (
-		addr    = flag.String("addr", envOr("GRPC_ADDR", ":9090"), "listen address")
-		csvPath = flag.String("csv", envOr("CSV_PATH", "meterusage.csv"), "path to meterusage.csv")
-	)
-	flag.Parse()
+	cfg, err := config.Load(config.GRPC, os.Args[0], os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	gc := cfg.GRPC
+
+	level, err := logging.Setup(os.Stderr, cfg.Logging.Format, cfg.Logging.Level)
+	if err != nil {
+		fatal("set up logging", logging.Err(err))
+	}
+	sampler, err := logging.NewSampler(cfg.Logging.AccessLogSampleRatio)
+	if err != nil {
+		fatal("set up logging", logging.Err(err))
+	}
+
+	// Validated by config.Load.
+	csvLoc, _ := time.LoadLocation(gc.CSV.TimeZone)
+	csvOpts := []csvrepo.Option{
+		csvrepo.WithLocation(csvLoc),
+		csvrepo.WithSchema(csvrepo.Schema(gc.CSV.Schema)),
+		csvrepo.WithParallelism(gc.CSV.Parallelism),
+		csvrepo.WithProgress(gc.CSV.ProgressInterval, logLoadProgress(gc.CSV.ProgressInterval)),
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	repo, err := csvrepo.NewFromFile(*csvPath)
+	shutdownTracing, err := telemetry.Setup(ctx, telemetry.Config{
+		ServiceName: "meterusage-grpc",
+		Exporter:    cfg.Tracing.Exporter,
+		File:        cfg.Tracing.File,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
 	if err != nil {
-		// CSV may contain a few bad rows (e.g. NaN). We keep going if we have usable readings.
-		log.Printf("warning: %v", err)
+		fatal("set up tracing", logging.Err(err))
 	}
-	if repo == nil {
-		log.Fatalf("failed to load csv from %q", *csvPath)
+	defer flushTraces(shutdownTracing)
+
+	var repo repo.ReadingRepository
+	switch gc.Store {
+	case "csv":
+		csvRepo, err := csvrepo.NewFromFile(gc.CSV.Path, csvOpts...)
+		switch {
+		case csvRepo == nil:
+			// Serve nothing, and report NOT_SERVING, until a reload succeeds.
+			slog.Error("failed to load csv, waiting for a reload", "path", gc.CSV.Path, logging.Err(err))
+			csvRepo = csvrepo.NewPending(gc.CSV.Path, csvOpts...)
+		case err != nil:
+			// CSV may contain a few bad rows (e.g. NaN). We keep going if we have usable readings.
+			slog.Warn("csv has invalid rows", "path", gc.CSV.Path, logging.Err(err))
+		}
+		repo = csvRepo
+		go reloadOnSIGHUP(ctx, csvRepo)
+		if gc.CSV.WatchInterval > 0 {
+			go csvRepo.Watch(ctx, gc.CSV.WatchInterval, func(changed bool, err error) {
+				if changed || err != nil {
+					logReload(csvRepo, "file change", changed, err)
+				}
+			})
+		}
+	case "sqlite":
+		sqliteRepo, err := openSQLite(gc.SQLite.Path, gc.CSV.Path, csvOpts,
+			sqliterepo.WithReadConns(gc.SQLite.ReadConns),
+			sqliterepo.WithIdempotencyTTL(gc.SQLite.IdempotencyTTL),
+		)
+		if err != nil {
+			fatal("open sqlite store", logging.Err(err))
+		}
+		defer sqliteRepo.Close()
+		repo = sqliteRepo
 	}
 
-	svc := service.NewMeterUsageService(repo)
+	svcOpts := []service.Option{
+		service.WithLimits(service.Limits(gc.Limits)),
+		service.WithQuality(service.QualityOptions(gc.Quality)),
+	}
+	if gc.PageTokenKey != "" {
+		svcOpts = append(svcOpts, service.WithPageTokenKey([]byte(gc.PageTokenKey)))
+	} else {
+		slog.Warn("no -page-token-key set; page tokens will not survive a restart or work across replicas")
+	}
+	svc := service.NewMeterUsageService(repo, svcOpts...)
 	api := grpcserver.New(svc)
 
-	lis, err := net.Listen("tcp", *addr)
+	lis, err := net.Listen("tcp", gc.Addr)
 	if err != nil {
-		log.Fatalf("listen %q: %v", *addr, err)
+		fatal("listen", "addr", gc.Addr, logging.Err(err))
 	}
-	log.Printf("gRPC listening on %s", *addr)
+	slog.Info("gRPC listening", "addr", gc.Addr)
 
-	g := grpc.NewServer()
+	// Observability goes first so that calls rejected by the TLS allowlist
+	// are logged and counted too.
+	serverOpts := []grpc.ServerOption{
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(telemetry.UnaryServerRequestID(), grpcserver.UnaryObserve(sampler), grpcserver.UnaryRecover()),
+		grpc.ChainStreamInterceptor(telemetry.StreamServerRequestID(), grpcserver.StreamObserve(sampler), grpcserver.StreamRecover()),
+	}
+	tlsOpts, err := tlsServerOptions(ctx, gc.TLS)
+	if err != nil {
+		fatal("configure TLS", logging.Err(err))
+	}
+	g := grpc.NewServer(append(serverOpts, tlsOpts...)...)
 	meterusagev1.RegisterMeterUsageServiceServer(g, api)
 
+	// NOT_SERVING until ReportHealth has seen enough readings.
 	hs := health.NewServer()
-	hs.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	hs.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
 	healthpb.RegisterHealthServer(g, hs)
+	go grpcserver.ReportHealth(ctx, repo, hs, gc.Readiness.MinRows, gc.Readiness.CheckInterval)
 
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
-	defer stop()
+	if gc.MetricsAddr != "" {
+		go serveMetrics(ctx, gc.MetricsAddr)
+	}
+	if gc.AdminAddr != "" {
+		go serveAdmin(ctx, gc.AdminAddr, level)
+	}
 
 	go func() {
 		<-ctx.Done()
-		log.Printf("shutting down gRPC")
+		slog.Info("shutting down gRPC")
+		// Let health-checking clients move away while calls drain.
+		hs.Shutdown()
 		ch := make(chan struct{})
 		go func() {
 			g.GracefulStop()
@@ -72,13 +176,210 @@ func main() {
 	}()
 
 	if err := g.Serve(lis); err != nil {
-		log.Fatalf("serve: %v", err)
+		fatal("serve", logging.Err(err))
+	}
+}
+
+// serveMetrics serves the Prometheus registry on addr until ctx is done. The
+// gRPC server keeps running if the listener fails.
+func serveMetrics(ctx context.Context, addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	slog.Info("metrics listening", "addr", addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Warn("metrics listener failed", logging.Err(err))
+	}
+}
+
+// serveAdmin serves the admin endpoints on addr until ctx is done. The gRPC
+// server keeps running if the listener fails.
+func serveAdmin(ctx context.Context, addr string, level *slog.LevelVar) {
+	mux := http.NewServeMux()
+	mux.Handle("/loglevel", logging.LevelHandler(level))
+	srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	slog.Info("admin listening", "addr", addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Warn("admin listener failed", logging.Err(err))
 	}
 }
 
-func envOr(k, fallback string) string {
-	if v := os.Getenv(k); v != "" {
-		return v
+// flushTraces exports spans still buffered at exit.
+func flushTraces(shutdown func(context.Context) error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdown(ctx); err != nil {
+		slog.Warn("flush traces", logging.Err(err))
+	}
+}
+
+// fatal logs msg at error level and exits, like log.Fatal.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// tlsReloadInterval is how often certificate files are checked for changes.
+const tlsReloadInterval = 10 * time.Second
+
+// tlsServerOptions returns the credentials and interceptors for the TLS
+// settings, or nothing (plaintext) if no certificate is configured.
+func tlsServerOptions(ctx context.Context, cfg config.ServerTLS) ([]grpc.ServerOption, error) {
+	if cfg.Cert == "" {
+		slog.Warn("no -tls-cert configured; serving plaintext gRPC")
+		return nil, nil
+	}
+
+	certs, err := tlsutil.NewReloader(tlsutil.Files{Cert: cfg.Cert, Key: cfg.Key, CA: cfg.ClientCA})
+	if err != nil {
+		return nil, err
+	}
+	go certs.Watch(ctx, tlsReloadInterval, func(err error) {
+		if err != nil {
+			slog.Warn("TLS reload failed, keeping previous certificates", logging.Err(err))
+			return
+		}
+		slog.Info("TLS certificates reloaded")
+	})
+	tlsCfg, err := certs.ServerConfig()
+	if err != nil {
+		return nil, err
+	}
+	opts := []grpc.ServerOption{grpc.Creds(credentials.NewTLS(tlsCfg))}
+
+	switch {
+	case cfg.ClientCA == "":
+		slog.Info("TLS enabled (no client certificates)")
+	case len(cfg.AllowedClients) == 0:
+		slog.Warn("mutual TLS enabled without -tls-allowed-clients; any certificate signed by the client CA may call MeterUsageService")
+	default:
+		allow := grpcserver.NewClientAllowlist(cfg.AllowedClients)
+		opts = append(opts,
+			grpc.ChainUnaryInterceptor(allow.UnaryInterceptor()),
+			grpc.ChainStreamInterceptor(allow.StreamInterceptor()),
+		)
+		slog.Info("mutual TLS enabled", "allowed_clients", cfg.AllowedClients)
+	}
+	return opts, nil
+}
+
+// reloadOnSIGHUP reloads the CSV whenever the process receives SIGHUP.
+func reloadOnSIGHUP(ctx context.Context, r *csvrepo.Repo) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			changed, err := r.Reload(ctx)
+			logReload(r, "SIGHUP", changed, err)
+		}
+	}
+}
+
+func logReload(r *csvrepo.Repo, trigger string, changed bool, err error) {
+	ds, _ := r.Dataset(context.Background())
+	switch {
+	case err != nil && !changed:
+		slog.Error("reload failed, still serving the previous version", "trigger", trigger, "version", ds.Version, "rows", ds.Rows, logging.Err(err))
+	case changed:
+		if err != nil {
+			slog.Warn("reload skipped invalid rows", "trigger", trigger, logging.Err(err))
+		}
+		slog.Info("reloaded csv", "trigger", trigger, "version", ds.Version, "rows", ds.Rows, "checksum", ds.Checksum)
+	default:
+		slog.Info("reload: csv unchanged", "trigger", trigger)
+	}
+}
+
+// logLoadProgress returns a function logging the progress of loading the CSV
+// files every interval. Only loads that take longer log their completion.
+func logLoadProgress(every time.Duration) func(csvrepo.Progress) {
+	return func(p csvrepo.Progress) {
+		if p.Done && p.Elapsed < every {
+			return
+		}
+		attrs := []any{
+			"file", p.Path,
+			"files", fmt.Sprintf("%d/%d", p.File, p.Files),
+			"read_bytes", p.ReadBytes,
+			"total_bytes", p.TotalBytes,
+			"rows", p.Rows,
+			"elapsed", p.Elapsed.Round(time.Millisecond),
+		}
+		if p.Done {
+			slog.Info("loaded csv", attrs...)
+			return
+		}
+		percent := 100.0
+		if p.TotalBytes > 0 {
+			percent = float64(p.ReadBytes) * 100 / float64(p.TotalBytes)
+		}
+		slog.Info("loading csv", append(attrs, "percent", fmt.Sprintf("%.1f", percent))...)
+	}
+}
+
+// openSQLite opens the database and, if it holds no readings yet, seeds it
+// from the CSV file (when present).
+func openSQLite(path, seedCSV string, csvOpts []csvrepo.Option, opts ...sqliterepo.Option) (*sqliterepo.Repo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	r, err := sqliterepo.Open(ctx, path, opts...)
+	if err != nil {
+		return nil, err
+	}
+	n, err := r.Count(ctx)
+	if err != nil {
+		_ = r.Close()
+		return nil, err
+	}
+	if n > 0 || seedCSV == "" {
+		slog.Info("opened sqlite store", "path", path, "rows", n)
+		return r, nil
+	}
+
+	// The seed is streamed into one transaction, without a deadline: a large
+	// file takes as long as it takes.
+	var (
+		report  domain.IngestionReport
+		loadErr error
+	)
+	err = r.Seed(context.Background(), func(add func([]domain.Reading) error) (domain.IngestionReport, error) {
+		report, loadErr = csvrepo.LoadBatches(seedCSV, add, csvOpts...)
+		if !report.Applied {
+			return report, loadErr
+		}
+		return report, nil
+	})
+	switch {
+	case !report.Applied && errors.Is(err, fs.ErrNotExist):
+		slog.Warn("sqlite store is empty and the seed csv was not found", "path", path, "seed_csv", seedCSV)
+		return r, nil
+	case err != nil:
+		_ = r.Close()
+		return nil, fmt.Errorf("seed sqlite from %q: %w", seedCSV, err)
+	case loadErr != nil:
+		slog.Warn("seed csv has invalid rows", "seed_csv", seedCSV, logging.Err(loadErr))
 	}
-	return fallback
+	slog.Info("seeded sqlite store", "path", path, "rows", report.Accepted, "seed_csv", seedCSV)
+	return r, nil
 }
@@ -2,91 +2,254 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
-	"log"
+	"fmt"
+	"log/slog"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
-	"strconv"
+	"strings"
 	"syscall"
 	"time"
+	_ "time/tzdata" // the runtime images ship without zoneinfo
 
 	meterusagev1 "github.com/milad/spectral/gen/go/proto/meterusage/v1"
+	"github.com/milad/spectral/internal/auth"
+	"github.com/milad/spectral/internal/config"
+	"github.com/milad/spectral/internal/logging"
+	"github.com/milad/spectral/internal/telemetry"
+	"github.com/milad/spectral/internal/tlsutil"
 	httpserver "github.com/milad/spectral/internal/transport/http"
+	"github.com/milad/spectral/internal/upstream"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 func main() {
-	var (
-		addr     = flag.String("addr", envOr("HTTP_ADDR", ":8080"), "listen address")
-		grpcAddr = flag.String("grpc", envOr("GRPC_TARGET", "127.0.0.1:9090"), "gRPC target host:port")
-	)
-	flag.Parse()
+	cfg, err := config.Load(config.HTTP, os.Args[0], os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	hc := cfg.HTTP
+
+	level, err := logging.Setup(os.Stderr, cfg.Logging.Format, cfg.Logging.Level)
+	if err != nil {
+		fatal("set up logging", logging.Err(err))
+	}
+	sampler, err := logging.NewSampler(cfg.Logging.AccessLogSampleRatio)
+	if err != nil {
+		fatal("set up logging", logging.Err(err))
+	}
+	opts := []httpserver.Option{
+		httpserver.WithAccessLogSampler(sampler),
+		httpserver.WithTimeouts(httpserver.Timeouts{
+			Upstream:    hc.Timeouts.Upstream,
+			Stream:      hc.Timeouts.Stream,
+			StreamWrite: hc.Timeouts.StreamWrite,
+		}),
+		httpserver.WithCache(httpserver.CacheLimits(hc.Cache)),
+	}
+	if authn := loadAuthenticator(hc.Auth.APIKeysFile, hc.Auth.JWKSFile, auth.JWTConfig{Issuer: hc.Auth.JWTIssuer, Audience: hc.Auth.JWTAudience}); authn != nil {
+		opts = append(opts, httpserver.WithAuthenticator(authn))
+	} else {
+		slog.Warn("no -api-keys or -jwks configured; /api is unauthenticated")
+	}
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	conn, err := grpc.NewClient(*grpcAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	shutdownTracing, err := telemetry.Setup(ctx, telemetry.Config{
+		ServiceName: "meterusage-http",
+		Exporter:    cfg.Tracing.Exporter,
+		File:        cfg.Tracing.File,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
 	if err != nil {
-		log.Fatalf("dial gRPC %q: %v", *grpcAddr, err)
+		fatal("set up tracing", logging.Err(err))
+	}
+	defer flushTraces(shutdownTracing)
+
+	rateLimits, err := httpserver.ParseRateLimits(hc.RateLimits)
+	if err != nil {
+		fatal("invalid -rate-limits", logging.Err(err))
+	}
+	opts = append(opts, httpserver.WithRateLimits(rateLimits))
+
+	creds := insecure.NewCredentials()
+	if up := hc.Upstream; up.TLS || up.CA != "" || up.Cert != "" {
+		serverName := up.ServerName
+		if serverName == "" {
+			serverName = targetHost(up.Target)
+		}
+		certs, err := tlsutil.NewReloader(tlsutil.Files{Cert: up.Cert, Key: up.Key, CA: up.CA})
+		if err != nil {
+			fatal("load gRPC client TLS files", logging.Err(err))
+		}
+		go certs.Watch(ctx, tlsReloadInterval, func(err error) {
+			if err != nil {
+				slog.Warn("TLS reload failed, keeping previous certificates", logging.Err(err))
+				return
+			}
+			slog.Info("TLS certificates reloaded")
+		})
+		creds = credentials.NewTLS(certs.ClientConfig(serverName))
+	} else {
+		slog.Warn("connecting to gRPC without TLS")
+	}
+
+	retryCodes, err := upstream.ParseCodes(hc.Upstream.Retry.Codes)
+	if err != nil {
+		fatal("invalid -retry-codes", logging.Err(err))
+	}
+	retry := upstream.RetryPolicy{
+		MaxAttempts:    hc.Upstream.Retry.MaxAttempts,
+		InitialBackoff: hc.Upstream.Retry.InitialBackoff,
+		MaxBackoff:     hc.Upstream.Retry.MaxBackoff,
+		Multiplier:     hc.Upstream.Retry.Multiplier,
+		RetryableCodes: retryCodes,
+		HedgeDelay:     hc.Upstream.Retry.HedgeDelay,
+	}
+	// The breaker comes after the retries so that it sees every attempt.
+	breaker := upstream.NewBreaker(upstream.BreakerConfig(hc.Upstream.Breaker))
+
+	balancing, err := upstream.Balancing(hc.Upstream.Balancer).DialOptions()
+	if err != nil {
+		fatal("invalid -grpc-lb-policy", logging.Err(err))
+	}
+
+	conn, err := grpc.NewClient(upstream.Target(hc.Upstream.Target), append(balancing,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithChainUnaryInterceptor(telemetry.UnaryClientRequestID(), upstream.UnaryRetry(retry), breaker.Unary()),
+		grpc.WithChainStreamInterceptor(telemetry.StreamClientRequestID(), breaker.Stream()),
+	)...)
+	if err != nil {
+		fatal("dial gRPC", "target", hc.Upstream.Target, logging.Err(err))
 	}
 	defer conn.Close()
 
 	// Reduce docker-compose race: wait a bit for gRPC to be ready.
-	wait := envDurationMs("GRPC_WAIT_TIMEOUT_MS", 20_000) // 20s default
-	waitForGRPC(ctx, conn, wait)
+	waitForGRPC(ctx, conn, hc.Upstream.WaitTimeout)
 
 	client := meterusagev1.NewMeterUsageServiceClient(conn)
-	srv := httpserver.New(client)
+	opts = append(opts, httpserver.WithUpstreamHealth(healthpb.NewHealthClient(conn)))
+	srv := httpserver.New(client, opts...)
 
 	h := &http.Server{
-		Addr:              *addr,
+		Addr:              hc.Addr,
 		Handler:           srv,
-		ReadHeaderTimeout: 5 * time.Second,
-		ReadTimeout:       15 * time.Second,
-		WriteTimeout:      15 * time.Second,
-		IdleTimeout:       60 * time.Second,
+		ReadHeaderTimeout: hc.Timeouts.ReadHeader,
+		ReadTimeout:       hc.Timeouts.Read,
+		WriteTimeout:      hc.Timeouts.Write,
+		IdleTimeout:       hc.Timeouts.Idle,
 	}
 
-	ln, err := net.Listen("tcp", *addr)
+	ln, err := net.Listen("tcp", hc.Addr)
 	if err != nil {
-		log.Fatalf("listen %q: %v", *addr, err)
+		fatal("listen", "addr", hc.Addr, logging.Err(err))
+	}
+	slog.Info("HTTP listening", "addr", hc.Addr, "grpc_target", hc.Upstream.Target)
+
+	if hc.AdminAddr != "" {
+		go serveAdmin(ctx, hc.AdminAddr, level)
 	}
-	log.Printf("HTTP listening on %s (gRPC target %s)", *addr, *grpcAddr)
 
 	go func() {
 		<-ctx.Done()
-		log.Printf("shutting down HTTP")
+		slog.Info("shutting down HTTP")
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 		_ = h.Shutdown(shutdownCtx)
 	}()
 
 	if err := h.Serve(ln); err != nil && err != http.ErrServerClosed {
-		log.Fatalf("serve: %v", err)
+		fatal("serve", logging.Err(err))
+	}
+}
+
+// loadAuthenticator builds the authenticator for the configured credential
+// sources, or returns nil if there are none.
+func loadAuthenticator(apiKeysPath, jwksPath string, jwtCfg auth.JWTConfig) auth.Authenticator {
+	var authenticators []auth.Authenticator
+	if apiKeysPath != "" {
+		keys, err := auth.LoadAPIKeys(apiKeysPath)
+		if err != nil {
+			fatal("load API keys", logging.Err(err))
+		}
+		authenticators = append(authenticators, keys)
+	}
+	if jwksPath != "" {
+		jwt, err := auth.LoadJWT(jwksPath, jwtCfg)
+		if err != nil {
+			fatal("load JWKS", logging.Err(err))
+		}
+		authenticators = append(authenticators, jwt)
+	}
+	if len(authenticators) == 0 {
+		return nil
+	}
+	return auth.Chain(authenticators...)
+}
+
+// serveAdmin serves the admin endpoints on addr until ctx is done. The
+// gateway keeps running if the listener fails.
+func serveAdmin(ctx context.Context, addr string, level *slog.LevelVar) {
+	mux := http.NewServeMux()
+	mux.Handle("/loglevel", logging.LevelHandler(level))
+	srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	slog.Info("admin listening", "addr", addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Warn("admin listener failed", logging.Err(err))
 	}
 }
 
-func envOr(k, fallback string) string {
-	if v := os.Getenv(k); v != "" {
-		return v
+// flushTraces exports spans still buffered at exit.
+func flushTraces(shutdown func(context.Context) error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdown(ctx); err != nil {
+		slog.Warn("flush traces", logging.Err(err))
 	}
-	return fallback
 }
 
-func envDurationMs(k string, fallbackMs int) time.Duration {
-	v := os.Getenv(k)
-	if v == "" {
-		return time.Duration(fallbackMs) * time.Millisecond
+// fatal logs msg at error level and exits, like log.Fatal.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// tlsReloadInterval is how often certificate files are checked for changes.
+const tlsReloadInterval = 10 * time.Second
+
+// targetHost returns the host of a gRPC target such as "dns:///grpc:9090" or
+// "127.0.0.1:9090".
+func targetHost(target string) string {
+	if i := strings.LastIndex(target, "/"); i >= 0 {
+		target = target[i+1:]
 	}
-	n, err := strconv.Atoi(v)
-	if err != nil || n <= 0 {
-		return time.Duration(fallbackMs) * time.Millisecond
+	// Backends listed together are expected to share a certificate.
+	target, _, _ = strings.Cut(target, ",")
+	if host, _, err := net.SplitHostPort(target); err == nil {
+		return host
 	}
-	return time.Duration(n) * time.Millisecond
+	return target
 }
 
 func waitForGRPC(ctx context.Context, conn *grpc.ClientConn, maxWait time.Duration) {
@@ -103,16 +266,21 @@ func waitForGRPC(ctx context.Context, conn *grpc.ClientConn, maxWait time.Durati
 			return
 		}
 
+		// Only SERVING is ready: a server still loading its data, or draining,
+		// answers the health check too.
 		reqCtx, cancel := context.WithTimeout(ctx, 1*time.Second)
-		_, err := hc.Check(reqCtx, &healthpb.HealthCheckRequest{})
+		resp, err := hc.Check(reqCtx, &healthpb.HealthCheckRequest{})
 		cancel()
-		if err == nil {
-			log.Printf("gRPC is ready")
+		if err == nil && resp.GetStatus() == healthpb.HealthCheckResponse_SERVING {
+			slog.Info("gRPC is ready")
 			return
 		}
+		if err == nil {
+			err = fmt.Errorf("health status %s", resp.GetStatus())
+		}
 
 		if time.Now().After(deadline) {
-			log.Printf("warning: gRPC not ready after %s; continuing anyway (%v)", maxWait, err)
+			slog.Warn("gRPC not ready; continuing anyway", "waited", maxWait.String(), logging.Err(err))
 			return
 		}
 
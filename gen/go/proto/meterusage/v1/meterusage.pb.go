@@ -9,6 +9,7 @@ package meterusagev1
 import (
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
 	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
 	reflect "reflect"
 	sync "sync"
@@ -22,6 +23,238 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+type QualityFlag int32
+
+const (
+	QualityFlag_QUALITY_FLAG_UNSPECIFIED QualityFlag = 0
+	// Another reading of the meter has the same time.
+	QualityFlag_QUALITY_FLAG_DUPLICATE QualityFlag = 1
+	// The usage is below zero.
+	QualityFlag_QUALITY_FLAG_NEGATIVE QualityFlag = 2
+	// The usage is far from that of the readings around it.
+	QualityFlag_QUALITY_FLAG_SPIKE QualityFlag = 3
+	// One or more readings are missing before this one, given the expected
+	// interval between readings.
+	QualityFlag_QUALITY_FLAG_GAP_BEFORE QualityFlag = 4
+)
+
+// Enum value maps for QualityFlag.
+var (
+	QualityFlag_name = map[int32]string{
+		0: "QUALITY_FLAG_UNSPECIFIED",
+		1: "QUALITY_FLAG_DUPLICATE",
+		2: "QUALITY_FLAG_NEGATIVE",
+		3: "QUALITY_FLAG_SPIKE",
+		4: "QUALITY_FLAG_GAP_BEFORE",
+	}
+	QualityFlag_value = map[string]int32{
+		"QUALITY_FLAG_UNSPECIFIED": 0,
+		"QUALITY_FLAG_DUPLICATE":   1,
+		"QUALITY_FLAG_NEGATIVE":    2,
+		"QUALITY_FLAG_SPIKE":       3,
+		"QUALITY_FLAG_GAP_BEFORE":  4,
+	}
+)
+
+func (x QualityFlag) Enum() *QualityFlag {
+	p := new(QualityFlag)
+	*p = x
+	return p
+}
+
+func (x QualityFlag) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (QualityFlag) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_meterusage_v1_meterusage_proto_enumTypes[0].Descriptor()
+}
+
+func (QualityFlag) Type() protoreflect.EnumType {
+	return &file_proto_meterusage_v1_meterusage_proto_enumTypes[0]
+}
+
+func (x QualityFlag) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use QualityFlag.Descriptor instead.
+func (QualityFlag) EnumDescriptor() ([]byte, []int) {
+	return file_proto_meterusage_v1_meterusage_proto_rawDescGZIP(), []int{0}
+}
+
+type AggregateFunction int32
+
+const (
+	AggregateFunction_AGGREGATE_FUNCTION_UNSPECIFIED AggregateFunction = 0
+	AggregateFunction_AGGREGATE_FUNCTION_SUM         AggregateFunction = 1
+	AggregateFunction_AGGREGATE_FUNCTION_AVG         AggregateFunction = 2
+	AggregateFunction_AGGREGATE_FUNCTION_MIN         AggregateFunction = 3
+	AggregateFunction_AGGREGATE_FUNCTION_MAX         AggregateFunction = 4
+	AggregateFunction_AGGREGATE_FUNCTION_COUNT       AggregateFunction = 5
+)
+
+// Enum value maps for AggregateFunction.
+var (
+	AggregateFunction_name = map[int32]string{
+		0: "AGGREGATE_FUNCTION_UNSPECIFIED",
+		1: "AGGREGATE_FUNCTION_SUM",
+		2: "AGGREGATE_FUNCTION_AVG",
+		3: "AGGREGATE_FUNCTION_MIN",
+		4: "AGGREGATE_FUNCTION_MAX",
+		5: "AGGREGATE_FUNCTION_COUNT",
+	}
+	AggregateFunction_value = map[string]int32{
+		"AGGREGATE_FUNCTION_UNSPECIFIED": 0,
+		"AGGREGATE_FUNCTION_SUM":         1,
+		"AGGREGATE_FUNCTION_AVG":         2,
+		"AGGREGATE_FUNCTION_MIN":         3,
+		"AGGREGATE_FUNCTION_MAX":         4,
+		"AGGREGATE_FUNCTION_COUNT":       5,
+	}
+)
+
+func (x AggregateFunction) Enum() *AggregateFunction {
+	p := new(AggregateFunction)
+	*p = x
+	return p
+}
+
+func (x AggregateFunction) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (AggregateFunction) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_meterusage_v1_meterusage_proto_enumTypes[1].Descriptor()
+}
+
+func (AggregateFunction) Type() protoreflect.EnumType {
+	return &file_proto_meterusage_v1_meterusage_proto_enumTypes[1]
+}
+
+func (x AggregateFunction) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use AggregateFunction.Descriptor instead.
+func (AggregateFunction) EnumDescriptor() ([]byte, []int) {
+	return file_proto_meterusage_v1_meterusage_proto_rawDescGZIP(), []int{1}
+}
+
+type CalendarInterval int32
+
+const (
+	CalendarInterval_CALENDAR_INTERVAL_UNSPECIFIED CalendarInterval = 0
+	CalendarInterval_CALENDAR_INTERVAL_DAY         CalendarInterval = 1
+	// Weeks start on Monday (ISO 8601).
+	CalendarInterval_CALENDAR_INTERVAL_WEEK  CalendarInterval = 2
+	CalendarInterval_CALENDAR_INTERVAL_MONTH CalendarInterval = 3
+)
+
+// Enum value maps for CalendarInterval.
+var (
+	CalendarInterval_name = map[int32]string{
+		0: "CALENDAR_INTERVAL_UNSPECIFIED",
+		1: "CALENDAR_INTERVAL_DAY",
+		2: "CALENDAR_INTERVAL_WEEK",
+		3: "CALENDAR_INTERVAL_MONTH",
+	}
+	CalendarInterval_value = map[string]int32{
+		"CALENDAR_INTERVAL_UNSPECIFIED": 0,
+		"CALENDAR_INTERVAL_DAY":         1,
+		"CALENDAR_INTERVAL_WEEK":        2,
+		"CALENDAR_INTERVAL_MONTH":       3,
+	}
+)
+
+func (x CalendarInterval) Enum() *CalendarInterval {
+	p := new(CalendarInterval)
+	*p = x
+	return p
+}
+
+func (x CalendarInterval) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (CalendarInterval) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_meterusage_v1_meterusage_proto_enumTypes[2].Descriptor()
+}
+
+func (CalendarInterval) Type() protoreflect.EnumType {
+	return &file_proto_meterusage_v1_meterusage_proto_enumTypes[2]
+}
+
+func (x CalendarInterval) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use CalendarInterval.Descriptor instead.
+func (CalendarInterval) EnumDescriptor() ([]byte, []int) {
+	return file_proto_meterusage_v1_meterusage_proto_rawDescGZIP(), []int{2}
+}
+
+// Controls buckets that contain no readings.
+type EmptyBuckets int32
+
+const (
+	// Same as EMPTY_BUCKETS_SKIP.
+	EmptyBuckets_EMPTY_BUCKETS_UNSPECIFIED EmptyBuckets = 0
+	// Empty buckets are omitted from the response.
+	EmptyBuckets_EMPTY_BUCKETS_SKIP EmptyBuckets = 1
+	// Empty buckets are returned with count 0 and unset aggregate values.
+	EmptyBuckets_EMPTY_BUCKETS_NULL EmptyBuckets = 2
+	// Empty buckets are returned with all requested aggregate values set to 0.
+	EmptyBuckets_EMPTY_BUCKETS_ZERO EmptyBuckets = 3
+)
+
+// Enum value maps for EmptyBuckets.
+var (
+	EmptyBuckets_name = map[int32]string{
+		0: "EMPTY_BUCKETS_UNSPECIFIED",
+		1: "EMPTY_BUCKETS_SKIP",
+		2: "EMPTY_BUCKETS_NULL",
+		3: "EMPTY_BUCKETS_ZERO",
+	}
+	EmptyBuckets_value = map[string]int32{
+		"EMPTY_BUCKETS_UNSPECIFIED": 0,
+		"EMPTY_BUCKETS_SKIP":        1,
+		"EMPTY_BUCKETS_NULL":        2,
+		"EMPTY_BUCKETS_ZERO":        3,
+	}
+)
+
+func (x EmptyBuckets) Enum() *EmptyBuckets {
+	p := new(EmptyBuckets)
+	*p = x
+	return p
+}
+
+func (x EmptyBuckets) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (EmptyBuckets) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_meterusage_v1_meterusage_proto_enumTypes[3].Descriptor()
+}
+
+func (EmptyBuckets) Type() protoreflect.EnumType {
+	return &file_proto_meterusage_v1_meterusage_proto_enumTypes[3]
+}
+
+func (x EmptyBuckets) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use EmptyBuckets.Descriptor instead.
+func (EmptyBuckets) EnumDescriptor() ([]byte, []int) {
+	return file_proto_meterusage_v1_meterusage_proto_rawDescGZIP(), []int{3}
+}
+
+// ListReadingsRequest has no time_zone, unlike AggregateReadingsRequest: start
+// and end are instants, so local-day filters need no zone once converted to
+// timestamps (the HTTP gateway does this for its `tz` param), and the times
+// returned are instants too.
 type ListReadingsRequest struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	// Inclusive start time filter. If unset, starts from the earliest reading.
@@ -29,10 +262,13 @@ type ListReadingsRequest struct {
 	// Exclusive end time filter. If unset, ends at the latest reading.
 	End *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=end,proto3" json:"end,omitempty"`
 	// Pagination. If page_size is 0, the server may return all readings in-range.
-	// If page_size is set, page_token is a cursor (RFC3339 timestamp) and the
-	// next page starts strictly after that timestamp.
-	PageSize      int32  `protobuf:"varint,3,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
-	PageToken     string `protobuf:"bytes,4,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	// If page_size is set, page_token is the opaque next_page_token of the
+	// previous page. A token is only valid with the same start, end and
+	// meter_ids it was issued for; page_size may change between pages.
+	PageSize  int32  `protobuf:"varint,3,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	PageToken string `protobuf:"bytes,4,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	// If set, only readings for these meters are returned.
+	MeterIds      []string `protobuf:"bytes,5,rep,name=meter_ids,json=meterIds,proto3" json:"meter_ids,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -95,6 +331,13 @@ func (x *ListReadingsRequest) GetPageToken() string {
 	return ""
 }
 
+func (x *ListReadingsRequest) GetMeterIds() []string {
+	if x != nil {
+		return x.MeterIds
+	}
+	return nil
+}
+
 type ListReadingsResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Readings      []*Reading             `protobuf:"bytes,1,rep,name=readings,proto3" json:"readings,omitempty"`
@@ -148,9 +391,13 @@ func (x *ListReadingsResponse) GetNextPageToken() string {
 }
 
 type Reading struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Time          *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=time,proto3" json:"time,omitempty"`
-	MeterUsage    float64                `protobuf:"fixed64,2,opt,name=meter_usage,json=meterUsage,proto3" json:"meter_usage,omitempty"`
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Time       *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=time,proto3" json:"time,omitempty"`
+	MeterUsage float64                `protobuf:"fixed64,2,opt,name=meter_usage,json=meterUsage,proto3" json:"meter_usage,omitempty"`
+	MeterId    string                 `protobuf:"bytes,3,opt,name=meter_id,json=meterId,proto3" json:"meter_id,omitempty"`
+	// What looks wrong about the reading, judged against the meter's readings
+	// around it; empty if nothing does. Ignored by AppendReadings.
+	QualityFlags  []QualityFlag `protobuf:"varint,4,rep,packed,name=quality_flags,json=qualityFlags,proto3,enum=meterusage.v1.QualityFlag" json:"quality_flags,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -199,78 +446,1891 @@ func (x *Reading) GetMeterUsage() float64 {
 	return 0
 }
 
-var File_proto_meterusage_v1_meterusage_proto protoreflect.FileDescriptor
+func (x *Reading) GetMeterId() string {
+	if x != nil {
+		return x.MeterId
+	}
+	return ""
+}
 
-const file_proto_meterusage_v1_meterusage_proto_rawDesc = "" +
-	"\n" +
-	"$proto/meterusage/v1/meterusage.proto\x12\rmeterusage.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xb1\x01\n" +
-	"\x13ListReadingsRequest\x120\n" +
-	"\x05start\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x05start\x12,\n" +
-	"\x03end\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x03end\x12\x1b\n" +
-	"\tpage_size\x18\x03 \x01(\x05R\bpageSize\x12\x1d\n" +
-	"\n" +
-	"page_token\x18\x04 \x01(\tR\tpageToken\"r\n" +
-	"\x14ListReadingsResponse\x122\n" +
-	"\breadings\x18\x01 \x03(\v2\x16.meterusage.v1.ReadingR\breadings\x12&\n" +
-	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"Z\n" +
-	"\aReading\x12.\n" +
-	"\x04time\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\x12\x1f\n" +
-	"\vmeter_usage\x18\x02 \x01(\x01R\n" +
-	"meterUsage2n\n" +
-	"\x11MeterUsageService\x12Y\n" +
-	"\fListReadings\x12\".meterusage.v1.ListReadingsRequest\x1a#.meterusage.v1.ListReadingsResponse\"\x00B\xbc\x01\n" +
-	"\x11com.meterusage.v1B\x0fMeterusageProtoP\x01ZAgithub.com/milad/spectral/gen/go/proto/meterusage/v1;meterusagev1\xa2\x02\x03MXX\xaa\x02\rMeterusage.V1\xca\x02\rMeterusage\\V1\xe2\x02\x19Meterusage\\V1\\GPBMetadata\xea\x02\x0eMeterusage::V1b\x06proto3"
+func (x *Reading) GetQualityFlags() []QualityFlag {
+	if x != nil {
+		return x.QualityFlags
+	}
+	return nil
+}
 
-var (
-	file_proto_meterusage_v1_meterusage_proto_rawDescOnce sync.Once
-	file_proto_meterusage_v1_meterusage_proto_rawDescData []byte
-)
+// StreamReadingsRequest has no time_zone, for the same reason as
+// ListReadingsRequest.
+type StreamReadingsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Inclusive start time filter. If unset, starts from the earliest reading.
+	Start *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=start,proto3" json:"start,omitempty"`
+	// Exclusive end time filter. If unset, ends at the latest reading.
+	End *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=end,proto3" json:"end,omitempty"`
+	// If set, only readings for these meters are returned.
+	MeterIds []string `protobuf:"bytes,3,rep,name=meter_ids,json=meterIds,proto3" json:"meter_ids,omitempty"`
+	// Maximum number of readings per response message. If 0, the server picks a default.
+	ChunkSize     int32 `protobuf:"varint,4,opt,name=chunk_size,json=chunkSize,proto3" json:"chunk_size,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
 
-func file_proto_meterusage_v1_meterusage_proto_rawDescGZIP() []byte {
-	file_proto_meterusage_v1_meterusage_proto_rawDescOnce.Do(func() {
-		file_proto_meterusage_v1_meterusage_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_proto_meterusage_v1_meterusage_proto_rawDesc), len(file_proto_meterusage_v1_meterusage_proto_rawDesc)))
-	})
-	return file_proto_meterusage_v1_meterusage_proto_rawDescData
+func (x *StreamReadingsRequest) Reset() {
+	*x = StreamReadingsRequest{}
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
 }
 
-var file_proto_meterusage_v1_meterusage_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
-var file_proto_meterusage_v1_meterusage_proto_goTypes = []any{
-	(*ListReadingsRequest)(nil),   // 0: meterusage.v1.ListReadingsRequest
-	(*ListReadingsResponse)(nil),  // 1: meterusage.v1.ListReadingsResponse
-	(*Reading)(nil),               // 2: meterusage.v1.Reading
-	(*timestamppb.Timestamp)(nil), // 3: google.protobuf.Timestamp
+func (x *StreamReadingsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
 }
-var file_proto_meterusage_v1_meterusage_proto_depIdxs = []int32{
-	3, // 0: meterusage.v1.ListReadingsRequest.start:type_name -> google.protobuf.Timestamp
-	3, // 1: meterusage.v1.ListReadingsRequest.end:type_name -> google.protobuf.Timestamp
-	2, // 2: meterusage.v1.ListReadingsResponse.readings:type_name -> meterusage.v1.Reading
-	3, // 3: meterusage.v1.Reading.time:type_name -> google.protobuf.Timestamp
-	0, // 4: meterusage.v1.MeterUsageService.ListReadings:input_type -> meterusage.v1.ListReadingsRequest
-	1, // 5: meterusage.v1.MeterUsageService.ListReadings:output_type -> meterusage.v1.ListReadingsResponse
-	5, // [5:6] is the sub-list for method output_type
-	4, // [4:5] is the sub-list for method input_type
-	4, // [4:4] is the sub-list for extension type_name
-	4, // [4:4] is the sub-list for extension extendee
-	0, // [0:4] is the sub-list for field type_name
+
+func (*StreamReadingsRequest) ProtoMessage() {}
+
+func (x *StreamReadingsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
 }
 
-func init() { file_proto_meterusage_v1_meterusage_proto_init() }
-func file_proto_meterusage_v1_meterusage_proto_init() {
-	if File_proto_meterusage_v1_meterusage_proto != nil {
-		return
+// Deprecated: Use StreamReadingsRequest.ProtoReflect.Descriptor instead.
+func (*StreamReadingsRequest) Descriptor() ([]byte, []int) {
+	return file_proto_meterusage_v1_meterusage_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *StreamReadingsRequest) GetStart() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Start
+	}
+	return nil
+}
+
+func (x *StreamReadingsRequest) GetEnd() *timestamppb.Timestamp {
+	if x != nil {
+		return x.End
+	}
+	return nil
+}
+
+func (x *StreamReadingsRequest) GetMeterIds() []string {
+	if x != nil {
+		return x.MeterIds
+	}
+	return nil
+}
+
+func (x *StreamReadingsRequest) GetChunkSize() int32 {
+	if x != nil {
+		return x.ChunkSize
+	}
+	return 0
+}
+
+type StreamReadingsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Readings      []*Reading             `protobuf:"bytes,1,rep,name=readings,proto3" json:"readings,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamReadingsResponse) Reset() {
+	*x = StreamReadingsResponse{}
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamReadingsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamReadingsResponse) ProtoMessage() {}
+
+func (x *StreamReadingsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamReadingsResponse.ProtoReflect.Descriptor instead.
+func (*StreamReadingsResponse) Descriptor() ([]byte, []int) {
+	return file_proto_meterusage_v1_meterusage_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *StreamReadingsResponse) GetReadings() []*Reading {
+	if x != nil {
+		return x.Readings
+	}
+	return nil
+}
+
+type AppendReadingsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Readings without a meter_id are assigned to the default meter.
+	Readings []*Reading `protobuf:"bytes,1,rep,name=readings,proto3" json:"readings,omitempty"`
+	// Optional. Retrying a request with the same key inserts its readings only
+	// once; reusing a key for different readings fails with ALREADY_EXISTS.
+	IdempotencyKey string `protobuf:"bytes,2,opt,name=idempotency_key,json=idempotencyKey,proto3" json:"idempotency_key,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *AppendReadingsRequest) Reset() {
+	*x = AppendReadingsRequest{}
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AppendReadingsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AppendReadingsRequest) ProtoMessage() {}
+
+func (x *AppendReadingsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AppendReadingsRequest.ProtoReflect.Descriptor instead.
+func (*AppendReadingsRequest) Descriptor() ([]byte, []int) {
+	return file_proto_meterusage_v1_meterusage_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *AppendReadingsRequest) GetReadings() []*Reading {
+	if x != nil {
+		return x.Readings
+	}
+	return nil
+}
+
+func (x *AppendReadingsRequest) GetIdempotencyKey() string {
+	if x != nil {
+		return x.IdempotencyKey
+	}
+	return ""
+}
+
+type AppendReadingsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	AcceptedCount int32                  `protobuf:"varint,1,opt,name=accepted_count,json=acceptedCount,proto3" json:"accepted_count,omitempty"`
+	RowErrors     []*RowError            `protobuf:"bytes,2,rep,name=row_errors,json=rowErrors,proto3" json:"row_errors,omitempty"`
+	// True if this request replayed an earlier request with the same idempotency key.
+	Replayed      bool `protobuf:"varint,3,opt,name=replayed,proto3" json:"replayed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AppendReadingsResponse) Reset() {
+	*x = AppendReadingsResponse{}
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AppendReadingsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AppendReadingsResponse) ProtoMessage() {}
+
+func (x *AppendReadingsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AppendReadingsResponse.ProtoReflect.Descriptor instead.
+func (*AppendReadingsResponse) Descriptor() ([]byte, []int) {
+	return file_proto_meterusage_v1_meterusage_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *AppendReadingsResponse) GetAcceptedCount() int32 {
+	if x != nil {
+		return x.AcceptedCount
+	}
+	return 0
+}
+
+func (x *AppendReadingsResponse) GetRowErrors() []*RowError {
+	if x != nil {
+		return x.RowErrors
+	}
+	return nil
+}
+
+func (x *AppendReadingsResponse) GetReplayed() bool {
+	if x != nil {
+		return x.Replayed
+	}
+	return false
+}
+
+type RowError struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Zero-based index into AppendReadingsRequest.readings.
+	Index         int32  `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	Message       string `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RowError) Reset() {
+	*x = RowError{}
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RowError) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RowError) ProtoMessage() {}
+
+func (x *RowError) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RowError.ProtoReflect.Descriptor instead.
+func (*RowError) Descriptor() ([]byte, []int) {
+	return file_proto_meterusage_v1_meterusage_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *RowError) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *RowError) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type ListMetersRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListMetersRequest) Reset() {
+	*x = ListMetersRequest{}
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListMetersRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListMetersRequest) ProtoMessage() {}
+
+func (x *ListMetersRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListMetersRequest.ProtoReflect.Descriptor instead.
+func (*ListMetersRequest) Descriptor() ([]byte, []int) {
+	return file_proto_meterusage_v1_meterusage_proto_rawDescGZIP(), []int{8}
+}
+
+type ListMetersResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Meters        []*Meter               `protobuf:"bytes,1,rep,name=meters,proto3" json:"meters,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListMetersResponse) Reset() {
+	*x = ListMetersResponse{}
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListMetersResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListMetersResponse) ProtoMessage() {}
+
+func (x *ListMetersResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListMetersResponse.ProtoReflect.Descriptor instead.
+func (*ListMetersResponse) Descriptor() ([]byte, []int) {
+	return file_proto_meterusage_v1_meterusage_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ListMetersResponse) GetMeters() []*Meter {
+	if x != nil {
+		return x.Meters
+	}
+	return nil
+}
+
+type Meter struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Id               string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	ReadingCount     int64                  `protobuf:"varint,2,opt,name=reading_count,json=readingCount,proto3" json:"reading_count,omitempty"`
+	FirstReadingTime *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=first_reading_time,json=firstReadingTime,proto3" json:"first_reading_time,omitempty"`
+	LastReadingTime  *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=last_reading_time,json=lastReadingTime,proto3" json:"last_reading_time,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *Meter) Reset() {
+	*x = Meter{}
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Meter) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Meter) ProtoMessage() {}
+
+func (x *Meter) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Meter.ProtoReflect.Descriptor instead.
+func (*Meter) Descriptor() ([]byte, []int) {
+	return file_proto_meterusage_v1_meterusage_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *Meter) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Meter) GetReadingCount() int64 {
+	if x != nil {
+		return x.ReadingCount
+	}
+	return 0
+}
+
+func (x *Meter) GetFirstReadingTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.FirstReadingTime
+	}
+	return nil
+}
+
+func (x *Meter) GetLastReadingTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastReadingTime
+	}
+	return nil
+}
+
+type GetDatasetRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetDatasetRequest) Reset() {
+	*x = GetDatasetRequest{}
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetDatasetRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetDatasetRequest) ProtoMessage() {}
+
+func (x *GetDatasetRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetDatasetRequest.ProtoReflect.Descriptor instead.
+func (*GetDatasetRequest) Descriptor() ([]byte, []int) {
+	return file_proto_meterusage_v1_meterusage_proto_rawDescGZIP(), []int{11}
+}
+
+type GetDatasetResponse struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Dataset *Dataset               `protobuf:"bytes,1,opt,name=dataset,proto3" json:"dataset,omitempty"`
+	// Identifies the key the server signs page tokens with, without revealing
+	// it. Servers behind one endpoint must report the same id, or a page token
+	// fails when the next page is served by another one.
+	PageTokenKeyId string `protobuf:"bytes,2,opt,name=page_token_key_id,json=pageTokenKeyId,proto3" json:"page_token_key_id,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *GetDatasetResponse) Reset() {
+	*x = GetDatasetResponse{}
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetDatasetResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetDatasetResponse) ProtoMessage() {}
+
+func (x *GetDatasetResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetDatasetResponse.ProtoReflect.Descriptor instead.
+func (*GetDatasetResponse) Descriptor() ([]byte, []int) {
+	return file_proto_meterusage_v1_meterusage_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *GetDatasetResponse) GetDataset() *Dataset {
+	if x != nil {
+		return x.Dataset
+	}
+	return nil
+}
+
+func (x *GetDatasetResponse) GetPageTokenKeyId() string {
+	if x != nil {
+		return x.PageTokenKeyId
+	}
+	return ""
+}
+
+type Dataset struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Increases whenever the served readings change (reload or append), also
+	// across server restarts.
+	Version  uint64 `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	RowCount int64  `protobuf:"varint,2,opt,name=row_count,json=rowCount,proto3" json:"row_count,omitempty"`
+	// Identifies the served readings: servers that loaded the same files and
+	// were sent the same appends report the same checksum, unlike version.
+	Checksum      string                 `protobuf:"bytes,3,opt,name=checksum,proto3" json:"checksum,omitempty"`
+	UpdateTime    *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=update_time,json=updateTime,proto3" json:"update_time,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Dataset) Reset() {
+	*x = Dataset{}
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Dataset) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Dataset) ProtoMessage() {}
+
+func (x *Dataset) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Dataset.ProtoReflect.Descriptor instead.
+func (*Dataset) Descriptor() ([]byte, []int) {
+	return file_proto_meterusage_v1_meterusage_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *Dataset) GetVersion() uint64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *Dataset) GetRowCount() int64 {
+	if x != nil {
+		return x.RowCount
+	}
+	return 0
+}
+
+func (x *Dataset) GetChecksum() string {
+	if x != nil {
+		return x.Checksum
+	}
+	return ""
+}
+
+func (x *Dataset) GetUpdateTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdateTime
+	}
+	return nil
+}
+
+type GetIngestionReportRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// If set, only issues in these categories are returned.
+	Categories []string `protobuf:"bytes,1,rep,name=categories,proto3" json:"categories,omitempty"`
+	// Maximum number of issues to return. If 0, all the issues kept are returned.
+	MaxIssues     uint32 `protobuf:"varint,2,opt,name=max_issues,json=maxIssues,proto3" json:"max_issues,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetIngestionReportRequest) Reset() {
+	*x = GetIngestionReportRequest{}
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetIngestionReportRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetIngestionReportRequest) ProtoMessage() {}
+
+func (x *GetIngestionReportRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetIngestionReportRequest.ProtoReflect.Descriptor instead.
+func (*GetIngestionReportRequest) Descriptor() ([]byte, []int) {
+	return file_proto_meterusage_v1_meterusage_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *GetIngestionReportRequest) GetCategories() []string {
+	if x != nil {
+		return x.Categories
+	}
+	return nil
+}
+
+func (x *GetIngestionReportRequest) GetMaxIssues() uint32 {
+	if x != nil {
+		return x.MaxIssues
+	}
+	return 0
+}
+
+type GetIngestionReportResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Report        *IngestionReport       `protobuf:"bytes,1,opt,name=report,proto3" json:"report,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetIngestionReportResponse) Reset() {
+	*x = GetIngestionReportResponse{}
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetIngestionReportResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetIngestionReportResponse) ProtoMessage() {}
+
+func (x *GetIngestionReportResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetIngestionReportResponse.ProtoReflect.Descriptor instead.
+func (*GetIngestionReportResponse) Descriptor() ([]byte, []int) {
+	return file_proto_meterusage_v1_meterusage_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *GetIngestionReportResponse) GetReport() *IngestionReport {
+	if x != nil {
+		return x.Report
+	}
+	return nil
+}
+
+type IngestionReport struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The file, directory or glob pattern that was loaded.
+	Source   string                 `protobuf:"bytes,1,opt,name=source,proto3" json:"source,omitempty"`
+	LoadTime *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=load_time,json=loadTime,proto3" json:"load_time,omitempty"`
+	// False if the load yielded no readings and the data loaded before is
+	// still being served.
+	Applied       bool  `protobuf:"varint,3,opt,name=applied,proto3" json:"applied,omitempty"`
+	FileCount     int32 `protobuf:"varint,4,opt,name=file_count,json=fileCount,proto3" json:"file_count,omitempty"`
+	AcceptedCount int64 `protobuf:"varint,5,opt,name=accepted_count,json=acceptedCount,proto3" json:"accepted_count,omitempty"`
+	RejectedCount int64 `protobuf:"varint,6,opt,name=rejected_count,json=rejectedCount,proto3" json:"rejected_count,omitempty"`
+	// Rejections by category, most frequent first. Counts cover all issues,
+	// whatever the request's filters.
+	Categories []*IngestionCategoryCount `protobuf:"bytes,7,rep,name=categories,proto3" json:"categories,omitempty"`
+	// Issues in file order.
+	Issues []*IngestionIssue `protobuf:"bytes,8,rep,name=issues,proto3" json:"issues,omitempty"`
+	// True if there were more issues than returned.
+	IssuesTruncated bool `protobuf:"varint,9,opt,name=issues_truncated,json=issuesTruncated,proto3" json:"issues_truncated,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *IngestionReport) Reset() {
+	*x = IngestionReport{}
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IngestionReport) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IngestionReport) ProtoMessage() {}
+
+func (x *IngestionReport) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IngestionReport.ProtoReflect.Descriptor instead.
+func (*IngestionReport) Descriptor() ([]byte, []int) {
+	return file_proto_meterusage_v1_meterusage_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *IngestionReport) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *IngestionReport) GetLoadTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LoadTime
+	}
+	return nil
+}
+
+func (x *IngestionReport) GetApplied() bool {
+	if x != nil {
+		return x.Applied
+	}
+	return false
+}
+
+func (x *IngestionReport) GetFileCount() int32 {
+	if x != nil {
+		return x.FileCount
+	}
+	return 0
+}
+
+func (x *IngestionReport) GetAcceptedCount() int64 {
+	if x != nil {
+		return x.AcceptedCount
+	}
+	return 0
+}
+
+func (x *IngestionReport) GetRejectedCount() int64 {
+	if x != nil {
+		return x.RejectedCount
+	}
+	return 0
+}
+
+func (x *IngestionReport) GetCategories() []*IngestionCategoryCount {
+	if x != nil {
+		return x.Categories
+	}
+	return nil
+}
+
+func (x *IngestionReport) GetIssues() []*IngestionIssue {
+	if x != nil {
+		return x.Issues
+	}
+	return nil
+}
+
+func (x *IngestionReport) GetIssuesTruncated() bool {
+	if x != nil {
+		return x.IssuesTruncated
+	}
+	return false
+}
+
+type IngestionCategoryCount struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Category      string                 `protobuf:"bytes,1,opt,name=category,proto3" json:"category,omitempty"`
+	Count         int64                  `protobuf:"varint,2,opt,name=count,proto3" json:"count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IngestionCategoryCount) Reset() {
+	*x = IngestionCategoryCount{}
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IngestionCategoryCount) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IngestionCategoryCount) ProtoMessage() {}
+
+func (x *IngestionCategoryCount) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IngestionCategoryCount.ProtoReflect.Descriptor instead.
+func (*IngestionCategoryCount) Descriptor() ([]byte, []int) {
+	return file_proto_meterusage_v1_meterusage_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *IngestionCategoryCount) GetCategory() string {
+	if x != nil {
+		return x.Category
+	}
+	return ""
+}
+
+func (x *IngestionCategoryCount) GetCount() int64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+type IngestionIssue struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	File  string                 `protobuf:"bytes,1,opt,name=file,proto3" json:"file,omitempty"`
+	// 1-based row number of a CSV row, the header included. 0 for issues that
+	// are not about a single row.
+	Row int64 `protobuf:"varint,2,opt,name=row,proto3" json:"row,omitempty"`
+	// The fields of the row as read, if it could be read.
+	Values []string `protobuf:"bytes,3,rep,name=values,proto3" json:"values,omitempty"`
+	// Why the input was rejected: invalid_file, malformed_row, missing_columns,
+	// missing_meter_id, invalid_time, nonexistent_time, invalid_usage,
+	// non_finite_usage, invalid_reading or invalid_entry (Green Button).
+	Category      string `protobuf:"bytes,4,opt,name=category,proto3" json:"category,omitempty"`
+	Message       string `protobuf:"bytes,5,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IngestionIssue) Reset() {
+	*x = IngestionIssue{}
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IngestionIssue) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IngestionIssue) ProtoMessage() {}
+
+func (x *IngestionIssue) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IngestionIssue.ProtoReflect.Descriptor instead.
+func (*IngestionIssue) Descriptor() ([]byte, []int) {
+	return file_proto_meterusage_v1_meterusage_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *IngestionIssue) GetFile() string {
+	if x != nil {
+		return x.File
+	}
+	return ""
+}
+
+func (x *IngestionIssue) GetRow() int64 {
+	if x != nil {
+		return x.Row
+	}
+	return 0
+}
+
+func (x *IngestionIssue) GetValues() []string {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+func (x *IngestionIssue) GetCategory() string {
+	if x != nil {
+		return x.Category
+	}
+	return ""
+}
+
+func (x *IngestionIssue) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+// GetQualityReportRequest has no time_zone either: gaps and spikes are
+// measured in elapsed time, which no zone changes.
+type GetQualityReportRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Inclusive start time. Required; the range may span at most the server's
+	// unpaged range limit (31 days by default).
+	Start *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=start,proto3" json:"start,omitempty"`
+	// Exclusive end time. Required.
+	End *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=end,proto3" json:"end,omitempty"`
+	// If set, only these meters are reported.
+	MeterIds      []string `protobuf:"bytes,3,rep,name=meter_ids,json=meterIds,proto3" json:"meter_ids,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetQualityReportRequest) Reset() {
+	*x = GetQualityReportRequest{}
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetQualityReportRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetQualityReportRequest) ProtoMessage() {}
+
+func (x *GetQualityReportRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetQualityReportRequest.ProtoReflect.Descriptor instead.
+func (*GetQualityReportRequest) Descriptor() ([]byte, []int) {
+	return file_proto_meterusage_v1_meterusage_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *GetQualityReportRequest) GetStart() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Start
+	}
+	return nil
+}
+
+func (x *GetQualityReportRequest) GetEnd() *timestamppb.Timestamp {
+	if x != nil {
+		return x.End
+	}
+	return nil
+}
+
+func (x *GetQualityReportRequest) GetMeterIds() []string {
+	if x != nil {
+		return x.MeterIds
+	}
+	return nil
+}
+
+type GetQualityReportResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Report        *QualityReport         `protobuf:"bytes,1,opt,name=report,proto3" json:"report,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetQualityReportResponse) Reset() {
+	*x = GetQualityReportResponse{}
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetQualityReportResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetQualityReportResponse) ProtoMessage() {}
+
+func (x *GetQualityReportResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetQualityReportResponse.ProtoReflect.Descriptor instead.
+func (*GetQualityReportResponse) Descriptor() ([]byte, []int) {
+	return file_proto_meterusage_v1_meterusage_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *GetQualityReportResponse) GetReport() *QualityReport {
+	if x != nil {
+		return x.Report
+	}
+	return nil
+}
+
+type QualityReport struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The expected time between two readings of a meter.
+	Interval *durationpb.Duration `protobuf:"bytes,1,opt,name=interval,proto3" json:"interval,omitempty"`
+	// Meters with readings in the range, ordered by ID.
+	Meters        []*MeterQuality `protobuf:"bytes,2,rep,name=meters,proto3" json:"meters,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QualityReport) Reset() {
+	*x = QualityReport{}
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QualityReport) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QualityReport) ProtoMessage() {}
+
+func (x *QualityReport) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QualityReport.ProtoReflect.Descriptor instead.
+func (*QualityReport) Descriptor() ([]byte, []int) {
+	return file_proto_meterusage_v1_meterusage_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *QualityReport) GetInterval() *durationpb.Duration {
+	if x != nil {
+		return x.Interval
+	}
+	return nil
+}
+
+func (x *QualityReport) GetMeters() []*MeterQuality {
+	if x != nil {
+		return x.Meters
+	}
+	return nil
+}
+
+type MeterQuality struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	MeterId      string                 `protobuf:"bytes,1,opt,name=meter_id,json=meterId,proto3" json:"meter_id,omitempty"`
+	ReadingCount int64                  `protobuf:"varint,2,opt,name=reading_count,json=readingCount,proto3" json:"reading_count,omitempty"`
+	// Readings missing from the gaps, at the expected interval.
+	MissingCount   int64 `protobuf:"varint,3,opt,name=missing_count,json=missingCount,proto3" json:"missing_count,omitempty"`
+	DuplicateCount int64 `protobuf:"varint,4,opt,name=duplicate_count,json=duplicateCount,proto3" json:"duplicate_count,omitempty"`
+	NegativeCount  int64 `protobuf:"varint,5,opt,name=negative_count,json=negativeCount,proto3" json:"negative_count,omitempty"`
+	SpikeCount     int64 `protobuf:"varint,6,opt,name=spike_count,json=spikeCount,proto3" json:"spike_count,omitempty"`
+	// Gaps that end in the range, in time order.
+	Gaps []*Gap `protobuf:"bytes,7,rep,name=gaps,proto3" json:"gaps,omitempty"`
+	// Readings flagged as duplicate, negative or spike, in time order.
+	Anomalies []*Reading `protobuf:"bytes,8,rep,name=anomalies,proto3" json:"anomalies,omitempty"`
+	// True if there were more gaps or anomalies than returned. The counts
+	// cover all of them.
+	Truncated     bool `protobuf:"varint,9,opt,name=truncated,proto3" json:"truncated,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MeterQuality) Reset() {
+	*x = MeterQuality{}
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MeterQuality) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MeterQuality) ProtoMessage() {}
+
+func (x *MeterQuality) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MeterQuality.ProtoReflect.Descriptor instead.
+func (*MeterQuality) Descriptor() ([]byte, []int) {
+	return file_proto_meterusage_v1_meterusage_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *MeterQuality) GetMeterId() string {
+	if x != nil {
+		return x.MeterId
+	}
+	return ""
+}
+
+func (x *MeterQuality) GetReadingCount() int64 {
+	if x != nil {
+		return x.ReadingCount
+	}
+	return 0
+}
+
+func (x *MeterQuality) GetMissingCount() int64 {
+	if x != nil {
+		return x.MissingCount
+	}
+	return 0
+}
+
+func (x *MeterQuality) GetDuplicateCount() int64 {
+	if x != nil {
+		return x.DuplicateCount
+	}
+	return 0
+}
+
+func (x *MeterQuality) GetNegativeCount() int64 {
+	if x != nil {
+		return x.NegativeCount
+	}
+	return 0
+}
+
+func (x *MeterQuality) GetSpikeCount() int64 {
+	if x != nil {
+		return x.SpikeCount
+	}
+	return 0
+}
+
+func (x *MeterQuality) GetGaps() []*Gap {
+	if x != nil {
+		return x.Gaps
+	}
+	return nil
+}
+
+func (x *MeterQuality) GetAnomalies() []*Reading {
+	if x != nil {
+		return x.Anomalies
+	}
+	return nil
+}
+
+func (x *MeterQuality) GetTruncated() bool {
+	if x != nil {
+		return x.Truncated
+	}
+	return false
+}
+
+type Gap struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The readings on either side of the gap.
+	Start         *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=start,proto3" json:"start,omitempty"`
+	End           *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=end,proto3" json:"end,omitempty"`
+	MissingCount  int64                  `protobuf:"varint,3,opt,name=missing_count,json=missingCount,proto3" json:"missing_count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Gap) Reset() {
+	*x = Gap{}
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Gap) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Gap) ProtoMessage() {}
+
+func (x *Gap) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Gap.ProtoReflect.Descriptor instead.
+func (*Gap) Descriptor() ([]byte, []int) {
+	return file_proto_meterusage_v1_meterusage_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *Gap) GetStart() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Start
+	}
+	return nil
+}
+
+func (x *Gap) GetEnd() *timestamppb.Timestamp {
+	if x != nil {
+		return x.End
+	}
+	return nil
+}
+
+func (x *Gap) GetMissingCount() int64 {
+	if x != nil {
+		return x.MissingCount
+	}
+	return 0
+}
+
+type AggregateReadingsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Inclusive start time filter. If unset, starts from the earliest reading.
+	Start *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=start,proto3" json:"start,omitempty"`
+	// Exclusive end time filter. If unset, ends at the latest reading.
+	End *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=end,proto3" json:"end,omitempty"`
+	// Bucket size. Exactly one must be set. Fixed-width buckets are aligned to
+	// the Unix epoch; calendar buckets are aligned to calendar boundaries in
+	// time_zone.
+	//
+	// Types that are valid to be assigned to Bucket:
+	//
+	//	*AggregateReadingsRequest_BucketWidth
+	//	*AggregateReadingsRequest_CalendarInterval
+	Bucket isAggregateReadingsRequest_Bucket `protobuf_oneof:"bucket"`
+	// Aggregates to compute per bucket. If empty, all functions are computed.
+	Functions    []AggregateFunction `protobuf:"varint,5,rep,packed,name=functions,proto3,enum=meterusage.v1.AggregateFunction" json:"functions,omitempty"`
+	EmptyBuckets EmptyBuckets        `protobuf:"varint,6,opt,name=empty_buckets,json=emptyBuckets,proto3,enum=meterusage.v1.EmptyBuckets" json:"empty_buckets,omitempty"`
+	// If set, only readings for these meters are aggregated. Readings from all
+	// selected meters are combined into the same buckets.
+	MeterIds []string `protobuf:"bytes,7,rep,name=meter_ids,json=meterIds,proto3" json:"meter_ids,omitempty"`
+	// IANA time zone (e.g. "Europe/Amsterdam") in which calendar buckets start
+	// at local midnight. Days are 23 or 25 hours long across DST changes.
+	// Defaults to UTC.
+	TimeZone      string `protobuf:"bytes,8,opt,name=time_zone,json=timeZone,proto3" json:"time_zone,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AggregateReadingsRequest) Reset() {
+	*x = AggregateReadingsRequest{}
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AggregateReadingsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AggregateReadingsRequest) ProtoMessage() {}
+
+func (x *AggregateReadingsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AggregateReadingsRequest.ProtoReflect.Descriptor instead.
+func (*AggregateReadingsRequest) Descriptor() ([]byte, []int) {
+	return file_proto_meterusage_v1_meterusage_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *AggregateReadingsRequest) GetStart() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Start
+	}
+	return nil
+}
+
+func (x *AggregateReadingsRequest) GetEnd() *timestamppb.Timestamp {
+	if x != nil {
+		return x.End
+	}
+	return nil
+}
+
+func (x *AggregateReadingsRequest) GetBucket() isAggregateReadingsRequest_Bucket {
+	if x != nil {
+		return x.Bucket
+	}
+	return nil
+}
+
+func (x *AggregateReadingsRequest) GetBucketWidth() *durationpb.Duration {
+	if x != nil {
+		if x, ok := x.Bucket.(*AggregateReadingsRequest_BucketWidth); ok {
+			return x.BucketWidth
+		}
+	}
+	return nil
+}
+
+func (x *AggregateReadingsRequest) GetCalendarInterval() CalendarInterval {
+	if x != nil {
+		if x, ok := x.Bucket.(*AggregateReadingsRequest_CalendarInterval); ok {
+			return x.CalendarInterval
+		}
+	}
+	return CalendarInterval_CALENDAR_INTERVAL_UNSPECIFIED
+}
+
+func (x *AggregateReadingsRequest) GetFunctions() []AggregateFunction {
+	if x != nil {
+		return x.Functions
+	}
+	return nil
+}
+
+func (x *AggregateReadingsRequest) GetEmptyBuckets() EmptyBuckets {
+	if x != nil {
+		return x.EmptyBuckets
+	}
+	return EmptyBuckets_EMPTY_BUCKETS_UNSPECIFIED
+}
+
+func (x *AggregateReadingsRequest) GetMeterIds() []string {
+	if x != nil {
+		return x.MeterIds
+	}
+	return nil
+}
+
+func (x *AggregateReadingsRequest) GetTimeZone() string {
+	if x != nil {
+		return x.TimeZone
+	}
+	return ""
+}
+
+type isAggregateReadingsRequest_Bucket interface {
+	isAggregateReadingsRequest_Bucket()
+}
+
+type AggregateReadingsRequest_BucketWidth struct {
+	BucketWidth *durationpb.Duration `protobuf:"bytes,3,opt,name=bucket_width,json=bucketWidth,proto3,oneof"`
+}
+
+type AggregateReadingsRequest_CalendarInterval struct {
+	CalendarInterval CalendarInterval `protobuf:"varint,4,opt,name=calendar_interval,json=calendarInterval,proto3,enum=meterusage.v1.CalendarInterval,oneof"`
+}
+
+func (*AggregateReadingsRequest_BucketWidth) isAggregateReadingsRequest_Bucket() {}
+
+func (*AggregateReadingsRequest_CalendarInterval) isAggregateReadingsRequest_Bucket() {}
+
+type AggregateReadingsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Buckets       []*Bucket              `protobuf:"bytes,1,rep,name=buckets,proto3" json:"buckets,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AggregateReadingsResponse) Reset() {
+	*x = AggregateReadingsResponse{}
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AggregateReadingsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AggregateReadingsResponse) ProtoMessage() {}
+
+func (x *AggregateReadingsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AggregateReadingsResponse.ProtoReflect.Descriptor instead.
+func (*AggregateReadingsResponse) Descriptor() ([]byte, []int) {
+	return file_proto_meterusage_v1_meterusage_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *AggregateReadingsResponse) GetBuckets() []*Bucket {
+	if x != nil {
+		return x.Buckets
+	}
+	return nil
+}
+
+type Bucket struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Inclusive bucket start.
+	Start *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=start,proto3" json:"start,omitempty"`
+	// Exclusive bucket end.
+	End *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=end,proto3" json:"end,omitempty"`
+	// Only the requested aggregates are set. min, max and avg are unset for
+	// empty buckets unless EMPTY_BUCKETS_ZERO was requested.
+	Count         *int64   `protobuf:"varint,3,opt,name=count,proto3,oneof" json:"count,omitempty"`
+	Sum           *float64 `protobuf:"fixed64,4,opt,name=sum,proto3,oneof" json:"sum,omitempty"`
+	Avg           *float64 `protobuf:"fixed64,5,opt,name=avg,proto3,oneof" json:"avg,omitempty"`
+	Min           *float64 `protobuf:"fixed64,6,opt,name=min,proto3,oneof" json:"min,omitempty"`
+	Max           *float64 `protobuf:"fixed64,7,opt,name=max,proto3,oneof" json:"max,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Bucket) Reset() {
+	*x = Bucket{}
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Bucket) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Bucket) ProtoMessage() {}
+
+func (x *Bucket) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_meterusage_v1_meterusage_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Bucket.ProtoReflect.Descriptor instead.
+func (*Bucket) Descriptor() ([]byte, []int) {
+	return file_proto_meterusage_v1_meterusage_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *Bucket) GetStart() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Start
+	}
+	return nil
+}
+
+func (x *Bucket) GetEnd() *timestamppb.Timestamp {
+	if x != nil {
+		return x.End
+	}
+	return nil
+}
+
+func (x *Bucket) GetCount() int64 {
+	if x != nil && x.Count != nil {
+		return *x.Count
+	}
+	return 0
+}
+
+func (x *Bucket) GetSum() float64 {
+	if x != nil && x.Sum != nil {
+		return *x.Sum
+	}
+	return 0
+}
+
+func (x *Bucket) GetAvg() float64 {
+	if x != nil && x.Avg != nil {
+		return *x.Avg
+	}
+	return 0
+}
+
+func (x *Bucket) GetMin() float64 {
+	if x != nil && x.Min != nil {
+		return *x.Min
+	}
+	return 0
+}
+
+func (x *Bucket) GetMax() float64 {
+	if x != nil && x.Max != nil {
+		return *x.Max
+	}
+	return 0
+}
+
+var File_proto_meterusage_v1_meterusage_proto protoreflect.FileDescriptor
+
+const file_proto_meterusage_v1_meterusage_proto_rawDesc = "" +
+	"\n" +
+	"$proto/meterusage/v1/meterusage.proto\x12\rmeterusage.v1\x1a\x1egoogle/protobuf/duration.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\xce\x01\n" +
+	"\x13ListReadingsRequest\x120\n" +
+	"\x05start\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x05start\x12,\n" +
+	"\x03end\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x03end\x12\x1b\n" +
+	"\tpage_size\x18\x03 \x01(\x05R\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\x04 \x01(\tR\tpageToken\x12\x1b\n" +
+	"\tmeter_ids\x18\x05 \x03(\tR\bmeterIds\"r\n" +
+	"\x14ListReadingsResponse\x122\n" +
+	"\breadings\x18\x01 \x03(\v2\x16.meterusage.v1.ReadingR\breadings\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"\xb6\x01\n" +
+	"\aReading\x12.\n" +
+	"\x04time\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\x12\x1f\n" +
+	"\vmeter_usage\x18\x02 \x01(\x01R\n" +
+	"meterUsage\x12\x19\n" +
+	"\bmeter_id\x18\x03 \x01(\tR\ameterId\x12?\n" +
+	"\rquality_flags\x18\x04 \x03(\x0e2\x1a.meterusage.v1.QualityFlagR\fqualityFlags\"\xb3\x01\n" +
+	"\x15StreamReadingsRequest\x120\n" +
+	"\x05start\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x05start\x12,\n" +
+	"\x03end\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x03end\x12\x1b\n" +
+	"\tmeter_ids\x18\x03 \x03(\tR\bmeterIds\x12\x1d\n" +
+	"\n" +
+	"chunk_size\x18\x04 \x01(\x05R\tchunkSize\"L\n" +
+	"\x16StreamReadingsResponse\x122\n" +
+	"\breadings\x18\x01 \x03(\v2\x16.meterusage.v1.ReadingR\breadings\"t\n" +
+	"\x15AppendReadingsRequest\x122\n" +
+	"\breadings\x18\x01 \x03(\v2\x16.meterusage.v1.ReadingR\breadings\x12'\n" +
+	"\x0fidempotency_key\x18\x02 \x01(\tR\x0eidempotencyKey\"\x93\x01\n" +
+	"\x16AppendReadingsResponse\x12%\n" +
+	"\x0eaccepted_count\x18\x01 \x01(\x05R\racceptedCount\x126\n" +
+	"\n" +
+	"row_errors\x18\x02 \x03(\v2\x17.meterusage.v1.RowErrorR\trowErrors\x12\x1a\n" +
+	"\breplayed\x18\x03 \x01(\bR\breplayed\":\n" +
+	"\bRowError\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\x05R\x05index\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\"\x13\n" +
+	"\x11ListMetersRequest\"B\n" +
+	"\x12ListMetersResponse\x12,\n" +
+	"\x06meters\x18\x01 \x03(\v2\x14.meterusage.v1.MeterR\x06meters\"\xce\x01\n" +
+	"\x05Meter\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12#\n" +
+	"\rreading_count\x18\x02 \x01(\x03R\freadingCount\x12H\n" +
+	"\x12first_reading_time\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\x10firstReadingTime\x12F\n" +
+	"\x11last_reading_time\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\x0flastReadingTime\"\x13\n" +
+	"\x11GetDatasetRequest\"q\n" +
+	"\x12GetDatasetResponse\x120\n" +
+	"\adataset\x18\x01 \x01(\v2\x16.meterusage.v1.DatasetR\adataset\x12)\n" +
+	"\x11page_token_key_id\x18\x02 \x01(\tR\x0epageTokenKeyId\"\x99\x01\n" +
+	"\aDataset\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\x04R\aversion\x12\x1b\n" +
+	"\trow_count\x18\x02 \x01(\x03R\browCount\x12\x1a\n" +
+	"\bchecksum\x18\x03 \x01(\tR\bchecksum\x12;\n" +
+	"\vupdate_time\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"updateTime\"Z\n" +
+	"\x19GetIngestionReportRequest\x12\x1e\n" +
+	"\n" +
+	"categories\x18\x01 \x03(\tR\n" +
+	"categories\x12\x1d\n" +
+	"\n" +
+	"max_issues\x18\x02 \x01(\rR\tmaxIssues\"T\n" +
+	"\x1aGetIngestionReportResponse\x126\n" +
+	"\x06report\x18\x01 \x01(\v2\x1e.meterusage.v1.IngestionReportR\x06report\"\x92\x03\n" +
+	"\x0fIngestionReport\x12\x16\n" +
+	"\x06source\x18\x01 \x01(\tR\x06source\x127\n" +
+	"\tload_time\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\bloadTime\x12\x18\n" +
+	"\aapplied\x18\x03 \x01(\bR\aapplied\x12\x1d\n" +
+	"\n" +
+	"file_count\x18\x04 \x01(\x05R\tfileCount\x12%\n" +
+	"\x0eaccepted_count\x18\x05 \x01(\x03R\racceptedCount\x12%\n" +
+	"\x0erejected_count\x18\x06 \x01(\x03R\rrejectedCount\x12E\n" +
+	"\n" +
+	"categories\x18\a \x03(\v2%.meterusage.v1.IngestionCategoryCountR\n" +
+	"categories\x125\n" +
+	"\x06issues\x18\b \x03(\v2\x1d.meterusage.v1.IngestionIssueR\x06issues\x12)\n" +
+	"\x10issues_truncated\x18\t \x01(\bR\x0fissuesTruncated\"J\n" +
+	"\x16IngestionCategoryCount\x12\x1a\n" +
+	"\bcategory\x18\x01 \x01(\tR\bcategory\x12\x14\n" +
+	"\x05count\x18\x02 \x01(\x03R\x05count\"\x84\x01\n" +
+	"\x0eIngestionIssue\x12\x12\n" +
+	"\x04file\x18\x01 \x01(\tR\x04file\x12\x10\n" +
+	"\x03row\x18\x02 \x01(\x03R\x03row\x12\x16\n" +
+	"\x06values\x18\x03 \x03(\tR\x06values\x12\x1a\n" +
+	"\bcategory\x18\x04 \x01(\tR\bcategory\x12\x18\n" +
+	"\amessage\x18\x05 \x01(\tR\amessage\"\x96\x01\n" +
+	"\x17GetQualityReportRequest\x120\n" +
+	"\x05start\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x05start\x12,\n" +
+	"\x03end\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x03end\x12\x1b\n" +
+	"\tmeter_ids\x18\x03 \x03(\tR\bmeterIds\"P\n" +
+	"\x18GetQualityReportResponse\x124\n" +
+	"\x06report\x18\x01 \x01(\v2\x1c.meterusage.v1.QualityReportR\x06report\"{\n" +
+	"\rQualityReport\x125\n" +
+	"\binterval\x18\x01 \x01(\v2\x19.google.protobuf.DurationR\binterval\x123\n" +
+	"\x06meters\x18\x02 \x03(\v2\x1b.meterusage.v1.MeterQualityR\x06meters\"\xe0\x02\n" +
+	"\fMeterQuality\x12\x19\n" +
+	"\bmeter_id\x18\x01 \x01(\tR\ameterId\x12#\n" +
+	"\rreading_count\x18\x02 \x01(\x03R\freadingCount\x12#\n" +
+	"\rmissing_count\x18\x03 \x01(\x03R\fmissingCount\x12'\n" +
+	"\x0fduplicate_count\x18\x04 \x01(\x03R\x0eduplicateCount\x12%\n" +
+	"\x0enegative_count\x18\x05 \x01(\x03R\rnegativeCount\x12\x1f\n" +
+	"\vspike_count\x18\x06 \x01(\x03R\n" +
+	"spikeCount\x12&\n" +
+	"\x04gaps\x18\a \x03(\v2\x12.meterusage.v1.GapR\x04gaps\x124\n" +
+	"\tanomalies\x18\b \x03(\v2\x16.meterusage.v1.ReadingR\tanomalies\x12\x1c\n" +
+	"\ttruncated\x18\t \x01(\bR\ttruncated\"\x8a\x01\n" +
+	"\x03Gap\x120\n" +
+	"\x05start\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x05start\x12,\n" +
+	"\x03end\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x03end\x12#\n" +
+	"\rmissing_count\x18\x03 \x01(\x03R\fmissingCount\"\xd0\x03\n" +
+	"\x18AggregateReadingsRequest\x120\n" +
+	"\x05start\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x05start\x12,\n" +
+	"\x03end\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x03end\x12>\n" +
+	"\fbucket_width\x18\x03 \x01(\v2\x19.google.protobuf.DurationH\x00R\vbucketWidth\x12N\n" +
+	"\x11calendar_interval\x18\x04 \x01(\x0e2\x1f.meterusage.v1.CalendarIntervalH\x00R\x10calendarInterval\x12>\n" +
+	"\tfunctions\x18\x05 \x03(\x0e2 .meterusage.v1.AggregateFunctionR\tfunctions\x12@\n" +
+	"\rempty_buckets\x18\x06 \x01(\x0e2\x1b.meterusage.v1.EmptyBucketsR\femptyBuckets\x12\x1b\n" +
+	"\tmeter_ids\x18\a \x03(\tR\bmeterIds\x12\x1b\n" +
+	"\ttime_zone\x18\b \x01(\tR\btimeZoneB\b\n" +
+	"\x06bucket\"L\n" +
+	"\x19AggregateReadingsResponse\x12/\n" +
+	"\abuckets\x18\x01 \x03(\v2\x15.meterusage.v1.BucketR\abuckets\"\x89\x02\n" +
+	"\x06Bucket\x120\n" +
+	"\x05start\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x05start\x12,\n" +
+	"\x03end\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x03end\x12\x19\n" +
+	"\x05count\x18\x03 \x01(\x03H\x00R\x05count\x88\x01\x01\x12\x15\n" +
+	"\x03sum\x18\x04 \x01(\x01H\x01R\x03sum\x88\x01\x01\x12\x15\n" +
+	"\x03avg\x18\x05 \x01(\x01H\x02R\x03avg\x88\x01\x01\x12\x15\n" +
+	"\x03min\x18\x06 \x01(\x01H\x03R\x03min\x88\x01\x01\x12\x15\n" +
+	"\x03max\x18\a \x01(\x01H\x04R\x03max\x88\x01\x01B\b\n" +
+	"\x06_countB\x06\n" +
+	"\x04_sumB\x06\n" +
+	"\x04_avgB\x06\n" +
+	"\x04_minB\x06\n" +
+	"\x04_max*\x97\x01\n" +
+	"\vQualityFlag\x12\x1c\n" +
+	"\x18QUALITY_FLAG_UNSPECIFIED\x10\x00\x12\x1a\n" +
+	"\x16QUALITY_FLAG_DUPLICATE\x10\x01\x12\x19\n" +
+	"\x15QUALITY_FLAG_NEGATIVE\x10\x02\x12\x16\n" +
+	"\x12QUALITY_FLAG_SPIKE\x10\x03\x12\x1b\n" +
+	"\x17QUALITY_FLAG_GAP_BEFORE\x10\x04*\xc5\x01\n" +
+	"\x11AggregateFunction\x12\"\n" +
+	"\x1eAGGREGATE_FUNCTION_UNSPECIFIED\x10\x00\x12\x1a\n" +
+	"\x16AGGREGATE_FUNCTION_SUM\x10\x01\x12\x1a\n" +
+	"\x16AGGREGATE_FUNCTION_AVG\x10\x02\x12\x1a\n" +
+	"\x16AGGREGATE_FUNCTION_MIN\x10\x03\x12\x1a\n" +
+	"\x16AGGREGATE_FUNCTION_MAX\x10\x04\x12\x1c\n" +
+	"\x18AGGREGATE_FUNCTION_COUNT\x10\x05*\x89\x01\n" +
+	"\x10CalendarInterval\x12!\n" +
+	"\x1dCALENDAR_INTERVAL_UNSPECIFIED\x10\x00\x12\x19\n" +
+	"\x15CALENDAR_INTERVAL_DAY\x10\x01\x12\x1a\n" +
+	"\x16CALENDAR_INTERVAL_WEEK\x10\x02\x12\x1b\n" +
+	"\x17CALENDAR_INTERVAL_MONTH\x10\x03*u\n" +
+	"\fEmptyBuckets\x12\x1d\n" +
+	"\x19EMPTY_BUCKETS_UNSPECIFIED\x10\x00\x12\x16\n" +
+	"\x12EMPTY_BUCKETS_SKIP\x10\x01\x12\x16\n" +
+	"\x12EMPTY_BUCKETS_NULL\x10\x02\x12\x16\n" +
+	"\x12EMPTY_BUCKETS_ZERO\x10\x032\x9a\x06\n" +
+	"\x11MeterUsageService\x12Y\n" +
+	"\fListReadings\x12\".meterusage.v1.ListReadingsRequest\x1a#.meterusage.v1.ListReadingsResponse\"\x00\x12h\n" +
+	"\x11AggregateReadings\x12'.meterusage.v1.AggregateReadingsRequest\x1a(.meterusage.v1.AggregateReadingsResponse\"\x00\x12a\n" +
+	"\x0eStreamReadings\x12$.meterusage.v1.StreamReadingsRequest\x1a%.meterusage.v1.StreamReadingsResponse\"\x000\x01\x12_\n" +
+	"\x0eAppendReadings\x12$.meterusage.v1.AppendReadingsRequest\x1a%.meterusage.v1.AppendReadingsResponse\"\x00\x12S\n" +
+	"\n" +
+	"ListMeters\x12 .meterusage.v1.ListMetersRequest\x1a!.meterusage.v1.ListMetersResponse\"\x00\x12S\n" +
+	"\n" +
+	"GetDataset\x12 .meterusage.v1.GetDatasetRequest\x1a!.meterusage.v1.GetDatasetResponse\"\x00\x12k\n" +
+	"\x12GetIngestionReport\x12(.meterusage.v1.GetIngestionReportRequest\x1a).meterusage.v1.GetIngestionReportResponse\"\x00\x12e\n" +
+	"\x10GetQualityReport\x12&.meterusage.v1.GetQualityReportRequest\x1a'.meterusage.v1.GetQualityReportResponse\"\x00B\xbc\x01\n" +
+	"\x11com.meterusage.v1B\x0fMeterusageProtoP\x01ZAgithub.com/milad/spectral/gen/go/proto/meterusage/v1;meterusagev1\xa2\x02\x03MXX\xaa\x02\rMeterusage.V1\xca\x02\rMeterusage\\V1\xe2\x02\x19Meterusage\\V1\\GPBMetadata\xea\x02\x0eMeterusage::V1b\x06proto3"
+
+var (
+	file_proto_meterusage_v1_meterusage_proto_rawDescOnce sync.Once
+	file_proto_meterusage_v1_meterusage_proto_rawDescData []byte
+)
+
+func file_proto_meterusage_v1_meterusage_proto_rawDescGZIP() []byte {
+	file_proto_meterusage_v1_meterusage_proto_rawDescOnce.Do(func() {
+		file_proto_meterusage_v1_meterusage_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_proto_meterusage_v1_meterusage_proto_rawDesc), len(file_proto_meterusage_v1_meterusage_proto_rawDesc)))
+	})
+	return file_proto_meterusage_v1_meterusage_proto_rawDescData
+}
+
+var file_proto_meterusage_v1_meterusage_proto_enumTypes = make([]protoimpl.EnumInfo, 4)
+var file_proto_meterusage_v1_meterusage_proto_msgTypes = make([]protoimpl.MessageInfo, 27)
+var file_proto_meterusage_v1_meterusage_proto_goTypes = []any{
+	(QualityFlag)(0),                   // 0: meterusage.v1.QualityFlag
+	(AggregateFunction)(0),             // 1: meterusage.v1.AggregateFunction
+	(CalendarInterval)(0),              // 2: meterusage.v1.CalendarInterval
+	(EmptyBuckets)(0),                  // 3: meterusage.v1.EmptyBuckets
+	(*ListReadingsRequest)(nil),        // 4: meterusage.v1.ListReadingsRequest
+	(*ListReadingsResponse)(nil),       // 5: meterusage.v1.ListReadingsResponse
+	(*Reading)(nil),                    // 6: meterusage.v1.Reading
+	(*StreamReadingsRequest)(nil),      // 7: meterusage.v1.StreamReadingsRequest
+	(*StreamReadingsResponse)(nil),     // 8: meterusage.v1.StreamReadingsResponse
+	(*AppendReadingsRequest)(nil),      // 9: meterusage.v1.AppendReadingsRequest
+	(*AppendReadingsResponse)(nil),     // 10: meterusage.v1.AppendReadingsResponse
+	(*RowError)(nil),                   // 11: meterusage.v1.RowError
+	(*ListMetersRequest)(nil),          // 12: meterusage.v1.ListMetersRequest
+	(*ListMetersResponse)(nil),         // 13: meterusage.v1.ListMetersResponse
+	(*Meter)(nil),                      // 14: meterusage.v1.Meter
+	(*GetDatasetRequest)(nil),          // 15: meterusage.v1.GetDatasetRequest
+	(*GetDatasetResponse)(nil),         // 16: meterusage.v1.GetDatasetResponse
+	(*Dataset)(nil),                    // 17: meterusage.v1.Dataset
+	(*GetIngestionReportRequest)(nil),  // 18: meterusage.v1.GetIngestionReportRequest
+	(*GetIngestionReportResponse)(nil), // 19: meterusage.v1.GetIngestionReportResponse
+	(*IngestionReport)(nil),            // 20: meterusage.v1.IngestionReport
+	(*IngestionCategoryCount)(nil),     // 21: meterusage.v1.IngestionCategoryCount
+	(*IngestionIssue)(nil),             // 22: meterusage.v1.IngestionIssue
+	(*GetQualityReportRequest)(nil),    // 23: meterusage.v1.GetQualityReportRequest
+	(*GetQualityReportResponse)(nil),   // 24: meterusage.v1.GetQualityReportResponse
+	(*QualityReport)(nil),              // 25: meterusage.v1.QualityReport
+	(*MeterQuality)(nil),               // 26: meterusage.v1.MeterQuality
+	(*Gap)(nil),                        // 27: meterusage.v1.Gap
+	(*AggregateReadingsRequest)(nil),   // 28: meterusage.v1.AggregateReadingsRequest
+	(*AggregateReadingsResponse)(nil),  // 29: meterusage.v1.AggregateReadingsResponse
+	(*Bucket)(nil),                     // 30: meterusage.v1.Bucket
+	(*timestamppb.Timestamp)(nil),      // 31: google.protobuf.Timestamp
+	(*durationpb.Duration)(nil),        // 32: google.protobuf.Duration
+}
+var file_proto_meterusage_v1_meterusage_proto_depIdxs = []int32{
+	31, // 0: meterusage.v1.ListReadingsRequest.start:type_name -> google.protobuf.Timestamp
+	31, // 1: meterusage.v1.ListReadingsRequest.end:type_name -> google.protobuf.Timestamp
+	6,  // 2: meterusage.v1.ListReadingsResponse.readings:type_name -> meterusage.v1.Reading
+	31, // 3: meterusage.v1.Reading.time:type_name -> google.protobuf.Timestamp
+	0,  // 4: meterusage.v1.Reading.quality_flags:type_name -> meterusage.v1.QualityFlag
+	31, // 5: meterusage.v1.StreamReadingsRequest.start:type_name -> google.protobuf.Timestamp
+	31, // 6: meterusage.v1.StreamReadingsRequest.end:type_name -> google.protobuf.Timestamp
+	6,  // 7: meterusage.v1.StreamReadingsResponse.readings:type_name -> meterusage.v1.Reading
+	6,  // 8: meterusage.v1.AppendReadingsRequest.readings:type_name -> meterusage.v1.Reading
+	11, // 9: meterusage.v1.AppendReadingsResponse.row_errors:type_name -> meterusage.v1.RowError
+	14, // 10: meterusage.v1.ListMetersResponse.meters:type_name -> meterusage.v1.Meter
+	31, // 11: meterusage.v1.Meter.first_reading_time:type_name -> google.protobuf.Timestamp
+	31, // 12: meterusage.v1.Meter.last_reading_time:type_name -> google.protobuf.Timestamp
+	17, // 13: meterusage.v1.GetDatasetResponse.dataset:type_name -> meterusage.v1.Dataset
+	31, // 14: meterusage.v1.Dataset.update_time:type_name -> google.protobuf.Timestamp
+	20, // 15: meterusage.v1.GetIngestionReportResponse.report:type_name -> meterusage.v1.IngestionReport
+	31, // 16: meterusage.v1.IngestionReport.load_time:type_name -> google.protobuf.Timestamp
+	21, // 17: meterusage.v1.IngestionReport.categories:type_name -> meterusage.v1.IngestionCategoryCount
+	22, // 18: meterusage.v1.IngestionReport.issues:type_name -> meterusage.v1.IngestionIssue
+	31, // 19: meterusage.v1.GetQualityReportRequest.start:type_name -> google.protobuf.Timestamp
+	31, // 20: meterusage.v1.GetQualityReportRequest.end:type_name -> google.protobuf.Timestamp
+	25, // 21: meterusage.v1.GetQualityReportResponse.report:type_name -> meterusage.v1.QualityReport
+	32, // 22: meterusage.v1.QualityReport.interval:type_name -> google.protobuf.Duration
+	26, // 23: meterusage.v1.QualityReport.meters:type_name -> meterusage.v1.MeterQuality
+	27, // 24: meterusage.v1.MeterQuality.gaps:type_name -> meterusage.v1.Gap
+	6,  // 25: meterusage.v1.MeterQuality.anomalies:type_name -> meterusage.v1.Reading
+	31, // 26: meterusage.v1.Gap.start:type_name -> google.protobuf.Timestamp
+	31, // 27: meterusage.v1.Gap.end:type_name -> google.protobuf.Timestamp
+	31, // 28: meterusage.v1.AggregateReadingsRequest.start:type_name -> google.protobuf.Timestamp
+	31, // 29: meterusage.v1.AggregateReadingsRequest.end:type_name -> google.protobuf.Timestamp
+	32, // 30: meterusage.v1.AggregateReadingsRequest.bucket_width:type_name -> google.protobuf.Duration
+	2,  // 31: meterusage.v1.AggregateReadingsRequest.calendar_interval:type_name -> meterusage.v1.CalendarInterval
+	1,  // 32: meterusage.v1.AggregateReadingsRequest.functions:type_name -> meterusage.v1.AggregateFunction
+	3,  // 33: meterusage.v1.AggregateReadingsRequest.empty_buckets:type_name -> meterusage.v1.EmptyBuckets
+	30, // 34: meterusage.v1.AggregateReadingsResponse.buckets:type_name -> meterusage.v1.Bucket
+	31, // 35: meterusage.v1.Bucket.start:type_name -> google.protobuf.Timestamp
+	31, // 36: meterusage.v1.Bucket.end:type_name -> google.protobuf.Timestamp
+	4,  // 37: meterusage.v1.MeterUsageService.ListReadings:input_type -> meterusage.v1.ListReadingsRequest
+	28, // 38: meterusage.v1.MeterUsageService.AggregateReadings:input_type -> meterusage.v1.AggregateReadingsRequest
+	7,  // 39: meterusage.v1.MeterUsageService.StreamReadings:input_type -> meterusage.v1.StreamReadingsRequest
+	9,  // 40: meterusage.v1.MeterUsageService.AppendReadings:input_type -> meterusage.v1.AppendReadingsRequest
+	12, // 41: meterusage.v1.MeterUsageService.ListMeters:input_type -> meterusage.v1.ListMetersRequest
+	15, // 42: meterusage.v1.MeterUsageService.GetDataset:input_type -> meterusage.v1.GetDatasetRequest
+	18, // 43: meterusage.v1.MeterUsageService.GetIngestionReport:input_type -> meterusage.v1.GetIngestionReportRequest
+	23, // 44: meterusage.v1.MeterUsageService.GetQualityReport:input_type -> meterusage.v1.GetQualityReportRequest
+	5,  // 45: meterusage.v1.MeterUsageService.ListReadings:output_type -> meterusage.v1.ListReadingsResponse
+	29, // 46: meterusage.v1.MeterUsageService.AggregateReadings:output_type -> meterusage.v1.AggregateReadingsResponse
+	8,  // 47: meterusage.v1.MeterUsageService.StreamReadings:output_type -> meterusage.v1.StreamReadingsResponse
+	10, // 48: meterusage.v1.MeterUsageService.AppendReadings:output_type -> meterusage.v1.AppendReadingsResponse
+	13, // 49: meterusage.v1.MeterUsageService.ListMeters:output_type -> meterusage.v1.ListMetersResponse
+	16, // 50: meterusage.v1.MeterUsageService.GetDataset:output_type -> meterusage.v1.GetDatasetResponse
+	19, // 51: meterusage.v1.MeterUsageService.GetIngestionReport:output_type -> meterusage.v1.GetIngestionReportResponse
+	24, // 52: meterusage.v1.MeterUsageService.GetQualityReport:output_type -> meterusage.v1.GetQualityReportResponse
+	45, // [45:53] is the sub-list for method output_type
+	37, // [37:45] is the sub-list for method input_type
+	37, // [37:37] is the sub-list for extension type_name
+	37, // [37:37] is the sub-list for extension extendee
+	0,  // [0:37] is the sub-list for field type_name
+}
+
+func init() { file_proto_meterusage_v1_meterusage_proto_init() }
+func file_proto_meterusage_v1_meterusage_proto_init() {
+	if File_proto_meterusage_v1_meterusage_proto != nil {
+		return
+	}
+	file_proto_meterusage_v1_meterusage_proto_msgTypes[24].OneofWrappers = []any{
+		(*AggregateReadingsRequest_BucketWidth)(nil),
+		(*AggregateReadingsRequest_CalendarInterval)(nil),
 	}
+	file_proto_meterusage_v1_meterusage_proto_msgTypes[26].OneofWrappers = []any{}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_meterusage_v1_meterusage_proto_rawDesc), len(file_proto_meterusage_v1_meterusage_proto_rawDesc)),
-			NumEnums:      0,
-			NumMessages:   3,
+			NumEnums:      4,
+			NumMessages:   27,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_proto_meterusage_v1_meterusage_proto_goTypes,
 		DependencyIndexes: file_proto_meterusage_v1_meterusage_proto_depIdxs,
+		EnumInfos:         file_proto_meterusage_v1_meterusage_proto_enumTypes,
 		MessageInfos:      file_proto_meterusage_v1_meterusage_proto_msgTypes,
 	}.Build()
 	File_proto_meterusage_v1_meterusage_proto = out.File
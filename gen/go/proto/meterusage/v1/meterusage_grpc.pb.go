@@ -19,7 +19,14 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	MeterUsageService_ListReadings_FullMethodName = "/meterusage.v1.MeterUsageService/ListReadings"
+	MeterUsageService_ListReadings_FullMethodName       = "/meterusage.v1.MeterUsageService/ListReadings"
+	MeterUsageService_AggregateReadings_FullMethodName  = "/meterusage.v1.MeterUsageService/AggregateReadings"
+	MeterUsageService_StreamReadings_FullMethodName     = "/meterusage.v1.MeterUsageService/StreamReadings"
+	MeterUsageService_AppendReadings_FullMethodName     = "/meterusage.v1.MeterUsageService/AppendReadings"
+	MeterUsageService_ListMeters_FullMethodName         = "/meterusage.v1.MeterUsageService/ListMeters"
+	MeterUsageService_GetDataset_FullMethodName         = "/meterusage.v1.MeterUsageService/GetDataset"
+	MeterUsageService_GetIngestionReport_FullMethodName = "/meterusage.v1.MeterUsageService/GetIngestionReport"
+	MeterUsageService_GetQualityReport_FullMethodName   = "/meterusage.v1.MeterUsageService/GetQualityReport"
 )
 
 // MeterUsageServiceClient is the client API for MeterUsageService service.
@@ -28,6 +35,26 @@ const (
 type MeterUsageServiceClient interface {
 	// Lists time-series readings, optionally filtered by [start, end).
 	ListReadings(ctx context.Context, in *ListReadingsRequest, opts ...grpc.CallOption) (*ListReadingsResponse, error)
+	// Aggregates readings in [start, end) into time buckets, returning one row per bucket.
+	AggregateReadings(ctx context.Context, in *AggregateReadingsRequest, opts ...grpc.CallOption) (*AggregateReadingsResponse, error)
+	// Streams readings in [start, end) in time order, in chunks. Unlike
+	// ListReadings, there is no cap on the range and no pagination.
+	StreamReadings(ctx context.Context, in *StreamReadingsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StreamReadingsResponse], error)
+	// Validates and inserts readings. Invalid rows are reported individually and
+	// do not prevent valid rows from being inserted.
+	AppendReadings(ctx context.Context, in *AppendReadingsRequest, opts ...grpc.CallOption) (*AppendReadingsResponse, error)
+	// Lists the meters that have readings, ordered by ID.
+	ListMeters(ctx context.Context, in *ListMetersRequest, opts ...grpc.CallOption) (*ListMetersResponse, error)
+	// Describes the dataset currently being served, e.g. to detect reloads.
+	GetDataset(ctx context.Context, in *GetDatasetRequest, opts ...grpc.CallOption) (*GetDatasetResponse, error)
+	// Reports the input rejected by the last load of the source files, so that
+	// data owners can fix them. The SQLite store reports the CSV it was seeded
+	// from, and nothing if it was not seeded. Fails with UNIMPLEMENTED for stores
+	// that are not loaded from files.
+	GetIngestionReport(ctx context.Context, in *GetIngestionReportRequest, opts ...grpc.CallOption) (*GetIngestionReportResponse, error)
+	// Summarizes the gaps and anomalies of the readings in [start, end), per
+	// meter. The same checks set Reading.quality_flags.
+	GetQualityReport(ctx context.Context, in *GetQualityReportRequest, opts ...grpc.CallOption) (*GetQualityReportResponse, error)
 }
 
 type meterUsageServiceClient struct {
@@ -48,12 +75,111 @@ func (c *meterUsageServiceClient) ListReadings(ctx context.Context, in *ListRead
 	return out, nil
 }
 
+func (c *meterUsageServiceClient) AggregateReadings(ctx context.Context, in *AggregateReadingsRequest, opts ...grpc.CallOption) (*AggregateReadingsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AggregateReadingsResponse)
+	err := c.cc.Invoke(ctx, MeterUsageService_AggregateReadings_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *meterUsageServiceClient) StreamReadings(ctx context.Context, in *StreamReadingsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StreamReadingsResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &MeterUsageService_ServiceDesc.Streams[0], MeterUsageService_StreamReadings_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamReadingsRequest, StreamReadingsResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MeterUsageService_StreamReadingsClient = grpc.ServerStreamingClient[StreamReadingsResponse]
+
+func (c *meterUsageServiceClient) AppendReadings(ctx context.Context, in *AppendReadingsRequest, opts ...grpc.CallOption) (*AppendReadingsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AppendReadingsResponse)
+	err := c.cc.Invoke(ctx, MeterUsageService_AppendReadings_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *meterUsageServiceClient) ListMeters(ctx context.Context, in *ListMetersRequest, opts ...grpc.CallOption) (*ListMetersResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListMetersResponse)
+	err := c.cc.Invoke(ctx, MeterUsageService_ListMeters_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *meterUsageServiceClient) GetDataset(ctx context.Context, in *GetDatasetRequest, opts ...grpc.CallOption) (*GetDatasetResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetDatasetResponse)
+	err := c.cc.Invoke(ctx, MeterUsageService_GetDataset_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *meterUsageServiceClient) GetIngestionReport(ctx context.Context, in *GetIngestionReportRequest, opts ...grpc.CallOption) (*GetIngestionReportResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetIngestionReportResponse)
+	err := c.cc.Invoke(ctx, MeterUsageService_GetIngestionReport_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *meterUsageServiceClient) GetQualityReport(ctx context.Context, in *GetQualityReportRequest, opts ...grpc.CallOption) (*GetQualityReportResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetQualityReportResponse)
+	err := c.cc.Invoke(ctx, MeterUsageService_GetQualityReport_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // MeterUsageServiceServer is the server API for MeterUsageService service.
 // All implementations must embed UnimplementedMeterUsageServiceServer
 // for forward compatibility.
 type MeterUsageServiceServer interface {
 	// Lists time-series readings, optionally filtered by [start, end).
 	ListReadings(context.Context, *ListReadingsRequest) (*ListReadingsResponse, error)
+	// Aggregates readings in [start, end) into time buckets, returning one row per bucket.
+	AggregateReadings(context.Context, *AggregateReadingsRequest) (*AggregateReadingsResponse, error)
+	// Streams readings in [start, end) in time order, in chunks. Unlike
+	// ListReadings, there is no cap on the range and no pagination.
+	StreamReadings(*StreamReadingsRequest, grpc.ServerStreamingServer[StreamReadingsResponse]) error
+	// Validates and inserts readings. Invalid rows are reported individually and
+	// do not prevent valid rows from being inserted.
+	AppendReadings(context.Context, *AppendReadingsRequest) (*AppendReadingsResponse, error)
+	// Lists the meters that have readings, ordered by ID.
+	ListMeters(context.Context, *ListMetersRequest) (*ListMetersResponse, error)
+	// Describes the dataset currently being served, e.g. to detect reloads.
+	GetDataset(context.Context, *GetDatasetRequest) (*GetDatasetResponse, error)
+	// Reports the input rejected by the last load of the source files, so that
+	// data owners can fix them. The SQLite store reports the CSV it was seeded
+	// from, and nothing if it was not seeded. Fails with UNIMPLEMENTED for stores
+	// that are not loaded from files.
+	GetIngestionReport(context.Context, *GetIngestionReportRequest) (*GetIngestionReportResponse, error)
+	// Summarizes the gaps and anomalies of the readings in [start, end), per
+	// meter. The same checks set Reading.quality_flags.
+	GetQualityReport(context.Context, *GetQualityReportRequest) (*GetQualityReportResponse, error)
 	mustEmbedUnimplementedMeterUsageServiceServer()
 }
 
@@ -67,6 +193,27 @@ type UnimplementedMeterUsageServiceServer struct{}
 func (UnimplementedMeterUsageServiceServer) ListReadings(context.Context, *ListReadingsRequest) (*ListReadingsResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method ListReadings not implemented")
 }
+func (UnimplementedMeterUsageServiceServer) AggregateReadings(context.Context, *AggregateReadingsRequest) (*AggregateReadingsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method AggregateReadings not implemented")
+}
+func (UnimplementedMeterUsageServiceServer) StreamReadings(*StreamReadingsRequest, grpc.ServerStreamingServer[StreamReadingsResponse]) error {
+	return status.Error(codes.Unimplemented, "method StreamReadings not implemented")
+}
+func (UnimplementedMeterUsageServiceServer) AppendReadings(context.Context, *AppendReadingsRequest) (*AppendReadingsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method AppendReadings not implemented")
+}
+func (UnimplementedMeterUsageServiceServer) ListMeters(context.Context, *ListMetersRequest) (*ListMetersResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListMeters not implemented")
+}
+func (UnimplementedMeterUsageServiceServer) GetDataset(context.Context, *GetDatasetRequest) (*GetDatasetResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetDataset not implemented")
+}
+func (UnimplementedMeterUsageServiceServer) GetIngestionReport(context.Context, *GetIngestionReportRequest) (*GetIngestionReportResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetIngestionReport not implemented")
+}
+func (UnimplementedMeterUsageServiceServer) GetQualityReport(context.Context, *GetQualityReportRequest) (*GetQualityReportResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetQualityReport not implemented")
+}
 func (UnimplementedMeterUsageServiceServer) mustEmbedUnimplementedMeterUsageServiceServer() {}
 func (UnimplementedMeterUsageServiceServer) testEmbeddedByValue()                           {}
 
@@ -106,6 +253,125 @@ func _MeterUsageService_ListReadings_Handler(srv interface{}, ctx context.Contex
 	return interceptor(ctx, in, info, handler)
 }
 
+func _MeterUsageService_AggregateReadings_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AggregateReadingsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MeterUsageServiceServer).AggregateReadings(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MeterUsageService_AggregateReadings_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MeterUsageServiceServer).AggregateReadings(ctx, req.(*AggregateReadingsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MeterUsageService_StreamReadings_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamReadingsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MeterUsageServiceServer).StreamReadings(m, &grpc.GenericServerStream[StreamReadingsRequest, StreamReadingsResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MeterUsageService_StreamReadingsServer = grpc.ServerStreamingServer[StreamReadingsResponse]
+
+func _MeterUsageService_AppendReadings_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AppendReadingsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MeterUsageServiceServer).AppendReadings(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MeterUsageService_AppendReadings_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MeterUsageServiceServer).AppendReadings(ctx, req.(*AppendReadingsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MeterUsageService_ListMeters_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListMetersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MeterUsageServiceServer).ListMeters(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MeterUsageService_ListMeters_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MeterUsageServiceServer).ListMeters(ctx, req.(*ListMetersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MeterUsageService_GetDataset_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetDatasetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MeterUsageServiceServer).GetDataset(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MeterUsageService_GetDataset_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MeterUsageServiceServer).GetDataset(ctx, req.(*GetDatasetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MeterUsageService_GetIngestionReport_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetIngestionReportRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MeterUsageServiceServer).GetIngestionReport(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MeterUsageService_GetIngestionReport_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MeterUsageServiceServer).GetIngestionReport(ctx, req.(*GetIngestionReportRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MeterUsageService_GetQualityReport_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetQualityReportRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MeterUsageServiceServer).GetQualityReport(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MeterUsageService_GetQualityReport_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MeterUsageServiceServer).GetQualityReport(ctx, req.(*GetQualityReportRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // MeterUsageService_ServiceDesc is the grpc.ServiceDesc for MeterUsageService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -117,7 +383,37 @@ var MeterUsageService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ListReadings",
 			Handler:    _MeterUsageService_ListReadings_Handler,
 		},
+		{
+			MethodName: "AggregateReadings",
+			Handler:    _MeterUsageService_AggregateReadings_Handler,
+		},
+		{
+			MethodName: "AppendReadings",
+			Handler:    _MeterUsageService_AppendReadings_Handler,
+		},
+		{
+			MethodName: "ListMeters",
+			Handler:    _MeterUsageService_ListMeters_Handler,
+		},
+		{
+			MethodName: "GetDataset",
+			Handler:    _MeterUsageService_GetDataset_Handler,
+		},
+		{
+			MethodName: "GetIngestionReport",
+			Handler:    _MeterUsageService_GetIngestionReport_Handler,
+		},
+		{
+			MethodName: "GetQualityReport",
+			Handler:    _MeterUsageService_GetQualityReport_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamReadings",
+			Handler:       _MeterUsageService_StreamReadings_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/meterusage/v1/meterusage.proto",
 }
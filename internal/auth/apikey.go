@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// APIKeyHeader is the request header carrying an API key.
+const APIKeyHeader = "X-API-Key"
+
+// APIKey is an entry of the API key file. Only the SHA-256 of the key is
+// stored; the key itself is never written to disk.
+type APIKey struct {
+	// Name identifies the key holder and becomes the principal's subject.
+	Name string `json:"name"`
+	// SHA256 is the hex-encoded SHA-256 digest of the key.
+	SHA256 string `json:"sha256"`
+	// Scopes defaults to read-only if empty.
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+// APIKeys authenticates requests by the key in the X-API-Key header.
+type APIKeys struct {
+	byHash map[[sha256.Size]byte]Principal
+}
+
+// NewAPIKeys validates keys and builds an authenticator for them.
+func NewAPIKeys(keys []APIKey) (*APIKeys, error) {
+	a := &APIKeys{byHash: make(map[[sha256.Size]byte]Principal, len(keys))}
+	for i, k := range keys {
+		if k.Name == "" {
+			return nil, fmt.Errorf("api key %d: name is required", i)
+		}
+		raw, err := hex.DecodeString(k.SHA256)
+		if err != nil || len(raw) != sha256.Size {
+			return nil, fmt.Errorf("api key %q: sha256 must be %d hex characters", k.Name, 2*sha256.Size)
+		}
+		hash := [sha256.Size]byte(raw)
+		if _, dup := a.byHash[hash]; dup {
+			return nil, fmt.Errorf("api key %q: duplicate sha256", k.Name)
+		}
+		scopes := k.Scopes
+		if len(scopes) == 0 {
+			scopes = []string{ScopeRead}
+		}
+		a.byHash[hash] = Principal{Subject: k.Name, Method: "api_key", Scopes: scopes}
+	}
+	return a, nil
+}
+
+// LoadAPIKeys reads a JSON file of the form {"keys": [APIKey, ...]}.
+func LoadAPIKeys(path string) (*APIKeys, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read api keys: %w", err)
+	}
+	var file struct {
+		Keys []APIKey `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &file); err != nil {
+		return nil, fmt.Errorf("parse api keys %q: %w", path, err)
+	}
+	if len(file.Keys) == 0 {
+		return nil, fmt.Errorf("api keys file %q has no keys", path)
+	}
+	return NewAPIKeys(file.Keys)
+}
+
+// HashAPIKey returns the value to store as APIKey.SHA256 for key.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Authenticate implements Authenticator. Keys are looked up by their digest,
+// so the comparison does not leak how much of a key matched.
+func (a *APIKeys) Authenticate(r *http.Request) (Principal, error) {
+	key := strings.TrimSpace(r.Header.Get(APIKeyHeader))
+	if key == "" {
+		return Principal{}, ErrNoCredentials
+	}
+	p, ok := a.byHash[sha256.Sum256([]byte(key))]
+	if !ok {
+		return Principal{}, fmt.Errorf("%w: unknown api key", ErrInvalidCredentials)
+	}
+	return p, nil
+}
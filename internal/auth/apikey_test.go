@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAPIKeys_Authenticate(t *testing.T) {
+	t.Parallel()
+
+	keys, err := NewAPIKeys([]APIKey{
+		{Name: "reader", SHA256: HashAPIKey("read-secret")},
+		{Name: "ingest", SHA256: HashAPIKey("write-secret"), Scopes: []string{ScopeRead, ScopeWrite}},
+	})
+	if err != nil {
+		t.Fatalf("NewAPIKeys: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/readings", nil)
+	if _, err := keys.Authenticate(req); !errors.Is(err, ErrNoCredentials) {
+		t.Fatalf("no header: expected ErrNoCredentials, got %v", err)
+	}
+
+	req.Header.Set(APIKeyHeader, "read-secret")
+	p, err := keys.Authenticate(req)
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if got, want := p.Subject, "reader"; got != want {
+		t.Fatalf("subject=%q want %q", got, want)
+	}
+	if !p.HasScope(ScopeRead) || p.HasScope(ScopeWrite) {
+		t.Fatalf("expected default read-only scopes, got %v", p.Scopes)
+	}
+
+	req.Header.Set(APIKeyHeader, "write-secret")
+	if p, err := keys.Authenticate(req); err != nil || !p.HasScope(ScopeWrite) {
+		t.Fatalf("ingest key: principal=%+v err=%v", p, err)
+	}
+
+	req.Header.Set(APIKeyHeader, "wrong")
+	if _, err := keys.Authenticate(req); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("wrong key: expected ErrInvalidCredentials, got %v", err)
+	}
+}
+
+func TestLoadAPIKeys_Validates(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"empty":     `{"keys": []}`,
+		"no name":   `{"keys": [{"sha256": "` + HashAPIKey("k") + `"}]}`,
+		"bad hash":  `{"keys": [{"name": "a", "sha256": "plaintext"}]}`,
+		"duplicate": `{"keys": [{"name": "a", "sha256": "` + HashAPIKey("k") + `"}, {"name": "b", "sha256": "` + HashAPIKey("k") + `"}]}`,
+		"not json":  `keys:`,
+	} {
+		path := filepath.Join(dir, name+".json")
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		if _, err := LoadAPIKeys(path); err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestChain_FallsThroughOnlyWithoutCredentials(t *testing.T) {
+	t.Parallel()
+
+	keys, err := NewAPIKeys([]APIKey{{Name: "reader", SHA256: HashAPIKey("secret")}})
+	if err != nil {
+		t.Fatalf("NewAPIKeys: %v", err)
+	}
+	rejectAll := authenticatorFunc(func(*http.Request) (Principal, error) {
+		return Principal{}, ErrInvalidCredentials
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/readings", nil)
+	if _, err := Chain(keys).Authenticate(req); !errors.Is(err, ErrNoCredentials) {
+		t.Fatalf("expected ErrNoCredentials, got %v", err)
+	}
+
+	req.Header.Set(APIKeyHeader, "secret")
+	if p, err := Chain(keys, rejectAll).Authenticate(req); err != nil || p.Subject != "reader" {
+		t.Fatalf("principal=%+v err=%v", p, err)
+	}
+	if _, err := Chain(rejectAll, keys).Authenticate(req); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected the first rejection to stop the chain, got %v", err)
+	}
+}
+
+type authenticatorFunc func(*http.Request) (Principal, error)
+
+func (f authenticatorFunc) Authenticate(r *http.Request) (Principal, error) { return f(r) }
@@ -0,0 +1,81 @@
+// Package auth authenticates API callers with static API keys or JWT bearer
+// tokens and carries the resulting principal through request contexts.
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+)
+
+var (
+	// ErrNoCredentials is returned by an Authenticator when the request carries
+	// no credentials of the kind it handles.
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials is returned when credentials are present but
+	// unknown, malformed, expired or otherwise not acceptable.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Scopes granted to principals.
+const (
+	ScopeRead  = "readings:read"
+	ScopeWrite = "readings:write"
+)
+
+// Principal is an authenticated caller.
+type Principal struct {
+	// Subject identifies the caller: the key name for API keys, the `sub`
+	// claim for JWTs.
+	Subject string
+	// Method is how the caller authenticated: "api_key" or "jwt".
+	Method string
+	Scopes []string
+}
+
+// HasScope reports whether the principal was granted scope.
+func (p Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+// Authenticator checks the credentials of a request.
+//
+// Implementations return ErrNoCredentials if the request has none of the
+// kind they handle, so that several authenticators can be chained, and an
+// error wrapping ErrInvalidCredentials if the credentials are rejected.
+type Authenticator interface {
+	Authenticate(r *http.Request) (Principal, error)
+}
+
+// Chain tries each authenticator in order and returns the first principal.
+// Credentials that one authenticator rejects are not offered to the next.
+func Chain(authenticators ...Authenticator) Authenticator {
+	return chain(authenticators)
+}
+
+type chain []Authenticator
+
+func (c chain) Authenticate(r *http.Request) (Principal, error) {
+	for _, a := range c {
+		p, err := a.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return p, err
+	}
+	return Principal{}, ErrNoCredentials
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying p.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the principal attached with WithPrincipal.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
@@ -0,0 +1,259 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// JWTConfig holds the claims a JWT must carry besides a valid signature.
+type JWTConfig struct {
+	// Issuer, if set, must equal the `iss` claim.
+	Issuer string
+	// Audience, if set, must be one of the `aud` claim values.
+	Audience string
+	// Leeway is the clock skew tolerated for `exp` and `nbf` (default 1m).
+	Leeway time.Duration
+}
+
+// JWT authenticates requests by a bearer token in the Authorization header,
+// signed by one of the keys of a local JWKS file.
+//
+// Supported algorithms are RS256/384/512, PS256/384/512, ES256/384/512 and
+// EdDSA (Ed25519). Tokens must have `sub` and `exp` claims; scopes come from
+// `scope` (space-separated) or `scp`.
+type JWT struct {
+	keys []verificationKey
+	cfg  JWTConfig
+	now  func() time.Time
+}
+
+// LoadJWT reads the JWKS file at path.
+func LoadJWT(path string, cfg JWTConfig) (*JWT, error) {
+	keys, err := loadJWKS(path)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Leeway == 0 {
+		cfg.Leeway = time.Minute
+	}
+	return &JWT{keys: keys, cfg: cfg, now: time.Now}, nil
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type jwtClaims struct {
+	Subject   string          `json:"sub"`
+	Issuer    string          `json:"iss"`
+	Audience  json.RawMessage `json:"aud"`
+	ExpiresAt *float64        `json:"exp"`
+	NotBefore *float64        `json:"nbf"`
+	Scope     string          `json:"scope"`
+	Scp       json.RawMessage `json:"scp"`
+}
+
+// Authenticate implements Authenticator.
+func (j *JWT) Authenticate(r *http.Request) (Principal, error) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return Principal{}, ErrNoCredentials
+	}
+	claims, err := j.verify(strings.TrimSpace(token))
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+	return Principal{Subject: claims.Subject, Method: "jwt", Scopes: claims.scopes()}, nil
+}
+
+// verify checks the signature and claims of a compact-serialized JWT.
+func (j *JWT) verify(token string) (jwtClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return jwtClaims{}, fmt.Errorf("malformed token")
+	}
+	enc := base64.RawURLEncoding
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return jwtClaims{}, fmt.Errorf("header: %w", err)
+	}
+	sig, err := enc.DecodeString(parts[2])
+	if err != nil {
+		return jwtClaims{}, fmt.Errorf("signature: malformed")
+	}
+	if err := j.verifySignature(header, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return jwtClaims{}, err
+	}
+
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return jwtClaims{}, fmt.Errorf("claims: %w", err)
+	}
+	if err := j.checkClaims(claims); err != nil {
+		return jwtClaims{}, err
+	}
+	return claims, nil
+}
+
+func decodeSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return fmt.Errorf("malformed")
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("malformed")
+	}
+	return nil
+}
+
+func (j *JWT) verifySignature(h jwtHeader, signed, sig []byte) error {
+	// Try every key that may have produced the token; with a kid that is
+	// normally exactly one.
+	matched := false
+	for _, k := range j.keys {
+		if h.Kid != "" && k.kid != h.Kid {
+			continue
+		}
+		if k.alg != "" && k.alg != h.Alg {
+			continue
+		}
+		ok, supported := verifyWith(k.pub, h.Alg, signed, sig)
+		if !supported {
+			continue
+		}
+		matched = true
+		if ok {
+			return nil
+		}
+	}
+	if !matched {
+		return fmt.Errorf("no key for alg %q and kid %q", h.Alg, h.Kid)
+	}
+	return fmt.Errorf("signature verification failed")
+}
+
+// algHashes maps the RSA and ECDSA algorithm names to their digest.
+var algHashes = map[string]crypto.Hash{
+	"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+	"PS256": crypto.SHA256, "PS384": crypto.SHA384, "PS512": crypto.SHA512,
+	"ES256": crypto.SHA256, "ES384": crypto.SHA384, "ES512": crypto.SHA512,
+}
+
+// esCurves is the curve each ECDSA algorithm is defined for (RFC 7518 3.4).
+var esCurves = map[string]elliptic.Curve{
+	"ES256": elliptic.P256(), "ES384": elliptic.P384(), "ES512": elliptic.P521(),
+}
+
+// verifyWith checks sig with pub. supported is false if alg cannot be used
+// with that kind of key (this includes "none").
+func verifyWith(pub crypto.PublicKey, alg string, signed, sig []byte) (ok, supported bool) {
+	if k, isEd := pub.(ed25519.PublicKey); isEd {
+		if alg != "EdDSA" {
+			return false, false
+		}
+		return ed25519.Verify(k, signed, sig), true
+	}
+
+	hash, known := algHashes[alg]
+	if !known {
+		return false, false
+	}
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		switch alg[:2] {
+		case "RS":
+			return rsa.VerifyPKCS1v15(k, hash, digest, sig) == nil, true
+		case "PS":
+			return rsa.VerifyPSS(k, hash, digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil, true
+		}
+	case *ecdsa.PublicKey:
+		if esCurves[alg] != k.Curve {
+			return false, false
+		}
+		// The signature is R || S, each padded to the curve size.
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return false, true
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		return ecdsa.Verify(k, digest, r, s), true
+	}
+	return false, false
+}
+
+func (j *JWT) checkClaims(c jwtClaims) error {
+	now := j.now()
+	if c.ExpiresAt == nil {
+		return fmt.Errorf("exp claim is required")
+	}
+	if now.After(numericDate(*c.ExpiresAt).Add(j.cfg.Leeway)) {
+		return fmt.Errorf("token expired")
+	}
+	if c.NotBefore != nil && now.Add(j.cfg.Leeway).Before(numericDate(*c.NotBefore)) {
+		return fmt.Errorf("token not valid yet")
+	}
+	if c.Subject == "" {
+		return fmt.Errorf("sub claim is required")
+	}
+	if j.cfg.Issuer != "" && c.Issuer != j.cfg.Issuer {
+		return fmt.Errorf("unexpected issuer")
+	}
+	if j.cfg.Audience != "" && !c.hasAudience(j.cfg.Audience) {
+		return fmt.Errorf("unexpected audience")
+	}
+	return nil
+}
+
+func numericDate(v float64) time.Time {
+	sec := int64(v)
+	return time.Unix(sec, int64((v-float64(sec))*1e9))
+}
+
+// hasAudience reports whether aud (a string or an array of strings) contains want.
+func (c jwtClaims) hasAudience(want string) bool {
+	var one string
+	if json.Unmarshal(c.Audience, &one) == nil {
+		return one == want
+	}
+	var many []string
+	if json.Unmarshal(c.Audience, &many) == nil {
+		for _, a := range many {
+			if a == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// scopes returns the granted scopes from `scope` (RFC 8693) or, if that is
+// empty, `scp` (a string or an array, as issued by some providers).
+func (c jwtClaims) scopes() []string {
+	if c.Scope != "" {
+		return strings.Fields(c.Scope)
+	}
+	var one string
+	if json.Unmarshal(c.Scp, &one) == nil {
+		return strings.Fields(one)
+	}
+	var many []string
+	_ = json.Unmarshal(c.Scp, &many)
+	return many
+}
@@ -0,0 +1,185 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testKeys struct {
+	ec  *ecdsa.PrivateKey
+	ed  ed25519.PrivateKey
+	rsa *rsa.PrivateKey
+}
+
+func newTestKeys(t *testing.T) testKeys {
+	t.Helper()
+	ec, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ec: %v", err)
+	}
+	_, ed, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ed25519: %v", err)
+	}
+	rk, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate rsa: %v", err)
+	}
+	return testKeys{ec: ec, ed: ed, rsa: rk}
+}
+
+// writeJWKS writes the public halves of keys as a JWKS file.
+func writeJWKS(t *testing.T, keys testKeys) string {
+	t.Helper()
+	b64 := base64.RawURLEncoding.EncodeToString
+	doc := map[string]any{"keys": []map[string]string{
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": b64(keys.ec.X.FillBytes(make([]byte, 32))), "y": b64(keys.ec.Y.FillBytes(make([]byte, 32)))},
+		{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": b64(keys.ed.Public().(ed25519.PublicKey))},
+		{"kty": "RSA", "kid": "rsa", "alg": "RS256", "n": b64(keys.rsa.N.Bytes()), "e": b64(big.NewInt(int64(keys.rsa.E)).Bytes())},
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": "AQAB", "e": "AQAB"},
+	}}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("marshal jwks: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatalf("write jwks: %v", err)
+	}
+	return path
+}
+
+func signJWT(t *testing.T, keys testKeys, alg, kid string, claims map[string]any) string {
+	t.Helper()
+	enc := base64.RawURLEncoding
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := enc.EncodeToString(header) + "." + enc.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var sig []byte
+	var err error
+	switch alg {
+	case "ES256":
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, keys.ec, digest[:])
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	case "EdDSA":
+		sig = ed25519.Sign(keys.ed, []byte(signed))
+	case "RS256":
+		sig, err = rsa.SignPKCS1v15(rand.Reader, keys.rsa, crypto.SHA256, digest[:])
+	case "none":
+	default:
+		t.Fatalf("unsupported alg %q", alg)
+	}
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return signed + "." + enc.EncodeToString(sig)
+}
+
+func bearer(token string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/api/readings", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func TestJWT_Authenticate(t *testing.T) {
+	t.Parallel()
+
+	keys := newTestKeys(t)
+	j, err := LoadJWT(writeJWKS(t, keys), JWTConfig{Issuer: "https://idp.example", Audience: "meterusage"})
+	if err != nil {
+		t.Fatalf("LoadJWT: %v", err)
+	}
+	now := time.Now()
+	claims := map[string]any{
+		"sub":   "billing-job",
+		"iss":   "https://idp.example",
+		"aud":   []string{"other", "meterusage"},
+		"exp":   now.Add(time.Hour).Unix(),
+		"scope": "readings:read readings:write",
+	}
+
+	for _, tc := range []struct{ alg, kid string }{{"ES256", "ec"}, {"EdDSA", "ed"}, {"RS256", "rsa"}, {"ES256", ""}} {
+		p, err := j.Authenticate(bearer(signJWT(t, keys, tc.alg, tc.kid, claims)))
+		if err != nil {
+			t.Fatalf("%s kid=%q: %v", tc.alg, tc.kid, err)
+		}
+		if p.Subject != "billing-job" || p.Method != "jwt" || !p.HasScope(ScopeWrite) {
+			t.Fatalf("%s: unexpected principal %+v", tc.alg, p)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/readings", nil)
+	if _, err := j.Authenticate(req); !errors.Is(err, ErrNoCredentials) {
+		t.Fatalf("no header: expected ErrNoCredentials, got %v", err)
+	}
+}
+
+func TestJWT_Rejects(t *testing.T) {
+	t.Parallel()
+
+	keys := newTestKeys(t)
+	j, err := LoadJWT(writeJWKS(t, keys), JWTConfig{Issuer: "https://idp.example", Audience: "meterusage"})
+	if err != nil {
+		t.Fatalf("LoadJWT: %v", err)
+	}
+	now := time.Now()
+	valid := func() map[string]any {
+		return map[string]any{
+			"sub": "billing-job",
+			"iss": "https://idp.example",
+			"aud": "meterusage",
+			"exp": now.Add(time.Hour).Unix(),
+		}
+	}
+	with := func(k string, v any) map[string]any {
+		c := valid()
+		if v == nil {
+			delete(c, k)
+		} else {
+			c[k] = v
+		}
+		return c
+	}
+
+	good := signJWT(t, keys, "ES256", "ec", valid())
+	parts := strings.Split(good, ".")
+	forged := parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"admin","exp":9999999999}`)) + "." + parts[2]
+
+	for name, token := range map[string]string{
+		"expired":          signJWT(t, keys, "ES256", "ec", with("exp", now.Add(-time.Hour).Unix())),
+		"no exp":           signJWT(t, keys, "ES256", "ec", with("exp", nil)),
+		"not yet valid":    signJWT(t, keys, "ES256", "ec", with("nbf", now.Add(time.Hour).Unix())),
+		"no sub":           signJWT(t, keys, "ES256", "ec", with("sub", nil)),
+		"wrong issuer":     signJWT(t, keys, "ES256", "ec", with("iss", "https://evil.example")),
+		"wrong audience":   signJWT(t, keys, "ES256", "ec", with("aud", "other")),
+		"alg none":         signJWT(t, keys, "none", "ec", valid()),
+		"alg not for key":  signJWT(t, keys, "EdDSA", "rsa", valid()),
+		"unknown kid":      signJWT(t, keys, "ES256", "missing", valid()),
+		"forged claims":    forged,
+		"malformed":        "not-a-jwt",
+		"truncated header": "e30..",
+	} {
+		if _, err := j.Authenticate(bearer(token)); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("%s: expected ErrInvalidCredentials, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+// Package config holds the settings of both servers. They come from, in
+// increasing order of precedence: built-in defaults, a YAML file shared by
+// both servers, environment variables and command-line flags.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/milad/spectral/internal/repo/csvrepo"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the layout of the configuration file. Each server reads the
+// shared sections and its own; the other server's section is ignored.
+type Config struct {
+	Logging Logging     `yaml:"logging"`
+	Tracing Tracing     `yaml:"tracing"`
+	GRPC    GRPCServer  `yaml:"grpc"`
+	HTTP    HTTPGateway `yaml:"http"`
+}
+
+type Logging struct {
+	Format               string  `yaml:"format"`
+	Level                string  `yaml:"level"`
+	AccessLogSampleRatio float64 `yaml:"access_log_sample_ratio"`
+}
+
+type Tracing struct {
+	Exporter    string  `yaml:"exporter"`
+	File        string  `yaml:"file"`
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+// GRPCServer configures cmd/grpcserver.
+type GRPCServer struct {
+	Addr         string      `yaml:"addr"`
+	MetricsAddr  string      `yaml:"metrics_addr"`
+	AdminAddr    string      `yaml:"admin_addr"`
+	Store        string      `yaml:"store"`
+	CSV          CSVStore    `yaml:"csv"`
+	SQLite       SQLite      `yaml:"sqlite"`
+	PageTokenKey string      `yaml:"page_token_key"`
+	TLS          ServerTLS   `yaml:"tls"`
+	Limits       GRPCLimits  `yaml:"limits"`
+	Quality      GRPCQuality `yaml:"quality"`
+	Readiness    Readiness   `yaml:"readiness"`
+}
+
+type CSVStore struct {
+	Path             string        `yaml:"path"`
+	TimeZone         string        `yaml:"time_zone"`
+	WatchInterval    time.Duration `yaml:"watch_interval"`
+	Parallelism      int           `yaml:"parallelism"`
+	ProgressInterval time.Duration `yaml:"progress_interval"`
+	Schema           CSVSchema     `yaml:"schema"`
+}
+
+// CSVSchema describes the layout of the CSV file; see csvrepo.Schema.
+type CSVSchema struct {
+	Header        bool     `yaml:"header"`
+	Delimiter     string   `yaml:"delimiter"`
+	TimeColumn    string   `yaml:"time_column"`
+	UsageColumn   string   `yaml:"usage_column"`
+	MeterIDColumn string   `yaml:"meter_id_column"`
+	TimeLayouts   []string `yaml:"time_layouts"`
+	DecimalComma  bool     `yaml:"decimal_comma"`
+	Multiplier    float64  `yaml:"multiplier"`
+}
+
+type SQLite struct {
+	Path           string        `yaml:"path"`
+	ReadConns      int           `yaml:"read_conns"`
+	IdempotencyTTL time.Duration `yaml:"idempotency_ttl"`
+}
+
+type ServerTLS struct {
+	Cert           string   `yaml:"cert"`
+	Key            string   `yaml:"key"`
+	ClientCA       string   `yaml:"client_ca"`
+	AllowedClients []string `yaml:"allowed_clients"`
+}
+
+// Readiness decides when the gRPC health service reports SERVING.
+type Readiness struct {
+	MinRows       int           `yaml:"min_rows"`
+	CheckInterval time.Duration `yaml:"check_interval"`
+}
+
+// GRPCLimits bounds the size of a single request; see service.Limits.
+type GRPCLimits struct {
+	MaxPageSize        int           `yaml:"max_page_size"`
+	MaxUnpagedRange    time.Duration `yaml:"max_unpaged_range"`
+	MaxAppendBatchSize int           `yaml:"max_append_batch_size"`
+	MaxStreamChunkSize int           `yaml:"max_stream_chunk_size"`
+}
+
+// GRPCQuality tunes the quality flags of readings; see service.QualityOptions.
+type GRPCQuality struct {
+	Interval       time.Duration `yaml:"interval"`
+	SpikeWindow    time.Duration `yaml:"spike_window"`
+	SpikeThreshold float64       `yaml:"spike_threshold"`
+}
+
+// HTTPGateway configures cmd/httpserver.
+type HTTPGateway struct {
+	Addr       string       `yaml:"addr"`
+	AdminAddr  string       `yaml:"admin_addr"`
+	Upstream   Upstream     `yaml:"upstream"`
+	Auth       Auth         `yaml:"auth"`
+	RateLimits string       `yaml:"rate_limits"`
+	Timeouts   HTTPTimeouts `yaml:"timeouts"`
+	Cache      HTTPCache    `yaml:"cache"`
+}
+
+// Upstream is the gateway's connection to the gRPC servers. Target is one
+// host:port or several (see upstream.Target).
+type Upstream struct {
+	Target      string           `yaml:"target"`
+	TLS         bool             `yaml:"tls"`
+	CA          string           `yaml:"ca"`
+	Cert        string           `yaml:"cert"`
+	Key         string           `yaml:"key"`
+	ServerName  string           `yaml:"server_name"`
+	WaitTimeout time.Duration    `yaml:"wait_timeout"`
+	Balancer    UpstreamBalancer `yaml:"balancer"`
+	Retry       UpstreamRetry    `yaml:"retry"`
+	Breaker     UpstreamBreaker  `yaml:"breaker"`
+}
+
+// UpstreamBalancer spreads calls over the backends; see upstream.Balancing.
+type UpstreamBalancer struct {
+	Policy       string        `yaml:"policy"`
+	HealthCheck  bool          `yaml:"health_check"`
+	FileInterval time.Duration `yaml:"file_interval"`
+}
+
+// UpstreamRetry applies to idempotent reads; see upstream.RetryPolicy.
+type UpstreamRetry struct {
+	MaxAttempts    int           `yaml:"max_attempts"`
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+	Multiplier     float64       `yaml:"multiplier"`
+	Codes          []string      `yaml:"codes"`
+	HedgeDelay     time.Duration `yaml:"hedge_delay"`
+}
+
+// UpstreamBreaker configures the circuit breaker; see upstream.BreakerConfig.
+type UpstreamBreaker struct {
+	FailureThreshold int           `yaml:"failure_threshold"`
+	OpenTimeout      time.Duration `yaml:"open_timeout"`
+}
+
+type Auth struct {
+	APIKeysFile string `yaml:"api_keys_file"`
+	JWKSFile    string `yaml:"jwks_file"`
+	JWTIssuer   string `yaml:"jwt_issuer"`
+	JWTAudience string `yaml:"jwt_audience"`
+}
+
+type HTTPTimeouts struct {
+	// Upstream bounds each unary gRPC call made for a request.
+	Upstream time.Duration `yaml:"upstream"`
+	// Stream bounds a whole streaming response or export.
+	Stream time.Duration `yaml:"stream"`
+	// StreamWrite bounds writing one chunk of a streaming response.
+	StreamWrite time.Duration `yaml:"stream_write"`
+	ReadHeader  time.Duration `yaml:"read_header"`
+	Read        time.Duration `yaml:"read"`
+	Write       time.Duration `yaml:"write"`
+	Idle        time.Duration `yaml:"idle"`
+}
+
+// HTTPCache bounds the gateway's response cache; see httpserver.CacheLimits.
+// A zero MaxEntries, MaxBytes or TTL disables it.
+type HTTPCache struct {
+	MaxEntries int           `yaml:"max_entries"`
+	MaxBytes   int           `yaml:"max_bytes"`
+	TTL        time.Duration `yaml:"ttl"`
+	VersionTTL time.Duration `yaml:"version_ttl"`
+}
+
+// Default returns the built-in defaults.
+func Default() Config {
+	return Config{
+		Logging: Logging{Format: "text", Level: "info", AccessLogSampleRatio: 1},
+		Tracing: Tracing{Exporter: "none", SampleRatio: 1},
+		GRPC: GRPCServer{
+			Addr:        ":9090",
+			MetricsAddr: ":9091",
+			Store:       "csv",
+			CSV: CSVStore{
+				Path:             "meterusage.csv",
+				TimeZone:         "UTC",
+				WatchInterval:    5 * time.Second,
+				ProgressInterval: 10 * time.Second,
+				Schema:           CSVSchema(csvrepo.DefaultSchema()),
+			},
+			SQLite: SQLite{
+				Path:           "meterusage.db",
+				IdempotencyTTL: 24 * time.Hour,
+			},
+			Limits: GRPCLimits{
+				MaxPageSize:        5_000,
+				MaxUnpagedRange:    31 * 24 * time.Hour,
+				MaxAppendBatchSize: 5_000,
+				MaxStreamChunkSize: 5_000,
+			},
+			Quality:   GRPCQuality{Interval: 15 * time.Minute, SpikeWindow: 3 * time.Hour, SpikeThreshold: 5},
+			Readiness: Readiness{MinRows: 1, CheckInterval: time.Second},
+		},
+		HTTP: HTTPGateway{
+			Addr:      ":8080",
+			AdminAddr: "127.0.0.1:8081",
+			Upstream: Upstream{
+				Target:      "127.0.0.1:9090",
+				WaitTimeout: 20 * time.Second,
+				Balancer:    UpstreamBalancer{Policy: "round_robin", HealthCheck: true, FileInterval: 5 * time.Second},
+				Retry: UpstreamRetry{
+					MaxAttempts:    3,
+					InitialBackoff: 50 * time.Millisecond,
+					MaxBackoff:     time.Second,
+					Multiplier:     2,
+					Codes:          []string{"UNAVAILABLE"},
+				},
+				Breaker: UpstreamBreaker{FailureThreshold: 5, OpenTimeout: 10 * time.Second},
+			},
+			Timeouts: HTTPTimeouts{
+				Upstream:    5 * time.Second,
+				Stream:      10 * time.Minute,
+				StreamWrite: 15 * time.Second,
+				ReadHeader:  5 * time.Second,
+				Read:        15 * time.Second,
+				Write:       15 * time.Second,
+				Idle:        60 * time.Second,
+			},
+			Cache: HTTPCache{
+				MaxEntries: 1_000,
+				MaxBytes:   64 << 20,
+				TTL:        30 * time.Second,
+				VersionTTL: time.Second,
+			},
+		},
+	}
+}
+
+// LoadFile overlays the YAML file at path onto c. Keys missing from the file
+// keep their current values; unknown keys are an error, so that a typo does
+// not silently fall back to a default.
+func (c *Config) LoadFile(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parse config %s: %w", path, err)
+	}
+	return nil
+}
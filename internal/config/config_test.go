@@ -0,0 +1,180 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return path
+}
+
+func envMap(m map[string]string) func(string) string {
+	return func(k string) string { return m[k] }
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	t.Parallel()
+
+	path := writeFile(t, `
+logging:
+  level: debug
+grpc:
+  addr: ":7000"
+  store: sqlite
+  limits:
+    max_page_size: 100
+  tls:
+    allowed_clients: [a, b]
+`)
+	env := envMap(map[string]string{
+		FileEnv:       path,
+		"GRPC_ADDR":   ":7001",
+		"SQLITE_PATH": "/data/env.db",
+	})
+	cfg, err := Load(GRPC, "grpcserver", []string{"-sqlite", "/data/flag.db", "-tls-cert", "c.pem", "-tls-key", "k.pem", "-tls-client-ca", "ca.pem"}, env)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if got, want := cfg.Logging.Level, "debug"; got != want {
+		t.Fatalf("file: level=%q want %q", got, want)
+	}
+	if got, want := cfg.GRPC.Limits.MaxPageSize, 100; got != want {
+		t.Fatalf("file: max_page_size=%d want %d", got, want)
+	}
+	if got, want := cfg.GRPC.Limits.MaxStreamChunkSize, 5_000; got != want {
+		t.Fatalf("default kept: max_stream_chunk_size=%d want %d", got, want)
+	}
+	if got, want := cfg.GRPC.Addr, ":7001"; got != want {
+		t.Fatalf("env over file: addr=%q want %q", got, want)
+	}
+	if got, want := cfg.GRPC.SQLite.Path, "/data/flag.db"; got != want {
+		t.Fatalf("flag over env: sqlite=%q want %q", got, want)
+	}
+	if got, want := strings.Join(cfg.GRPC.TLS.AllowedClients, ","), "a,b"; got != want {
+		t.Fatalf("allowed_clients=%q want %q", got, want)
+	}
+}
+
+func TestLoad_HTTPDefaultsAndLegacyEnv(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := Load(HTTP, "httpserver", []string{"-grpc", "grpc:9090", "-upstream-timeout", "2s"}, envMap(map[string]string{
+		"GRPC_WAIT_TIMEOUT_MS": "1500",
+		"GRPC_TLS":             "true",
+	}))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got, want := cfg.HTTP.Upstream.Target, "grpc:9090"; got != want {
+		t.Fatalf("target=%q want %q", got, want)
+	}
+	if got, want := cfg.HTTP.Upstream.WaitTimeout, 1500*time.Millisecond; got != want {
+		t.Fatalf("wait_timeout=%s want %s", got, want)
+	}
+	if !cfg.HTTP.Upstream.TLS {
+		t.Fatalf("expected GRPC_TLS=true to enable TLS")
+	}
+	if got, want := cfg.HTTP.Timeouts.Upstream, 2*time.Second; got != want {
+		t.Fatalf("upstream timeout=%s want %s", got, want)
+	}
+	if got, want := cfg.HTTP.Timeouts.Stream, 10*time.Minute; got != want {
+		t.Fatalf("stream timeout=%s want %s", got, want)
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		server Server
+		file   string
+		env    map[string]string
+		args   []string
+		want   []string
+	}{
+		"unknown key": {
+			server: GRPC,
+			file:   "grpc:\n  adress: \":1\"\n",
+			want:   []string{"field adress not found"},
+		},
+		"invalid env": {
+			server: GRPC,
+			env:    map[string]string{"CSV_WATCH_INTERVAL": "often"},
+			want:   []string{`CSV_WATCH_INTERVAL="often"`},
+		},
+		"flag of the other server": {
+			server: HTTP,
+			args:   []string{"-store", "sqlite"},
+			want:   []string{"-store"},
+		},
+		"every problem is reported": {
+			server: GRPC,
+			file:   "logging:\n  format: xml\ngrpc:\n  store: mongo\n  csv:\n    time_zone: Mars/Base\n  limits:\n    max_page_size: -1\n",
+			args:   []string{"-tls-allowed-clients", "gw", "-ready-check-interval", "0s", "-csv-header=false", "-csv-parallelism", "-2", "-quality-interval", "0s"},
+			want: []string{
+				"logging.format",
+				"grpc.store",
+				"grpc.csv.time_zone",
+				"grpc.limits.max_page_size",
+				"grpc.tls.allowed_clients: requires grpc.tls.client_ca",
+				"grpc.readiness.check_interval",
+				"grpc.csv.schema: csv schema: time column \"time\": files without a header need column positions",
+				"grpc.csv.parallelism",
+				"grpc.quality.interval",
+			},
+		},
+		"http": {
+			server: HTTP,
+			args:   []string{"-grpc-cert", "c.pem", "-stream-timeout", "0s", "-cache-ttl", "-1s", "-grpc-lb-policy", "random"},
+			want:   []string{"http.upstream.cert and http.upstream.key", "http.timeouts.stream", "http.cache.ttl", "http.upstream.balancer.policy"},
+		},
+	} {
+		env := tc.env
+		if tc.file != "" {
+			env = map[string]string{FileEnv: writeFile(t, tc.file)}
+		}
+		_, err := Load(tc.server, "test", tc.args, envMap(env))
+		if err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+		for _, want := range tc.want {
+			if !strings.Contains(err.Error(), want) {
+				t.Fatalf("%s: error %q does not mention %q", name, err, want)
+			}
+		}
+	}
+}
+
+func TestLoad_Help(t *testing.T) {
+	t.Parallel()
+
+	if _, err := Load(GRPC, "test", []string{"-h"}, envMap(nil)); !errors.Is(err, flag.ErrHelp) {
+		t.Fatalf("err=%v want flag.ErrHelp", err)
+	}
+}
+
+func TestExampleFileMatchesDefaults(t *testing.T) {
+	t.Parallel()
+
+	cfg := Default()
+	if err := cfg.LoadFile("../../config.example.yaml"); err != nil {
+		t.Fatalf("LoadFile: %v", err)
+	}
+	cfg.GRPC.TLS.AllowedClients = nil // [] in the file
+	if got, want := cfg, Default(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("config.example.yaml differs from Default():\n got %+v\nwant %+v", got, want)
+	}
+}
@@ -0,0 +1,251 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Server selects the settings a binary accepts.
+type Server int
+
+const (
+	GRPC Server = iota + 1
+	HTTP
+)
+
+// FileEnv names the environment variable that can give the configuration
+// file instead of -config.
+const FileEnv = "CONFIG_FILE"
+
+// setting ties one Config field to its flag and environment variable. An
+// empty flag or env means the setting has none.
+type setting struct {
+	server Server // 0: both servers
+	flag   string
+	env    string
+	usage  string
+	value  func(*Config) flag.Value
+}
+
+var settings = []setting{
+	{0, "log-format", "LOG_FORMAT", "log format: text or json", func(c *Config) flag.Value { return (*stringValue)(&c.Logging.Format) }},
+	{0, "log-level", "LOG_LEVEL", "minimum log level: debug, info, warn or error", func(c *Config) flag.Value { return (*stringValue)(&c.Logging.Level) }},
+	{0, "access-log-sample-ratio", "ACCESS_LOG_SAMPLE_RATIO", "fraction of successful requests written to the access log; failures are always logged", func(c *Config) flag.Value { return (*floatValue)(&c.Logging.AccessLogSampleRatio) }},
+	{0, "trace-exporter", "TRACE_EXPORTER", "span exporter: none, otlp (OTEL_EXPORTER_OTLP_* env), stdout or file", func(c *Config) flag.Value { return (*stringValue)(&c.Tracing.Exporter) }},
+	{0, "trace-file", "TRACE_FILE", "file receiving spans with -trace-exporter file", func(c *Config) flag.Value { return (*stringValue)(&c.Tracing.File) }},
+	{0, "trace-sample-ratio", "TRACE_SAMPLE_RATIO", "fraction of new traces to record", func(c *Config) flag.Value { return (*floatValue)(&c.Tracing.SampleRatio) }},
+
+	{GRPC, "addr", "GRPC_ADDR", "listen address", func(c *Config) flag.Value { return (*stringValue)(&c.GRPC.Addr) }},
+	{GRPC, "metrics-addr", "METRICS_ADDR", "listen address of the Prometheus /metrics endpoint; empty disables it", func(c *Config) flag.Value { return (*stringValue)(&c.GRPC.MetricsAddr) }},
+	{GRPC, "admin-addr", "GRPC_ADMIN_ADDR", "listen address of the admin endpoints (/loglevel); empty disables them", func(c *Config) flag.Value { return (*stringValue)(&c.GRPC.AdminAddr) }},
+	{GRPC, "store", "STORE", "reading store: csv (in-memory, from -csv) or sqlite", func(c *Config) flag.Value { return (*stringValue)(&c.GRPC.Store) }},
+	{GRPC, "csv", "CSV_PATH", "path to meterusage.csv or a Green Button XML feed, optionally .gz or .zst compressed; or a directory or glob pattern of such files", func(c *Config) flag.Value { return (*stringValue)(&c.GRPC.CSV.Path) }},
+	{GRPC, "csv-tz", "CSV_TZ", "IANA time zone of the wall-clock times in -csv", func(c *Config) flag.Value { return (*stringValue)(&c.GRPC.CSV.TimeZone) }},
+	{GRPC, "watch", "CSV_WATCH_INTERVAL", "how often to check -csv for changes (with -store csv); 0 disables, SIGHUP always reloads", func(c *Config) flag.Value { return (*durationValue)(&c.GRPC.CSV.WatchInterval) }},
+	{GRPC, "csv-parallelism", "CSV_PARALLELISM", "goroutines parsing -csv; 0 means one per CPU", func(c *Config) flag.Value { return (*intValue)(&c.GRPC.CSV.Parallelism) }},
+	{GRPC, "csv-progress-interval", "CSV_PROGRESS_INTERVAL", "how often to log the progress of loading -csv; 0 disables", func(c *Config) flag.Value { return (*durationValue)(&c.GRPC.CSV.ProgressInterval) }},
+	{GRPC, "csv-header", "CSV_HEADER", "the first row of -csv names the columns; without it, columns are given by position", func(c *Config) flag.Value { return (*boolValue)(&c.GRPC.CSV.Schema.Header) }},
+	{GRPC, "csv-delimiter", "CSV_DELIMITER", "field separator of -csv: one character, or tab", func(c *Config) flag.Value { return (*stringValue)(&c.GRPC.CSV.Schema.Delimiter) }},
+	{GRPC, "csv-time-column", "CSV_TIME_COLUMN", "header name or 1-based position of the time column of -csv", func(c *Config) flag.Value { return (*stringValue)(&c.GRPC.CSV.Schema.TimeColumn) }},
+	{GRPC, "csv-usage-column", "CSV_USAGE_COLUMN", "header name or 1-based position of the usage column of -csv", func(c *Config) flag.Value { return (*stringValue)(&c.GRPC.CSV.Schema.UsageColumn) }},
+	{GRPC, "csv-meter-column", "CSV_METER_COLUMN", "header name or 1-based position of the meter ID column of -csv; empty for none", func(c *Config) flag.Value { return (*stringValue)(&c.GRPC.CSV.Schema.MeterIDColumn) }},
+	{GRPC, "csv-time-layouts", "CSV_TIME_LAYOUTS", "comma-separated time layouts of -csv, tried in order: Go layouts, unix, unix_ms or rfc3339", func(c *Config) flag.Value { return (*listValue)(&c.GRPC.CSV.Schema.TimeLayouts) }},
+	{GRPC, "csv-decimal-comma", "CSV_DECIMAL_COMMA", "usage in -csv is written 1.234,5", func(c *Config) flag.Value { return (*boolValue)(&c.GRPC.CSV.Schema.DecimalComma) }},
+	{GRPC, "csv-multiplier", "CSV_MULTIPLIER", "factor applied to usage in -csv, e.g. 0.001 for Wh", func(c *Config) flag.Value { return (*floatValue)(&c.GRPC.CSV.Schema.Multiplier) }},
+	{GRPC, "sqlite", "SQLITE_PATH", "path to the SQLite database (with -store sqlite)", func(c *Config) flag.Value { return (*stringValue)(&c.GRPC.SQLite.Path) }},
+	{GRPC, "sqlite-read-conns", "SQLITE_READ_CONNS", "connections reading the SQLite database at once; 0 means one per CPU", func(c *Config) flag.Value { return (*intValue)(&c.GRPC.SQLite.ReadConns) }},
+	{GRPC, "sqlite-idempotency-ttl", "SQLITE_IDEMPOTENCY_TTL", "how long the SQLite store remembers an append's idempotency key", func(c *Config) flag.Value { return (*durationValue)(&c.GRPC.SQLite.IdempotencyTTL) }},
+	{GRPC, "page-token-key", "PAGE_TOKEN_KEY", "secret used to sign page tokens; shared by all replicas (default: random per process)", func(c *Config) flag.Value { return (*stringValue)(&c.GRPC.PageTokenKey) }},
+	{GRPC, "tls-cert", "TLS_CERT_FILE", "PEM certificate chain; enables TLS", func(c *Config) flag.Value { return (*stringValue)(&c.GRPC.TLS.Cert) }},
+	{GRPC, "tls-key", "TLS_KEY_FILE", "PEM private key for -tls-cert", func(c *Config) flag.Value { return (*stringValue)(&c.GRPC.TLS.Key) }},
+	{GRPC, "tls-client-ca", "TLS_CLIENT_CA_FILE", "PEM CA bundle that client certificates must chain to; enables mutual TLS", func(c *Config) flag.Value { return (*stringValue)(&c.GRPC.TLS.ClientCA) }},
+	{GRPC, "tls-allowed-clients", "TLS_ALLOWED_CLIENTS", "comma-separated client certificate identities (URI/DNS SAN or CN) allowed to call MeterUsageService", func(c *Config) flag.Value { return (*listValue)(&c.GRPC.TLS.AllowedClients) }},
+	{GRPC, "max-page-size", "MAX_PAGE_SIZE", "largest page_size accepted by ListReadings", func(c *Config) flag.Value { return (*intValue)(&c.GRPC.Limits.MaxPageSize) }},
+	{GRPC, "max-unpaged-range", "MAX_UNPAGED_RANGE", "longest time range ListReadings returns without pagination, and GetQualityReport covers", func(c *Config) flag.Value { return (*durationValue)(&c.GRPC.Limits.MaxUnpagedRange) }},
+	{GRPC, "max-append-batch-size", "MAX_APPEND_BATCH_SIZE", "most readings accepted by one AppendReadings call", func(c *Config) flag.Value { return (*intValue)(&c.GRPC.Limits.MaxAppendBatchSize) }},
+	{GRPC, "max-stream-chunk-size", "MAX_STREAM_CHUNK_SIZE", "largest chunk_size accepted by StreamReadings", func(c *Config) flag.Value { return (*intValue)(&c.GRPC.Limits.MaxStreamChunkSize) }},
+	{GRPC, "quality-interval", "QUALITY_INTERVAL", "expected time between two readings of a meter; readings further apart are flagged gap_before", func(c *Config) flag.Value { return (*durationValue)(&c.GRPC.Quality.Interval) }},
+	{GRPC, "quality-spike-window", "QUALITY_SPIKE_WINDOW", "how far before and after a reading the readings it is compared to for spikes lie; 0 disables spike detection", func(c *Config) flag.Value { return (*durationValue)(&c.GRPC.Quality.SpikeWindow) }},
+	{GRPC, "quality-spike-threshold", "QUALITY_SPIKE_THRESHOLD", "modified z-score above which a reading is flagged as a spike; 0 disables spike detection", func(c *Config) flag.Value { return (*floatValue)(&c.GRPC.Quality.SpikeThreshold) }},
+	{GRPC, "ready-min-rows", "READY_MIN_ROWS", "readings the dataset must hold before the health service reports SERVING", func(c *Config) flag.Value { return (*intValue)(&c.GRPC.Readiness.MinRows) }},
+	{GRPC, "ready-check-interval", "READY_CHECK_INTERVAL", "how often the dataset is checked for the health service", func(c *Config) flag.Value { return (*durationValue)(&c.GRPC.Readiness.CheckInterval) }},
+
+	{HTTP, "addr", "HTTP_ADDR", "listen address", func(c *Config) flag.Value { return (*stringValue)(&c.HTTP.Addr) }},
+	{HTTP, "admin-addr", "ADMIN_ADDR", "listen address of the admin endpoints (/loglevel); empty disables them", func(c *Config) flag.Value { return (*stringValue)(&c.HTTP.AdminAddr) }},
+	{HTTP, "grpc", "GRPC_TARGET", "gRPC target: host:port, a comma-separated list of them, dns:///host:port or file:///path (one host:port per line)", func(c *Config) flag.Value { return (*stringValue)(&c.HTTP.Upstream.Target) }},
+	{HTTP, "grpc-tls", "GRPC_TLS", "connect to gRPC over TLS (implied by -grpc-ca and -grpc-cert)", func(c *Config) flag.Value { return (*boolValue)(&c.HTTP.Upstream.TLS) }},
+	{HTTP, "grpc-ca", "GRPC_TLS_CA_FILE", "PEM CA bundle to verify the gRPC server with (default: system roots)", func(c *Config) flag.Value { return (*stringValue)(&c.HTTP.Upstream.CA) }},
+	{HTTP, "grpc-cert", "GRPC_TLS_CERT_FILE", "PEM client certificate for mutual TLS", func(c *Config) flag.Value { return (*stringValue)(&c.HTTP.Upstream.Cert) }},
+	{HTTP, "grpc-key", "GRPC_TLS_KEY_FILE", "PEM private key for -grpc-cert", func(c *Config) flag.Value { return (*stringValue)(&c.HTTP.Upstream.Key) }},
+	{HTTP, "grpc-server-name", "GRPC_TLS_SERVER_NAME", "name the gRPC server certificate must be valid for (default: host of -grpc)", func(c *Config) flag.Value { return (*stringValue)(&c.HTTP.Upstream.ServerName) }},
+	{HTTP, "grpc-wait-timeout", "GRPC_WAIT_TIMEOUT", "how long to wait at startup for the gRPC server to report SERVING; 0 does not wait", func(c *Config) flag.Value { return (*durationValue)(&c.HTTP.Upstream.WaitTimeout) }},
+	{HTTP, "", "GRPC_WAIT_TIMEOUT_MS", "", func(c *Config) flag.Value { return (*millisValue)(&c.HTTP.Upstream.WaitTimeout) }},
+	{HTTP, "grpc-lb-policy", "GRPC_LB_POLICY", "how calls are spread over the gRPC backends: round_robin or least_request", func(c *Config) flag.Value { return (*stringValue)(&c.HTTP.Upstream.Balancer.Policy) }},
+	{HTTP, "grpc-health-check", "GRPC_HEALTH_CHECK", "send no calls to gRPC backends whose health service is not SERVING", func(c *Config) flag.Value { return (*boolValue)(&c.HTTP.Upstream.Balancer.HealthCheck) }},
+	{HTTP, "grpc-targets-interval", "GRPC_TARGETS_INTERVAL", "how often a file:// gRPC target is read again", func(c *Config) flag.Value { return (*durationValue)(&c.HTTP.Upstream.Balancer.FileInterval) }},
+	{HTTP, "retry-max-attempts", "RETRY_MAX_ATTEMPTS", "attempts of an idempotent gRPC call, the first included; 1 disables retries and hedging", func(c *Config) flag.Value { return (*intValue)(&c.HTTP.Upstream.Retry.MaxAttempts) }},
+	{HTTP, "retry-initial-backoff", "RETRY_INITIAL_BACKOFF", "upper bound of the random wait before the first retry", func(c *Config) flag.Value { return (*durationValue)(&c.HTTP.Upstream.Retry.InitialBackoff) }},
+	{HTTP, "retry-max-backoff", "RETRY_MAX_BACKOFF", "upper bound of the random wait before any retry", func(c *Config) flag.Value { return (*durationValue)(&c.HTTP.Upstream.Retry.MaxBackoff) }},
+	{HTTP, "retry-backoff-multiplier", "RETRY_BACKOFF_MULTIPLIER", "growth of the backoff bound after each retry", func(c *Config) flag.Value { return (*floatValue)(&c.HTTP.Upstream.Retry.Multiplier) }},
+	{HTTP, "retry-codes", "RETRY_CODES", "comma-separated gRPC status codes to retry, e.g. UNAVAILABLE,RESOURCE_EXHAUSTED", func(c *Config) flag.Value { return (*listValue)(&c.HTTP.Upstream.Retry.Codes) }},
+	{HTTP, "hedge-delay", "HEDGE_DELAY", "send another attempt of an idempotent call that has not answered after this long; 0 disables hedging", func(c *Config) flag.Value { return (*durationValue)(&c.HTTP.Upstream.Retry.HedgeDelay) }},
+	{HTTP, "breaker-failure-threshold", "BREAKER_FAILURE_THRESHOLD", "consecutive unavailable or timed-out gRPC calls that open the circuit breaker; 0 disables it", func(c *Config) flag.Value { return (*intValue)(&c.HTTP.Upstream.Breaker.FailureThreshold) }},
+	{HTTP, "breaker-open-timeout", "BREAKER_OPEN_TIMEOUT", "how long the circuit breaker fails calls fast before trying the server again", func(c *Config) flag.Value { return (*durationValue)(&c.HTTP.Upstream.Breaker.OpenTimeout) }},
+	{HTTP, "api-keys", "API_KEYS_FILE", "JSON file of hashed API keys accepted in X-API-Key", func(c *Config) flag.Value { return (*stringValue)(&c.HTTP.Auth.APIKeysFile) }},
+	{HTTP, "jwks", "JWKS_FILE", "JWKS file with the keys that sign accepted bearer tokens", func(c *Config) flag.Value { return (*stringValue)(&c.HTTP.Auth.JWKSFile) }},
+	{HTTP, "jwt-issuer", "JWT_ISSUER", "required iss claim of bearer tokens (optional)", func(c *Config) flag.Value { return (*stringValue)(&c.HTTP.Auth.JWTIssuer) }},
+	{HTTP, "jwt-audience", "JWT_AUDIENCE", "required aud claim of bearer tokens (optional)", func(c *Config) flag.Value { return (*stringValue)(&c.HTTP.Auth.JWTAudience) }},
+	{HTTP, "rate-limits", "RATE_LIMITS", "per-client rate limits as ROUTE=RATE:BURST,... (RATE per second; ROUTE a path or * for other /api/ routes)", func(c *Config) flag.Value { return (*stringValue)(&c.HTTP.RateLimits) }},
+	{HTTP, "upstream-timeout", "UPSTREAM_TIMEOUT", "timeout of each unary gRPC call", func(c *Config) flag.Value { return (*durationValue)(&c.HTTP.Timeouts.Upstream) }},
+	{HTTP, "stream-timeout", "STREAM_TIMEOUT", "timeout of a whole streaming response or export", func(c *Config) flag.Value { return (*durationValue)(&c.HTTP.Timeouts.Stream) }},
+	{HTTP, "cache-max-entries", "CACHE_MAX_ENTRIES", "maximum number of cached /api/readings pages; 0 disables the cache", func(c *Config) flag.Value { return (*intValue)(&c.HTTP.Cache.MaxEntries) }},
+	{HTTP, "cache-max-bytes", "CACHE_MAX_BYTES", "maximum total size of cached pages in bytes; 0 disables the cache", func(c *Config) flag.Value { return (*intValue)(&c.HTTP.Cache.MaxBytes) }},
+	{HTTP, "cache-ttl", "CACHE_TTL", "how long a page is served from the cache; 0 disables the cache", func(c *Config) flag.Value { return (*durationValue)(&c.HTTP.Cache.TTL) }},
+	{HTTP, "cache-version-ttl", "CACHE_VERSION_TTL", "how long the upstream dataset version is trusted before it is checked again", func(c *Config) flag.Value { return (*durationValue)(&c.HTTP.Cache.VersionTTL) }},
+}
+
+// Load builds the configuration of server from the defaults, the file named
+// by -config (or CONFIG_FILE), the environment and the flags in args, and
+// validates it. It returns flag.ErrHelp if args ask for help.
+func Load(server Server, name string, args []string, getenv func(string) string) (Config, error) {
+	// The first pass only finds -config and reports bad or help flags; the
+	// second re-applies the flags on top of the file and the environment.
+	cfg := Default()
+	fs, path := flagSet(server, name, &cfg, getenv(FileEnv))
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	cfg = Default()
+	if *path != "" {
+		if err := cfg.LoadFile(*path); err != nil {
+			return Config{}, err
+		}
+	}
+	if err := applyEnv(server, &cfg, getenv); err != nil {
+		return Config{}, err
+	}
+	fs, _ = flagSet(server, name, &cfg, *path)
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	if err := cfg.Validate(server); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+func flagSet(server Server, name string, cfg *Config, file string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	path := fs.String("config", file, "YAML configuration file (env "+FileEnv+"); environment variables and flags override it")
+	for _, s := range settings {
+		if s.flag == "" || (s.server != 0 && s.server != server) {
+			continue
+		}
+		fs.Var(s.value(cfg), s.flag, s.usage+" (env "+s.env+")")
+	}
+	return fs, path
+}
+
+func applyEnv(server Server, cfg *Config, getenv func(string) string) error {
+	var errs []error
+	for _, s := range settings {
+		if s.server != 0 && s.server != server {
+			continue
+		}
+		if v := getenv(s.env); v != "" {
+			if err := s.value(cfg).Set(v); err != nil {
+				errs = append(errs, fmt.Errorf("invalid %s=%q: %w", s.env, v, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+type stringValue string
+
+func (v *stringValue) Set(s string) error { *v = stringValue(s); return nil }
+func (v *stringValue) String() string     { return string(*v) }
+
+type boolValue bool
+
+func (v *boolValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return errors.New("not a boolean")
+	}
+	*v = boolValue(b)
+	return nil
+}
+func (v *boolValue) String() string   { return strconv.FormatBool(bool(*v)) }
+func (v *boolValue) IsBoolFlag() bool { return true }
+
+type intValue int
+
+func (v *intValue) Set(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return errors.New("not an integer")
+	}
+	*v = intValue(n)
+	return nil
+}
+func (v *intValue) String() string { return strconv.Itoa(int(*v)) }
+
+type floatValue float64
+
+func (v *floatValue) Set(s string) error {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return errors.New("not a number")
+	}
+	*v = floatValue(f)
+	return nil
+}
+func (v *floatValue) String() string { return strconv.FormatFloat(float64(*v), 'g', -1, 64) }
+
+type durationValue time.Duration
+
+func (v *durationValue) Set(s string) error {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return errors.New("not a duration such as 5s or 1m30s")
+	}
+	*v = durationValue(d)
+	return nil
+}
+func (v *durationValue) String() string { return time.Duration(*v).String() }
+
+// millisValue reads a duration given in milliseconds, for older variables.
+type millisValue time.Duration
+
+func (v *millisValue) Set(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return errors.New("not a number of milliseconds")
+	}
+	*v = millisValue(time.Duration(n) * time.Millisecond)
+	return nil
+}
+func (v *millisValue) String() string { return strconv.FormatInt(time.Duration(*v).Milliseconds(), 10) }
+
+// listValue is a comma-separated list.
+type listValue []string
+
+func (v *listValue) Set(s string) error {
+	*v = nil
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*v = append(*v, item)
+		}
+	}
+	return nil
+}
+func (v *listValue) String() string { return strings.Join(*v, ",") }
@@ -0,0 +1,156 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/milad/spectral/internal/repo/csvrepo"
+	"google.golang.org/grpc/codes"
+)
+
+// Validate checks the shared sections and the section of server, returning
+// every problem found rather than only the first. Problems are reported by
+// their key in the configuration file.
+func (c *Config) Validate(server Server) error {
+	v := &validator{}
+	v.check(c.Logging.Format == "text" || c.Logging.Format == "json", "logging.format: must be text or json, got %q", c.Logging.Format)
+	var level slog.Level
+	v.check(level.UnmarshalText([]byte(c.Logging.Level)) == nil, "logging.level: must be debug, info, warn or error, got %q", c.Logging.Level)
+	v.ratio("logging.access_log_sample_ratio", c.Logging.AccessLogSampleRatio)
+
+	switch c.Tracing.Exporter {
+	case "none", "otlp", "stdout":
+	case "file":
+		v.check(c.Tracing.File != "", "tracing.file: required with tracing.exporter file")
+	default:
+		v.fail("tracing.exporter: must be none, otlp, stdout or file, got %q", c.Tracing.Exporter)
+	}
+	v.ratio("tracing.sample_ratio", c.Tracing.SampleRatio)
+
+	switch server {
+	case GRPC:
+		c.GRPC.validate(v)
+	case HTTP:
+		c.HTTP.validate(v)
+	}
+	return v.err()
+}
+
+func (g *GRPCServer) validate(v *validator) {
+	v.check(g.Addr != "", "grpc.addr: required")
+	switch g.Store {
+	case "csv":
+		v.check(g.CSV.Path != "", "grpc.csv.path: required with grpc.store csv")
+	case "sqlite":
+		v.check(g.SQLite.Path != "", "grpc.sqlite.path: required with grpc.store sqlite")
+	default:
+		v.fail("grpc.store: must be csv or sqlite, got %q", g.Store)
+	}
+	if _, err := time.LoadLocation(g.CSV.TimeZone); err != nil {
+		v.fail("grpc.csv.time_zone: %v", err)
+	}
+	v.check(g.SQLite.ReadConns >= 0, "grpc.sqlite.read_conns: must not be negative")
+	v.check(g.SQLite.IdempotencyTTL > 0, "grpc.sqlite.idempotency_ttl: must be positive")
+	v.check(g.CSV.WatchInterval >= 0, "grpc.csv.watch_interval: must not be negative")
+	v.check(g.CSV.Parallelism >= 0, "grpc.csv.parallelism: must not be negative")
+	v.check(g.CSV.ProgressInterval >= 0, "grpc.csv.progress_interval: must not be negative")
+	if err := csvrepo.Schema(g.CSV.Schema).Validate(); err != nil {
+		v.fail("grpc.csv.schema: %v", err)
+	}
+
+	v.check((g.TLS.Cert == "") == (g.TLS.Key == ""), "grpc.tls.cert and grpc.tls.key: must be set together")
+	v.check(g.TLS.ClientCA == "" || g.TLS.Cert != "", "grpc.tls.client_ca: requires grpc.tls.cert and grpc.tls.key")
+	v.check(len(g.TLS.AllowedClients) == 0 || g.TLS.ClientCA != "", "grpc.tls.allowed_clients: requires grpc.tls.client_ca")
+
+	v.positive("grpc.limits.max_page_size", g.Limits.MaxPageSize)
+	v.check(g.Limits.MaxUnpagedRange > 0, "grpc.limits.max_unpaged_range: must be positive")
+	v.positive("grpc.limits.max_append_batch_size", g.Limits.MaxAppendBatchSize)
+	v.positive("grpc.limits.max_stream_chunk_size", g.Limits.MaxStreamChunkSize)
+	v.check(g.Quality.Interval > 0, "grpc.quality.interval: must be positive")
+	v.check(g.Quality.SpikeWindow >= 0, "grpc.quality.spike_window: must not be negative")
+	v.check(g.Quality.SpikeThreshold >= 0, "grpc.quality.spike_threshold: must not be negative")
+	v.check(g.Readiness.MinRows >= 0, "grpc.readiness.min_rows: must not be negative")
+	v.check(g.Readiness.CheckInterval > 0, "grpc.readiness.check_interval: must be positive")
+}
+
+func (h *HTTPGateway) validate(v *validator) {
+	v.check(h.Addr != "", "http.addr: required")
+	v.check(h.Upstream.Target != "", "http.upstream.target: required")
+	v.check((h.Upstream.Cert == "") == (h.Upstream.Key == ""), "http.upstream.cert and http.upstream.key: must be set together")
+	v.check(h.Upstream.WaitTimeout >= 0, "http.upstream.wait_timeout: must not be negative")
+	if up := h.Upstream; (up.TLS || up.CA != "" || up.Cert != "") && strings.HasPrefix(up.Target, "file://") {
+		v.check(up.ServerName != "", "http.upstream.server_name: required with TLS and a file:// target")
+	}
+	v.check(h.Upstream.Balancer.Policy == "round_robin" || h.Upstream.Balancer.Policy == "least_request",
+		"http.upstream.balancer.policy: must be round_robin or least_request, got %q", h.Upstream.Balancer.Policy)
+	v.check(h.Upstream.Balancer.FileInterval > 0, "http.upstream.balancer.file_interval: must be positive")
+
+	r := h.Upstream.Retry
+	v.positive("http.upstream.retry.max_attempts", r.MaxAttempts)
+	if r.MaxAttempts > 1 {
+		v.check(r.InitialBackoff > 0, "http.upstream.retry.initial_backoff: must be positive")
+		v.check(r.MaxBackoff >= r.InitialBackoff, "http.upstream.retry.max_backoff: must not be below initial_backoff")
+		v.check(r.Multiplier >= 1, "http.upstream.retry.multiplier: must be at least 1, got %v", r.Multiplier)
+		v.check(len(r.Codes) > 0 || r.HedgeDelay > 0, "http.upstream.retry.codes: required unless hedge_delay is set")
+	}
+	for _, name := range r.Codes {
+		var c codes.Code
+		v.check(c.UnmarshalJSON([]byte(strconv.Quote(name))) == nil, "http.upstream.retry.codes: unknown status code %q", name)
+	}
+	v.check(r.HedgeDelay >= 0, "http.upstream.retry.hedge_delay: must not be negative")
+	v.check(h.Upstream.Breaker.FailureThreshold >= 0, "http.upstream.breaker.failure_threshold: must not be negative")
+	v.check(h.Upstream.Breaker.FailureThreshold == 0 || h.Upstream.Breaker.OpenTimeout > 0, "http.upstream.breaker.open_timeout: must be positive")
+
+	for _, t := range []struct {
+		key string
+		d   time.Duration
+	}{
+		{"upstream", h.Timeouts.Upstream},
+		{"stream", h.Timeouts.Stream},
+		{"stream_write", h.Timeouts.StreamWrite},
+		{"read_header", h.Timeouts.ReadHeader},
+		{"read", h.Timeouts.Read},
+		{"write", h.Timeouts.Write},
+		{"idle", h.Timeouts.Idle},
+	} {
+		v.check(t.d > 0, "http.timeouts.%s: must be positive", t.key)
+	}
+
+	v.check(h.Cache.MaxEntries >= 0, "http.cache.max_entries: must not be negative")
+	v.check(h.Cache.MaxBytes >= 0, "http.cache.max_bytes: must not be negative")
+	v.check(h.Cache.TTL >= 0, "http.cache.ttl: must not be negative")
+	v.check(h.Cache.VersionTTL >= 0, "http.cache.version_ttl: must not be negative")
+}
+
+type validator struct {
+	problems []string
+}
+
+func (v *validator) fail(format string, args ...any) {
+	v.problems = append(v.problems, fmt.Sprintf(format, args...))
+}
+
+func (v *validator) check(ok bool, format string, args ...any) {
+	if !ok {
+		v.fail(format, args...)
+	}
+}
+
+func (v *validator) positive(key string, n int) {
+	v.check(n > 0, "%s: must be positive, got %d", key, n)
+}
+
+func (v *validator) ratio(key string, r float64) {
+	v.check(r >= 0 && r <= 1, "%s: must be within [0, 1], got %v", key, r)
+}
+
+func (v *validator) err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return errors.New("invalid configuration:\n  " + strings.Join(v.problems, "\n  "))
+}
@@ -0,0 +1,16 @@
+package domain
+
+import "time"
+
+// Dataset describes the data currently served by a repository.
+type Dataset struct {
+	// Version increases whenever the served data changes (reload or append).
+	// It does not start over when the server restarts.
+	Version uint64
+	Rows    int
+	// Checksum identifies the served readings: repositories that loaded the
+	// same files and were sent the same appends report the same checksum.
+	// It is empty for a repository that never held any readings.
+	Checksum  string
+	UpdatedAt time.Time
+}
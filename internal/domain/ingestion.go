@@ -0,0 +1,66 @@
+package domain
+
+import "time"
+
+// IngestionCategory classifies why input was rejected while loading data.
+type IngestionCategory string
+
+const (
+	// IngestInvalidFile: a file could not be opened, decompressed or
+	// recognised, or its header does not match the expected columns.
+	IngestInvalidFile IngestionCategory = "invalid_file"
+	// IngestMalformedRow: a row is not valid CSV, e.g. a stray quote.
+	IngestMalformedRow IngestionCategory = "malformed_row"
+	// IngestMissingColumns: a row has fewer fields than the columns in use.
+	IngestMissingColumns IngestionCategory = "missing_columns"
+	// IngestMissingMeterID: the meter ID column is empty.
+	IngestMissingMeterID IngestionCategory = "missing_meter_id"
+	// IngestInvalidTime: the time matches none of the layouts.
+	IngestInvalidTime IngestionCategory = "invalid_time"
+	// IngestNonexistentTime: a local time skipped by a DST change.
+	IngestNonexistentTime IngestionCategory = "nonexistent_time"
+	// IngestInvalidUsage: the usage is not a number.
+	IngestInvalidUsage IngestionCategory = "invalid_usage"
+	// IngestNonFiniteUsage: the usage is NaN or infinite.
+	IngestNonFiniteUsage IngestionCategory = "non_finite_usage"
+	// IngestInvalidReading: any other reason a reading fails Validate.
+	IngestInvalidReading IngestionCategory = "invalid_reading"
+	// IngestInvalidEntry: a Green Button entry or interval reading was skipped.
+	IngestInvalidEntry IngestionCategory = "invalid_entry"
+)
+
+// IngestionReport describes what the last load of a repository's source
+// rejected, so that data owners can fix their files.
+type IngestionReport struct {
+	Source   string // the path that was loaded: a file, directory or pattern
+	LoadedAt time.Time
+	// Applied is false if the load yielded no readings, in which case the
+	// data loaded before keeps being served.
+	Applied  bool
+	Files    int
+	Accepted int
+	Rejected int
+	// Categories counts the rejected input by category, most frequent first.
+	Categories []IngestionCount
+	// Issues lists rejected input in file order. Only the first ones are
+	// kept: IssuesTruncated tells whether there were more.
+	Issues          []IngestionIssue
+	IssuesTruncated bool
+}
+
+// IngestionCount is the number of rejections in a category.
+type IngestionCount struct {
+	Category IngestionCategory
+	Count    int
+}
+
+// IngestionIssue is a rejected row, or a problem with a whole file.
+type IngestionIssue struct {
+	File string
+	// Row is the 1-based number of a CSV row, the header included; 0 for
+	// issues that are not about one, such as Green Button entries.
+	Row      int
+	Values   []string // the fields of a CSV row, if it could be read
+	Category IngestionCategory
+	Message  string
+}
@@ -0,0 +1,11 @@
+package domain
+
+import "time"
+
+// Meter summarizes the readings available for a single meter.
+type Meter struct {
+	ID           string
+	ReadingCount int
+	FirstReading time.Time
+	LastReading  time.Time
+}
@@ -0,0 +1,71 @@
+package domain
+
+import "time"
+
+// QualityFlags marks what looks wrong about a reading; the zero value means
+// nothing does. Flags are derived from the neighbouring readings of the same
+// meter when readings are served, and are not stored.
+type QualityFlags uint8
+
+const (
+	// QualityDuplicate: another reading of the meter has the same time.
+	QualityDuplicate QualityFlags = 1 << iota
+	// QualityNegative: the usage is below zero.
+	QualityNegative
+	// QualitySpike: the usage is far from that of the surrounding readings.
+	QualitySpike
+	// QualityGapBefore: one or more intervals are missing before the reading.
+	QualityGapBefore
+)
+
+var qualityNames = []struct {
+	flag QualityFlags
+	name string
+}{
+	{QualityDuplicate, "duplicate"},
+	{QualityNegative, "negative"},
+	{QualitySpike, "spike"},
+	{QualityGapBefore, "gap_before"},
+}
+
+// Names returns the names of the flags set in f, such as "spike".
+func (f QualityFlags) Names() []string {
+	var names []string
+	for _, q := range qualityNames {
+		if f&q.flag != 0 {
+			names = append(names, q.name)
+		}
+	}
+	return names
+}
+
+// QualityReport summarizes the gaps and anomalies of readings in a time range.
+type QualityReport struct {
+	// Interval is the expected time between two readings of a meter.
+	Interval time.Duration
+	Meters   []MeterQuality // ordered by ID
+}
+
+// MeterQuality summarizes the gaps and anomalies of one meter's readings.
+type MeterQuality struct {
+	MeterID  string
+	Readings int
+	// MissingIntervals is the number of readings missing from the gaps.
+	MissingIntervals int
+	Duplicates       int
+	Negatives        int
+	Spikes           int
+	// Gaps and Anomalies, the readings flagged for anything but a gap before
+	// them, are in time order. Only the first ones are kept: Truncated tells
+	// whether there were more. The counts above cover all of them.
+	Gaps      []Gap
+	Anomalies []Reading
+	Truncated bool
+}
+
+// Gap is a run of missing readings between the readings at Start and End.
+type Gap struct {
+	Start   time.Time
+	End     time.Time
+	Missing int
+}
@@ -1,9 +1,35 @@
 package domain
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// DefaultMeterID is assigned to readings from sources that do not identify a meter,
+// such as the original two-column CSV format.
+const DefaultMeterID = "default"
+
+var ErrInvalidReading = errors.New("invalid reading")
 
 // Reading represents a single meter usage reading at a point in time.
 type Reading struct {
+	MeterID    string
 	Time       time.Time
 	MeterUsage float64
+	// Quality is set on readings served by the service, see QualityFlags.
+	Quality QualityFlags
+}
+
+// Validate applies the rules every reading must satisfy, regardless of whether
+// it was loaded from a file or submitted through the API.
+func (r Reading) Validate() error {
+	if r.Time.IsZero() {
+		return fmt.Errorf("%w: missing time", ErrInvalidReading)
+	}
+	if math.IsNaN(r.MeterUsage) || math.IsInf(r.MeterUsage, 0) {
+		return fmt.Errorf("%w: meterusage must be finite, got %v", ErrInvalidReading, r.MeterUsage)
+	}
+	return nil
 }
@@ -0,0 +1,108 @@
+// Package greenbutton reads and writes meter readings in the Green Button
+// format: NAESB ESPI resources (UsagePoint, MeterReading, ReadingType,
+// IntervalBlock, LocalTimeParameters) carried in an Atom feed.
+package greenbutton
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"math"
+)
+
+// atomNS is the namespace of the feed; ESPI resources are in
+// http://naesb.org/espi, which is spelled out in the struct tags.
+const atomNS = "http://www.w3.org/2005/Atom"
+
+// Units of measure (ESPI UnitSymbolKind) that readings can be converted from.
+// Energy is served in kWh and power in kW.
+const (
+	uomW  = 38
+	uomWh = 72
+)
+
+// ESPI ReadingType codes written to exported feeds.
+const (
+	accumulationDelta  = 4  // deltaData: each value covers its own interval
+	commodityElectric  = 1  // electricity, secondary metered
+	qualifierNormal    = 12 // normal
+	flowForward        = 1  // delivered to the customer
+	kindEnergy         = 12 // energy
+	serviceElectricity = 0  // UsagePoint ServiceCategory kind
+)
+
+// dstRuleDisabled is the DstRuleType value of zones without daylight saving.
+const dstRuleDisabled = "FFFFFFFF"
+
+type linkXML struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+}
+
+type readingTypeXML struct {
+	AccumulationBehaviour int    `xml:"accumulationBehaviour"`
+	Commodity             int    `xml:"commodity"`
+	DataQualifier         int    `xml:"dataQualifier"`
+	FlowDirection         int    `xml:"flowDirection"`
+	IntervalLength        uint32 `xml:"intervalLength,omitempty"`
+	Kind                  int    `xml:"kind"`
+	PowerOfTenMultiplier  int    `xml:"powerOfTenMultiplier"`
+	TimeAttribute         int    `xml:"timeAttribute"`
+	UOM                   int    `xml:"uom"`
+}
+
+// scale returns the exponent that turns raw values of this reading type into
+// kWh or kW.
+func (rt readingTypeXML) scale() (int, error) {
+	switch rt.UOM {
+	case uomWh, uomW:
+		return rt.PowerOfTenMultiplier - 3, nil
+	default:
+		return 0, fmt.Errorf("unsupported unit of measure %d (want %d, Wh, or %d, W)", rt.UOM, uomWh, uomW)
+	}
+}
+
+type intervalBlockXML struct {
+	Interval intervalXML          `xml:"interval"`
+	Readings []intervalReadingXML `xml:"IntervalReading"`
+}
+
+// intervalXML is an ESPI DateTimeInterval: start is in seconds since the Unix
+// epoch, UTC.
+type intervalXML struct {
+	Duration uint32 `xml:"duration"`
+	Start    int64  `xml:"start"`
+}
+
+type intervalReadingXML struct {
+	TimePeriod *intervalXML `xml:"timePeriod"`
+	Value      *int64       `xml:"value"`
+}
+
+type localTimeParametersXML struct {
+	DSTEndRule   string `xml:"dstEndRule"`
+	DSTOffset    int    `xml:"dstOffset"`
+	DSTStartRule string `xml:"dstStartRule"`
+	TZOffset     int    `xml:"tzOffset"`
+}
+
+type serviceCategoryXML struct {
+	Kind int `xml:"kind"`
+}
+
+// scaled returns v × 10^exp. Dividing by an exact power of ten keeps values
+// such as 55090 × 10^-3 at their shortest decimal form.
+func scaled(v int64, exp int) float64 {
+	if exp < 0 {
+		return float64(v) / math.Pow10(-exp)
+	}
+	return float64(v) * math.Pow10(exp)
+}
+
+// entryID returns a name-based (version 5 style) UUID URN for an Atom id, so
+// that exporting the same resources twice gives the same ids.
+func entryID(name string) string {
+	h := sha1.Sum([]byte(name))
+	h[6] = h[6]&0x0f | 0x50
+	h[8] = h[8]&0x3f | 0x80
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", h[0:4], h[4:6], h[6:8], h[8:10], h[10:16])
+}
@@ -0,0 +1,216 @@
+package greenbutton
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/milad/spectral/internal/domain"
+)
+
+// entryXML is an Atom entry as read. Element names are matched without their
+// namespace, as feeds in the wild do not all declare them.
+type entryXML struct {
+	Links   []linkXML  `xml:"link"`
+	Content contentXML `xml:"content"`
+}
+
+type contentXML struct {
+	MeterReading   *struct{}          `xml:"MeterReading"`
+	ReadingType    *readingTypeXML    `xml:"ReadingType"`
+	IntervalBlocks []intervalBlockXML `xml:"IntervalBlock"`
+}
+
+func (e entryXML) link(rel string) string {
+	for _, l := range e.Links {
+		if l.Rel == rel {
+			return hrefPath(l.Href)
+		}
+	}
+	return ""
+}
+
+// feed collects the resources of a document; IntervalBlocks can only be
+// converted once the ReadingType they refer to is known, and that may come
+// later in the document.
+type feed struct {
+	readingTypes  map[string]readingTypeXML // by self link
+	meterReadings map[string][]string       // self link -> related links
+	blocks        []blockEntry
+}
+
+type blockEntry struct {
+	entry  int    // position in the document, for errors
+	parent string // self link of the MeterReading
+	blocks []intervalBlockXML
+}
+
+// EntryError is an entry of a feed, or a reading in one, that was skipped.
+type EntryError struct {
+	Entry int // 1-based position of the entry in the feed
+	Err   error
+}
+
+func (e *EntryError) Error() string { return fmt.Sprintf("entry %d: %v", e.Entry, e.Err) }
+
+func (e *EntryError) Unwrap() error { return e.Err }
+
+// Parse reads the interval readings of a Green Button feed (an Atom feed of
+// ESPI resources, or a single entry).
+//
+// Every IntervalReading becomes a domain.Reading of the UsagePoint the block
+// belongs to: the meter ID is the UsagePoint's ID in the resource links
+// (.../UsagePoint/{id}/MeterReading/...), or domain.DefaultMeterID for blocks
+// without links. The reading is timestamped at the end of its interval, like
+// the rows of a meterusage CSV, and its value is scaled by the ReadingType's
+// powerOfTenMultiplier and converted from Wh to kWh (or W to kW); other units
+// are rejected. A block uses the ReadingType its MeterReading links to as
+// "related", or the only ReadingType of the feed.
+//
+// ESPI times are UTC, so the feed's LocalTimeParameters do not change the
+// readings. Invalid readings and blocks are skipped and returned as a joined
+// error (errors.Join) of *EntryError. A document without any entry is an
+// error; one that is not well-formed is read up to the fault.
+func Parse(r io.Reader) ([]domain.Reading, error) {
+	f := feed{readingTypes: map[string]readingTypeXML{}, meterReadings: map[string][]string{}}
+	var errs []error
+
+	dec := xml.NewDecoder(r)
+	entries := 0
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("read feed: %w", err))
+			break
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "entry" {
+			continue
+		}
+		entries++
+		var e entryXML
+		if err := dec.DecodeElement(&e, &start); err != nil {
+			errs = append(errs, fmt.Errorf("entry %d: %w", entries, err))
+			break
+		}
+		f.add(entries, e)
+	}
+	if entries == 0 && len(errs) == 0 {
+		return nil, errors.New("no Atom entries found; not a Green Button feed")
+	}
+
+	readings, blockErrs := f.readings()
+	return readings, errors.Join(append(errs, blockErrs...)...)
+}
+
+func (f *feed) add(n int, e entryXML) {
+	self := e.link("self")
+	c := e.Content
+	if c.ReadingType != nil {
+		f.readingTypes[self] = *c.ReadingType
+	}
+	if c.MeterReading != nil {
+		var related []string
+		for _, l := range e.Links {
+			if l.Rel == "related" {
+				related = append(related, hrefPath(l.Href))
+			}
+		}
+		f.meterReadings[self] = related
+	}
+	if len(c.IntervalBlocks) > 0 {
+		// Blocks link "up" to .../MeterReading/{id}/IntervalBlock.
+		parent := e.link("up")
+		if parent == "" {
+			parent = self
+		}
+		if i := strings.LastIndex(parent, "/IntervalBlock"); i >= 0 {
+			parent = parent[:i]
+		}
+		f.blocks = append(f.blocks, blockEntry{entry: n, parent: parent, blocks: c.IntervalBlocks})
+	}
+}
+
+func (f *feed) readings() ([]domain.Reading, []error) {
+	readings := []domain.Reading{}
+	var errs []error
+	for _, b := range f.blocks {
+		rt, err := f.readingType(b.parent)
+		if err != nil {
+			errs = append(errs, &EntryError{Entry: b.entry, Err: err})
+			continue
+		}
+		exp, err := rt.scale()
+		if err != nil {
+			errs = append(errs, &EntryError{Entry: b.entry, Err: err})
+			continue
+		}
+		meterID := usagePointID(b.parent)
+		for i, block := range b.blocks {
+			for j, ir := range block.Readings {
+				if ir.TimePeriod == nil || ir.Value == nil {
+					errs = append(errs, &EntryError{Entry: b.entry, Err: fmt.Errorf("IntervalBlock %d: IntervalReading %d: missing timePeriod or value", i+1, j+1)})
+					continue
+				}
+				end := ir.TimePeriod.Start + int64(ir.TimePeriod.Duration)
+				reading := domain.Reading{
+					MeterID:    meterID,
+					Time:       time.Unix(end, 0).UTC(),
+					MeterUsage: scaled(*ir.Value, exp),
+				}
+				if err := reading.Validate(); err != nil {
+					errs = append(errs, &EntryError{Entry: b.entry, Err: fmt.Errorf("IntervalBlock %d: IntervalReading %d: %w", i+1, j+1, err)})
+					continue
+				}
+				readings = append(readings, reading)
+			}
+		}
+	}
+	return readings, errs
+}
+
+// readingType finds the ReadingType of the MeterReading at parent.
+func (f *feed) readingType(parent string) (readingTypeXML, error) {
+	for _, related := range f.meterReadings[parent] {
+		if rt, ok := f.readingTypes[related]; ok {
+			return rt, nil
+		}
+	}
+	if len(f.readingTypes) == 1 {
+		for _, rt := range f.readingTypes {
+			return rt, nil
+		}
+	}
+	return readingTypeXML{}, fmt.Errorf("IntervalBlock of %q: cannot tell its ReadingType among %d", parent, len(f.readingTypes))
+}
+
+// usagePointID returns the {id} of .../UsagePoint/{id}/... in a resource path.
+func usagePointID(path string) string {
+	parts := strings.Split(path, "/")
+	for i := 0; i+1 < len(parts); i++ {
+		if parts[i] == "UsagePoint" && parts[i+1] != "" {
+			if id, err := url.PathUnescape(parts[i+1]); err == nil {
+				return id
+			}
+			return parts[i+1]
+		}
+	}
+	return domain.DefaultMeterID
+}
+
+// hrefPath reduces a link to its path, so that links written with different
+// hosts or as relative references still match.
+func hrefPath(href string) string {
+	href = strings.TrimSpace(href)
+	if u, err := url.Parse(href); err == nil {
+		href = u.EscapedPath()
+	}
+	return "/" + strings.Trim(href, "/")
+}
@@ -0,0 +1,178 @@
+package greenbutton
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/milad/spectral/internal/domain"
+)
+
+// sampleFeed has two usage points whose blocks use different reading types,
+// with prefixed ESPI elements and absolute links as utilities publish them.
+const sampleFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom" xmlns:espi="http://naesb.org/espi">
+  <id>urn:uuid:0a0cf3fa-3f1f-4b4e-9fb7-8e5d3f1c1a01</id>
+  <title>Green Button Usage Feed</title>
+  <updated>2019-01-02T00:00:00Z</updated>
+  <entry>
+    <id>urn:uuid:1</id>
+    <link rel="self" href="https://utility.example/DataCustodian/espi/1_1/resource/Subscription/5/UsagePoint/home"/>
+    <title>Home</title>
+    <content><espi:UsagePoint><espi:ServiceCategory><espi:kind>0</espi:kind></espi:ServiceCategory></espi:UsagePoint></content>
+  </entry>
+  <entry>
+    <id>urn:uuid:2</id>
+    <link rel="self" href="https://utility.example/DataCustodian/espi/1_1/resource/LocalTimeParameters/1"/>
+    <content><espi:LocalTimeParameters><espi:dstEndRule>B40E2000</espi:dstEndRule><espi:dstOffset>3600</espi:dstOffset><espi:dstStartRule>360E2000</espi:dstStartRule><espi:tzOffset>-18000</espi:tzOffset></espi:LocalTimeParameters></content>
+  </entry>
+  <entry>
+    <id>urn:uuid:3</id>
+    <link rel="self" href="https://utility.example/DataCustodian/espi/1_1/resource/Subscription/5/UsagePoint/home/MeterReading/1"/>
+    <link rel="up" href="https://utility.example/DataCustodian/espi/1_1/resource/Subscription/5/UsagePoint/home/MeterReading"/>
+    <link rel="related" href="https://utility.example/DataCustodian/espi/1_1/resource/Subscription/5/UsagePoint/home/MeterReading/1/IntervalBlock"/>
+    <link rel="related" href="https://utility.example/DataCustodian/espi/1_1/resource/ReadingType/wh"/>
+    <content><espi:MeterReading/></content>
+  </entry>
+  <entry>
+    <id>urn:uuid:4</id>
+    <link rel="self" href="https://utility.example/DataCustodian/espi/1_1/resource/ReadingType/wh"/>
+    <content><espi:ReadingType><espi:accumulationBehaviour>4</espi:accumulationBehaviour><espi:intervalLength>900</espi:intervalLength><espi:powerOfTenMultiplier>0</espi:powerOfTenMultiplier><espi:uom>72</espi:uom></espi:ReadingType></content>
+  </entry>
+  <entry>
+    <id>urn:uuid:5</id>
+    <link rel="self" href="https://utility.example/DataCustodian/espi/1_1/resource/Subscription/5/UsagePoint/home/MeterReading/1/IntervalBlock/1"/>
+    <link rel="up" href="https://utility.example/DataCustodian/espi/1_1/resource/Subscription/5/UsagePoint/home/MeterReading/1/IntervalBlock"/>
+    <content><espi:IntervalBlock>
+      <espi:interval><espi:duration>1800</espi:duration><espi:start>1546300800</espi:start></espi:interval>
+      <espi:IntervalReading><espi:timePeriod><espi:duration>900</espi:duration><espi:start>1546300800</espi:start></espi:timePeriod><espi:value>55090</espi:value></espi:IntervalReading>
+      <espi:IntervalReading><espi:timePeriod><espi:duration>900</espi:duration><espi:start>1546301700</espi:start></espi:timePeriod><espi:value>54640</espi:value></espi:IntervalReading>
+    </espi:IntervalBlock></content>
+  </entry>
+  <entry>
+    <id>urn:uuid:6</id>
+    <link rel="self" href="/espi/1_1/resource/Subscription/5/UsagePoint/shop/MeterReading/1/IntervalBlock/1"/>
+    <link rel="up" href="/espi/1_1/resource/Subscription/5/UsagePoint/shop/MeterReading/1/IntervalBlock"/>
+    <content><espi:IntervalBlock>
+      <espi:interval><espi:duration>3600</espi:duration><espi:start>1546300800</espi:start></espi:interval>
+      <espi:IntervalReading><espi:timePeriod><espi:duration>3600</espi:duration><espi:start>1546300800</espi:start></espi:timePeriod><espi:value>1234</espi:value></espi:IntervalReading>
+    </espi:IntervalBlock></content>
+  </entry>
+  <entry>
+    <id>urn:uuid:7</id>
+    <link rel="self" href="/espi/1_1/resource/Subscription/5/UsagePoint/shop/MeterReading/1"/>
+    <link rel="related" href="/espi/1_1/resource/ReadingType/kwh"/>
+    <content><espi:MeterReading/></content>
+  </entry>
+  <entry>
+    <id>urn:uuid:8</id>
+    <link rel="self" href="/espi/1_1/resource/ReadingType/kwh"/>
+    <content><espi:ReadingType><espi:powerOfTenMultiplier>3</espi:powerOfTenMultiplier><espi:uom>72</espi:uom></espi:ReadingType></content>
+  </entry>
+</feed>`
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	got, err := Parse(strings.NewReader(sampleFeed))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	t0 := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	want := []domain.Reading{
+		{MeterID: "home", Time: t0.Add(15 * time.Minute), MeterUsage: 55.09},
+		{MeterID: "home", Time: t0.Add(30 * time.Minute), MeterUsage: 54.64},
+		// The block comes before its ReadingType, which is in kWh.
+		{MeterID: "shop", Time: t0.Add(time.Hour), MeterUsage: 1234},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d readings want %d: %v", len(got), len(want), got)
+	}
+	for i := range want {
+		if !got[i].Time.Equal(want[i].Time) || got[i].MeterID != want[i].MeterID || got[i].MeterUsage != want[i].MeterUsage {
+			t.Fatalf("reading %d: got %+v want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestParse_SingleReadingTypeWithoutLinks(t *testing.T) {
+	t.Parallel()
+
+	got, err := Parse(strings.NewReader(`<feed xmlns="http://www.w3.org/2005/Atom">
+  <entry><content><IntervalBlock xmlns="http://naesb.org/espi">
+    <IntervalReading><timePeriod><duration>3600</duration><start>1546300800</start></timePeriod><value>2500</value></IntervalReading>
+  </IntervalBlock></content></entry>
+  <entry><content><ReadingType xmlns="http://naesb.org/espi"><powerOfTenMultiplier>-1</powerOfTenMultiplier><uom>38</uom></ReadingType></content></entry>
+</feed>`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(got) != 1 || got[0].MeterID != domain.DefaultMeterID || got[0].MeterUsage != 0.25 {
+		t.Fatalf("got %+v want one reading of 0.25 kW for the default meter", got)
+	}
+}
+
+func TestParse_SkipsInvalidBlocksAndReadings(t *testing.T) {
+	t.Parallel()
+
+	got, err := Parse(strings.NewReader(`<feed xmlns="http://www.w3.org/2005/Atom">
+  <entry>
+    <link rel="self" href="UsagePoint/1/MeterReading/1"/>
+    <link rel="related" href="ReadingType/therm"/>
+    <content><MeterReading xmlns="http://naesb.org/espi"/></content>
+  </entry>
+  <entry><link rel="self" href="ReadingType/therm"/><content><ReadingType xmlns="http://naesb.org/espi"><uom>169</uom></ReadingType></content></entry>
+  <entry>
+    <link rel="up" href="UsagePoint/1/MeterReading/1/IntervalBlock"/>
+    <content><IntervalBlock xmlns="http://naesb.org/espi">
+      <IntervalReading><timePeriod><duration>3600</duration><start>1546300800</start></timePeriod><value>1</value></IntervalReading>
+    </IntervalBlock></content>
+  </entry>
+  <entry>
+    <link rel="self" href="UsagePoint/2/MeterReading/1"/>
+    <link rel="related" href="ReadingType/wh"/>
+    <content><MeterReading xmlns="http://naesb.org/espi"/></content>
+  </entry>
+  <entry><link rel="self" href="ReadingType/wh"/><content><ReadingType xmlns="http://naesb.org/espi"><uom>72</uom></ReadingType></content></entry>
+  <entry>
+    <link rel="up" href="UsagePoint/2/MeterReading/1/IntervalBlock"/>
+    <content><IntervalBlock xmlns="http://naesb.org/espi">
+      <IntervalReading><value>1</value></IntervalReading>
+      <IntervalReading><timePeriod><duration>3600</duration><start>1546300800</start></timePeriod><value>1000</value></IntervalReading>
+    </IntervalBlock></content>
+  </entry>
+</feed>`))
+	if len(got) != 1 || got[0].MeterID != "2" || got[0].MeterUsage != 1 {
+		t.Fatalf("got %+v want the one valid reading of meter 2", got)
+	}
+	for _, want := range []string{"unsupported unit of measure 169", "IntervalReading 1: missing timePeriod"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("err=%v want it to mention %q", err, want)
+		}
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	t.Parallel()
+
+	for name, doc := range map[string]string{
+		"not a feed": "time,meterusage\n",
+		"no entries": `<feed xmlns="http://www.w3.org/2005/Atom"></feed>`,
+		"truncated":  `<feed xmlns="http://www.w3.org/2005/Atom"><entry><content>`,
+	} {
+		got, err := Parse(strings.NewReader(doc))
+		if err == nil || len(got) != 0 {
+			t.Fatalf("%s: got %v, %v want an error", name, got, err)
+		}
+	}
+
+	// Values are validated like any other reading.
+	_, err := Parse(strings.NewReader(`<feed xmlns="http://www.w3.org/2005/Atom"><entry><content>
+<ReadingType xmlns="http://naesb.org/espi"><powerOfTenMultiplier>400</powerOfTenMultiplier><uom>72</uom></ReadingType>
+<IntervalBlock xmlns="http://naesb.org/espi"><IntervalReading><timePeriod><duration>900</duration><start>1546300800</start></timePeriod><value>1</value></IntervalReading></IntervalBlock>
+</content></entry></feed>`))
+	if !errors.Is(err, domain.ErrInvalidReading) {
+		t.Fatalf("err=%v want ErrInvalidReading", err)
+	}
+}
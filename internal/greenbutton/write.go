@@ -0,0 +1,325 @@
+package greenbutton
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"time"
+)
+
+// Exported values are Wh × 10^-3, i.e. integer mWh, which keeps six decimals
+// of the kWh readings.
+const (
+	exportMultiplier = -3
+	exportScale      = 1e6 // kWh -> mWh
+)
+
+// resourceBase prefixes the resource links of exported feeds. They are
+// relative: only their structure matters to Parse.
+const (
+	resourceBase = "espi/1_1/resource/"
+	usagePoints  = resourceBase + "RetailCustomer/1/UsagePoint"
+)
+
+type entryOut struct {
+	XMLName   xml.Name   `xml:"entry"`
+	ID        string     `xml:"id"`
+	Links     []linkXML  `xml:"link"`
+	Title     string     `xml:"title"`
+	Content   contentOut `xml:"content"`
+	Published string     `xml:"published"`
+	Updated   string     `xml:"updated"`
+}
+
+type contentOut struct {
+	LocalTimeParameters *localTimeParametersXML `xml:"http://naesb.org/espi LocalTimeParameters"`
+	UsagePoint          *usagePointOut          `xml:"http://naesb.org/espi UsagePoint"`
+	MeterReading        *struct{}               `xml:"http://naesb.org/espi MeterReading"`
+	ReadingType         *readingTypeXML         `xml:"http://naesb.org/espi ReadingType"`
+	IntervalBlock       *intervalBlockXML       `xml:"http://naesb.org/espi IntervalBlock"`
+}
+
+type usagePointOut struct {
+	ServiceCategory serviceCategoryXML `xml:"ServiceCategory"`
+}
+
+// Writer renders readings as a Green Button feed, one page at a time: every
+// page adds an IntervalBlock entry per meter, and the UsagePoint, MeterReading
+// and ReadingType entries of a meter come with its first block.
+//
+// Readings are taken to be in kWh and timestamped at the end of their
+// interval, as Parse reads them back. Intervals are the time since the
+// meter's previous reading, so readings must be written in time order per
+// meter; the first reading of a meter is held until the second one gives its
+// length.
+type Writer struct {
+	enc *xml.Encoder
+	loc *time.Location
+	now string
+
+	started bool
+	meters  map[string]*meterOut
+	order   []*meterOut // in order of their first reading
+	pending []*meterOut // meters with readings in the current page
+}
+
+type meterOut struct {
+	id       string
+	href     string // of the UsagePoint
+	entries  bool   // UsagePoint, MeterReading and ReadingType written
+	blocks   int
+	last     time.Time
+	holding  bool // the first reading waits for an interval length
+	held     int64
+	interval time.Duration
+	readings []intervalReadingXML
+}
+
+// NewWriter returns a Writer to w. loc only sets the feed's
+// LocalTimeParameters: ESPI times are UTC.
+func NewWriter(w io.Writer, loc *time.Location) *Writer {
+	if loc == nil {
+		loc = time.UTC
+	}
+	return &Writer{
+		enc:    xml.NewEncoder(w),
+		loc:    loc,
+		now:    time.Now().UTC().Format(time.RFC3339),
+		meters: map[string]*meterOut{},
+	}
+}
+
+// WriteReading adds a reading of meterUsage kWh ending at t.
+func (w *Writer) WriteReading(meterID string, t time.Time, meterUsage float64) error {
+	value := meterUsage * exportScale
+	if math.Abs(value) >= 1<<47 {
+		return fmt.Errorf("meter %q at %s: %v kWh does not fit an ESPI value", meterID, t, meterUsage)
+	}
+	v := int64(math.Round(value))
+
+	m := w.meters[meterID]
+	if m == nil {
+		m = &meterOut{id: meterID, href: usagePoints + "/" + url.PathEscape(meterID)}
+		w.meters[meterID] = m
+		w.order = append(w.order, m)
+	}
+	switch {
+	case m.last.IsZero():
+		m.holding, m.held = true, v
+	case m.holding:
+		m.interval = t.Sub(m.last)
+		w.add(m, m.last, m.interval, m.held)
+		m.holding = false
+		w.add(m, t, m.interval, v)
+	default:
+		w.add(m, t, t.Sub(m.last), v)
+	}
+	m.last = t
+	return nil
+}
+
+func (w *Writer) add(m *meterOut, end time.Time, d time.Duration, v int64) {
+	if len(m.readings) == 0 {
+		w.pending = append(w.pending, m)
+	}
+	m.readings = append(m.readings, intervalReadingXML{
+		TimePeriod: &intervalXML{Duration: uint32(d / time.Second), Start: end.Add(-d).Unix()},
+		Value:      &v,
+	})
+}
+
+// EndPage writes the readings added since the previous page.
+func (w *Writer) EndPage() error {
+	if err := w.writePending(); err != nil {
+		return err
+	}
+	return w.enc.Flush()
+}
+
+// Close writes the remaining readings and ends the feed. A meter's only
+// reading gets an interval of zero length.
+func (w *Writer) Close() error {
+	for _, m := range w.order {
+		if m.holding {
+			w.add(m, m.last, m.interval, m.held)
+			m.holding = false
+		}
+	}
+	if err := w.writePending(); err != nil {
+		return err
+	}
+	if err := w.start(time.Now()); err != nil {
+		return err
+	}
+	if err := w.enc.EncodeToken(xml.EndElement{Name: xml.Name{Local: "feed"}}); err != nil {
+		return err
+	}
+	return w.enc.Flush()
+}
+
+// start writes the feed header and the LocalTimeParameters, computed for the
+// year of the first reading.
+func (w *Writer) start(first time.Time) error {
+	if w.started {
+		return nil
+	}
+	w.started = true
+	if err := w.enc.EncodeToken(xml.ProcInst{Target: "xml", Inst: []byte(`version="1.0" encoding="UTF-8"`)}); err != nil {
+		return err
+	}
+	feed := xml.StartElement{Name: xml.Name{Local: "feed"}, Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: atomNS}}}
+	if err := w.enc.EncodeToken(feed); err != nil {
+		return err
+	}
+	for _, tok := range []struct{ name, value string }{
+		{"id", entryID(atomNS + " feed " + w.now)},
+		{"title", "Meter usage"},
+		{"updated", w.now},
+	} {
+		if err := w.enc.Encode(struct {
+			XMLName xml.Name
+			Value   string `xml:",chardata"`
+		}{xml.Name{Local: tok.name}, tok.value}); err != nil {
+			return err
+		}
+	}
+	ltp := localTimeParameters(w.loc, first.In(w.loc).Year())
+	return w.entry(resourceBase+"LocalTimeParameters/1", "", nil, w.loc.String(), contentOut{LocalTimeParameters: &ltp})
+}
+
+func (w *Writer) writePending() error {
+	if len(w.pending) == 0 {
+		return nil
+	}
+	first := time.Unix(w.pending[0].readings[0].TimePeriod.Start, 0)
+	if err := w.start(first); err != nil {
+		return err
+	}
+	for _, m := range w.pending {
+		if err := w.writeMeter(m); err != nil {
+			return err
+		}
+	}
+	w.pending = w.pending[:0]
+	return nil
+}
+
+func (w *Writer) writeMeter(m *meterOut) error {
+	meterReading := m.href + "/MeterReading/1"
+	readingType := resourceBase + "ReadingType/" + url.PathEscape(m.id)
+	if !m.entries {
+		m.entries = true
+		if err := w.entry(m.href, usagePoints, []linkXML{
+			{Rel: "related", Href: m.href + "/MeterReading"},
+			{Rel: "related", Href: resourceBase + "LocalTimeParameters/1"},
+		}, m.id, contentOut{UsagePoint: &usagePointOut{ServiceCategory: serviceCategoryXML{Kind: serviceElectricity}}}); err != nil {
+			return err
+		}
+		if err := w.entry(meterReading, m.href+"/MeterReading", []linkXML{
+			{Rel: "related", Href: meterReading + "/IntervalBlock"},
+			{Rel: "related", Href: readingType},
+		}, m.id, contentOut{MeterReading: &struct{}{}}); err != nil {
+			return err
+		}
+		if err := w.entry(readingType, resourceBase+"ReadingType", nil, "Energy delivered (Wh)", contentOut{ReadingType: &readingTypeXML{
+			AccumulationBehaviour: accumulationDelta,
+			Commodity:             commodityElectric,
+			DataQualifier:         qualifierNormal,
+			FlowDirection:         flowForward,
+			IntervalLength:        uint32(m.interval / time.Second),
+			Kind:                  kindEnergy,
+			PowerOfTenMultiplier:  exportMultiplier,
+			UOM:                   uomWh,
+		}}); err != nil {
+			return err
+		}
+	}
+
+	m.blocks++
+	first, last := m.readings[0].TimePeriod, m.readings[len(m.readings)-1].TimePeriod
+	block := &intervalBlockXML{
+		Interval: intervalXML{
+			Start:    first.Start,
+			Duration: uint32(last.Start + int64(last.Duration) - first.Start),
+		},
+		Readings: m.readings,
+	}
+	err := w.entry(fmt.Sprintf("%s/IntervalBlock/%d", meterReading, m.blocks), meterReading+"/IntervalBlock", nil, m.id, contentOut{IntervalBlock: block})
+	m.readings = nil
+	return err
+}
+
+func (w *Writer) entry(self, up string, related []linkXML, title string, content contentOut) error {
+	links := []linkXML{{Rel: "self", Href: self}}
+	if up != "" {
+		links = append(links, linkXML{Rel: "up", Href: up})
+	}
+	return w.enc.Encode(entryOut{
+		ID:        entryID(self),
+		Links:     append(links, related...),
+		Title:     title,
+		Content:   content,
+		Published: w.now,
+		Updated:   w.now,
+	})
+}
+
+// localTimeParameters describes loc in the given year: its standard offset
+// and, if it observes daylight saving, the DST offset and the rules for when
+// it starts and ends.
+func localTimeParameters(loc *time.Location, year int) localTimeParametersXML {
+	p := localTimeParametersXML{DSTStartRule: dstRuleDisabled, DSTEndRule: dstRuleDisabled}
+	t := time.Date(year, 1, 1, 0, 0, 0, 0, loc)
+	_, p.TZOffset = t.Zone()
+	dstOffset := 0
+	var startRule, endRule string
+	for {
+		_, end := t.ZoneBounds()
+		if end.IsZero() || end.In(loc).Year() != year {
+			break
+		}
+		_, before := t.Zone()
+		_, after := end.Zone()
+		// The rule is the wall-clock time at which the change happens, before it.
+		wall := end.In(time.FixedZone("", before))
+		switch {
+		case end.IsDST() && !t.IsDST():
+			startRule, dstOffset = dstRule(wall), after
+			p.TZOffset = before
+		case !end.IsDST() && t.IsDST():
+			endRule = dstRule(wall)
+			p.TZOffset = after
+		}
+		t = end
+	}
+	if startRule != "" && endRule != "" {
+		p.DSTStartRule, p.DSTEndRule = startRule, endRule
+		p.DSTOffset = dstOffset - p.TZOffset
+	}
+	return p
+}
+
+// dstRule encodes wall as an ESPI DstRuleType: the nth (or last) weekday of
+// the month at that time of day.
+//
+//	bits 0-11 seconds, 12-16 hour, 17-19 weekday (Monday = 1),
+//	20-24 day of month (unused here), 25-27 operator, 28-31 month
+//
+// Operators 2 to 6 select the first to fifth occurrence of the weekday in the
+// month and 7 the last one.
+func dstRule(wall time.Time) string {
+	day := wall.Day()
+	op := (day-1)/7 + 2
+	if day+7 > time.Date(wall.Year(), wall.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day() {
+		op = 7
+	}
+	weekday := int(wall.Weekday())
+	if weekday == 0 {
+		weekday = 7
+	}
+	v := uint32(wall.Month())<<28 | uint32(op)<<25 | uint32(weekday)<<17 |
+		uint32(wall.Hour())<<12 | uint32(wall.Minute()*60+wall.Second())
+	return fmt.Sprintf("%08X", v)
+}
@@ -0,0 +1,125 @@
+package greenbutton
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata" // tests must not depend on the host's zoneinfo
+
+	"github.com/milad/spectral/internal/domain"
+)
+
+func TestWriter_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	t0 := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	in := []domain.Reading{
+		{MeterID: "default", Time: t0.Add(15 * time.Minute), MeterUsage: 55.09},
+		{MeterID: "site a/1", Time: t0.Add(15 * time.Minute), MeterUsage: 1.5},
+		{MeterID: "default", Time: t0.Add(30 * time.Minute), MeterUsage: 54.64},
+		{MeterID: "single", Time: t0.Add(30 * time.Minute), MeterUsage: 7},
+		{MeterID: "default", Time: t0.Add(45 * time.Minute), MeterUsage: -0.000001},
+		{MeterID: "site a/1", Time: t0.Add(75 * time.Minute), MeterUsage: 2},
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf, time.UTC)
+	// Two pages, split between readings of the same meter.
+	for i, r := range in {
+		if err := w.WriteReading(r.MeterID, r.Time, r.MeterUsage); err != nil {
+			t.Fatalf("WriteReading: %v", err)
+		}
+		if i == 2 {
+			if err := w.EndPage(); err != nil {
+				t.Fatalf("EndPage: %v", err)
+			}
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := xml.Unmarshal(buf.Bytes(), new(struct{})); err != nil {
+		t.Fatalf("not well-formed XML: %v\n%s", err, buf.String())
+	}
+
+	got, err := Parse(&buf)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	byKey := map[string]float64{}
+	for _, r := range got {
+		byKey[r.MeterID+" "+r.Time.Format(time.RFC3339)] = r.MeterUsage
+	}
+	if len(got) != len(in) {
+		t.Fatalf("got %d readings want %d: %v", len(got), len(in), got)
+	}
+	for _, r := range in {
+		if v, ok := byKey[r.MeterID+" "+r.Time.Format(time.RFC3339)]; !ok || v != r.MeterUsage {
+			t.Fatalf("reading %+v came back as %v (found=%v)", r, v, ok)
+		}
+	}
+}
+
+func TestWriter_Intervals(t *testing.T) {
+	t.Parallel()
+
+	t0 := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	w := NewWriter(&buf, time.UTC)
+	for i := 1; i <= 3; i++ {
+		_ = w.WriteReading("m", t0.Add(time.Duration(i)*15*time.Minute), 1)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"<intervalLength>900</intervalLength>",
+		"<timePeriod><duration>900</duration><start>1546300800</start></timePeriod><value>1000000</value>",
+		"<interval><duration>2700</duration><start>1546300800</start></interval>",
+		"<powerOfTenMultiplier>-3</powerOfTenMultiplier>",
+		"<uom>72</uom>",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("feed does not contain %s:\n%s", want, out)
+		}
+	}
+}
+
+func TestWriter_EmptyFeed(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	if err := NewWriter(&buf, time.UTC).Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	got, err := Parse(&buf)
+	if err != nil || len(got) != 0 {
+		t.Fatalf("Parse=%v, %v want an empty feed", got, err)
+	}
+}
+
+func TestLocalTimeParameters(t *testing.T) {
+	t.Parallel()
+
+	for name, want := range map[string]localTimeParametersXML{
+		"UTC": {DSTStartRule: dstRuleDisabled, DSTEndRule: dstRuleDisabled},
+		// The second Sunday of March and the first of November, at 2:00.
+		"America/New_York": {TZOffset: -18000, DSTOffset: 3600, DSTStartRule: "360E2000", DSTEndRule: "B40E2000"},
+		// The last Sundays of March and October, at 2:00 and 3:00.
+		"Europe/Berlin": {TZOffset: 3600, DSTOffset: 3600, DSTStartRule: "3E0E2000", DSTEndRule: "AE0E3000"},
+		// Daylight saving spans the new year.
+		"Australia/Sydney": {TZOffset: 36000, DSTOffset: 3600, DSTStartRule: "A40E2000", DSTEndRule: "440E3000"},
+		"Asia/Kolkata":     {TZOffset: 19800, DSTStartRule: dstRuleDisabled, DSTEndRule: dstRuleDisabled},
+	} {
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			t.Fatalf("LoadLocation(%s): %v", name, err)
+		}
+		if got := localTimeParameters(loc, 2019); got != want {
+			t.Fatalf("%s: got %+v want %+v", name, got, want)
+		}
+	}
+}
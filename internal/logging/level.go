@@ -0,0 +1,44 @@
+package logging
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+type levelJSON struct {
+	Level string `json:"level"`
+}
+
+// LevelHandler serves the current level of lv on GET and changes it on PUT,
+// with a body of {"level": "debug"}. It has no authentication of its own and
+// belongs on an admin listener, not on a public port.
+func LevelHandler(lv *slog.LevelVar) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+		case http.MethodPut:
+			var body levelJSON
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&body); err != nil {
+				http.Error(w, "invalid JSON body", http.StatusBadRequest)
+				return
+			}
+			l, err := ParseLevel(body.Level)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if old := lv.Level(); old != l {
+				lv.Set(l)
+				slog.WarnContext(r.Context(), "log level changed", "from", old.String(), "to", l.String())
+			}
+		default:
+			w.Header().Set("Allow", "GET, HEAD, PUT")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(levelJSON{Level: strings.ToLower(lv.Level().String())})
+	})
+}
@@ -0,0 +1,110 @@
+// Package logging configures log/slog for both binaries: the output format,
+// a level that can be changed at runtime, request-scoped fields and access
+// log sampling.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/milad/spectral/internal/telemetry"
+)
+
+// Formats accepted by New.
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Field names shared by every log line, so the pipeline can index them
+// regardless of which process or package wrote the line.
+const (
+	KeyRequestID  = "req_id"
+	KeyTraceID    = "trace_id"
+	KeyRoute      = "route"
+	KeyMethod     = "method"
+	KeyPath       = "path"
+	KeyStatus     = "status"
+	KeyDuration   = "duration_ms"
+	KeyGRPCMethod = "grpc_method"
+	KeyGRPCCode   = "grpc_code"
+	KeyPrincipal  = "principal"
+	KeyPeer       = "peer"
+	KeyError      = "error"
+)
+
+// ParseLevel parses debug, info, warn or error (case-insensitive).
+func ParseLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return 0, fmt.Errorf("invalid log level %q (want debug, info, warn or error)", s)
+	}
+	return l, nil
+}
+
+// New returns a logger writing to w in the given format, filtered by level.
+// Records logged with a context get its request and trace IDs attached.
+func New(w io.Writer, format string, level slog.Leveler) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: level}
+	var h slog.Handler
+	switch format {
+	case FormatJSON:
+		h = slog.NewJSONHandler(w, opts)
+	case "", FormatText:
+		h = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("unknown log format %q (want json or text)", format)
+	}
+	return slog.New(contextHandler{h}), nil
+}
+
+// Setup installs a logger built by New as the slog default, which also
+// routes the standard log package (and so the gRPC and HTTP libraries)
+// through it. The returned LevelVar changes the level at runtime.
+func Setup(w io.Writer, format, level string) (*slog.LevelVar, error) {
+	l, err := ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	lv := new(slog.LevelVar)
+	lv.Set(l)
+	logger, err := New(w, format, lv)
+	if err != nil {
+		return nil, err
+	}
+	slog.SetDefault(logger)
+	return lv, nil
+}
+
+// Err returns the attribute for an error.
+func Err(err error) slog.Attr {
+	return slog.Any(KeyError, err)
+}
+
+// contextHandler adds the request and trace IDs from the record's context.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx != nil {
+		if id := telemetry.RequestIDFromContext(ctx); id != "" {
+			r.AddAttrs(slog.String(KeyRequestID, id))
+		}
+		if id := telemetry.TraceID(ctx); id != "" {
+			r.AddAttrs(slog.String(KeyTraceID, id))
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,110 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/milad/spectral/internal/telemetry"
+)
+
+func TestNew_JSONAddsContextFields(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	logger, err := New(&buf, FormatJSON, slog.LevelInfo)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx := telemetry.WithRequestID(context.Background(), "abc")
+	logger.InfoContext(ctx, "http request", KeyStatus, 200)
+	logger.DebugContext(ctx, "dropped")
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("expected exactly one JSON line, got %q: %v", buf.String(), err)
+	}
+	if got, want := line[KeyRequestID], "abc"; got != want {
+		t.Fatalf("%s=%v want %v", KeyRequestID, got, want)
+	}
+	if got, want := line[KeyStatus], 200.0; got != want {
+		t.Fatalf("%s=%v want %v", KeyStatus, got, want)
+	}
+	if _, ok := line[KeyTraceID]; ok {
+		t.Fatalf("expected no %s without a span", KeyTraceID)
+	}
+
+	if _, err := New(&buf, "xml", slog.LevelInfo); err == nil {
+		t.Fatalf("expected an error for an unknown format")
+	}
+}
+
+func TestSampler_KeepsFailuresAndARatioOfSuccesses(t *testing.T) {
+	t.Parallel()
+
+	s, err := NewSampler(0.25)
+	if err != nil {
+		t.Fatalf("NewSampler: %v", err)
+	}
+	kept := 0
+	for i := 0; i < 100; i++ {
+		if s.Keep(false) {
+			kept++
+		}
+	}
+	if got, want := kept, 25; got != want {
+		t.Fatalf("kept %d successes want %d", got, want)
+	}
+	for i := 0; i < 10; i++ {
+		if !s.Keep(true) {
+			t.Fatalf("expected every failure to be kept")
+		}
+	}
+
+	var none *Sampler
+	if !none.Keep(false) {
+		t.Fatalf("expected a nil sampler to keep everything")
+	}
+	for _, ratio := range []float64{-0.1, 1.5} {
+		if _, err := NewSampler(ratio); err == nil {
+			t.Fatalf("ratio %v: expected an error", ratio)
+		}
+	}
+}
+
+func TestLevelHandler(t *testing.T) {
+	t.Parallel()
+
+	lv := new(slog.LevelVar)
+	h := LevelHandler(lv)
+
+	do := func(method, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(method, "/loglevel", strings.NewReader(body)))
+		return rr
+	}
+
+	if rr := do(http.MethodGet, ""); strings.TrimSpace(rr.Body.String()) != `{"level":"info"}` {
+		t.Fatalf("GET body=%q", rr.Body.String())
+	}
+	if rr := do(http.MethodPut, `{"level":"DEBUG"}`); rr.Code != http.StatusOK {
+		t.Fatalf("PUT status=%d body=%s", rr.Code, rr.Body.String())
+	}
+	if got, want := lv.Level(), slog.LevelDebug; got != want {
+		t.Fatalf("level=%s want %s", got, want)
+	}
+	if got, want := do(http.MethodPut, `{"level":"loud"}`).Code, http.StatusBadRequest; got != want {
+		t.Fatalf("invalid level: status=%d want %d", got, want)
+	}
+	if got, want := do(http.MethodPost, `{"level":"warn"}`).Code, http.StatusMethodNotAllowed; got != want {
+		t.Fatalf("POST: status=%d want %d", got, want)
+	}
+	if got, want := lv.Level(), slog.LevelDebug; got != want {
+		t.Fatalf("level after rejected requests=%s want %s", got, want)
+	}
+}
@@ -0,0 +1,38 @@
+package logging
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// Sampler thins out access logs for successful requests. Failed requests are
+// always logged, since they are the ones worth reading.
+//
+// Sampling is deterministic: with a ratio of 0.1 exactly every tenth
+// successful request is logged, so low-traffic routes are not silenced by bad
+// luck.
+type Sampler struct {
+	ratio float64
+	n     atomic.Uint64
+}
+
+// NewSampler returns a sampler keeping the given fraction, within [0, 1], of
+// successful requests. A ratio of 1 logs everything.
+func NewSampler(ratio float64) (*Sampler, error) {
+	if ratio < 0 || ratio > 1 {
+		return nil, fmt.Errorf("access log sample ratio must be within [0, 1], got %v", ratio)
+	}
+	return &Sampler{ratio: ratio}, nil
+}
+
+// Keep reports whether a request should be logged. A nil Sampler keeps
+// everything.
+func (s *Sampler) Keep(failed bool) bool {
+	if s == nil || failed || s.ratio >= 1 {
+		return true
+	}
+	n := s.n.Add(1)
+	// Keep the request that pushes the running count of kept requests up by
+	// one.
+	return uint64(float64(n)*s.ratio) > uint64(float64(n-1)*s.ratio)
+}
@@ -0,0 +1,268 @@
+package csvrepo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/milad/spectral/internal/domain"
+)
+
+// Progress describes a load of one or more files, see WithProgress.
+type Progress struct {
+	Path        string // the file being read
+	File, Files int    // the position of Path (from 1) and the number of files
+	// ReadBytes and TotalBytes count the file contents as stored, so
+	// compressed files report how much of the file has been read.
+	ReadBytes  int64
+	TotalBytes int64
+	Rows       int64 // readings parsed so far from CSV files
+	Elapsed    time.Duration
+	Done       bool // the last report of the load
+}
+
+// Load reads the readings in path: a CSV file or Green Button feed, possibly
+// gzip or zstd compressed, a directory of such files, or a glob pattern
+// matching them. Readings are returned in file order. As with ParseReadings,
+// a file can be partially loaded: the error then describes what was skipped,
+// and it only matches fs.ErrNotExist if no file was found.
+func Load(path string, opts ...Option) ([]domain.Reading, error) {
+	res, parseErr, err := loadFiles(path, opts, nil)
+	if err != nil {
+		return nil, err
+	}
+	return res.readings, parseErr
+}
+
+// LoadBatches reads the readings in path like Load, but hands them to fn in
+// file order, a batch at a time, instead of holding them all in memory. fn must
+// not retain the batch. If fn fails, the load stops and its error is
+// returned. The report describes the load in any case.
+func LoadBatches(path string, fn func([]domain.Reading) error, opts ...Option) (domain.IngestionReport, error) {
+	res, parseErr, err := loadFiles(path, opts, fn)
+	if err != nil {
+		return res.report, err
+	}
+	return res.report, parseErr
+}
+
+// loadResult is what a load yields, whether or not it succeeded.
+type loadResult struct {
+	readings []domain.Reading // nil when emitted
+	// checksum identifies the contents of the files as stored. A single
+	// file's is that of its bytes; with several, names and sizes are hashed
+	// too, so that moving rows between files changes it.
+	checksum string
+	report   domain.IngestionReport
+}
+
+// loadFiles reads and parses the files at path, see Load. err is set if
+// nothing usable was loaded, or emit failed; parseErr reports files and rows
+// that were skipped otherwise. The report is set in either case. With emit,
+// readings are handed to it instead of returned.
+func loadFiles(path string, opts []Option, emit func([]domain.Reading) error) (res loadResult, parseErr, err error) {
+	rb := newReportBuilder(path)
+	paths, err := expand(path)
+	if err != nil {
+		rb.add(path, err)
+		return loadResult{report: rb.build(0, 0, false)}, nil, fmt.Errorf("open %q: %w", path, err)
+	}
+
+	l := &loader{paths: paths, sizes: make([]int64, len(paths)), start: time.Now(), hash: sha256.New(), issues: rb}
+	for i, p := range paths {
+		if fi, err := os.Stat(p); err == nil {
+			l.sizes[i] = fi.Size()
+			l.total += fi.Size()
+		}
+	}
+	o := newOptions(opts)
+	opts = append(opts[:len(opts):len(opts)], func(o *options) { o.onRows = l.addRows })
+	if emit != nil {
+		l.emit = emit
+		opts = append(opts, func(o *options) { o.emit = l.emitBatch })
+	}
+
+	loadsInProgress.Inc()
+	loadSizeBytes.Set(float64(l.total))
+	loadReadBytes.Set(0)
+	loadRows.Set(0)
+	stopReports := l.report(o.progressEvery, o.progress)
+
+	var (
+		readings []domain.Reading
+		errs     []error
+	)
+	for i := range paths {
+		rs, err := l.loadFile(i, opts)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if emit != nil {
+			// Green Button feeds are parsed whole.
+			if len(rs) > 0 {
+				l.emitBatch(rs)
+			}
+			if l.emitErr != nil {
+				break
+			}
+			continue
+		}
+		readings = append(readings, rs...)
+	}
+
+	stopReports()
+	loadsInProgress.Dec()
+	loadDuration.Observe(time.Since(l.start).Seconds())
+
+	accepted := len(readings)
+	if emit != nil {
+		accepted = l.emitted
+	}
+	if l.emitErr != nil {
+		return loadResult{report: rb.build(len(paths), accepted, false)}, nil, l.emitErr
+	}
+	err = errors.Join(errs...)
+	if accepted == 0 && err != nil {
+		return loadResult{report: rb.build(len(paths), 0, false)}, nil, err
+	}
+	if readings == nil && emit == nil {
+		readings = []domain.Reading{}
+	}
+	sum := l.hash.Sum(nil)
+	return loadResult{
+		readings: readings,
+		checksum: hex.EncodeToString(sum[:8]),
+		report:   rb.build(len(paths), accepted, true),
+	}, err, nil
+}
+
+// loader tracks a load for progress and ingestion reports.
+type loader struct {
+	paths  []string
+	sizes  []int64
+	total  int64
+	start  time.Time
+	hash   hash.Hash // of every byte read from the files
+	issues *reportBuilder
+
+	file atomic.Int64 // index of the file being read
+	read atomic.Int64
+	rows atomic.Int64
+
+	// emit receives the readings with LoadBatches; emitted counts them, and
+	// emitErr is its first error, after which readings are dropped.
+	emit    func([]domain.Reading) error
+	emitted int
+	emitErr error
+}
+
+func (l *loader) loadFile(i int, opts []Option) ([]domain.Reading, error) {
+	path := l.paths[i]
+	l.file.Store(int64(i))
+	if len(l.paths) > 1 {
+		fmt.Fprintf(l.hash, "%s\x00%d\x00", path, l.sizes[i])
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		l.issues.add(path, err)
+		return nil, fmt.Errorf("open %q: %w", path, err)
+	}
+	defer f.Close()
+
+	// Hash and count the stored bytes; the rest of the file is drained below
+	// if the parser stops early, so the checksum covers all of it.
+	src := io.TeeReader(&countingReader{r: f, l: l}, l.hash)
+	defer func() { _, _ = io.Copy(io.Discard, src) }()
+
+	r, closeDecoder, err := decompress(src)
+	if err != nil {
+		l.issues.add(path, err)
+		return nil, fmt.Errorf("open %q: %w", path, err)
+	}
+	defer closeDecoder()
+
+	readings, err := ParseReadings(r, opts...)
+	if err != nil {
+		l.issues.add(path, err)
+		return readings, fmt.Errorf("parse %q: %w", path, err)
+	}
+	return readings, nil
+}
+
+func (l *loader) emitBatch(readings []domain.Reading) {
+	if l.emitErr != nil {
+		return
+	}
+	if err := l.emit(readings); err != nil {
+		l.emitErr = err
+		return
+	}
+	l.emitted += len(readings)
+}
+
+func (l *loader) addRows(n int) {
+	l.rows.Add(int64(n))
+	loadRows.Add(float64(n))
+}
+
+func (l *loader) progress(done bool) Progress {
+	i := int(l.file.Load())
+	return Progress{
+		Path:       l.paths[i],
+		File:       i + 1,
+		Files:      len(l.paths),
+		ReadBytes:  l.read.Load(),
+		TotalBytes: l.total,
+		Rows:       l.rows.Load(),
+		Elapsed:    time.Since(l.start),
+		Done:       done,
+	}
+}
+
+// report calls fn every interval until stop is called, and then once more
+// with Done set. It does nothing if fn is nil.
+func (l *loader) report(every time.Duration, fn func(Progress)) (stop func()) {
+	if fn == nil {
+		return func() {}
+	}
+	quit := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		t := time.NewTicker(every)
+		defer t.Stop()
+		for {
+			select {
+			case <-quit:
+				return
+			case <-t.C:
+				fn(l.progress(false))
+			}
+		}
+	})
+	return func() {
+		close(quit)
+		wg.Wait()
+		fn(l.progress(true))
+	}
+}
+
+// countingReader counts the bytes read into its loader's progress.
+type countingReader struct {
+	r io.Reader
+	l *loader
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.l.read.Add(int64(n))
+	loadReadBytes.Add(float64(n))
+	return n, err
+}
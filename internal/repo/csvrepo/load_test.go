@@ -0,0 +1,196 @@
+package csvrepo
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/milad/spectral/internal/domain"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string][]byte) {
+	t.Helper()
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+}
+
+func TestLoad_CompressedFilesInADirectory(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string][]byte{
+		"2019-01.csv.gz":  gzipped(t, "meter_id,time,meterusage\na,2019-01-01 00:15:00,1\n"),
+		"2019-02.csv.zst": zstded(t, "meter_id,time,meterusage\na,2019-02-01 00:15:00,2\nb,2019-02-01 00:15:00,3\n"),
+		"2019-03.csv":     []byte("meter_id,time,meterusage\nb,2019-03-01 00:15:00,4\n"),
+	})
+
+	for path, want := range map[string][]float64{
+		dir:                               {1, 2, 3, 4},
+		filepath.Join(dir, "*.csv.[gz]*"): {1, 2, 3},
+	} {
+		readings, err := Load(path)
+		if err != nil {
+			t.Fatalf("Load(%s): %v", path, err)
+		}
+		var got []float64
+		for _, r := range readings {
+			got = append(got, r.MeterUsage)
+		}
+		if !slices.Equal(got, want) {
+			t.Fatalf("Load(%s): usage %v want %v (in file order)", path, got, want)
+		}
+	}
+
+	r, err := NewFromFile(dir)
+	if err != nil {
+		t.Fatalf("NewFromFile: %v", err)
+	}
+	meters, _ := r.ListMeters(t.Context())
+	if len(meters) != 2 || meters[0].ReadingCount != 2 || meters[1].ReadingCount != 2 {
+		t.Fatalf("meters=%+v want a and b with two readings each", meters)
+	}
+}
+
+func TestLoad_KeepsWhatPrecedesACorruptFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	full := gzipped(t, "time,meterusage\n2019-01-01 00:15:00,1\n2019-01-01 00:30:00,2\n")
+	writeFiles(t, dir, map[string][]byte{
+		"a.csv":    []byte("time,meterusage\n2019-01-01 00:00:00,1\n"),
+		"b.csv.gz": full[:len(full)-6], // cut in the gzip trailer
+	})
+
+	readings, err := Load(dir)
+	if len(readings) != 3 {
+		t.Fatalf("got %d readings want 3", len(readings))
+	}
+	if err == nil || !strings.Contains(err.Error(), `parse "`+filepath.Join(dir, "b.csv.gz")+`"`) {
+		t.Fatalf("err=%v want it to name the truncated file", err)
+	}
+
+	if _, err := Load(filepath.Join(dir, "*.zst")); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("no matches: err=%v want fs.ErrNotExist", err)
+	}
+}
+
+func TestLoad_ReportsProgress(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	var b strings.Builder
+	b.WriteString("time,meterusage\n")
+	t0 := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 10_000 {
+		b.WriteString(t0.Add(time.Duration(i)*15*time.Minute).Format(timeLayout) + ",1\n")
+	}
+	writeFiles(t, dir, map[string][]byte{"a.csv.gz": gzipped(t, b.String()), "b.csv": []byte("time,meterusage\n")})
+
+	var (
+		mu      sync.Mutex
+		reports []Progress
+	)
+	readings, err := Load(dir, WithProgress(time.Microsecond, func(p Progress) {
+		mu.Lock()
+		defer mu.Unlock()
+		reports = append(reports, p)
+	}))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	last := reports[len(reports)-1]
+	var size int64
+	for _, name := range []string{"a.csv.gz", "b.csv"} {
+		fi, _ := os.Stat(filepath.Join(dir, name))
+		size += fi.Size()
+	}
+	want := Progress{Path: filepath.Join(dir, "b.csv"), File: 2, Files: 2, ReadBytes: size, TotalBytes: size, Rows: int64(len(readings)), Done: true}
+	last.Elapsed = 0
+	if last != want {
+		t.Fatalf("last report %+v want %+v", last, want)
+	}
+	for _, p := range reports[:len(reports)-1] {
+		if p.Done || p.ReadBytes > size || p.Rows > int64(len(readings)) {
+			t.Fatalf("report %+v during the load", p)
+		}
+	}
+}
+
+func TestRepo_SortsReadingsFromSeveralFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string][]byte{
+		"a.csv": []byte("time,meterusage\n2019-01-02 00:00:00,2\n"),
+		"b.csv": []byte("time,meterusage\n2019-01-01 00:00:00,1\n"),
+	})
+	r, err := NewFromFile(dir)
+	if err != nil {
+		t.Fatalf("NewFromFile: %v", err)
+	}
+	out, _ := r.List(t.Context(), nil, nil, nil)
+	want := []domain.Reading{
+		{MeterID: domain.DefaultMeterID, Time: mustUTC(t, "2019-01-01 00:00:00"), MeterUsage: 1},
+		{MeterID: domain.DefaultMeterID, Time: mustUTC(t, "2019-01-02 00:00:00"), MeterUsage: 2},
+	}
+	if len(out) != 2 || out[0] != want[0] || out[1] != want[1] {
+		t.Fatalf("List=%+v want %+v", out, want)
+	}
+}
+
+func TestLoadBatches_StreamsReadings(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	var b strings.Builder
+	b.WriteString("time,meterusage\n")
+	t0 := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 10_000 {
+		b.WriteString(t0.Add(time.Duration(i)*15*time.Minute).Format(timeLayout) + ",1\n")
+		if i%5 == 0 {
+			b.WriteString("not-a-time,1\n")
+		}
+	}
+	writeFiles(t, dir, map[string][]byte{"a.csv": []byte(b.String())})
+
+	var batches, total int
+	report, err := LoadBatches(dir, func(readings []domain.Reading) error {
+		if len(readings) > rowBatchSize {
+			t.Fatalf("batch of %d readings, want at most %d", len(readings), rowBatchSize)
+		}
+		batches++
+		total += len(readings)
+		return nil
+	})
+	if err == nil {
+		t.Fatalf("err=nil want the invalid rows")
+	}
+	if batches < 2 || total != 10_000 {
+		t.Fatalf("%d batches of %d readings in all, want several and 10000", batches, total)
+	}
+	if !report.Applied || report.Accepted != 10_000 || report.Rejected != 2_000 || !report.IssuesTruncated || len(report.Issues) != maxReportIssues {
+		t.Fatalf("report: applied=%v accepted=%d rejected=%d truncated=%v issues=%d", report.Applied, report.Accepted, report.Rejected, report.IssuesTruncated, len(report.Issues))
+	}
+
+	failed := errors.New("insert failed")
+	batches = 0
+	report, err = LoadBatches(dir, func([]domain.Reading) error {
+		batches++
+		return failed
+	})
+	if !errors.Is(err, failed) || batches != 1 || report.Applied {
+		t.Fatalf("failing fn: err=%v after %d batches, applied=%v; want its error after one batch", err, batches, report.Applied)
+	}
+}
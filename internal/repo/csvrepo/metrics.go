@@ -0,0 +1,30 @@
+package csvrepo
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	loadsInProgress = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "csv_load_in_progress",
+		Help: "Number of CSV loads (at startup, reloads and seeding) in progress.",
+	})
+	loadReadBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "csv_load_read_bytes",
+		Help: "Bytes read by the current or last CSV load, as stored (compressed files count compressed bytes).",
+	})
+	loadSizeBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "csv_load_size_bytes",
+		Help: "Total size of the files of the current or last CSV load.",
+	})
+	loadRows = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "csv_load_rows",
+		Help: "Readings parsed by the current or last CSV load.",
+	})
+	loadDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "csv_load_duration_seconds",
+		Help:    "Time taken to read and parse the CSV files of a load.",
+		Buckets: prometheus.ExponentialBuckets(0.01, 4, 10),
+	})
+)
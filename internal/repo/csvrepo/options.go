@@ -0,0 +1,72 @@
+package csvrepo
+
+import (
+	"runtime"
+	"time"
+
+	"github.com/milad/spectral/internal/domain"
+)
+
+// Option configures how CSV files are parsed.
+type Option func(*options)
+
+type options struct {
+	location    *time.Location
+	schema      Schema
+	parallelism int
+
+	progressEvery time.Duration
+	progress      func(Progress)
+
+	// onRows, if set, is told how many readings each parsed batch yielded.
+	onRows func(n int)
+	// emit, if set, is handed the readings of each parsed CSV batch, which
+	// ParseReadingsCSV then does not return.
+	emit func([]domain.Reading)
+}
+
+// WithLocation interprets the wall-clock times in the file in loc instead of
+// UTC. Times skipped by a DST change are rejected; times repeated by one are
+// resolved using the order of each meter's readings, see ParseReadingsCSV.
+func WithLocation(loc *time.Location) Option {
+	return func(o *options) {
+		if loc != nil {
+			o.location = loc
+		}
+	}
+}
+
+// WithSchema reads files laid out as s instead of DefaultSchema. An invalid
+// schema makes every parse fail; check it with Schema.Validate first.
+func WithSchema(s Schema) Option {
+	return func(o *options) { o.schema = s }
+}
+
+// WithParallelism parses CSV rows on n goroutines. n < 1 means one per CPU,
+// the default.
+func WithParallelism(n int) Option {
+	return func(o *options) {
+		if n < 1 {
+			n = runtime.GOMAXPROCS(0)
+		}
+		o.parallelism = n
+	}
+}
+
+// WithProgress calls fn every interval while files are loaded, and once when
+// a load is done. It does not apply to ParseReadings and ParseReadingsCSV.
+func WithProgress(every time.Duration, fn func(Progress)) Option {
+	return func(o *options) {
+		if every > 0 && fn != nil {
+			o.progressEvery, o.progress = every, fn
+		}
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{location: time.UTC, schema: DefaultSchema(), parallelism: runtime.GOMAXPROCS(0)}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
@@ -1,87 +1,362 @@
 package csvrepo
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/csv"
 	"errors"
 	"fmt"
 	"io"
 	"math"
-	"strconv"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/milad/spectral/internal/domain"
+	"github.com/milad/spectral/internal/greenbutton"
 )
 
 const (
 	timeLayout = "2006-01-02 15:04:05"
+
+	// maxRowErrors bounds the *RowError a parse returns; invalid rows past it
+	// are only counted, in an *OmittedRowErrors.
+	maxRowErrors = 1_000
 )
 
+// ParseReadings parses readings from a CSV file (see ParseReadingsCSV) or a
+// Green Button XML feed (see greenbutton.Parse), told apart by their first
+// character. Options only apply to CSV: feed times are UTC.
+func ParseReadings(r io.Reader, opts ...Option) ([]domain.Reading, error) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(512)
+	head = bytes.TrimLeft(bytes.TrimPrefix(head, []byte("\xef\xbb\xbf")), " \t\r\n")
+	if bytes.HasPrefix(head, []byte("<")) {
+		return greenbutton.Parse(br)
+	}
+	return ParseReadingsCSV(br, opts...)
+}
+
 // ParseReadingsCSV parses readings from the provided CSV reader.
 //
-// Expected header: time,meterusage
+// The file is read as described by the WithSchema option, DefaultSchema if not
+// given: header time,meterusage with an optional meter_id column in any
+// position (e.g. meter_id,time,meterusage). Without a meter ID column, all
+// readings belong to domain.DefaultMeterID.
+//
+// Wall-clock times (by default in the layout "2006-01-02 15:04:05") are
+// interpreted as UTC, or in the zone set with WithLocation. Local times that do
+// not exist because the clocks were put forward are rejected. Times that occur
+// twice because the clocks were put back resolve to the first occurrence,
+// unless that would be earlier than the meter's previous reading in the file:
+// meters log in order, so the repeated hour is then taken as the second
+// occurrence.
 //
-// Times are parsed using layout "2006-01-02 15:04:05" and interpreted as UTC.
-// Invalid rows are skipped and returned as a joined error (errors.Join).
-func ParseReadingsCSV(r io.Reader) ([]domain.Reading, error) {
+// Rows are parsed in batches on the goroutines set with WithParallelism; the
+// result is the same as a sequential parse, in file order.
+//
+// Invalid rows are skipped and returned as a joined error (errors.Join) of
+// *RowError, the first 1000 of them, then an *OmittedRowErrors counting the
+// rest.
+func ParseReadingsCSV(r io.Reader, opts ...Option) ([]domain.Reading, error) {
+	o := newOptions(opts)
+	schema, err := o.schema.compile()
+	if err != nil {
+		return nil, err
+	}
 	cr := csv.NewReader(r)
+	cr.Comma = schema.delimiter
 	cr.FieldsPerRecord = -1 // be permissive; validate ourselves
-	cr.TrimLeadingSpace = true
+	cr.TrimLeadingSpace = schema.trimLeadingSpace()
 
-	header, err := cr.Read()
-	if err != nil {
-		return nil, fmt.Errorf("read header: %w", err)
+	var header []string
+	rowNum := 0
+	if schema.header {
+		if header, err = cr.Read(); err != nil {
+			return nil, fmt.Errorf("read header: %w", err)
+		}
+		rowNum++
 	}
-	if len(header) < 2 || strings.ToLower(strings.TrimSpace(header[0])) != "time" || strings.ToLower(strings.TrimSpace(header[1])) != "meterusage" {
-		return nil, fmt.Errorf("unexpected header %q (want %q)", strings.Join(header, ","), "time,meterusage")
+	cols, err := schema.resolve(header)
+	if err != nil {
+		return nil, err
 	}
+	p := rowParser{schema: schema, cols: cols, location: o.location}
 
 	var (
 		readings []domain.Reading
 		rowErrs  []error
-		rowNum   = 1 // header
+		omitted  OmittedRowErrors
+		// lastTime holds the previous reading of each meter, to resolve
+		// ambiguous local times.
+		lastTime = map[string]time.Time{}
 	)
+	addRowErr := func(err *RowError) {
+		if len(rowErrs) < maxRowErrors {
+			rowErrs = append(rowErrs, err)
+			return
+		}
+		if omitted.Counts == nil {
+			omitted.Counts = map[domain.IngestionCategory]int{}
+		}
+		omitted.Counts[err.Category]++
+	}
+	parseRows(cr, rowNum, o.parallelism, p.parse, func(batch []parsedRow) {
+		n := len(readings)
+		for _, row := range batch {
+			t := row.early
+			if !t.IsZero() {
+				if prev, seen := lastTime[row.meterID]; seen && t.Before(prev) {
+					t = row.late
+				}
+				lastTime[row.meterID] = t
+			}
+			if row.err != nil {
+				addRowErr(row.err)
+				continue
+			}
 
-	for {
-		row, err := cr.Read()
-		if err == io.EOF {
-			break
+			reading := domain.Reading{
+				MeterID:    row.meterID,
+				Time:       t.UTC(),
+				MeterUsage: row.usage,
+			}
+			if err := reading.Validate(); err != nil {
+				category := domain.IngestInvalidReading
+				if math.IsNaN(reading.MeterUsage) || math.IsInf(reading.MeterUsage, 0) {
+					category = domain.IngestNonFiniteUsage
+				}
+				addRowErr(&RowError{Row: row.num, Values: row.values, Category: category, Err: err})
+				continue
+			}
+			readings = append(readings, reading)
 		}
-		rowNum++
-		if err != nil {
-			rowErrs = append(rowErrs, fmt.Errorf("row %d: read: %w", rowNum, err))
-			continue
+		if o.onRows != nil {
+			o.onRows(len(readings) - n)
 		}
-		if len(row) < 2 {
-			rowErrs = append(rowErrs, fmt.Errorf("row %d: expected 2 columns, got %d", rowNum, len(row)))
-			continue
+		if o.emit != nil && len(readings) > 0 {
+			o.emit(readings)
+			readings = nil
+		}
+	})
+
+	if omitted.Counts != nil {
+		rowErrs = append(rowErrs, &omitted)
+	}
+	// Ensure we return stable, non-nil slice.
+	if readings == nil {
+		readings = []domain.Reading{}
+	}
+	return readings, errors.Join(rowErrs...)
+}
+
+// RowError is a CSV row that was skipped, with the reason in Err.
+type RowError struct {
+	Row      int      // 1-based, the header included
+	Values   []string // the fields of the row; nil if it could not be read
+	Category domain.IngestionCategory
+	Err      error
+}
+
+func (e *RowError) Error() string { return fmt.Sprintf("row %d: %v", e.Row, e.Err) }
+
+// OmittedRowErrors counts the invalid rows of a parse past the first 1000,
+// which are not returned one by one.
+type OmittedRowErrors struct {
+	Counts map[domain.IngestionCategory]int
+}
+
+func (e *OmittedRowErrors) Error() string {
+	n := 0
+	for _, c := range e.Counts {
+		n += c
+	}
+	return fmt.Sprintf("%d more invalid rows", n)
+}
+
+func (e *RowError) Unwrap() error { return e.Err }
+
+// rowBatchSize is the number of rows a worker parses at a time.
+const rowBatchSize = 4096
+
+// rawRow is a record as read from the file, or the error reading it.
+type rawRow struct {
+	num    int
+	fields []string
+	err    error
+}
+
+// parsedRow is a parsed record. Ambiguous local times depend on the rows
+// before, so they are resolved in file order: early and late are the two
+// instants a time may denote, the same instant for unambiguous times, and
+// zero if the time could not be parsed.
+type parsedRow struct {
+	num         int
+	values      []string
+	meterID     string
+	early, late time.Time
+	usage       float64
+	err         *RowError
+}
+
+// rowParser parses the fields of a record; it is safe for concurrent use.
+type rowParser struct {
+	schema   compiledSchema
+	cols     columns
+	location *time.Location
+}
+
+func (p rowParser) parse(row rawRow) parsedRow {
+	out := parsedRow{num: row.num, values: row.fields, meterID: domain.DefaultMeterID}
+	fail := func(category domain.IngestionCategory, err error) parsedRow {
+		out.err = &RowError{Row: row.num, Values: row.fields, Category: category, Err: err}
+		return out
+	}
+	if row.err != nil {
+		category := domain.IngestMalformedRow
+		var perr *csv.ParseError
+		if !errors.As(row.err, &perr) {
+			category = domain.IngestInvalidFile
 		}
+		return fail(category, fmt.Errorf("read: %w", row.err))
+	}
+	if len(row.fields) < p.cols.width {
+		return fail(domain.IngestMissingColumns, fmt.Errorf("expected %d columns, got %d", p.cols.width, len(row.fields)))
+	}
 
-		t, err := time.ParseInLocation(timeLayout, strings.TrimSpace(row[0]), time.UTC)
-		if err != nil {
-			rowErrs = append(rowErrs, fmt.Errorf("row %d: parse time %q: %w", rowNum, row[0], err))
-			continue
+	if p.cols.meterID >= 0 {
+		out.meterID = strings.TrimSpace(row.fields[p.cols.meterID])
+		if out.meterID == "" {
+			return fail(domain.IngestMissingMeterID, errors.New("missing meter_id"))
 		}
+	}
 
-		f, err := strconv.ParseFloat(strings.TrimSpace(row[1]), 64)
-		if err != nil {
-			rowErrs = append(rowErrs, fmt.Errorf("row %d: parse meterusage %q: %w", rowNum, row[1], err))
-			continue
+	v := row.fields[p.cols.time]
+	t, wall, err := p.schema.parseTime(v)
+	if err != nil {
+		return fail(domain.IngestInvalidTime, fmt.Errorf("parse time %q: %w", v, err))
+	}
+	out.early, out.late = t, t
+	if wall {
+		var ok bool
+		if out.early, out.late, ok = resolveLocal(t, p.location); !ok {
+			return fail(domain.IngestNonexistentTime, fmt.Errorf("time %q does not exist in %s (skipped by a DST change)", v, p.location))
 		}
-		if math.IsNaN(f) || math.IsInf(f, 0) {
-			rowErrs = append(rowErrs, fmt.Errorf("row %d: invalid meterusage %v", rowNum, f))
-			continue
+	}
+
+	v = row.fields[p.cols.usage]
+	if out.usage, err = p.schema.parseUsage(v); err != nil {
+		return fail(domain.IngestInvalidUsage, fmt.Errorf("parse meterusage %q: %w", v, err))
+	}
+	return out
+}
+
+// parseRows reads the records of cr, numbering them after rowNum, and parses
+// them rowBatchSize at a time on the given number of goroutines. emit receives
+// the batches in file order. At most two batches per goroutine are held in
+// memory, so a large file is never read far ahead of emit.
+//
+// Reading stops at the first error that is not about a single record, such as
+// a truncated compressed stream; the error is reported as that row's.
+func parseRows(cr *csv.Reader, rowNum, workers int, parse func(rawRow) parsedRow, emit func([]parsedRow)) {
+	type batch struct {
+		seq  int
+		rows []rawRow
+		out  []parsedRow
+	}
+	var (
+		jobs  = make(chan *batch)
+		done  = make(chan *batch)
+		slots = make(chan struct{}, 2*workers)
+	)
+
+	go func() {
+		defer close(jobs)
+		for seq, eof := 0, false; !eof; seq++ {
+			slots <- struct{}{}
+			b := &batch{seq: seq, rows: make([]rawRow, 0, rowBatchSize)}
+			for len(b.rows) < rowBatchSize {
+				fields, err := cr.Read()
+				if err == io.EOF {
+					eof = true
+					break
+				}
+				rowNum++
+				b.rows = append(b.rows, rawRow{num: rowNum, fields: fields, err: err})
+				var perr *csv.ParseError
+				if err != nil && !errors.As(err, &perr) {
+					eof = true
+					break
+				}
+			}
+			jobs <- b
 		}
+	}()
 
-		readings = append(readings, domain.Reading{
-			Time:       t,
-			MeterUsage: f,
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Go(func() {
+			for b := range jobs {
+				b.out = make([]parsedRow, len(b.rows))
+				for i, row := range b.rows {
+					b.out[i] = parse(row)
+				}
+				b.rows = nil
+				done <- b
+			}
 		})
 	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
 
-	// Ensure we return stable, non-nil slice.
-	if readings == nil {
-		readings = []domain.Reading{}
+	pending := map[int]*batch{}
+	next := 0
+	for b := range done {
+		pending[b.seq] = b
+		for b, ok := pending[next]; ok; b, ok = pending[next] {
+			delete(pending, next)
+			emit(b.out)
+			<-slots
+			next++
+		}
 	}
-	return readings, errors.Join(rowErrs...)
+}
+
+// resolveLocal maps a wall-clock time (given with a UTC location) to the
+// instants it denotes in loc. early and late differ only for times repeated
+// when the clocks go back; ok is false for times skipped when they go forward.
+func resolveLocal(wall time.Time, loc *time.Location) (early, late time.Time, ok bool) {
+	if loc == time.UTC {
+		return wall, wall, true
+	}
+	var found []time.Time
+	// A DST change shifts the offset at most once within a day, so the offsets
+	// a day before and after cover every candidate.
+	for _, probe := range []time.Time{wall.Add(-24 * time.Hour), wall.Add(24 * time.Hour)} {
+		_, offset := probe.In(loc).Zone()
+		c := wall.Add(-time.Duration(offset) * time.Second)
+		if sameWallClock(c.In(loc), wall) && !slices.ContainsFunc(found, c.Equal) {
+			found = append(found, c)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return time.Time{}, time.Time{}, false
+	case 1:
+		return found[0], found[0], true
+	}
+	if found[1].Before(found[0]) {
+		found[0], found[1] = found[1], found[0]
+	}
+	return found[0], found[1], true
+}
+
+func sameWallClock(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd &&
+		a.Hour() == b.Hour() && a.Minute() == b.Minute() && a.Second() == b.Second() && a.Nanosecond() == b.Nanosecond()
 }
@@ -1,9 +1,15 @@
 package csvrepo
 
 import (
+	"errors"
+	"fmt"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
+	_ "time/tzdata" // tests must not depend on the host's zoneinfo
+
+	"github.com/milad/spectral/internal/domain"
 )
 
 func TestParseReadingsCSV_OK(t *testing.T) {
@@ -28,6 +34,43 @@ time,meterusage
 	if got, want := readings[0].MeterUsage, 55.09; got != want {
 		t.Fatalf("meter usage[0]=%v want %v", got, want)
 	}
+	if got, want := readings[0].MeterID, domain.DefaultMeterID; got != want {
+		t.Fatalf("meter id[0]=%q want %q", got, want)
+	}
+}
+
+func TestParseReadingsCSV_MeterIDColumn(t *testing.T) {
+	t.Parallel()
+
+	csv := strings.NewReader(strings.TrimSpace(`
+meter_id,time,meterusage
+site-a,2019-01-01 00:15:00,55.09
+site-b,2019-01-01 00:15:00,12.5
+,2019-01-01 00:30:00,1.0
+`))
+
+	readings, err := ParseReadingsCSV(csv)
+	if err == nil {
+		t.Fatalf("expected error for missing meter_id, got nil")
+	}
+	if got, want := len(readings), 2; got != want {
+		t.Fatalf("len(readings)=%d want %d", got, want)
+	}
+	if readings[0].MeterID != "site-a" || readings[1].MeterID != "site-b" {
+		t.Fatalf("unexpected meter ids: %q, %q", readings[0].MeterID, readings[1].MeterID)
+	}
+	if got, want := readings[1].MeterUsage, 12.5; got != want {
+		t.Fatalf("meter usage[1]=%v want %v", got, want)
+	}
+}
+
+func TestParseReadingsCSV_RejectsUnknownHeader(t *testing.T) {
+	t.Parallel()
+
+	_, err := ParseReadingsCSV(strings.NewReader("timestamp,usage\n"))
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
 }
 
 func TestParseReadingsCSV_SkipsInvalidRows(t *testing.T) {
@@ -49,3 +92,153 @@ not-a-time,12.0
 		t.Fatalf("len(readings)=%d want %d", got, want)
 	}
 }
+
+func TestParseReadingsCSV_LocalTimeAcrossDST(t *testing.T) {
+	t.Parallel()
+
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("load location: %v", err)
+	}
+
+	// On 2019-11-03 New York clocks went back from 02:00 EDT to 01:00 EST, so
+	// 01:00-01:59 occurs twice; meter a logs both passes, meter b only one.
+	// On 2019-03-10 they went forward from 02:00 to 03:00, so 02:30 does not exist.
+	csv := strings.NewReader(strings.TrimSpace(`
+meter_id,time,meterusage
+a,2019-11-03 00:45:00,1
+a,2019-11-03 01:30:00,2
+a,2019-11-03 01:00:00,3
+a,2019-11-03 01:30:00,4
+b,2019-11-03 01:30:00,5
+a,2019-03-10 02:30:00,6
+a,2019-01-01 00:00:00,7
+`))
+
+	readings, err := ParseReadingsCSV(csv, WithLocation(ny))
+	if err == nil || !strings.Contains(err.Error(), "row 7") {
+		t.Fatalf("expected an error for the skipped time on row 7, got %v", err)
+	}
+
+	want := []string{
+		"2019-11-03T04:45:00Z", // 00:45 EDT
+		"2019-11-03T05:30:00Z", // 01:30 EDT, first pass
+		"2019-11-03T06:00:00Z", // 01:00 EST: earlier than the previous reading, so second pass
+		"2019-11-03T06:30:00Z", // 01:30 EST
+		"2019-11-03T05:30:00Z", // meter b has no earlier reading: first pass
+		"2019-01-01T05:00:00Z", // 00:00 EST
+	}
+	if got := len(readings); got != len(want) {
+		t.Fatalf("len(readings)=%d want %d", got, len(want))
+	}
+	for i, w := range want {
+		if got := readings[i].Time.Format(time.RFC3339); got != w {
+			t.Fatalf("readings[%d].Time=%s want %s", i, got, w)
+		}
+		if readings[i].Time.Location() != time.UTC {
+			t.Fatalf("readings[%d] location=%v want UTC", i, readings[i].Time.Location())
+		}
+	}
+}
+
+func TestParseReadingsCSV_ParallelMatchesSequential(t *testing.T) {
+	t.Parallel()
+
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("load location: %v", err)
+	}
+	// Several batches of rows, with the repeated hour of 2019-11-03 and an
+	// invalid row straddling the first batch boundary.
+	var b strings.Builder
+	b.WriteString("meter_id,time,meterusage\n")
+	t0 := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	for row := 2; row <= 3*rowBatchSize+100; row++ {
+		switch row {
+		case rowBatchSize + 1:
+			b.WriteString("m0,2019-11-03 01:45:00,1\nm0,2019-11-03 01:15:00,2\n")
+			row++
+		case rowBatchSize + 3, 2*rowBatchSize + 7:
+			b.WriteString("m1,not a time,1\n")
+		default:
+			fmt.Fprintf(&b, "m%d,%s,%d\n", row%3, t0.Add(time.Duration(row)*5*time.Minute).Format(timeLayout), row)
+		}
+	}
+
+	seq, seqErr := ParseReadingsCSV(strings.NewReader(b.String()), WithLocation(ny), WithParallelism(1))
+	par, parErr := ParseReadingsCSV(strings.NewReader(b.String()), WithLocation(ny), WithParallelism(8))
+	if !reflect.DeepEqual(seq, par) {
+		t.Fatalf("parallel parse differs from the sequential one")
+	}
+	if seqErr == nil || parErr == nil || seqErr.Error() != parErr.Error() {
+		t.Fatalf("errors differ: %v\n%v", seqErr, parErr)
+	}
+	for _, want := range []string{fmt.Sprintf("row %d:", rowBatchSize+3), fmt.Sprintf("row %d:", 2*rowBatchSize+7)} {
+		if !strings.Contains(parErr.Error(), want) {
+			t.Fatalf("err=%v want it to mention %q", parErr, want)
+		}
+	}
+	if got, want := len(par), 3*rowBatchSize+99-2; got != want {
+		t.Fatalf("len(readings)=%d want %d", got, want)
+	}
+	// 01:45 follows readings from January: EDT. 01:15 is then earlier than
+	// the meter's previous reading: EST.
+	if got, want := par[rowBatchSize-1].Time, time.Date(2019, 11, 3, 5, 45, 0, 0, time.UTC); !got.Equal(want) {
+		t.Fatalf("repeated hour on the batch boundary: got %v want %v", got, want)
+	}
+	if got, want := par[rowBatchSize].Time, time.Date(2019, 11, 3, 6, 15, 0, 0, time.UTC); !got.Equal(want) {
+		t.Fatalf("repeated hour on the batch boundary: got %v want %v", got, want)
+	}
+}
+
+func TestParseReadings_DetectsFormat(t *testing.T) {
+	t.Parallel()
+
+	feed := "\xef\xbb\xbf\n" + `<feed xmlns="http://www.w3.org/2005/Atom"><entry><content>
+<ReadingType xmlns="http://naesb.org/espi"><uom>72</uom></ReadingType>
+<IntervalBlock xmlns="http://naesb.org/espi"><IntervalReading><timePeriod><duration>900</duration><start>1546300800</start></timePeriod><value>55090</value></IntervalReading></IntervalBlock>
+</content></entry></feed>`
+	for name, in := range map[string]string{
+		"csv":          "time,meterusage\n2019-01-01 00:15:00,55.09\n",
+		"green button": feed,
+	} {
+		readings, err := ParseReadings(strings.NewReader(in))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		want := time.Date(2019, 1, 1, 0, 15, 0, 0, time.UTC)
+		if len(readings) != 1 || !readings[0].Time.Equal(want) || readings[0].MeterUsage != 55.09 {
+			t.Fatalf("%s: got %+v", name, readings)
+		}
+	}
+}
+
+func TestParseReadingsCSV_CapsRowErrors(t *testing.T) {
+	t.Parallel()
+
+	var b strings.Builder
+	b.WriteString("time,meterusage\n2019-01-01 00:15:00,1\n")
+	for range maxRowErrors + 500 {
+		b.WriteString("not-a-time,1\n")
+	}
+
+	readings, err := ParseReadingsCSV(strings.NewReader(b.String()))
+	if len(readings) != 1 {
+		t.Fatalf("len(readings)=%d want 1", len(readings))
+	}
+	var rowErrs int
+	var omitted *OmittedRowErrors
+	for _, err := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var rowErr *RowError
+		switch {
+		case errors.As(err, &rowErr):
+			rowErrs++
+		case errors.As(err, &omitted):
+		default:
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+	if rowErrs != maxRowErrors || omitted == nil || omitted.Counts[domain.IngestInvalidTime] != 500 {
+		t.Fatalf("row errors=%d omitted=%+v want %d and 500 invalid times", rowErrs, omitted, maxRowErrors)
+	}
+}
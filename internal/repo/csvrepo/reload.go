@@ -0,0 +1,110 @@
+package csvrepo
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+)
+
+// ErrNotFileBacked is returned by Reload for repos that were not created with NewFromFile.
+var ErrNotFileBacked = errors.New("repository is not backed by a file")
+
+// Reload re-reads the CSV files the repo was created from and, if their contents
+// changed, atomically replaces the served readings. Readings added with Append
+// are kept. The file is parsed before any lock that readers or writers need is
+// taken, so serving continues while a large file is loaded.
+//
+// If the files cannot be read or yield no readings, the current snapshot keeps
+// being served and an error is returned with changed=false. As with
+// NewFromFile, a partially successful parse is applied: changed is true and
+// err describes the rows that were skipped.
+func (r *Repo) Reload(ctx context.Context) (changed bool, err error) {
+	if r.path == "" {
+		return false, ErrNotFileBacked
+	}
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+
+	res, parseErr, err := loadFiles(r.path, r.opts, nil)
+	r.report.Store(&res.report)
+	if err != nil {
+		return false, err
+	}
+	if res.checksum == r.source {
+		return false, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	sortReadings(res.readings)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.source = res.checksum
+	r.storeLocked(mergeReadings(res.readings, r.appended), res.checksum)
+	return true, parseErr
+}
+
+// Watch polls the CSV files every interval and calls Reload once a change has
+// settled, i.e. the number of files, their total size and latest modification
+// time were the same on two consecutive polls, so half-written files are not
+// picked up. onReload, if
+// non-nil, receives the result of every attempted reload. Watch blocks until
+// ctx is done.
+func (r *Repo) Watch(ctx context.Context, interval time.Duration, onReload func(changed bool, err error)) {
+	// The zero stamp never matches a real file, so the first settled poll
+	// reloads; that catches changes made between NewFromFile and Watch, and
+	// is a cheap no-op (checksum match) otherwise.
+	var last, pending fileStamp
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		// The file may be briefly missing while it is being replaced.
+		cur, err := stat(r.path)
+		if err != nil || cur == last {
+			pending = fileStamp{}
+			continue
+		}
+		if cur != pending {
+			pending = cur
+			continue
+		}
+		last, pending = cur, fileStamp{}
+
+		changed, err := r.Reload(ctx)
+		if onReload != nil && ctx.Err() == nil {
+			onReload(changed, err)
+		}
+	}
+}
+
+type fileStamp struct {
+	files   int
+	size    int64
+	modTime int64 // UnixNano, of the most recently modified file
+}
+
+func stat(path string) (fileStamp, error) {
+	paths, err := expand(path)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	s := fileStamp{files: len(paths)}
+	for _, p := range paths {
+		fi, err := os.Stat(p)
+		if err != nil {
+			return fileStamp{}, err
+		}
+		s.size += fi.Size()
+		s.modTime = max(s.modTime, fi.ModTime().UnixNano())
+	}
+	return s, nil
+}
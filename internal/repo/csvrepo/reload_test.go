@@ -0,0 +1,201 @@
+package csvrepo
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/milad/spectral/internal/domain"
+)
+
+func writeCSV(t *testing.T, path, body string) {
+	t.Helper()
+	// Write then rename, like a deploy would, so readers never see a partial file.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(body), 0o644); err != nil {
+		t.Fatalf("write %s: %v", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatalf("rename %s: %v", tmp, err)
+	}
+}
+
+func TestRepo_ReloadSwapsSnapshotAndKeepsAppended(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	path := filepath.Join(t.TempDir(), "meterusage.csv")
+	writeCSV(t, path, "time,meterusage\n2019-01-01 00:15:00,1\n")
+	r, err := NewFromFile(path)
+	if err != nil {
+		t.Fatalf("NewFromFile: %v", err)
+	}
+	before, _ := r.Dataset(ctx)
+	if got, want := before.Rows, 1; got != want {
+		t.Fatalf("Rows=%d want %d", got, want)
+	}
+	old, _ := r.List(ctx, nil, nil, nil)
+
+	if _, err := r.Append(ctx, "", "", []domain.Reading{{Time: mustUTC(t, "2019-01-02 00:00:00"), MeterUsage: 9}}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+
+	changed, err := r.Reload(ctx)
+	if err != nil || changed {
+		t.Fatalf("Reload of unchanged file: changed=%v err=%v", changed, err)
+	}
+
+	writeCSV(t, path, "time,meterusage\n2019-01-01 00:15:00,1\n2019-01-01 00:30:00,2\n")
+	changed, err = r.Reload(ctx)
+	if err != nil || !changed {
+		t.Fatalf("Reload: changed=%v err=%v", changed, err)
+	}
+
+	out, _ := r.List(ctx, nil, nil, nil)
+	if got, want := len(out), 3; got != want {
+		t.Fatalf("len(out)=%d want %d", got, want)
+	}
+	if got, want := out[2].MeterUsage, 9.0; got != want {
+		t.Fatalf("appended reading lost: out[2].MeterUsage=%v want %v", got, want)
+	}
+	if got, want := len(old), 1; got != want {
+		t.Fatalf("earlier List result changed: len=%d want %d", got, want)
+	}
+
+	after, _ := r.Dataset(ctx)
+	if after.Version <= before.Version || after.Checksum == before.Checksum || after.Rows != 3 {
+		t.Fatalf("unexpected dataset after reload: before=%+v after=%+v", before, after)
+	}
+}
+
+func TestRepo_ReloadFailureKeepsSnapshot(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	path := filepath.Join(t.TempDir(), "meterusage.csv")
+	writeCSV(t, path, "time,meterusage\n2019-01-01 00:15:00,1\n")
+	r, err := NewFromFile(path)
+	if err != nil {
+		t.Fatalf("NewFromFile: %v", err)
+	}
+	before, _ := r.Dataset(ctx)
+
+	writeCSV(t, path, "not,a,valid,header\n")
+	changed, err := r.Reload(ctx)
+	if err == nil || changed {
+		t.Fatalf("expected failed reload, got changed=%v err=%v", changed, err)
+	}
+
+	after, _ := r.Dataset(ctx)
+	if after != before {
+		t.Fatalf("dataset changed after failed reload: before=%+v after=%+v", before, after)
+	}
+	out, _ := r.List(ctx, nil, nil, nil)
+	if got, want := len(out), 1; got != want {
+		t.Fatalf("len(out)=%d want %d", got, want)
+	}
+}
+
+func TestRepo_PendingLoadsOnReload(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	path := filepath.Join(t.TempDir(), "meterusage.csv")
+	r := NewPending(path)
+	if ds, _ := r.Dataset(ctx); ds.Rows != 0 {
+		t.Fatalf("Rows=%d want 0 before the file exists", ds.Rows)
+	}
+	if changed, err := r.Reload(ctx); err == nil || changed {
+		t.Fatalf("Reload of a missing file: changed=%v err=%v", changed, err)
+	}
+
+	pending, _ := r.Dataset(ctx)
+	writeCSV(t, path, "time,meterusage\n2019-01-01 00:15:00,1\n")
+	if changed, err := r.Reload(ctx); err != nil || !changed {
+		t.Fatalf("Reload: changed=%v err=%v", changed, err)
+	}
+	if ds, _ := r.Dataset(ctx); ds.Rows != 1 || ds.Version <= pending.Version {
+		t.Fatalf("dataset=%+v want 1 row at a version above %d", ds, pending.Version)
+	}
+}
+
+func TestRepo_ReloadRequiresFile(t *testing.T) {
+	t.Parallel()
+
+	if _, err := New(nil).Reload(context.Background()); !errors.Is(err, ErrNotFileBacked) {
+		t.Fatalf("expected ErrNotFileBacked, got %v", err)
+	}
+}
+
+func TestRepo_WatchReloadsChangedFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "meterusage.csv")
+	writeCSV(t, path, "time,meterusage\n2019-01-01 00:15:00,1\n")
+	r, err := NewFromFile(path)
+	if err != nil {
+		t.Fatalf("NewFromFile: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reloaded := make(chan struct{}, 1)
+	go r.Watch(ctx, 5*time.Millisecond, func(changed bool, err error) {
+		if changed && err == nil {
+			select {
+			case reloaded <- struct{}{}:
+			default:
+			}
+		}
+	})
+
+	writeCSV(t, path, "time,meterusage\n2019-01-01 00:15:00,1\n2019-01-01 00:30:00,2\n")
+	select {
+	case <-reloaded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for reload")
+	}
+
+	ds, _ := r.Dataset(context.Background())
+	if got, want := ds.Rows, 2; got != want {
+		t.Fatalf("Rows=%d want %d", got, want)
+	}
+}
+
+func TestRepo_WatchReloadsWhenAFileIsAdded(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeCSV(t, filepath.Join(dir, "2019-01.csv"), "time,meterusage\n2019-01-01 00:15:00,1\n")
+	r, err := NewFromFile(filepath.Join(dir, "*.csv"))
+	if err != nil {
+		t.Fatalf("NewFromFile: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reloaded := make(chan struct{}, 1)
+	go r.Watch(ctx, 5*time.Millisecond, func(changed bool, err error) {
+		if changed && err == nil {
+			select {
+			case reloaded <- struct{}{}:
+			default:
+			}
+		}
+	})
+
+	writeCSV(t, filepath.Join(dir, "2019-02.csv"), "time,meterusage\n2019-02-01 00:15:00,2\n")
+	select {
+	case <-reloaded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for reload")
+	}
+
+	ds, _ := r.Dataset(context.Background())
+	if got, want := ds.Rows, 2; got != want {
+		t.Fatalf("Rows=%d want %d", got, want)
+	}
+}
@@ -2,52 +2,217 @@ package csvrepo
 
 import (
 	"context"
-	"fmt"
-	"os"
+	"slices"
 	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/milad/spectral/internal/domain"
 	"github.com/milad/spectral/internal/repo"
 )
 
-var _ repo.ReadingRepository = (*Repo)(nil)
+var (
+	_ repo.WritableReadingRepository = (*Repo)(nil)
+	_ repo.PagedReadingRepository    = (*Repo)(nil)
+	_ repo.SeekingReadingRepository  = (*Repo)(nil)
+	_ repo.IngestionReporter         = (*Repo)(nil)
+)
+
+// maxIdempotencyKeys bounds the memory used to remember appended batches; the
+// oldest keys are forgotten first.
+const maxIdempotencyKeys = 10_000
 
-// Repo is an in-memory repository backed by a CSV file loaded at startup.
+// Repo is an in-memory repository backed by CSV files loaded at startup.
+// Appended readings are kept in memory only and are lost on restart.
+//
+// A file-backed Repo can be refreshed with Reload (or Watch) while serving;
+// readers keep the snapshot they started with.
 type Repo struct {
-	readings []domain.Reading // sorted ascending by Time
+	path string // source file, directory or pattern; empty for repos built with New
+	opts []Option
+
+	// snap is replaced wholesale on every write, so slices handed out by List
+	// stay valid and unchanged for readers.
+	snap atomic.Pointer[snapshot]
+	// report describes the last load, including loads that failed or did
+	// not change the snapshot.
+	report atomic.Pointer[domain.IngestionReport]
+
+	reloadMu sync.Mutex       // serializes reloads, including the parse
+	mu       sync.Mutex       // serializes writers
+	appended []domain.Reading // readings added by Append, re-applied on reload
+	digest   repo.Digest      // of appended
+	source   string           // checksum of the files last loaded; written under reloadMu and mu
+	idemKeys map[string]string
+	idemFIFO []string
 }
 
-func NewFromFile(path string) (*Repo, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return nil, fmt.Errorf("open csv %q: %w", path, err)
-	}
-	defer f.Close()
+type snapshot struct {
+	readings []domain.Reading // sorted ascending by Time, then MeterID
+	meters   []domain.Meter   // sorted by ID
+	byMeter  map[string][]int // indexes into readings of each meter's readings
+	dataset  domain.Dataset
+}
 
-	readings, parseErr := ParseReadingsCSV(f)
-	if len(readings) == 0 && parseErr != nil {
-		return nil, fmt.Errorf("parse csv %q: %w", path, parseErr)
+// NewFromFile returns a repo serving the readings in path, which may be a
+// single file, a directory or a glob pattern, with files optionally gzip or
+// zstd compressed; see Load. Files are streamed and parsed in parallel.
+func NewFromFile(path string, opts ...Option) (*Repo, error) {
+	res, parseErr, err := loadFiles(path, opts, nil)
+	if err != nil {
+		return nil, err
 	}
-	sort.Slice(readings, func(i, j int) bool { return readings[i].Time.Before(readings[j].Time) })
+	r := newRepo(res.readings, res.checksum)
+	r.path = path
+	r.opts = opts
+	r.report.Store(&res.report)
 
 	// Parsing can be partially successful; surface warnings to the caller.
 	if parseErr != nil {
-		return &Repo{readings: readings}, fmt.Errorf("parse csv %q: %w", path, parseErr)
+		return r, parseErr
 	}
-	return &Repo{readings: readings}, nil
+	return r, nil
 }
 
+// NewPending returns a repo backed by the CSV at path that serves no readings
+// until a Reload succeeds, for a file that is missing or unusable at startup.
+func NewPending(path string, opts ...Option) *Repo {
+	r := newRepo(nil, "")
+	r.path = path
+	r.opts = opts
+	return r
+}
+
+// New returns a repo serving readings. Its checksum is derived from the
+// readings, as there are no files.
 func New(readings []domain.Reading) *Repo {
-	cp := append([]domain.Reading(nil), readings...)
-	sort.Slice(cp, func(i, j int) bool { return cp[i].Time.Before(cp[j].Time) })
-	return &Repo{readings: cp}
+	readings = append([]domain.Reading(nil), readings...)
+	sortReadings(readings)
+	var d repo.Digest
+	d.Add(readings...)
+	return newRepo(readings, d.Checksum(""))
+}
+
+// newRepo takes ownership of readings; source is the checksum of the files
+// they were loaded from.
+func newRepo(readings []domain.Reading, source string) *Repo {
+	sortReadings(readings)
+	r := &Repo{idemKeys: map[string]string{}, source: source}
+	r.storeLocked(readings, source)
+	return r
+}
+
+// storeLocked publishes a new snapshot of readings, the files with checksum
+// source plus the appended readings. It takes ownership of readings, which
+// must already be sorted. Callers hold r.mu (or own r exclusively).
+//
+// The dataset checksum covers the appended readings too, so it is the same
+// on every replica that loaded the same files and was sent the same appends.
+//
+// The version is the publish time in Unix nanoseconds, raised past the
+// previous version if the clock has not moved, so that it keeps increasing
+// across restarts instead of starting over: a new process never reports a
+// version that an earlier one used for other data.
+func (r *Repo) storeLocked(readings []domain.Reading, source string) {
+	now := time.Now().UTC()
+	version := uint64(now.UnixNano())
+	if prev := r.snap.Load(); prev != nil && version <= prev.dataset.Version {
+		version = prev.dataset.Version + 1
+	}
+	meters, byMeter := summarizeMeters(readings)
+	r.snap.Store(&snapshot{
+		readings: readings,
+		meters:   meters,
+		byMeter:  byMeter,
+		dataset: domain.Dataset{
+			Version:   version,
+			Rows:      len(readings),
+			Checksum:  r.digest.Checksum(source),
+			UpdatedAt: now,
+		},
+	})
+}
+
+// sortReadings normalizes meter IDs and sorts readings in List order. Files
+// are usually in time order already, which is checked first.
+func sortReadings(readings []domain.Reading) {
+	for i := range readings {
+		if readings[i].MeterID == "" {
+			readings[i].MeterID = domain.DefaultMeterID
+		}
+	}
+	if sort.SliceIsSorted(readings, func(i, j int) bool { return lessReading(readings[i], readings[j]) }) {
+		return
+	}
+	sort.SliceStable(readings, func(i, j int) bool { return lessReading(readings[i], readings[j]) })
+}
+
+func lessReading(a, b domain.Reading) bool {
+	if !a.Time.Equal(b.Time) {
+		return a.Time.Before(b.Time)
+	}
+	return a.MeterID < b.MeterID
+}
+
+// summarizeMeters expects readings sorted by time. It also returns, for each
+// meter, the indexes of its readings.
+func summarizeMeters(readings []domain.Reading) ([]domain.Meter, map[string][]int) {
+	byID := map[string]*domain.Meter{}
+	byMeter := map[string][]int{}
+	for i, r := range readings {
+		m, ok := byID[r.MeterID]
+		if !ok {
+			m = &domain.Meter{ID: r.MeterID, FirstReading: r.Time}
+			byID[r.MeterID] = m
+		}
+		m.ReadingCount++
+		m.LastReading = r.Time
+		byMeter[r.MeterID] = append(byMeter[r.MeterID], i)
+	}
+	meters := make([]domain.Meter, 0, len(byID))
+	for _, m := range byID {
+		meters = append(meters, *m)
+	}
+	sort.Slice(meters, func(i, j int) bool { return meters[i].ID < meters[j].ID })
+	return meters, byMeter
 }
 
-func (r *Repo) List(ctx context.Context, startInclusive *time.Time, endExclusive *time.Time) ([]domain.Reading, error) {
+func (r *Repo) List(ctx context.Context, startInclusive *time.Time, endExclusive *time.Time, meterIDs []string) ([]domain.Reading, error) {
 	_ = ctx // reserved for future cancellation-aware backends
 
-	readings := r.readings
+	readings := inRange(r.snap.Load().readings, startInclusive, endExclusive)
+	if len(meterIDs) > 0 {
+		return filterMeters(readings, meterIDs, 0), nil
+	}
+
+	// Return a view into the in-memory slice; callers must not mutate it.
+	return readings, nil
+}
+
+// ListAfter finds after by binary search, so a page costs no more than the
+// readings it returns (and, with meterIDs, those of other meters it passes).
+func (r *Repo) ListAfter(ctx context.Context, startInclusive *time.Time, endExclusive *time.Time, meterIDs []string, after *repo.Position, limit int) ([]domain.Reading, error) {
+	_ = ctx
+
+	if after != nil && (startInclusive == nil || after.Time.After(*startInclusive)) {
+		startInclusive = &after.Time
+	}
+	readings := inRange(r.snap.Load().readings, startInclusive, endExclusive)
+	if after != nil {
+		readings = readings[repo.SkipThrough(readings, *after):]
+	}
+	if len(meterIDs) > 0 {
+		return filterMeters(readings, meterIDs, limit), nil
+	}
+	if limit > 0 && limit < len(readings) {
+		readings = readings[:limit]
+	}
+	return readings, nil
+}
+
+// inRange returns the view of sorted readings in [start, end).
+func inRange(readings []domain.Reading, startInclusive, endExclusive *time.Time) []domain.Reading {
 	if startInclusive != nil {
 		start := *startInclusive
 		i := sort.Search(len(readings), func(i int) bool { return !readings[i].Time.Before(start) })
@@ -58,7 +223,101 @@ func (r *Repo) List(ctx context.Context, startInclusive *time.Time, endExclusive
 		j := sort.Search(len(readings), func(i int) bool { return !readings[i].Time.Before(end) })
 		readings = readings[:j]
 	}
+	return readings
+}
 
-	// Return a view into the in-memory slice; callers must not mutate it.
-	return readings, nil
+// filterMeters copies the readings of meterIDs, at most limit of them if limit
+// is positive.
+func filterMeters(readings []domain.Reading, meterIDs []string, limit int) []domain.Reading {
+	out := []domain.Reading{}
+	for _, rd := range readings {
+		if limit > 0 && len(out) == limit {
+			break
+		}
+		if slices.Contains(meterIDs, rd.MeterID) {
+			out = append(out, rd)
+		}
+	}
+	return out
+}
+
+// LastBefore searches the meter's own readings, so it does not depend on how
+// many readings of other meters there are.
+func (r *Repo) LastBefore(ctx context.Context, meterID string, t time.Time) (domain.Reading, bool, error) {
+	_ = ctx
+
+	snap := r.snap.Load()
+	idx := snap.byMeter[meterID]
+	i := sort.Search(len(idx), func(i int) bool { return !snap.readings[idx[i]].Time.Before(t) })
+	if i == 0 {
+		return domain.Reading{}, false, nil
+	}
+	return snap.readings[idx[i-1]], true, nil
+}
+
+func (r *Repo) ListMeters(ctx context.Context) ([]domain.Meter, error) {
+	_ = ctx
+	return append([]domain.Meter(nil), r.snap.Load().meters...), nil
+}
+
+func (r *Repo) Dataset(ctx context.Context) (domain.Dataset, error) {
+	_ = ctx
+	return r.snap.Load().dataset, nil
+}
+
+func (r *Repo) Append(ctx context.Context, idempotencyKey, fingerprint string, readings []domain.Reading) (bool, error) {
+	_ = ctx
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if idempotencyKey != "" {
+		if prev, ok := r.idemKeys[idempotencyKey]; ok {
+			if prev != fingerprint {
+				return false, repo.ErrIdempotencyConflict
+			}
+			return true, nil
+		}
+	}
+
+	if len(readings) > 0 {
+		batch := append([]domain.Reading(nil), readings...)
+		sortReadings(batch)
+		cur := r.snap.Load()
+		r.appended = mergeReadings(r.appended, batch)
+		r.digest.Add(batch...)
+		r.storeLocked(mergeReadings(cur.readings, batch), r.source)
+	}
+
+	if idempotencyKey != "" {
+		r.rememberKey(idempotencyKey, fingerprint)
+	}
+	return false, nil
+}
+
+func (r *Repo) rememberKey(key, fingerprint string) {
+	if len(r.idemFIFO) >= maxIdempotencyKeys {
+		delete(r.idemKeys, r.idemFIFO[0])
+		r.idemFIFO = r.idemFIFO[1:]
+	}
+	r.idemKeys[key] = fingerprint
+	r.idemFIFO = append(r.idemFIFO, key)
+}
+
+// mergeReadings merges two sorted slices into a new one. Existing readings sort
+// before new readings with the same time and meter.
+func mergeReadings(a, b []domain.Reading) []domain.Reading {
+	out := make([]domain.Reading, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if lessReading(b[j], a[i]) {
+			out = append(out, b[j])
+			j++
+		} else {
+			out = append(out, a[i])
+			i++
+		}
+	}
+	out = append(out, a[i:]...)
+	return append(out, b[j:]...)
 }
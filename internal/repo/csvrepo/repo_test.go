@@ -2,10 +2,13 @@ package csvrepo
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/milad/spectral/internal/domain"
+	"github.com/milad/spectral/internal/repo"
+	"github.com/milad/spectral/internal/repo/repotest"
 )
 
 func mustUTC(t *testing.T, s string) time.Time {
@@ -29,7 +32,7 @@ func TestRepo_ListFiltersByTimeRange(t *testing.T) {
 	start := mustUTC(t, "2019-01-01 00:30:00")
 	end := mustUTC(t, "2019-01-01 00:45:00")
 
-	out, err := r.List(context.Background(), &start, &end)
+	out, err := r.List(context.Background(), &start, &end, nil)
 	if err != nil {
 		t.Fatalf("List: %v", err)
 	}
@@ -40,3 +43,226 @@ func TestRepo_ListFiltersByTimeRange(t *testing.T) {
 		t.Fatalf("out[0].MeterUsage=%v want %v", got, want)
 	}
 }
+
+func TestRepo_ListFiltersByMeter(t *testing.T) {
+	t.Parallel()
+
+	r := New([]domain.Reading{
+		{MeterID: "b", Time: mustUTC(t, "2019-01-01 00:15:00"), MeterUsage: 1},
+		{MeterID: "a", Time: mustUTC(t, "2019-01-01 00:15:00"), MeterUsage: 2},
+		{MeterID: "c", Time: mustUTC(t, "2019-01-01 00:30:00"), MeterUsage: 3},
+	})
+
+	all, err := r.List(context.Background(), nil, nil, nil)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if all[0].MeterID != "a" || all[1].MeterID != "b" {
+		t.Fatalf("expected equal times to be ordered by meter id, got %q, %q", all[0].MeterID, all[1].MeterID)
+	}
+
+	out, err := r.List(context.Background(), nil, nil, []string{"b", "c"})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if got, want := len(out), 2; got != want {
+		t.Fatalf("len(out)=%d want %d", got, want)
+	}
+	if out[0].MeterID != "b" || out[1].MeterID != "c" {
+		t.Fatalf("unexpected meters: %q, %q", out[0].MeterID, out[1].MeterID)
+	}
+}
+
+func TestRepo_ListMeters(t *testing.T) {
+	t.Parallel()
+
+	r := New([]domain.Reading{
+		{MeterID: "b", Time: mustUTC(t, "2019-01-01 00:15:00"), MeterUsage: 1},
+		{MeterID: "a", Time: mustUTC(t, "2019-01-01 00:30:00"), MeterUsage: 2},
+		{MeterID: "b", Time: mustUTC(t, "2019-01-01 00:45:00"), MeterUsage: 3},
+		{Time: mustUTC(t, "2019-01-01 00:45:00"), MeterUsage: 4},
+	})
+
+	meters, err := r.ListMeters(context.Background())
+	if err != nil {
+		t.Fatalf("ListMeters: %v", err)
+	}
+	if got, want := len(meters), 3; got != want {
+		t.Fatalf("len(meters)=%d want %d", got, want)
+	}
+	if meters[0].ID != "a" || meters[1].ID != "b" || meters[2].ID != domain.DefaultMeterID {
+		t.Fatalf("unexpected meter order: %+v", meters)
+	}
+	b := meters[1]
+	if b.ReadingCount != 2 || !b.FirstReading.Equal(mustUTC(t, "2019-01-01 00:15:00")) || !b.LastReading.Equal(mustUTC(t, "2019-01-01 00:45:00")) {
+		t.Fatalf("unexpected summary for meter b: %+v", b)
+	}
+}
+
+func TestRepo_AppendPreservesOrder(t *testing.T) {
+	t.Parallel()
+
+	r := New([]domain.Reading{
+		{Time: mustUTC(t, "2019-01-01 00:15:00"), MeterUsage: 1},
+		{Time: mustUTC(t, "2019-01-01 00:45:00"), MeterUsage: 3},
+	})
+	before, err := r.List(context.Background(), nil, nil, nil)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+
+	replayed, err := r.Append(context.Background(), "", "", []domain.Reading{
+		{Time: mustUTC(t, "2019-01-01 01:00:00"), MeterUsage: 4},
+		{Time: mustUTC(t, "2019-01-01 00:30:00"), MeterUsage: 2},
+	})
+	if err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if replayed {
+		t.Fatalf("expected replayed=false")
+	}
+
+	after, err := r.List(context.Background(), nil, nil, nil)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if got, want := len(after), 4; got != want {
+		t.Fatalf("len(after)=%d want %d", got, want)
+	}
+	for i, rd := range after {
+		if got, want := rd.MeterUsage, float64(i+1); got != want {
+			t.Fatalf("after[%d].MeterUsage=%v want %v", i, got, want)
+		}
+		if rd.MeterID != domain.DefaultMeterID {
+			t.Fatalf("after[%d].MeterID=%q want %q", i, rd.MeterID, domain.DefaultMeterID)
+		}
+	}
+	// Slices returned earlier are snapshots and must not change.
+	if got, want := len(before), 2; got != want || before[1].MeterUsage != 3 {
+		t.Fatalf("earlier List result changed: %+v", before)
+	}
+}
+
+func TestRepo_AppendIdempotency(t *testing.T) {
+	t.Parallel()
+
+	r := New(nil)
+	batch := []domain.Reading{{Time: mustUTC(t, "2019-01-01 00:15:00"), MeterUsage: 1}}
+
+	if _, err := r.Append(context.Background(), "k1", "fp1", batch); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	replayed, err := r.Append(context.Background(), "k1", "fp1", batch)
+	if err != nil {
+		t.Fatalf("Append (retry): %v", err)
+	}
+	if !replayed {
+		t.Fatalf("expected replayed=true")
+	}
+	if _, err := r.Append(context.Background(), "k1", "fp2", batch); !errors.Is(err, repo.ErrIdempotencyConflict) {
+		t.Fatalf("expected ErrIdempotencyConflict, got %v", err)
+	}
+
+	all, err := r.List(context.Background(), nil, nil, nil)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if got, want := len(all), 1; got != want {
+		t.Fatalf("len(all)=%d want %d", got, want)
+	}
+}
+
+func TestRepo_ListAfterWalksPages(t *testing.T) {
+	t.Parallel()
+	repotest.ListAfterWalksPages(t, New(repotest.Readings()))
+}
+
+func TestRepo_LastBefore(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	r := New(repotest.Readings())
+
+	for name, tc := range map[string]struct {
+		meterID string
+		t       string
+		want    float64 // MeterUsage; 0 if there is no reading
+	}{
+		"before first":    {meterID: "a", t: "2019-01-01 00:15:00"},
+		"last of equal":   {meterID: "b", t: "2019-01-01 00:30:00", want: 4},
+		"skips meters":    {meterID: "a", t: "2019-01-01 01:00:00", want: 5},
+		"strictly before": {meterID: "b", t: "2019-01-01 00:45:00", want: 4},
+		"unknown meter":   {meterID: "c", t: "2019-01-01 01:00:00"},
+	} {
+		got, ok, err := r.LastBefore(ctx, tc.meterID, mustUTC(t, tc.t))
+		if err != nil {
+			t.Fatalf("%s: LastBefore: %v", name, err)
+		}
+		if ok != (tc.want != 0) || got.MeterUsage != tc.want {
+			t.Fatalf("%s: got %+v ok=%v want usage %v", name, got, ok, tc.want)
+		}
+		if ok && (got.MeterID != tc.meterID || !got.Time.Before(mustUTC(t, tc.t))) {
+			t.Fatalf("%s: got %+v, want meter %s before %s", name, got, tc.meterID, tc.t)
+		}
+	}
+}
+
+func TestRepo_ChecksumIsSharedByReplicas(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	base := []domain.Reading{{MeterID: "a", Time: mustUTC(t, "2019-01-01 00:15:00"), MeterUsage: 1}}
+	more := []domain.Reading{
+		{MeterID: "a", Time: mustUTC(t, "2019-01-01 00:30:00"), MeterUsage: 2},
+		{MeterID: "b", Time: mustUTC(t, "2019-01-01 00:30:00"), MeterUsage: 3},
+	}
+
+	checksum := func(batches ...[]domain.Reading) string {
+		t.Helper()
+		r := New(base)
+		for _, b := range batches {
+			if _, err := r.Append(ctx, "", "", b); err != nil {
+				t.Fatalf("Append: %v", err)
+			}
+		}
+		ds, err := r.Dataset(ctx)
+		if err != nil {
+			t.Fatalf("Dataset: %v", err)
+		}
+		return ds.Checksum
+	}
+
+	one := checksum(more)
+	if got := checksum(more[1:], more[:1]); got != one {
+		t.Fatalf("checksum=%q want %q for the same readings in other batches", got, one)
+	}
+	if got := checksum(); got == one || got == "" {
+		t.Fatalf("checksum=%q want one of its own for other readings", got)
+	}
+	if got := checksum(more, nil); got != one {
+		t.Fatalf("checksum=%q want %q after an empty append", got, one)
+	}
+}
+
+func TestRepo_VersionKeepsIncreasingAcrossRepos(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	// A repo built later, as by a restarted process, starts above every
+	// version an earlier one reported.
+	first := New(nil)
+	var last uint64
+	for i := range 3 {
+		if _, err := first.Append(ctx, "", "", []domain.Reading{{Time: mustUTC(t, "2019-01-01 00:15:00"), MeterUsage: float64(i)}}); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+		ds, _ := first.Dataset(ctx)
+		if ds.Version <= last {
+			t.Fatalf("version %d after append %d, want above %d", ds.Version, i, last)
+		}
+		last = ds.Version
+	}
+
+	time.Sleep(time.Millisecond)
+	if ds, _ := New(nil).Dataset(ctx); ds.Version <= last {
+		t.Fatalf("new repo at version %d, want above %d", ds.Version, last)
+	}
+}
@@ -0,0 +1,93 @@
+package csvrepo
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/milad/spectral/internal/domain"
+	"github.com/milad/spectral/internal/greenbutton"
+)
+
+// maxReportIssues bounds the issues an ingestion report lists; all of them
+// are counted.
+const maxReportIssues = 1_000
+
+// IngestionReport describes what the last load of the files rejected, whether
+// at startup or by Reload; loads that did not change the data are reported
+// too. Repos built with New, or whose files could not be loaded at startup and
+// were not reloaded since, report nothing.
+func (r *Repo) IngestionReport(ctx context.Context) (domain.IngestionReport, error) {
+	_ = ctx
+	if rep := r.report.Load(); rep != nil {
+		return *rep, nil
+	}
+	return domain.IngestionReport{Source: r.path}, nil
+}
+
+// reportBuilder collects the issues of a load into a report.
+type reportBuilder struct {
+	report domain.IngestionReport
+	counts map[domain.IngestionCategory]int
+}
+
+func newReportBuilder(source string) *reportBuilder {
+	return &reportBuilder{
+		report: domain.IngestionReport{Source: source, LoadedAt: time.Now().UTC()},
+		counts: map[domain.IngestionCategory]int{},
+	}
+}
+
+// add records the issues in err, as returned by ParseReadings for file or by
+// opening it: errors joined with errors.Join are reported one by one.
+func (b *reportBuilder) add(file string, err error) {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, err := range joined.Unwrap() {
+			b.add(file, err)
+		}
+		return
+	}
+
+	var omitted *OmittedRowErrors
+	if errors.As(err, &omitted) {
+		for c, n := range omitted.Counts {
+			b.counts[c] += n
+			b.report.Rejected += n
+		}
+		b.report.IssuesTruncated = true
+		return
+	}
+
+	issue := domain.IngestionIssue{File: file, Category: domain.IngestInvalidFile, Message: err.Error()}
+	var rowErr *RowError
+	var entryErr *greenbutton.EntryError
+	switch {
+	case errors.As(err, &rowErr):
+		issue.Row, issue.Values, issue.Category, issue.Message = rowErr.Row, rowErr.Values, rowErr.Category, rowErr.Err.Error()
+	case errors.As(err, &entryErr):
+		issue.Category = domain.IngestInvalidEntry
+	}
+
+	b.counts[issue.Category]++
+	b.report.Rejected++
+	if len(b.report.Issues) < maxReportIssues {
+		b.report.Issues = append(b.report.Issues, issue)
+	} else {
+		b.report.IssuesTruncated = true
+	}
+}
+
+// build completes the report; applied tells whether the load is served.
+func (b *reportBuilder) build(files, accepted int, applied bool) domain.IngestionReport {
+	rep := b.report
+	rep.Files, rep.Accepted, rep.Applied = files, accepted, applied
+	for c, n := range b.counts {
+		rep.Categories = append(rep.Categories, domain.IngestionCount{Category: c, Count: n})
+	}
+	slices.SortFunc(rep.Categories, func(a, b domain.IngestionCount) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Category, b.Category))
+	})
+	return rep
+}
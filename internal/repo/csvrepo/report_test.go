@@ -0,0 +1,152 @@
+package csvrepo
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/milad/spectral/internal/domain"
+)
+
+func TestRepo_IngestionReport(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "meterusage.csv")
+	writeCSV(t, path, "meter_id,time,meterusage\n"+
+		"a,2019-01-01 00:15:00,1\n"+
+		"a,2019-01-01 00:30:00,NaN\n"+
+		",2019-01-01 00:45:00,2\n"+
+		"a,yesterday,3\n"+
+		"a,2019-01-01 01:00:00,x\n"+
+		"a,2019-01-01 01:15:00,y\n"+
+		"a,2019-01-01 01:30:00\n")
+	r, err := NewFromFile(path)
+	if r == nil {
+		t.Fatalf("NewFromFile: %v", err)
+	}
+
+	rep, err := r.IngestionReport(context.Background())
+	if err != nil {
+		t.Fatalf("IngestionReport: %v", err)
+	}
+	if rep.Source != path || !rep.Applied || rep.Files != 1 || rep.Accepted != 1 || rep.Rejected != 6 || rep.LoadedAt.IsZero() {
+		t.Fatalf("unexpected report: %+v", rep)
+	}
+	wantCounts := []domain.IngestionCount{
+		{Category: domain.IngestInvalidUsage, Count: 2},
+		{Category: domain.IngestInvalidTime, Count: 1},
+		{Category: domain.IngestMissingColumns, Count: 1},
+		{Category: domain.IngestMissingMeterID, Count: 1},
+		{Category: domain.IngestNonFiniteUsage, Count: 1},
+	}
+	if !slices.Equal(rep.Categories, wantCounts) {
+		t.Fatalf("categories=%v want %v", rep.Categories, wantCounts)
+	}
+
+	if got, want := len(rep.Issues), 6; got != want {
+		t.Fatalf("len(issues)=%d want %d", got, want)
+	}
+	nan := rep.Issues[0]
+	if nan.File != path || nan.Row != 3 || nan.Category != domain.IngestNonFiniteUsage || !slices.Equal(nan.Values, []string{"a", "2019-01-01 00:30:00", "NaN"}) {
+		t.Fatalf("unexpected issue: %+v", nan)
+	}
+	if strings.Contains(nan.Message, "row 3") {
+		t.Fatalf("message %q repeats the row number", nan.Message)
+	}
+}
+
+func TestRepo_IngestionReport_TruncatesIssues(t *testing.T) {
+	t.Parallel()
+
+	var b strings.Builder
+	b.WriteString("time,meterusage\n2019-01-01 00:15:00,1\n")
+	for i := range maxReportIssues + 5 {
+		fmt.Fprintf(&b, "2019-01-01 00:15:00,bad%d\n", i)
+	}
+	path := filepath.Join(t.TempDir(), "meterusage.csv")
+	writeCSV(t, path, b.String())
+	r, _ := NewFromFile(path)
+
+	rep, _ := r.IngestionReport(context.Background())
+	if got, want := rep.Rejected, maxReportIssues+5; got != want {
+		t.Fatalf("rejected=%d want %d", got, want)
+	}
+	if got, want := len(rep.Issues), maxReportIssues; got != want || !rep.IssuesTruncated {
+		t.Fatalf("len(issues)=%d want %d, truncated=%v", got, want, rep.IssuesTruncated)
+	}
+	if got, want := rep.Categories, []domain.IngestionCount{{Category: domain.IngestInvalidUsage, Count: maxReportIssues + 5}}; !slices.Equal(got, want) {
+		t.Fatalf("categories=%v want %v", got, want)
+	}
+}
+
+func TestRepo_IngestionReport_FailedReload(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	path := filepath.Join(t.TempDir(), "meterusage.csv")
+	writeCSV(t, path, "time,meterusage\n2019-01-01 00:15:00,1\n")
+	r, err := NewFromFile(path)
+	if err != nil {
+		t.Fatalf("NewFromFile: %v", err)
+	}
+	if rep, _ := r.IngestionReport(ctx); !rep.Applied || rep.Rejected != 0 || len(rep.Issues) != 0 {
+		t.Fatalf("unexpected report: %+v", rep)
+	}
+
+	writeCSV(t, path, "not,a,valid,header\n")
+	if _, err := r.Reload(ctx); err == nil {
+		t.Fatal("expected failed reload")
+	}
+	rep, _ := r.IngestionReport(ctx)
+	if rep.Applied || rep.Accepted != 0 || rep.Rejected != 1 {
+		t.Fatalf("unexpected report: %+v", rep)
+	}
+	if len(rep.Issues) != 1 || rep.Issues[0].Category != domain.IngestInvalidFile || rep.Issues[0].Row != 0 {
+		t.Fatalf("unexpected issues: %+v", rep.Issues)
+	}
+}
+
+func TestLoad_ReportsFilesAndEntries(t *testing.T) {
+	t.Parallel()
+
+	feed := `<feed xmlns="http://www.w3.org/2005/Atom"><entry><content>
+<ReadingType xmlns="http://naesb.org/espi"><uom>72</uom></ReadingType>
+<IntervalBlock xmlns="http://naesb.org/espi">
+<IntervalReading><timePeriod><duration>900</duration><start>1546300800</start></timePeriod><value>55090</value></IntervalReading>
+<IntervalReading><timePeriod><duration>900</duration><start>1546301700</start></timePeriod></IntervalReading>
+</IntervalBlock>
+</content></entry></feed>`
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string][]byte{
+		"a.xml": []byte(feed),
+		"b.csv": []byte("time,meterusage\n"),
+		"c.csv": gzipped(t, "time,meterusage\n2019-01-01 00:30:00,1\n")[:10],
+	})
+
+	res, _, err := loadFiles(dir, nil, nil)
+	if err != nil {
+		t.Fatalf("loadFiles: %v", err)
+	}
+	rep := res.report
+	if rep.Files != 3 || rep.Accepted != 1 || !rep.Applied {
+		t.Fatalf("unexpected report: %+v", rep)
+	}
+	var got []string
+	for _, i := range rep.Issues {
+		got = append(got, filepath.Base(i.File)+":"+string(i.Category))
+	}
+	if want := []string{"a.xml:invalid_entry", "c.csv:invalid_file"}; !slices.Equal(got, want) {
+		t.Fatalf("issues=%v want %v", got, want)
+	}
+
+	res, _, err = loadFiles(filepath.Join(dir, "missing.csv"), nil, nil)
+	if err == nil {
+		t.Fatal("expected an error for a missing file")
+	}
+	if rep := res.report; rep.Applied || len(rep.Issues) != 1 || rep.Issues[0].Category != domain.IngestInvalidFile {
+		t.Fatalf("unexpected report: %+v", rep)
+	}
+}
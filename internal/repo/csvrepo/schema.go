@@ -0,0 +1,288 @@
+package csvrepo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Time layouts with special meaning in Schema.TimeLayouts. Any other value is
+// a Go time layout.
+const (
+	LayoutUnix      = "unix"    // seconds since the Unix epoch
+	LayoutUnixMilli = "unix_ms" // milliseconds since the Unix epoch
+	LayoutRFC3339   = "rfc3339" // RFC 3339, with optional fractional seconds
+)
+
+// Schema describes the layout of a CSV file, so that vendor exports can be
+// loaded as they are. DefaultSchema is the original meterusage format.
+type Schema struct {
+	// Header tells whether the first row names the columns. Without one,
+	// columns must be given by position.
+	Header bool
+	// Delimiter is the field separator: a single character, or "tab".
+	Delimiter string
+	// TimeColumn, UsageColumn and MeterIDColumn select columns by header name
+	// (case-insensitive) or by 1-based position, e.g. "3". A meter ID column
+	// given by name may be missing from the file, and an empty MeterIDColumn
+	// means there is none; such rows belong to domain.DefaultMeterID.
+	TimeColumn    string
+	UsageColumn   string
+	MeterIDColumn string
+	// TimeLayouts are tried in order. Epoch and RFC 3339 times, and layouts
+	// with a zone, are instants; others are wall-clock times in the
+	// WithLocation zone.
+	TimeLayouts []string
+	// DecimalComma reads usage as 1.234,5: ',' is the decimal separator and
+	// '.' separates thousands.
+	DecimalComma bool
+	// Multiplier converts usage to the served unit, e.g. 0.001 for Wh.
+	Multiplier float64
+}
+
+// DefaultSchema is the meterusage format: a time,meterusage header, an
+// optional meter_id column and times like 2019-01-01 00:15:00.
+func DefaultSchema() Schema {
+	return Schema{
+		Header:        true,
+		Delimiter:     ",",
+		TimeColumn:    "time",
+		UsageColumn:   "meterusage",
+		MeterIDColumn: "meter_id",
+		TimeLayouts:   []string{timeLayout},
+		Multiplier:    1,
+	}
+}
+
+// Validate reports settings that cannot describe a file.
+func (s Schema) Validate() error {
+	_, err := s.compile()
+	return err
+}
+
+// compiledSchema is a validated Schema, ready for parsing.
+type compiledSchema struct {
+	header     bool
+	delimiter  rune
+	time       column
+	usage      column
+	meterID    column // name and index unset: no meter column
+	layouts    []timeLayoutSpec
+	decimal    bool
+	multiplier float64
+}
+
+// column is a column selected by name or by (0-based) index.
+type column struct {
+	name  string
+	index int
+}
+
+func (c column) String() string {
+	if c.name != "" {
+		return strconv.Quote(c.name)
+	}
+	return "#" + strconv.Itoa(c.index+1)
+}
+
+type timeLayoutSpec struct {
+	layout   string
+	absolute bool // the value is an instant, not a wall-clock time
+}
+
+func (s Schema) compile() (compiledSchema, error) {
+	c := compiledSchema{header: s.Header, decimal: s.DecimalComma, multiplier: s.Multiplier}
+	var errs []error
+
+	switch {
+	case s.Delimiter == "tab":
+		c.delimiter = '\t'
+	case utf8.RuneCountInString(s.Delimiter) == 1:
+		c.delimiter, _ = utf8.DecodeRuneInString(s.Delimiter)
+		if c.delimiter == '"' || c.delimiter == '\r' || c.delimiter == '\n' || c.delimiter == utf8.RuneError {
+			errs = append(errs, fmt.Errorf("delimiter %q: not allowed", s.Delimiter))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("delimiter %q: must be a single character or \"tab\"", s.Delimiter))
+	}
+
+	var err error
+	if c.time, err = s.column("time", s.TimeColumn, true); err != nil {
+		errs = append(errs, err)
+	}
+	if c.usage, err = s.column("usage", s.UsageColumn, true); err != nil {
+		errs = append(errs, err)
+	}
+	if c.meterID, err = s.column("meter ID", s.MeterIDColumn, false); err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(s.TimeLayouts) == 0 {
+		errs = append(errs, errors.New("time layouts: at least one is required"))
+	}
+	for _, l := range s.TimeLayouts {
+		switch l {
+		case LayoutUnix, LayoutUnixMilli, LayoutRFC3339:
+			c.layouts = append(c.layouts, timeLayoutSpec{layout: l, absolute: true})
+		case "":
+			errs = append(errs, errors.New("time layouts: empty layout"))
+		default:
+			zoned := strings.Contains(l, "Z07") || strings.Contains(l, "-07") || strings.Contains(l, "MST")
+			c.layouts = append(c.layouts, timeLayoutSpec{layout: l, absolute: zoned})
+		}
+	}
+
+	if s.Multiplier == 0 || math.IsNaN(s.Multiplier) || math.IsInf(s.Multiplier, 0) {
+		errs = append(errs, fmt.Errorf("multiplier %v: must be a finite, non-zero number", s.Multiplier))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return compiledSchema{}, fmt.Errorf("csv schema: %w", err)
+	}
+	return c, nil
+}
+
+// column parses a column selector: digits are a 1-based position, anything
+// else a header name.
+func (s Schema) column(what, spec string, required bool) (column, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		if required {
+			return column{}, fmt.Errorf("%s column: required", what)
+		}
+		return column{index: -1}, nil
+	}
+	if n, err := strconv.Atoi(spec); err == nil {
+		if n < 1 {
+			return column{}, fmt.Errorf("%s column %q: positions start at 1", what, spec)
+		}
+		return column{index: n - 1}, nil
+	}
+	if !s.Header {
+		return column{}, fmt.Errorf("%s column %q: files without a header need column positions", what, spec)
+	}
+	return column{name: strings.ToLower(spec), index: -1}, nil
+}
+
+// columns holds the positions of known columns; meterID is -1 if absent.
+type columns struct {
+	time, usage, meterID int
+	width                int // minimum number of fields a row must have
+}
+
+// resolve finds the columns in header, or takes them by position when the
+// file has none (header is nil).
+func (c compiledSchema) resolve(header []string) (columns, error) {
+	cols := columns{meterID: -1}
+	var missing []string
+	for _, x := range []struct {
+		col      column
+		dst      *int
+		required bool
+	}{
+		{c.time, &cols.time, true},
+		{c.usage, &cols.usage, true},
+		{c.meterID, &cols.meterID, false},
+	} {
+		*x.dst = x.col.index
+		if x.col.name != "" {
+			*x.dst = -1
+			for i, h := range header {
+				if strings.ToLower(strings.TrimSpace(h)) != x.col.name {
+					continue
+				}
+				if *x.dst >= 0 {
+					return columns{}, fmt.Errorf("unexpected header %q: duplicate column %q", strings.Join(header, string(c.delimiter)), h)
+				}
+				*x.dst = i
+			}
+			if *x.dst < 0 && x.required {
+				missing = append(missing, x.col.String())
+			}
+		}
+		cols.width = max(cols.width, *x.dst+1)
+	}
+	if len(missing) > 0 {
+		want := c.time.String() + " and " + c.usage.String()
+		if c.meterID.name != "" {
+			want += ", optionally with " + c.meterID.String()
+		}
+		return columns{}, fmt.Errorf("unexpected header %q: missing %s (want %s)", strings.Join(header, string(c.delimiter)), strings.Join(missing, ", "), want)
+	}
+	return cols, nil
+}
+
+// parseTime tries the layouts in order. wall is true for wall-clock times,
+// which the caller resolves in its location.
+func (c compiledSchema) parseTime(v string) (t time.Time, wall bool, err error) {
+	v = strings.TrimSpace(v)
+	for _, l := range c.layouts {
+		switch l.layout {
+		case LayoutUnix, LayoutUnixMilli:
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				continue
+			}
+			if l.layout == LayoutUnix {
+				return time.Unix(n, 0).UTC(), false, nil
+			}
+			return time.UnixMilli(n).UTC(), false, nil
+		case LayoutRFC3339:
+			if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+				return t.UTC(), false, nil
+			}
+		default:
+			if t, err := time.ParseInLocation(l.layout, v, time.UTC); err == nil {
+				if l.absolute {
+					return t.UTC(), false, nil
+				}
+				return t, true, nil
+			}
+		}
+	}
+	if len(c.layouts) == 1 {
+		return time.Time{}, false, fmt.Errorf("does not match layout %q", c.layouts[0].layout)
+	}
+	names := make([]string, len(c.layouts))
+	for i, l := range c.layouts {
+		names[i] = strconv.Quote(l.layout)
+	}
+	return time.Time{}, false, fmt.Errorf("matches none of the layouts %s", strings.Join(names, ", "))
+}
+
+// parseUsage reads a number in the schema's notation and applies the
+// multiplier.
+func (c compiledSchema) parseUsage(v string) (float64, error) {
+	v = strings.TrimSpace(v)
+	if c.decimal {
+		v = strings.ReplaceAll(strings.ReplaceAll(v, ".", ""), ",", ".")
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, err
+	}
+	switch m := c.multiplier; {
+	case m == 1:
+		return f, nil
+	case math.Abs(m) < 1 && isInteger(1/m):
+		// Dividing by 1000 rather than multiplying by 0.001 keeps values such
+		// as 55090 Wh at 55.09 kWh instead of 55.090000000000003.
+		return f / math.Round(1/m), nil
+	default:
+		return f * m, nil
+	}
+}
+
+func isInteger(f float64) bool {
+	return math.Abs(f-math.Round(f)) < 1e-9
+}
+
+// trimLeadingSpace tells whether the csv.Reader may skip leading spaces: not
+// when the delimiter is itself a space, as empty fields would be skipped too.
+func (c compiledSchema) trimLeadingSpace() bool {
+	return !unicode.IsSpace(c.delimiter)
+}
@@ -0,0 +1,136 @@
+package csvrepo
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/milad/spectral/internal/domain"
+)
+
+func TestParseReadingsCSV_Schema(t *testing.T) {
+	t.Parallel()
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("load location: %v", err)
+	}
+	t0 := time.Date(2019, 1, 1, 0, 15, 0, 0, time.UTC)
+
+	for name, tc := range map[string]struct {
+		schema Schema
+		loc    *time.Location
+		in     string
+		want   []domain.Reading
+		rowErr string // the error for skipped rows, if any
+	}{
+		"semicolons, decimal comma and Wh": {
+			schema: Schema{
+				Header:        true,
+				Delimiter:     ";",
+				TimeColumn:    "Zeitstempel",
+				UsageColumn:   "Verbrauch (Wh)",
+				MeterIDColumn: "Zähler",
+				TimeLayouts:   []string{LayoutRFC3339, "02.01.2006 15:04"},
+				DecimalComma:  true,
+				Multiplier:    0.001,
+			},
+			loc: berlin,
+			in: "Zähler;Verbrauch (Wh);Zeitstempel\n" +
+				"a;55.090,0;2019-01-01T00:15:00Z\n" +
+				"b;\"1,5\";01.01.2019 01:15\n",
+			want: []domain.Reading{
+				{MeterID: "a", Time: t0, MeterUsage: 55.09},
+				{MeterID: "b", Time: t0, MeterUsage: 0.0015}, // 01:15 CET
+			},
+		},
+		"no header, tabs and epoch milliseconds": {
+			schema: Schema{
+				Delimiter:     "tab",
+				TimeColumn:    "2",
+				UsageColumn:   "3",
+				MeterIDColumn: "1",
+				TimeLayouts:   []string{LayoutUnixMilli},
+				Multiplier:    1,
+			},
+			in: "a\t1546301700000\t1\n\tignored\n" + "b\t1546301700000\t2\textra\n",
+			want: []domain.Reading{
+				{MeterID: "a", Time: t0, MeterUsage: 1},
+				{MeterID: "b", Time: t0, MeterUsage: 2},
+			},
+			rowErr: "row 2: expected 3 columns",
+		},
+		"positions in a file with a header, epoch seconds": {
+			schema: Schema{Header: true, Delimiter: ",", TimeColumn: "1", UsageColumn: "2", TimeLayouts: []string{LayoutUnix}, Multiplier: 2},
+			in:     "ts,kwh\n1546301700,1.25\n",
+			want:   []domain.Reading{{MeterID: domain.DefaultMeterID, Time: t0, MeterUsage: 2.5}},
+		},
+		"meter column named but absent": {
+			schema: func() Schema {
+				s := DefaultSchema()
+				s.MeterIDColumn = "site"
+				return s
+			}(),
+			in:   "time,meterusage\n2019-01-01 00:15:00,1\n",
+			want: []domain.Reading{{MeterID: domain.DefaultMeterID, Time: t0, MeterUsage: 1}},
+		},
+	} {
+		opts := []Option{WithSchema(tc.schema)}
+		if tc.loc != nil {
+			opts = append(opts, WithLocation(tc.loc))
+		}
+		got, err := ParseReadingsCSV(strings.NewReader(tc.in), opts...)
+		if (err == nil) != (tc.rowErr == "") || err != nil && !strings.Contains(err.Error(), tc.rowErr) {
+			t.Fatalf("%s: err=%v want %q", name, err, tc.rowErr)
+		}
+		if len(got) != len(tc.want) {
+			t.Fatalf("%s: got %+v want %+v", name, got, tc.want)
+		}
+		for i := range got {
+			if got[i].MeterID != tc.want[i].MeterID || !got[i].Time.Equal(tc.want[i].Time) || got[i].MeterUsage != tc.want[i].MeterUsage {
+				t.Fatalf("%s: reading %d = %+v want %+v", name, i, got[i], tc.want[i])
+			}
+		}
+	}
+}
+
+func TestParseReadingsCSV_ReportsUnmatchedLayouts(t *testing.T) {
+	t.Parallel()
+
+	s := DefaultSchema()
+	s.TimeLayouts = []string{LayoutRFC3339, LayoutUnix}
+	_, err := ParseReadingsCSV(strings.NewReader("time,meterusage\n2019-01-01 00:15:00,1\n"), WithSchema(s))
+	if err == nil || !strings.Contains(err.Error(), `matches none of the layouts "rfc3339", "unix"`) {
+		t.Fatalf("err=%v", err)
+	}
+}
+
+func TestSchema_Validate(t *testing.T) {
+	t.Parallel()
+
+	if err := DefaultSchema().Validate(); err != nil {
+		t.Fatalf("DefaultSchema: %v", err)
+	}
+	for name, tc := range map[string]struct {
+		edit func(*Schema)
+		want string
+	}{
+		"long delimiter":       {func(s *Schema) { s.Delimiter = ";;" }, "delimiter"},
+		"quote delimiter":      {func(s *Schema) { s.Delimiter = `"` }, "delimiter"},
+		"names without header": {func(s *Schema) { s.Header = false }, "need column positions"},
+		"position zero":        {func(s *Schema) { s.UsageColumn = "0" }, "positions start at 1"},
+		"no time column":       {func(s *Schema) { s.TimeColumn = "" }, "time column: required"},
+		"no layouts":           {func(s *Schema) { s.TimeLayouts = nil }, "at least one"},
+		"zero multiplier":      {func(s *Schema) { s.Multiplier = 0 }, "multiplier"},
+	} {
+		s := DefaultSchema()
+		tc.edit(&s)
+		err := s.Validate()
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("%s: err=%v want it to mention %q", name, err, tc.want)
+		}
+		if _, err := ParseReadingsCSV(strings.NewReader("time,meterusage\n"), WithSchema(s)); err == nil {
+			t.Fatalf("%s: parsing with an invalid schema succeeded", name)
+		}
+	}
+}
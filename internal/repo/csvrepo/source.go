@@ -0,0 +1,94 @@
+package csvrepo
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// expand returns the files a repo path stands for, in lexical order: the path
+// itself, the files in a directory, or the files that match a filepath.Match
+// pattern such as "exports/*.csv.gz". Hidden files, such as those editors and
+// uploads write before renaming them, are left out of the last two.
+func expand(path string) ([]string, error) {
+	fi, err := os.Stat(path)
+	switch {
+	case err == nil && !fi.IsDir():
+		return []string{path}, nil
+	case err == nil:
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		files := make([]string, len(entries))
+		for i, e := range entries {
+			files[i] = filepath.Join(path, e.Name())
+		}
+		return onlyFiles(files)
+	case os.IsNotExist(err) && strings.ContainsAny(path, `*?[`):
+		matches, err := filepath.Glob(path)
+		if err != nil {
+			return nil, err
+		}
+		return onlyFiles(matches)
+	default:
+		return nil, err
+	}
+}
+
+// onlyFiles drops directories and hidden files from paths.
+func onlyFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, p := range paths {
+		if strings.HasPrefix(filepath.Base(p), ".") {
+			continue
+		}
+		if fi, err := os.Stat(p); err == nil && !fi.IsDir() {
+			files = append(files, p)
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files to load: %w", fs.ErrNotExist)
+	}
+	slices.Sort(files)
+	return files, nil
+}
+
+// Magic numbers of the compressed formats that are read transparently.
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// decompress returns the contents of r, decompressed if it is a gzip or zstd
+// stream. The format is told from the data, not the file name. close releases
+// the decoder; it does not close r.
+func decompress(r io.Reader) (_ io.Reader, close func(), err error) {
+	br := bufio.NewReaderSize(r, 64<<10)
+	head, _ := br.Peek(len(zstdMagic))
+	switch {
+	case bytes.HasPrefix(head, gzipMagic):
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, nil, fmt.Errorf("gzip: %w", err)
+		}
+		return zr, func() { _ = zr.Close() }, nil
+	case bytes.HasPrefix(head, zstdMagic):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, nil, fmt.Errorf("zstd: %w", err)
+		}
+		return zr, zr.Close, nil
+	default:
+		return br, func() {}, nil
+	}
+}
@@ -0,0 +1,101 @@
+package csvrepo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func gzipped(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := io.WriteString(zw, s); err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func zstded(t *testing.T, s string) []byte {
+	t.Helper()
+	zw, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatalf("zstd: %v", err)
+	}
+	defer zw.Close()
+	return zw.EncodeAll([]byte(s), nil)
+}
+
+func TestDecompress(t *testing.T) {
+	t.Parallel()
+
+	const want = "time,meterusage\n2019-01-01 00:15:00,55.09\n"
+	for name, in := range map[string][]byte{
+		"plain": []byte(want),
+		"gzip":  gzipped(t, want),
+		"zstd":  zstded(t, want),
+	} {
+		r, closeDecoder, err := decompress(bytes.NewReader(in))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got, err := io.ReadAll(r)
+		closeDecoder()
+		if err != nil || string(got) != want {
+			t.Fatalf("%s: got %q, %v want %q", name, got, err, want)
+		}
+	}
+
+	// A gzip header alone is not a usable stream.
+	if _, _, err := decompress(bytes.NewReader(gzipMagic)); err == nil {
+		t.Fatal("truncated gzip header: expected an error")
+	}
+}
+
+func TestExpand(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	for _, name := range []string{"b.csv", "a.csv.gz", ".b.csv.tmp", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "old.csv"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	in := func(names ...string) []string {
+		for i, n := range names {
+			names[i] = filepath.Join(dir, n)
+		}
+		return names
+	}
+
+	for path, want := range map[string][]string{
+		filepath.Join(dir, "b.csv"):  in("b.csv"),
+		dir:                          in("a.csv.gz", "b.csv", "notes.txt"),
+		filepath.Join(dir, "*.csv*"): in("a.csv.gz", "b.csv"),
+	} {
+		got, err := expand(path)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Fatalf("expand(%s)=%v, %v want %v", path, got, err, want)
+		}
+	}
+
+	for _, path := range []string{filepath.Join(dir, "missing.csv"), filepath.Join(dir, "*.zst"), filepath.Join(dir, "old*")} {
+		if _, err := expand(path); !errors.Is(err, fs.ErrNotExist) {
+			t.Fatalf("expand(%s): err=%v want fs.ErrNotExist", path, err)
+		}
+	}
+}
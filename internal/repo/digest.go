@@ -0,0 +1,46 @@
+package repo
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+
+	"github.com/milad/spectral/internal/domain"
+)
+
+// Digest summarizes a multiset of readings. Repositories holding the same
+// readings have the same Digest whatever order or batches they were added in,
+// so replicas of a dataset report the same checksum.
+type Digest struct {
+	Sum   uint64 // wrapping sum of the readings' hashes
+	Count int64
+}
+
+// Add adds readings, whose meter IDs must already be defaulted, to d.
+func (d *Digest) Add(readings ...domain.Reading) {
+	var buf [16]byte
+	for _, r := range readings {
+		h := sha256.New()
+		h.Write([]byte(r.MeterID))
+		h.Write([]byte{0})
+		binary.BigEndian.PutUint64(buf[:8], uint64(r.Time.UnixNano()))
+		binary.BigEndian.PutUint64(buf[8:], math.Float64bits(r.MeterUsage))
+		h.Write(buf[:])
+		d.Sum += binary.BigEndian.Uint64(h.Sum(nil))
+		d.Count++
+	}
+}
+
+// Checksum identifies a dataset made of base, such as a checksum of the files
+// it was loaded from, and the readings in d. It is base if d is empty.
+func (d Digest) Checksum(base string) string {
+	if d.Count == 0 {
+		return base
+	}
+	var buf [16]byte
+	binary.BigEndian.PutUint64(buf[:8], d.Sum)
+	binary.BigEndian.PutUint64(buf[8:], uint64(d.Count))
+	sum := sha256.Sum256(append([]byte(base+"\x00"), buf[:]...))
+	return hex.EncodeToString(sum[:8])
+}
@@ -2,14 +2,100 @@ package repo
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/milad/spectral/internal/domain"
 )
 
+// ErrIdempotencyConflict is returned by Append when an idempotency key is reused
+// for a different batch.
+var ErrIdempotencyConflict = errors.New("idempotency key reused with a different batch")
+
 // ReadingRepository provides access to meter usage readings.
 type ReadingRepository interface {
 	// List returns readings in ascending time order, optionally filtered by [start, end).
+	// Readings with equal times are ordered by meter ID. If meterIDs is non-empty,
+	// only readings for those meters are returned.
 	// The returned slice must be treated as read-only by callers.
-	List(ctx context.Context, startInclusive *time.Time, endExclusive *time.Time) ([]domain.Reading, error)
+	List(ctx context.Context, startInclusive *time.Time, endExclusive *time.Time, meterIDs []string) ([]domain.Reading, error)
+
+	// ListMeters returns the known meters ordered by ID.
+	ListMeters(ctx context.Context) ([]domain.Meter, error)
+
+	// Dataset describes the data currently being served.
+	Dataset(ctx context.Context) (domain.Dataset, error)
+}
+
+// Position identifies a reading in List order. Readings are ordered by time,
+// then meter ID, then insertion order, so Seq (the 0-based index among readings
+// with the same time and meter) pins down a position even when timestamps
+// repeat. Appends only ever add readings after existing ones with the same time
+// and meter, so a position stays valid across writes.
+type Position struct {
+	Time    time.Time
+	MeterID string
+	Seq     int
+}
+
+// SkipThrough returns the index of the first reading in List order that comes
+// after pos. readings must be in List order and start at or after pos.Time.
+func SkipThrough(readings []domain.Reading, pos Position) int {
+	seq := -1
+	for i, r := range readings {
+		if !r.Time.Equal(pos.Time) || r.MeterID > pos.MeterID {
+			return i
+		}
+		if r.MeterID == pos.MeterID {
+			seq++
+			if seq > pos.Seq {
+				return i
+			}
+		}
+	}
+	return len(readings)
+}
+
+// PagedReadingRepository is a ReadingRepository that can read a range a page
+// at a time, without materializing all of it.
+type PagedReadingRepository interface {
+	ReadingRepository
+
+	// ListAfter returns the first limit readings that List would return for
+	// the same filters and that come after the position after (nil for the
+	// first page). A limit <= 0 means no limit.
+	ListAfter(ctx context.Context, startInclusive *time.Time, endExclusive *time.Time, meterIDs []string, after *Position, limit int) ([]domain.Reading, error)
+}
+
+// SeekingReadingRepository is a ReadingRepository that can find a meter's
+// latest reading before a time without scanning the meter's history.
+type SeekingReadingRepository interface {
+	ReadingRepository
+
+	// LastBefore returns the last reading of meterID, in List order, with a
+	// time before t; ok is false if there is none.
+	LastBefore(ctx context.Context, meterID string, t time.Time) (r domain.Reading, ok bool, err error)
+}
+
+// WritableReadingRepository is a ReadingRepository that accepts new readings.
+type WritableReadingRepository interface {
+	ReadingRepository
+
+	// Append inserts already validated readings, preserving List ordering.
+	//
+	// If idempotencyKey is non-empty, the batch is recorded under that key along
+	// with fingerprint. A later Append with the same key and fingerprint inserts
+	// nothing and returns replayed=true; a different fingerprint fails with
+	// ErrIdempotencyConflict. readings may be empty, to record a key for a
+	// batch that had no valid rows.
+	Append(ctx context.Context, idempotencyKey, fingerprint string, readings []domain.Reading) (replayed bool, err error)
+}
+
+// IngestionReporter is a ReadingRepository loaded or seeded from files, which
+// keeps a report of the input it rejected.
+type IngestionReporter interface {
+	ReadingRepository
+
+	// IngestionReport describes the last load of the repository's files.
+	IngestionReport(ctx context.Context) (domain.IngestionReport, error)
 }
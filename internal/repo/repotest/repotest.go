@@ -0,0 +1,68 @@
+// Package repotest holds checks shared by the tests of the repo
+// implementations.
+package repotest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/milad/spectral/internal/domain"
+	"github.com/milad/spectral/internal/repo"
+)
+
+// Readings returns readings in List order that repeat a time and meter, so
+// that a page boundary can fall inside a run of duplicates.
+func Readings() []domain.Reading {
+	at := func(minute int) time.Time { return time.Date(2019, 1, 1, 0, minute, 0, 0, time.UTC) }
+	return []domain.Reading{
+		{MeterID: "a", Time: at(15), MeterUsage: 1},
+		{MeterID: "b", Time: at(15), MeterUsage: 2},
+		{MeterID: "b", Time: at(15), MeterUsage: 3},
+		{MeterID: "b", Time: at(15), MeterUsage: 4},
+		{MeterID: "a", Time: at(30), MeterUsage: 5},
+		{MeterID: "b", Time: at(45), MeterUsage: 6},
+	}
+}
+
+// ListAfterWalksPages checks that walking r with ListAfter, two readings at a
+// time, returns what List does. r must hold Readings.
+func ListAfterWalksPages(t *testing.T, r repo.PagedReadingRepository) {
+	t.Helper()
+	ctx := context.Background()
+
+	for name, ids := range map[string][]string{"all": nil, "b": {"b"}} {
+		want, err := r.List(ctx, nil, nil, ids)
+		if err != nil {
+			t.Fatalf("%s: List: %v", name, err)
+		}
+		var (
+			got   []domain.Reading
+			after *repo.Position
+		)
+		for range 10 {
+			page, err := r.ListAfter(ctx, nil, nil, ids, after, 2)
+			if err != nil {
+				t.Fatalf("%s: ListAfter: %v", name, err)
+			}
+			if len(page) == 0 {
+				break
+			}
+			got = append(got, page...)
+			// Seq counts the earlier readings with the same time and meter.
+			last, seq := got[len(got)-1], 0
+			for i := len(got) - 2; i >= 0 && got[i].Time.Equal(last.Time) && got[i].MeterID == last.MeterID; i-- {
+				seq++
+			}
+			after = &repo.Position{Time: last.Time, MeterID: last.MeterID, Seq: seq}
+		}
+		if len(got) != len(want) {
+			t.Fatalf("%s: got %d readings want %d", name, len(got), len(want))
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("%s: got[%d]=%+v want %+v", name, i, got[i], want[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package sqliterepo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// migrations are applied in order; the schema version is the number of
+// migrations applied. Never edit or reorder an existing entry, only append.
+var migrations = []string{
+	// 1: readings with an index for [start, end) range scans.
+	`CREATE TABLE readings (
+		id          INTEGER PRIMARY KEY,
+		meter_id    TEXT    NOT NULL,
+		time        TEXT    NOT NULL, -- fixed-width UTC timestamp, see timeLayout
+		meter_usage REAL    NOT NULL
+	);
+	CREATE INDEX readings_time_idx ON readings (time, meter_id);
+	CREATE INDEX readings_meter_time_idx ON readings (meter_id, time);`,
+
+	// 2: idempotency keys for AppendReadings.
+	`CREATE TABLE idempotency_keys (
+		key         TEXT PRIMARY KEY,
+		fingerprint TEXT NOT NULL,
+		created_at  TEXT NOT NULL
+	);`,
+
+	// 3: expired idempotency keys are deleted by age.
+	`CREATE INDEX idempotency_keys_created_idx ON idempotency_keys (created_at);`,
+
+	// 4: a digest of the stored readings (see repo.Digest), kept up to date by
+	// Append. Open fills it in for readings stored before it existed.
+	`CREATE TABLE readings_digest (
+		id    INTEGER PRIMARY KEY CHECK (id = 1),
+		sum   INTEGER NOT NULL, -- repo.Digest.Sum, as a signed 64-bit integer
+		count INTEGER NOT NULL
+	);
+	INSERT INTO readings_digest (id, sum, count) VALUES (1, 0, 0);`,
+
+	// 5: the ingestion report of the CSV the store was seeded from, stored by
+	// Seed.
+	`CREATE TABLE ingestion_report (
+		id     INTEGER PRIMARY KEY CHECK (id = 1),
+		report TEXT    NOT NULL -- domain.IngestionReport as JSON
+	);`,
+}
+
+func migrate(ctx context.Context, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+	)`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	var current int
+	if err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+	if current > len(migrations) {
+		return fmt.Errorf("database schema version %d is newer than supported version %d", current, len(migrations))
+	}
+
+	for v := current + 1; v <= len(migrations); v++ {
+		if err := applyMigration(ctx, db, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyMigration(ctx context.Context, db *sql.DB, version int) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("migration %d: begin: %w", version, err)
+	}
+	defer tx.Rollback() // no-op after Commit
+
+	if _, err := tx.ExecContext(ctx, migrations[version-1]); err != nil {
+		return fmt.Errorf("migration %d: %w", version, err)
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES (?)`, version); err != nil {
+		return fmt.Errorf("migration %d: record version: %w", version, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("migration %d: commit: %w", version, err)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package sqliterepo
+
+import (
+	"runtime"
+	"time"
+)
+
+// DefaultIdempotencyTTL is how long an idempotency key is remembered unless
+// WithIdempotencyTTL is given.
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// Option configures a Repo.
+type Option func(*options)
+
+type options struct {
+	readConns      int
+	idempotencyTTL time.Duration
+}
+
+func defaultOptions() options {
+	return options{
+		readConns:      runtime.GOMAXPROCS(0),
+		idempotencyTTL: DefaultIdempotencyTTL,
+	}
+}
+
+// WithReadConns lets up to n queries read the database at once, next to the
+// single writer. n < 1 means one per CPU.
+func WithReadConns(n int) Option {
+	return func(o *options) {
+		if n < 1 {
+			n = runtime.GOMAXPROCS(0)
+		}
+		o.readConns = n
+	}
+}
+
+// WithIdempotencyTTL forgets idempotency keys d after the batch they were
+// recorded with; a retry after that inserts the batch again. Expired keys are
+// deleted as new batches are appended. d <= 0 keeps the default.
+func WithIdempotencyTTL(d time.Duration) Option {
+	return func(o *options) {
+		if d > 0 {
+			o.idempotencyTTL = d
+		}
+	}
+}
@@ -0,0 +1,483 @@
+package sqliterepo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/milad/spectral/internal/domain"
+	"github.com/milad/spectral/internal/repo"
+
+	_ "modernc.org/sqlite" // registers the "sqlite" driver
+)
+
+var (
+	_ repo.WritableReadingRepository = (*Repo)(nil)
+	_ repo.PagedReadingRepository    = (*Repo)(nil)
+	_ repo.SeekingReadingRepository  = (*Repo)(nil)
+	_ repo.IngestionReporter         = (*Repo)(nil)
+)
+
+// timeLayout is fixed-width, so lexical order of stored times matches
+// chronological order and range filters can use the time index directly.
+const timeLayout = "2006-01-02T15:04:05.000000000Z"
+
+// Repo is a persistent repository backed by SQLite. It uses a pure-Go driver,
+// so builds stay CGO-free.
+type Repo struct {
+	// db is the single connection that writes; rdb is a pool of read-only
+	// connections, so reads do not queue behind a write or each other.
+	db  *sql.DB
+	rdb *sql.DB
+	// updatedAt is the UnixNano time of the last write through this Repo (or of
+	// Open); SQLite does not record when rows were inserted.
+	updatedAt      atomic.Int64
+	idempotencyTTL time.Duration
+}
+
+// Open opens (creating if needed) the database at path and applies any pending
+// schema migrations.
+func Open(ctx context.Context, path string, opts ...Option) (*Repo, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	// WAL lets readers proceed while a write is in progress; the busy timeout
+	// covers the short window in which SQLite still needs an exclusive lock.
+	db, err := sql.Open("sqlite", fileURI(path, "_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)"))
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite %q: %w", path, err)
+	}
+	// SQLite allows a single writer; one connection avoids SQLITE_BUSY between
+	// our own writers.
+	db.SetMaxOpenConns(1)
+
+	if err := migrate(ctx, db); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("migrate sqlite %q: %w", path, err)
+	}
+	if err := backfillDigest(ctx, db); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("digest sqlite %q: %w", path, err)
+	}
+
+	// The readers open the file only once migrate has created it.
+	rdb, err := sql.Open("sqlite", fileURI(path, "mode=ro&_pragma=busy_timeout(5000)&_pragma=query_only(1)"))
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("open sqlite %q for reading: %w", path, err)
+	}
+	rdb.SetMaxOpenConns(o.readConns)
+	rdb.SetMaxIdleConns(o.readConns)
+	if err := rdb.PingContext(ctx); err != nil {
+		_ = rdb.Close()
+		_ = db.Close()
+		return nil, fmt.Errorf("open sqlite %q for reading: %w", path, err)
+	}
+
+	r := &Repo{db: db, rdb: rdb, idempotencyTTL: o.idempotencyTTL}
+	r.updatedAt.Store(time.Now().UnixNano())
+	return r, nil
+}
+
+// fileURI returns the SQLite URI of the database at path, with the parameters
+// in query. Characters of path that mean something in a URI, such as ? and %,
+// are escaped; a relative path stays relative.
+func fileURI(path, query string) string {
+	u := url.URL{Scheme: "file", Path: path, OmitHost: true, RawQuery: query}
+	return u.String()
+}
+
+// backfillDigest recomputes the readings digest if it does not cover every
+// stored reading, as for a database written before it was kept.
+func backfillDigest(ctx context.Context, db *sql.DB) error {
+	var stored, rows int64
+	if err := db.QueryRowContext(ctx, `SELECT (SELECT count FROM readings_digest), (SELECT COUNT(*) FROM readings)`).Scan(&stored, &rows); err != nil {
+		return fmt.Errorf("query digest: %w", err)
+	}
+	if stored == rows {
+		return nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback() // no-op after Commit
+
+	rs, err := tx.QueryContext(ctx, `SELECT meter_id, time, meter_usage FROM readings`)
+	if err != nil {
+		return fmt.Errorf("query readings: %w", err)
+	}
+	var d repo.Digest
+	for rs.Next() {
+		var (
+			rd domain.Reading
+			ts string
+		)
+		if err := rs.Scan(&rd.MeterID, &ts, &rd.MeterUsage); err != nil {
+			_ = rs.Close()
+			return fmt.Errorf("scan reading: %w", err)
+		}
+		if rd.Time, err = parseTime(ts); err != nil {
+			_ = rs.Close()
+			return err
+		}
+		d.Add(rd)
+	}
+	if err := rs.Close(); err != nil {
+		return fmt.Errorf("query readings: %w", err)
+	}
+	if err := rs.Err(); err != nil {
+		return fmt.Errorf("query readings: %w", err)
+	}
+	if err := storeDigest(ctx, tx, d); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func loadDigest(ctx context.Context, tx *sql.Tx) (repo.Digest, error) {
+	var (
+		d   repo.Digest
+		sum int64
+	)
+	if err := tx.QueryRowContext(ctx, `SELECT sum, count FROM readings_digest`).Scan(&sum, &d.Count); err != nil {
+		return repo.Digest{}, fmt.Errorf("query digest: %w", err)
+	}
+	d.Sum = uint64(sum)
+	return d, nil
+}
+
+func storeDigest(ctx context.Context, tx *sql.Tx, d repo.Digest) error {
+	if _, err := tx.ExecContext(ctx, `UPDATE readings_digest SET sum = ?, count = ?`, int64(d.Sum), d.Count); err != nil {
+		return fmt.Errorf("update digest: %w", err)
+	}
+	return nil
+}
+
+func (r *Repo) Close() error {
+	return errors.Join(r.rdb.Close(), r.db.Close())
+}
+
+func (r *Repo) List(ctx context.Context, startInclusive *time.Time, endExclusive *time.Time, meterIDs []string) ([]domain.Reading, error) {
+	return r.ListAfter(ctx, startInclusive, endExclusive, meterIDs, nil, 0)
+}
+
+// ListAfter resumes after a position with a keyset condition on the time
+// index; Seq is resolved to the row ID it stands for, as rows with the same
+// time and meter are ordered by ID.
+func (r *Repo) ListAfter(ctx context.Context, startInclusive *time.Time, endExclusive *time.Time, meterIDs []string, after *repo.Position, limit int) ([]domain.Reading, error) {
+	var (
+		where []string
+		args  []any
+	)
+	if startInclusive != nil {
+		where = append(where, "time >= ?")
+		args = append(args, formatTime(*startInclusive))
+	}
+	if endExclusive != nil {
+		where = append(where, "time < ?")
+		args = append(args, formatTime(*endExclusive))
+	}
+	if len(meterIDs) > 0 {
+		where = append(where, "meter_id IN ("+strings.TrimSuffix(strings.Repeat("?,", len(meterIDs)), ",")+")")
+		for _, id := range meterIDs {
+			args = append(args, id)
+		}
+	}
+	if after != nil {
+		// A Seq past the end of its run (the rows are never deleted, so only
+		// for a forged position) skips the whole run.
+		ts := formatTime(after.Time)
+		where = append(where, `(time > ? OR (time = ? AND (meter_id > ? OR (meter_id = ? AND id > COALESCE(
+			(SELECT id FROM readings WHERE time = ? AND meter_id = ? ORDER BY id LIMIT 1 OFFSET ?),
+			9223372036854775807)))))`)
+		args = append(args, ts, ts, after.MeterID, after.MeterID, ts, after.MeterID, after.Seq)
+	}
+
+	q := "SELECT meter_id, time, meter_usage FROM readings"
+	if len(where) > 0 {
+		q += " WHERE " + strings.Join(where, " AND ")
+	}
+	q += " ORDER BY time, meter_id, id"
+	if limit > 0 {
+		q += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := r.rdb.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query readings: %w", err)
+	}
+	defer rows.Close()
+
+	out := []domain.Reading{}
+	for rows.Next() {
+		var (
+			rd domain.Reading
+			ts string
+		)
+		if err := rows.Scan(&rd.MeterID, &ts, &rd.MeterUsage); err != nil {
+			return nil, fmt.Errorf("scan reading: %w", err)
+		}
+		if rd.Time, err = parseTime(ts); err != nil {
+			return nil, err
+		}
+		out = append(out, rd)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query readings: %w", err)
+	}
+	return out, nil
+}
+
+// LastBefore walks the (meter_id, time) index backwards from t.
+func (r *Repo) LastBefore(ctx context.Context, meterID string, t time.Time) (domain.Reading, bool, error) {
+	var (
+		rd domain.Reading
+		ts string
+	)
+	err := r.rdb.QueryRowContext(ctx, `
+		SELECT meter_id, time, meter_usage FROM readings
+		WHERE meter_id = ? AND time < ?
+		ORDER BY time DESC, id DESC
+		LIMIT 1`, meterID, formatTime(t)).Scan(&rd.MeterID, &ts, &rd.MeterUsage)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return domain.Reading{}, false, nil
+	case err != nil:
+		return domain.Reading{}, false, fmt.Errorf("query last reading: %w", err)
+	}
+	if rd.Time, err = parseTime(ts); err != nil {
+		return domain.Reading{}, false, err
+	}
+	return rd, true, nil
+}
+
+func (r *Repo) ListMeters(ctx context.Context) ([]domain.Meter, error) {
+	rows, err := r.rdb.QueryContext(ctx, `
+		SELECT meter_id, COUNT(*), MIN(time), MAX(time)
+		FROM readings
+		GROUP BY meter_id
+		ORDER BY meter_id`)
+	if err != nil {
+		return nil, fmt.Errorf("query meters: %w", err)
+	}
+	defer rows.Close()
+
+	out := []domain.Meter{}
+	for rows.Next() {
+		var (
+			m           domain.Meter
+			first, last string
+		)
+		if err := rows.Scan(&m.ID, &m.ReadingCount, &first, &last); err != nil {
+			return nil, fmt.Errorf("scan meter: %w", err)
+		}
+		if m.FirstReading, err = parseTime(first); err != nil {
+			return nil, err
+		}
+		if m.LastReading, err = parseTime(last); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query meters: %w", err)
+	}
+	return out, nil
+}
+
+func (r *Repo) Append(ctx context.Context, idempotencyKey, fingerprint string, readings []domain.Reading) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback() // no-op after Commit
+
+	now := time.Now()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE created_at < ?`, formatTime(now.Add(-r.idempotencyTTL))); err != nil {
+		return false, fmt.Errorf("prune idempotency keys: %w", err)
+	}
+	if idempotencyKey != "" {
+		var prev string
+		err := tx.QueryRowContext(ctx, `SELECT fingerprint FROM idempotency_keys WHERE key = ?`, idempotencyKey).Scan(&prev)
+		switch {
+		case err == nil && prev == fingerprint:
+			return true, nil
+		case err == nil:
+			return false, repo.ErrIdempotencyConflict
+		case !errors.Is(err, sql.ErrNoRows):
+			return false, fmt.Errorf("lookup idempotency key: %w", err)
+		}
+	}
+
+	ins, err := newInserter(ctx, tx)
+	if err != nil {
+		return false, err
+	}
+	defer ins.close()
+	if err := ins.insert(ctx, readings); err != nil {
+		return false, err
+	}
+	if err := storeDigest(ctx, tx, ins.digest); err != nil {
+		return false, err
+	}
+
+	if idempotencyKey != "" {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO idempotency_keys (key, fingerprint, created_at) VALUES (?, ?, ?)`,
+			idempotencyKey, fingerprint, formatTime(now),
+		); err != nil {
+			return false, fmt.Errorf("record idempotency key: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("commit: %w", err)
+	}
+	r.updatedAt.Store(time.Now().UnixNano())
+	return false, nil
+}
+
+// Seed adds the readings that load passes to add, a batch at a time, in a
+// single transaction: a seed that fails leaves the store as it was. The report
+// load returns is stored with the readings, for IngestionReport. Seed returns
+// the error of load or of a failed insert.
+func (r *Repo) Seed(ctx context.Context, load func(add func([]domain.Reading) error) (domain.IngestionReport, error)) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback() // no-op after Commit
+
+	ins, err := newInserter(ctx, tx)
+	if err != nil {
+		return err
+	}
+	defer ins.close()
+	report, err := load(func(readings []domain.Reading) error { return ins.insert(ctx, readings) })
+	if err != nil {
+		return err
+	}
+	if err := storeDigest(ctx, tx, ins.digest); err != nil {
+		return err
+	}
+	b, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("encode ingestion report: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO ingestion_report (id, report) VALUES (1, ?)`, string(b)); err != nil {
+		return fmt.Errorf("store ingestion report: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	r.updatedAt.Store(time.Now().UnixNano())
+	return nil
+}
+
+// IngestionReport describes the CSV the store was seeded from, as loaded by
+// Seed. Stores that were never seeded report nothing.
+func (r *Repo) IngestionReport(ctx context.Context) (domain.IngestionReport, error) {
+	var b string
+	err := r.rdb.QueryRowContext(ctx, `SELECT report FROM ingestion_report WHERE id = 1`).Scan(&b)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.IngestionReport{}, nil
+	}
+	if err != nil {
+		return domain.IngestionReport{}, fmt.Errorf("query ingestion report: %w", err)
+	}
+	var rep domain.IngestionReport
+	if err := json.Unmarshal([]byte(b), &rep); err != nil {
+		return domain.IngestionReport{}, fmt.Errorf("decode ingestion report: %w", err)
+	}
+	return rep, nil
+}
+
+// inserter inserts readings within a transaction and keeps the readings
+// digest up to date; the caller stores digest before committing.
+type inserter struct {
+	stmt   *sql.Stmt
+	digest repo.Digest
+}
+
+func newInserter(ctx context.Context, tx *sql.Tx) (*inserter, error) {
+	d, err := loadDigest(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO readings (meter_id, time, meter_usage) VALUES (?, ?, ?)`)
+	if err != nil {
+		return nil, fmt.Errorf("prepare insert: %w", err)
+	}
+	return &inserter{stmt: stmt, digest: d}, nil
+}
+
+func (ins *inserter) insert(ctx context.Context, readings []domain.Reading) error {
+	for _, rd := range readings {
+		if rd.MeterID == "" {
+			rd.MeterID = domain.DefaultMeterID
+		}
+		if _, err := ins.stmt.ExecContext(ctx, rd.MeterID, formatTime(rd.Time), rd.MeterUsage); err != nil {
+			return fmt.Errorf("insert reading: %w", err)
+		}
+		ins.digest.Add(rd)
+	}
+	return nil
+}
+
+func (ins *inserter) close() { _ = ins.stmt.Close() }
+
+// Dataset reports the highest row ID as the version: rows are never deleted or
+// updated, so it increases with every insert. The row count and checksum come
+// from the readings digest, so that the health checks polling it do not scan
+// the table.
+func (r *Repo) Dataset(ctx context.Context) (domain.Dataset, error) {
+	var (
+		ds      domain.Dataset
+		version int64
+		d       repo.Digest
+		sum     int64
+	)
+	if err := r.rdb.QueryRowContext(ctx,
+		`SELECT (SELECT COALESCE(MAX(id), 0) FROM readings), sum, count FROM readings_digest`,
+	).Scan(&version, &sum, &d.Count); err != nil {
+		return domain.Dataset{}, fmt.Errorf("query dataset: %w", err)
+	}
+	d.Sum = uint64(sum)
+	ds.Rows = int(d.Count)
+	ds.Version = uint64(version)
+	ds.Checksum = d.Checksum("")
+	ds.UpdatedAt = time.Unix(0, r.updatedAt.Load()).UTC()
+	return ds, nil
+}
+
+// Count returns the number of stored readings.
+func (r *Repo) Count(ctx context.Context) (int, error) {
+	var n int
+	if err := r.rdb.QueryRowContext(ctx, `SELECT COUNT(*) FROM readings`).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count readings: %w", err)
+	}
+	return n, nil
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeLayout)
+}
+
+func parseTime(s string) (time.Time, error) {
+	t, err := time.Parse(timeLayout, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse stored time %q: %w", s, err)
+	}
+	return t, nil
+}
@@ -0,0 +1,441 @@
+package sqliterepo
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/milad/spectral/internal/domain"
+	"github.com/milad/spectral/internal/repo"
+	"github.com/milad/spectral/internal/repo/repotest"
+)
+
+func mustUTC(t *testing.T, s string) time.Time {
+	t.Helper()
+	got, err := time.ParseInLocation("2006-01-02 15:04:05", s, time.UTC)
+	if err != nil {
+		t.Fatalf("parse time %q: %v", s, err)
+	}
+	return got
+}
+
+func openTemp(t *testing.T, opts ...Option) (*Repo, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "meterusage.db")
+	r, err := Open(context.Background(), path, opts...)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { _ = r.Close() })
+	return r, path
+}
+
+func TestOpen_EscapesPath(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "a?b#c%20d e")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+	path := filepath.Join(dir, "meterusage.db")
+	r, err := Open(ctx, path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer r.Close()
+	if _, err := r.Append(ctx, "", "", repotest.Readings()); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if n, err := r.Count(ctx); err != nil || n != len(repotest.Readings()) {
+		t.Fatalf("Count=%d err=%v want %d", n, err, len(repotest.Readings()))
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("database not created at %q: %v", path, err)
+	}
+}
+
+func TestFileURI(t *testing.T) {
+	t.Parallel()
+
+	for path, want := range map[string]string{
+		"meterusage.db":       "file:meterusage.db?mode=ro",
+		"data/meterusage.db":  "file:data/meterusage.db?mode=ro",
+		"/var/lib/a?b#c%d.db": "file:/var/lib/a%3Fb%23c%25d.db?mode=ro",
+	} {
+		if got := fileURI(path, "mode=ro"); got != want {
+			t.Fatalf("fileURI(%q)=%q want %q", path, got, want)
+		}
+	}
+}
+
+func TestRepo_ListFiltersAndOrders(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	r, _ := openTemp(t)
+
+	if _, err := r.Append(ctx, "", "", []domain.Reading{
+		{MeterID: "c", Time: mustUTC(t, "2019-01-01 00:45:00"), MeterUsage: 3},
+		{MeterID: "b", Time: mustUTC(t, "2019-01-01 00:15:00"), MeterUsage: 1},
+		{MeterID: "a", Time: mustUTC(t, "2019-01-01 00:15:00"), MeterUsage: 2},
+		{Time: mustUTC(t, "2019-01-01 00:30:00"), MeterUsage: 4},
+	}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+
+	all, err := r.List(ctx, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if got, want := len(all), 4; got != want {
+		t.Fatalf("len(all)=%d want %d", got, want)
+	}
+	if all[0].MeterID != "a" || all[1].MeterID != "b" {
+		t.Fatalf("expected equal times to be ordered by meter id, got %q, %q", all[0].MeterID, all[1].MeterID)
+	}
+	if got, want := all[2].MeterID, domain.DefaultMeterID; got != want {
+		t.Fatalf("all[2].MeterID=%q want %q", got, want)
+	}
+	if got, want := all[0].Time, mustUTC(t, "2019-01-01 00:15:00"); !got.Equal(want) {
+		t.Fatalf("all[0].Time=%v want %v", got, want)
+	}
+
+	start := mustUTC(t, "2019-01-01 00:30:00")
+	end := mustUTC(t, "2019-01-01 00:45:00")
+	out, err := r.List(ctx, &start, &end, nil)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if got, want := len(out), 1; got != want {
+		t.Fatalf("len(out)=%d want %d", got, want)
+	}
+	if got, want := out[0].MeterUsage, 4.0; got != want {
+		t.Fatalf("out[0].MeterUsage=%v want %v", got, want)
+	}
+
+	out, err = r.List(ctx, nil, nil, []string{"b", "c"})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if got, want := len(out), 2; got != want {
+		t.Fatalf("len(out)=%d want %d", got, want)
+	}
+	if out[0].MeterID != "b" || out[1].MeterID != "c" {
+		t.Fatalf("unexpected meters: %q, %q", out[0].MeterID, out[1].MeterID)
+	}
+}
+
+func TestRepo_ListMeters(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	r, _ := openTemp(t)
+
+	if _, err := r.Append(ctx, "", "", []domain.Reading{
+		{MeterID: "b", Time: mustUTC(t, "2019-01-01 00:15:00"), MeterUsage: 1},
+		{MeterID: "a", Time: mustUTC(t, "2019-01-01 00:30:00"), MeterUsage: 2},
+		{MeterID: "b", Time: mustUTC(t, "2019-01-01 00:45:00"), MeterUsage: 3},
+	}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+
+	meters, err := r.ListMeters(ctx)
+	if err != nil {
+		t.Fatalf("ListMeters: %v", err)
+	}
+	if got, want := len(meters), 2; got != want {
+		t.Fatalf("len(meters)=%d want %d", got, want)
+	}
+	b := meters[1]
+	if b.ID != "b" || b.ReadingCount != 2 {
+		t.Fatalf("unexpected meter: %+v", b)
+	}
+	if !b.FirstReading.Equal(mustUTC(t, "2019-01-01 00:15:00")) || !b.LastReading.Equal(mustUTC(t, "2019-01-01 00:45:00")) {
+		t.Fatalf("unexpected reading span: %v..%v", b.FirstReading, b.LastReading)
+	}
+}
+
+func TestRepo_AppendIdempotency(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	r, _ := openTemp(t)
+
+	batch := []domain.Reading{{MeterID: "a", Time: mustUTC(t, "2019-01-01 00:15:00"), MeterUsage: 1}}
+	if replayed, err := r.Append(ctx, "k1", "fp1", batch); err != nil || replayed {
+		t.Fatalf("first Append: replayed=%v err=%v", replayed, err)
+	}
+	if replayed, err := r.Append(ctx, "k1", "fp1", batch); err != nil || !replayed {
+		t.Fatalf("replayed Append: replayed=%v err=%v", replayed, err)
+	}
+	if _, err := r.Append(ctx, "k1", "fp2", batch); !errors.Is(err, repo.ErrIdempotencyConflict) {
+		t.Fatalf("expected ErrIdempotencyConflict, got %v", err)
+	}
+
+	n, err := r.Count(ctx)
+	if err != nil {
+		t.Fatalf("Count: %v", err)
+	}
+	if got, want := n, 1; got != want {
+		t.Fatalf("Count=%d want %d", got, want)
+	}
+}
+
+func TestRepo_IdempotencyKeysExpire(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	r, _ := openTemp(t, WithIdempotencyTTL(time.Millisecond))
+
+	batch := []domain.Reading{{MeterID: "a", Time: mustUTC(t, "2019-01-01 00:15:00"), MeterUsage: 1}}
+	if _, err := r.Append(ctx, "k1", "fp1", batch); err != nil {
+		t.Fatalf("first Append: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	// The key has expired, so it can be used again; appending also prunes it.
+	if replayed, err := r.Append(ctx, "k2", "fp2", batch); err != nil || replayed {
+		t.Fatalf("second Append: replayed=%v err=%v", replayed, err)
+	}
+	var keys []string
+	rows, err := r.db.QueryContext(ctx, `SELECT key FROM idempotency_keys`)
+	if err != nil {
+		t.Fatalf("query keys: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var k string
+		if err := rows.Scan(&k); err != nil {
+			t.Fatalf("scan key: %v", err)
+		}
+		keys = append(keys, k)
+	}
+	if len(keys) != 1 || keys[0] != "k2" {
+		t.Fatalf("keys=%v want [k2]", keys)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	if replayed, err := r.Append(ctx, "k2", "fp3", batch); err != nil || replayed {
+		t.Fatalf("Append with expired key: replayed=%v err=%v", replayed, err)
+	}
+}
+
+func TestRepo_ReadsDoNotWaitForWriter(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	r, _ := openTemp(t)
+
+	if _, err := r.Append(ctx, "", "", []domain.Reading{
+		{MeterID: "a", Time: mustUTC(t, "2019-01-01 00:15:00"), MeterUsage: 1},
+	}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+
+	// Hold the writer connection in an open transaction.
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `INSERT INTO readings (meter_id, time, meter_usage) VALUES ('a', ?, 2)`,
+		formatTime(mustUTC(t, "2019-01-01 00:30:00"))); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	readCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	got, err := r.List(readCtx, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("List during write: %v", err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("List during write returned %d readings, want only the committed one", len(got))
+	}
+
+	// The read pool cannot write.
+	if _, err := r.rdb.ExecContext(ctx, `DELETE FROM readings`); err == nil {
+		t.Fatalf("write through the read pool succeeded")
+	}
+}
+
+func TestOpen_ReopenKeepsData(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	r, path := openTemp(t)
+
+	if _, err := r.Append(ctx, "", "", []domain.Reading{
+		{MeterID: "a", Time: mustUTC(t, "2019-01-01 00:15:00"), MeterUsage: 1},
+	}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// Re-running migrations against an up-to-date schema must be a no-op.
+	r2, err := Open(ctx, path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer r2.Close()
+
+	n, err := r2.Count(ctx)
+	if err != nil {
+		t.Fatalf("Count: %v", err)
+	}
+	if got, want := n, 1; got != want {
+		t.Fatalf("Count=%d want %d", got, want)
+	}
+}
+
+func TestRepo_ChecksumCoversEveryReading(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	readings := []domain.Reading{
+		{MeterID: "a", Time: mustUTC(t, "2019-01-01 00:15:00"), MeterUsage: 1},
+		{MeterID: "b", Time: mustUTC(t, "2019-01-01 00:15:00"), MeterUsage: 2},
+	}
+	checksum := func(r *Repo) string {
+		t.Helper()
+		ds, err := r.Dataset(ctx)
+		if err != nil {
+			t.Fatalf("Dataset: %v", err)
+		}
+		return ds.Checksum
+	}
+
+	one, path := openTemp(t)
+	if _, err := one.Append(ctx, "", "", readings); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	other, _ := openTemp(t)
+	for _, rd := range []domain.Reading{readings[1], readings[0]} {
+		if _, err := other.Append(ctx, "", "", []domain.Reading{rd}); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	want := checksum(one)
+	if want == "" || checksum(other) != want {
+		t.Fatalf("checksums=%q,%q want equal ones for the same readings", want, checksum(other))
+	}
+
+	// A database written before the digest was kept gets one on Open.
+	if _, err := one.db.ExecContext(ctx, `UPDATE readings_digest SET sum = 0, count = 0`); err != nil {
+		t.Fatalf("reset digest: %v", err)
+	}
+	if err := one.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	reopened, err := Open(ctx, path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer reopened.Close()
+	if got := checksum(reopened); got != want {
+		t.Fatalf("checksum=%q want %q after reopening", got, want)
+	}
+}
+
+func TestRepo_ListAfterWalksPages(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	r, _ := openTemp(t)
+
+	if _, err := r.Append(ctx, "", "", repotest.Readings()); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+
+	repotest.ListAfterWalksPages(t, r)
+}
+
+func TestRepo_LastBefore(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	r, _ := openTemp(t)
+
+	if _, err := r.Append(ctx, "", "", repotest.Readings()); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+
+	for name, tc := range map[string]struct {
+		meterID string
+		t       string
+		want    float64 // MeterUsage; 0 if there is no reading
+	}{
+		"before first":    {meterID: "a", t: "2019-01-01 00:15:00"},
+		"last of equal":   {meterID: "b", t: "2019-01-01 00:30:00", want: 4},
+		"skips meters":    {meterID: "a", t: "2019-01-01 01:00:00", want: 5},
+		"strictly before": {meterID: "b", t: "2019-01-01 00:45:00", want: 4},
+		"unknown meter":   {meterID: "c", t: "2019-01-01 01:00:00"},
+	} {
+		got, ok, err := r.LastBefore(ctx, tc.meterID, mustUTC(t, tc.t))
+		if err != nil {
+			t.Fatalf("%s: LastBefore: %v", name, err)
+		}
+		if ok != (tc.want != 0) || got.MeterUsage != tc.want {
+			t.Fatalf("%s: got %+v ok=%v want usage %v", name, got, ok, tc.want)
+		}
+		if ok && (got.MeterID != tc.meterID || !got.Time.Before(mustUTC(t, tc.t))) {
+			t.Fatalf("%s: got %+v, want meter %s before %s", name, got, tc.meterID, tc.t)
+		}
+	}
+}
+
+func TestRepo_Seed(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	r, _ := openTemp(t)
+	batch := func(usage float64) []domain.Reading {
+		return []domain.Reading{{Time: mustUTC(t, "2019-01-01 00:00:00"), MeterUsage: usage}}
+	}
+
+	report := domain.IngestionReport{
+		Source:     "seed.csv",
+		LoadedAt:   mustUTC(t, "2019-01-02 00:00:00"),
+		Applied:    true,
+		Files:      1,
+		Accepted:   3,
+		Rejected:   1,
+		Categories: []domain.IngestionCount{{Category: domain.IngestInvalidUsage, Count: 1}},
+		Issues:     []domain.IngestionIssue{{File: "seed.csv", Row: 3, Values: []string{"x"}, Category: domain.IngestInvalidUsage, Message: "bad"}},
+	}
+
+	failed := errors.New("load failed")
+	err := r.Seed(ctx, func(add func([]domain.Reading) error) (domain.IngestionReport, error) {
+		if err := add(batch(1)); err != nil {
+			return domain.IngestionReport{}, err
+		}
+		return report, failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("Seed: err=%v want %v", err, failed)
+	}
+	if n, _ := r.Count(ctx); n != 0 {
+		t.Fatalf("failed seed left %d readings", n)
+	}
+	if got, err := r.IngestionReport(ctx); err != nil || got.Source != "" {
+		t.Fatalf("IngestionReport=%+v err=%v want none after a failed seed", got, err)
+	}
+
+	err = r.Seed(ctx, func(add func([]domain.Reading) error) (domain.IngestionReport, error) {
+		for _, usage := range []float64{1, 2, 3} {
+			if err := add(batch(usage)); err != nil {
+				return domain.IngestionReport{}, err
+			}
+		}
+		return report, nil
+	})
+	if err != nil {
+		t.Fatalf("Seed: %v", err)
+	}
+	ds, err := r.Dataset(ctx)
+	if err != nil || ds.Rows != 3 || ds.Checksum == "" {
+		t.Fatalf("dataset=%+v err=%v want 3 rows and a checksum", ds, err)
+	}
+	got, err := r.IngestionReport(ctx)
+	if err != nil || !reflect.DeepEqual(got, report) {
+		t.Fatalf("IngestionReport=%+v err=%v want %+v", got, err, report)
+	}
+}
@@ -0,0 +1,263 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var ErrInvalidAggregation = errors.New("invalid aggregation")
+
+// MaxAggregateBuckets caps the number of buckets a single aggregation may span,
+// including empty buckets that are skipped in the response.
+const MaxAggregateBuckets = 10_000
+
+type AggregateFunc int
+
+const (
+	AggregateSum AggregateFunc = iota + 1
+	AggregateAvg
+	AggregateMin
+	AggregateMax
+	AggregateCount
+)
+
+// AllAggregateFuncs is used when a request does not name any functions.
+var AllAggregateFuncs = []AggregateFunc{AggregateSum, AggregateAvg, AggregateMin, AggregateMax, AggregateCount}
+
+type CalendarInterval int
+
+const (
+	CalendarNone CalendarInterval = iota
+	CalendarDay
+	CalendarWeek
+	CalendarMonth
+)
+
+// EmptyBuckets controls how buckets without readings are reported.
+type EmptyBuckets int
+
+const (
+	EmptyBucketsSkip EmptyBuckets = iota
+	EmptyBucketsNull
+	EmptyBucketsZero
+)
+
+type AggregateQuery struct {
+	Start *time.Time
+	End   *time.Time
+
+	// MeterIDs restricts the aggregation to the given meters; readings from
+	// all selected meters are combined into the same buckets.
+	MeterIDs []string
+
+	// Exactly one of Width and Calendar must be set.
+	Width    time.Duration
+	Calendar CalendarInterval
+	// Location is the time zone in which calendar buckets start at midnight;
+	// nil means UTC. Days are 23 or 25 hours long across DST changes. Fixed
+	// widths are always aligned to the Unix epoch.
+	Location *time.Location
+
+	Funcs        []AggregateFunc
+	EmptyBuckets EmptyBuckets
+}
+
+// Bucket holds the aggregates of readings in [Start, End). Aggregates that were
+// not requested, or that are undefined for an empty bucket, are nil.
+type Bucket struct {
+	Start time.Time
+	End   time.Time
+
+	Count *int64
+	Sum   *float64
+	Avg   *float64
+	Min   *float64
+	Max   *float64
+}
+
+func (s *MeterUsageService) AggregateReadings(ctx context.Context, q AggregateQuery) (_ []Bucket, err error) {
+	ctx, span := startSpan(ctx, "MeterUsageService.AggregateReadings",
+		attribute.String("bucket_width", q.Width.String()),
+		attribute.Int("calendar", int(q.Calendar)),
+	)
+	defer func() { endSpan(span, err) }()
+
+	if q.Start != nil && q.End != nil && !q.Start.Before(*q.End) {
+		return nil, fmt.Errorf("%w: start must be before end", ErrInvalidTimeRange)
+	}
+	if (q.Width > 0) == (q.Calendar != CalendarNone) {
+		return nil, fmt.Errorf("%w: exactly one of bucket width or calendar interval is required", ErrInvalidAggregation)
+	}
+	if q.Width < 0 {
+		return nil, fmt.Errorf("%w: bucket width must be positive", ErrInvalidAggregation)
+	}
+	if q.Calendar < CalendarNone || q.Calendar > CalendarMonth {
+		return nil, fmt.Errorf("%w: unknown calendar interval", ErrInvalidAggregation)
+	}
+	if q.EmptyBuckets < EmptyBucketsSkip || q.EmptyBuckets > EmptyBucketsZero {
+		return nil, fmt.Errorf("%w: unknown empty bucket mode", ErrInvalidAggregation)
+	}
+	funcs := q.Funcs
+	if len(funcs) == 0 {
+		funcs = AllAggregateFuncs
+	}
+	for _, f := range funcs {
+		if f < AggregateSum || f > AggregateCount {
+			return nil, fmt.Errorf("%w: unknown aggregate function", ErrInvalidAggregation)
+		}
+	}
+
+	readings, err := s.repoList(ctx, q.Start, q.End, q.MeterIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Without explicit bounds, the range is defined by the data itself.
+	var from, to time.Time
+	switch {
+	case q.Start != nil:
+		from = *q.Start
+	case len(readings) > 0:
+		from = readings[0].Time
+	default:
+		return []Bucket{}, nil
+	}
+	switch {
+	case q.End != nil:
+		to = *q.End
+	case len(readings) > 0:
+		to = readings[len(readings)-1].Time.Add(time.Nanosecond)
+	default:
+		return []Bucket{}, nil
+	}
+
+	b := bucketer{width: q.Width, calendar: q.Calendar, loc: q.Location}
+	if b.loc == nil {
+		b.loc = time.UTC
+	}
+	out := []Bucket{}
+	i, n := 0, 0
+	for bStart := b.floor(from); bStart.Before(to); bStart = b.next(bStart) {
+		if n++; n > MaxAggregateBuckets {
+			return nil, fmt.Errorf("%w: range spans too many buckets (max %d)", ErrInvalidAggregation, MaxAggregateBuckets)
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		bEnd := b.next(bStart)
+
+		var acc accumulator
+		for i < len(readings) && readings[i].Time.Before(bEnd) {
+			acc.add(readings[i].MeterUsage)
+			i++
+		}
+		if acc.count == 0 && q.EmptyBuckets == EmptyBucketsSkip {
+			continue
+		}
+		out = append(out, acc.bucket(bStart, bEnd, funcs, q.EmptyBuckets == EmptyBucketsZero))
+	}
+	return out, nil
+}
+
+type bucketer struct {
+	width    time.Duration
+	calendar CalendarInterval
+	loc      *time.Location
+}
+
+// floor returns the start of the bucket containing t.
+func (b bucketer) floor(t time.Time) time.Time {
+	t = t.In(b.loc)
+	switch b.calendar {
+	case CalendarDay:
+		return b.midnight(t.Year(), t.Month(), t.Day())
+	case CalendarWeek:
+		// Go weekdays start on Sunday; ISO weeks start on Monday.
+		offset := (int(t.Weekday()) + 6) % 7
+		return b.midnight(t.Year(), t.Month(), t.Day()-offset)
+	case CalendarMonth:
+		return b.midnight(t.Year(), t.Month(), 1)
+	default:
+		off := t.Sub(time.Unix(0, 0)) % b.width
+		if off < 0 {
+			off += b.width
+		}
+		return t.Add(-off)
+	}
+}
+
+// next returns the start of the bucket following the one starting at t.
+func (b bucketer) next(t time.Time) time.Time {
+	switch b.calendar {
+	case CalendarDay:
+		return b.midnight(t.Year(), t.Month(), t.Day()+1)
+	case CalendarWeek:
+		return b.midnight(t.Year(), t.Month(), t.Day()+7)
+	case CalendarMonth:
+		return b.midnight(t.Year(), t.Month()+1, 1)
+	default:
+		return t.Add(b.width)
+	}
+}
+
+// midnight returns the start of the given (normalized) day in b.loc. Where
+// a DST change skips midnight, the day starts at the first instant after it.
+func (b bucketer) midnight(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, b.loc)
+}
+
+type accumulator struct {
+	count    int64
+	sum      float64
+	min, max float64
+}
+
+func (a *accumulator) add(v float64) {
+	if a.count == 0 {
+		a.min, a.max = v, v
+	} else {
+		a.min = math.Min(a.min, v)
+		a.max = math.Max(a.max, v)
+	}
+	a.count++
+	a.sum += v
+}
+
+func (a *accumulator) bucket(start, end time.Time, funcs []AggregateFunc, zeroFill bool) Bucket {
+	out := Bucket{Start: start, End: end}
+	defined := a.count > 0 || zeroFill
+	for _, f := range funcs {
+		switch f {
+		case AggregateCount:
+			out.Count = ptr(a.count)
+		case AggregateSum:
+			if defined {
+				out.Sum = ptr(a.sum)
+			}
+		case AggregateAvg:
+			if defined {
+				avg := 0.0
+				if a.count > 0 {
+					avg = a.sum / float64(a.count)
+				}
+				out.Avg = ptr(avg)
+			}
+		case AggregateMin:
+			if defined {
+				out.Min = ptr(a.min)
+			}
+		case AggregateMax:
+			if defined {
+				out.Max = ptr(a.max)
+			}
+		}
+	}
+	return out
+}
+
+func ptr[T any](v T) *T { return &v }
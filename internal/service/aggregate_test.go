@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	_ "time/tzdata" // tests must not depend on the host's zoneinfo
+
+	"github.com/milad/spectral/internal/domain"
+	"github.com/milad/spectral/internal/repo/csvrepo"
+)
+
+func TestMeterUsageService_AggregateReadings_FixedWidth(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := csvrepo.New([]domain.Reading{
+		{Time: base.Add(15 * time.Minute), MeterUsage: 1},
+		{Time: base.Add(30 * time.Minute), MeterUsage: 2},
+		{Time: base.Add(45 * time.Minute), MeterUsage: 3},
+		{Time: base.Add(60 * time.Minute), MeterUsage: 10},
+	})
+	svc := NewMeterUsageService(r)
+
+	buckets, err := svc.AggregateReadings(context.Background(), AggregateQuery{Width: time.Hour})
+	if err != nil {
+		t.Fatalf("AggregateReadings: %v", err)
+	}
+	if got, want := len(buckets), 2; got != want {
+		t.Fatalf("len(buckets)=%d want %d", got, want)
+	}
+
+	b := buckets[0]
+	if !b.Start.Equal(base) || !b.End.Equal(base.Add(time.Hour)) {
+		t.Fatalf("bucket[0]=[%s, %s) want [%s, %s)", b.Start, b.End, base, base.Add(time.Hour))
+	}
+	if *b.Count != 3 || *b.Sum != 6 || *b.Avg != 2 || *b.Min != 1 || *b.Max != 3 {
+		t.Fatalf("unexpected aggregates: count=%d sum=%v avg=%v min=%v max=%v", *b.Count, *b.Sum, *b.Avg, *b.Min, *b.Max)
+	}
+	if got, want := *buckets[1].Sum, 10.0; got != want {
+		t.Fatalf("bucket[1].Sum=%v want %v", got, want)
+	}
+}
+
+func TestMeterUsageService_AggregateReadings_OnlyRequestedFuncs(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := csvrepo.New([]domain.Reading{{Time: base, MeterUsage: 1}})
+	svc := NewMeterUsageService(r)
+
+	buckets, err := svc.AggregateReadings(context.Background(), AggregateQuery{
+		Calendar: CalendarDay,
+		Funcs:    []AggregateFunc{AggregateMax},
+	})
+	if err != nil {
+		t.Fatalf("AggregateReadings: %v", err)
+	}
+	if got, want := len(buckets), 1; got != want {
+		t.Fatalf("len(buckets)=%d want %d", got, want)
+	}
+	if b := buckets[0]; b.Max == nil || b.Sum != nil || b.Count != nil {
+		t.Fatalf("expected only max to be set, got %+v", b)
+	}
+}
+
+func TestMeterUsageService_AggregateReadings_EmptyBuckets(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := csvrepo.New([]domain.Reading{
+		{Time: base.Add(10 * time.Minute), MeterUsage: 1},
+		{Time: base.Add(130 * time.Minute), MeterUsage: 2},
+	})
+	svc := NewMeterUsageService(r)
+	end := base.Add(3 * time.Hour)
+
+	tests := []struct {
+		mode    EmptyBuckets
+		buckets int
+		check   func(b Bucket) bool
+	}{
+		{EmptyBucketsSkip, 2, nil},
+		{EmptyBucketsNull, 3, func(b Bucket) bool { return *b.Count == 0 && b.Sum == nil && b.Min == nil }},
+		{EmptyBucketsZero, 3, func(b Bucket) bool { return *b.Count == 0 && *b.Sum == 0 && *b.Min == 0 }},
+	}
+	for _, tt := range tests {
+		buckets, err := svc.AggregateReadings(context.Background(), AggregateQuery{
+			Start:        &base,
+			End:          &end,
+			Width:        time.Hour,
+			EmptyBuckets: tt.mode,
+		})
+		if err != nil {
+			t.Fatalf("mode %d: AggregateReadings: %v", tt.mode, err)
+		}
+		if got := len(buckets); got != tt.buckets {
+			t.Fatalf("mode %d: len(buckets)=%d want %d", tt.mode, got, tt.buckets)
+		}
+		if tt.check != nil && !tt.check(buckets[1]) {
+			t.Fatalf("mode %d: unexpected empty bucket %+v", tt.mode, buckets[1])
+		}
+	}
+}
+
+func TestMeterUsageService_AggregateReadings_CalendarMonth(t *testing.T) {
+	t.Parallel()
+
+	r := csvrepo.New([]domain.Reading{
+		{Time: time.Date(2019, 1, 31, 23, 45, 0, 0, time.UTC), MeterUsage: 1},
+		{Time: time.Date(2019, 2, 1, 0, 0, 0, 0, time.UTC), MeterUsage: 2},
+		{Time: time.Date(2019, 2, 28, 23, 45, 0, 0, time.UTC), MeterUsage: 3},
+	})
+	svc := NewMeterUsageService(r)
+
+	buckets, err := svc.AggregateReadings(context.Background(), AggregateQuery{
+		Calendar: CalendarMonth,
+		Funcs:    []AggregateFunc{AggregateSum},
+	})
+	if err != nil {
+		t.Fatalf("AggregateReadings: %v", err)
+	}
+	if got, want := len(buckets), 2; got != want {
+		t.Fatalf("len(buckets)=%d want %d", got, want)
+	}
+	if got, want := buckets[1].End, time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Fatalf("bucket[1].End=%s want %s", got, want)
+	}
+	if got, want := *buckets[1].Sum, 5.0; got != want {
+		t.Fatalf("bucket[1].Sum=%v want %v", got, want)
+	}
+}
+
+func TestMeterUsageService_AggregateReadings_CalendarDayInTimeZone(t *testing.T) {
+	t.Parallel()
+
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("load location: %v", err)
+	}
+	// 2019-11-03 is 25 hours long in New York (clocks go back at 02:00 EDT).
+	r := csvrepo.New([]domain.Reading{
+		{Time: time.Date(2019, 11, 3, 3, 59, 0, 0, time.UTC), MeterUsage: 1}, // Nov 2, 23:59 EDT
+		{Time: time.Date(2019, 11, 3, 4, 0, 0, 0, time.UTC), MeterUsage: 2},  // Nov 3, 00:00 EDT
+		{Time: time.Date(2019, 11, 4, 4, 59, 0, 0, time.UTC), MeterUsage: 3}, // Nov 3, 23:59 EST
+		{Time: time.Date(2019, 11, 4, 5, 0, 0, 0, time.UTC), MeterUsage: 4},  // Nov 4, 00:00 EST
+	})
+	svc := NewMeterUsageService(r)
+
+	buckets, err := svc.AggregateReadings(context.Background(), AggregateQuery{
+		Calendar: CalendarDay,
+		Location: ny,
+		Funcs:    []AggregateFunc{AggregateSum},
+	})
+	if err != nil {
+		t.Fatalf("AggregateReadings: %v", err)
+	}
+	if got, want := len(buckets), 3; got != want {
+		t.Fatalf("len(buckets)=%d want %d", got, want)
+	}
+	b := buckets[1]
+	if got, want := b.End.Sub(b.Start), 25*time.Hour; got != want {
+		t.Fatalf("bucket[1] length=%s want %s", got, want)
+	}
+	if got, want := b.Start.Format(time.RFC3339), "2019-11-03T00:00:00-04:00"; got != want {
+		t.Fatalf("bucket[1].Start=%s want %s", got, want)
+	}
+	if got, want := *b.Sum, 5.0; got != want {
+		t.Fatalf("bucket[1].Sum=%v want %v", got, want)
+	}
+}
+
+func TestMeterUsageService_AggregateReadings_RejectsInvalidQuery(t *testing.T) {
+	t.Parallel()
+
+	svc := NewMeterUsageService(csvrepo.New([]domain.Reading{}))
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(1, 0, 0)
+
+	for _, q := range []AggregateQuery{
+		{},
+		{Width: time.Hour, Calendar: CalendarDay},
+		{Width: time.Hour, Funcs: []AggregateFunc{42}},
+		{Start: &start, End: &end, Width: time.Minute},
+	} {
+		_, err := svc.AggregateReadings(context.Background(), q)
+		if !errors.Is(err, ErrInvalidAggregation) {
+			t.Fatalf("query %+v: expected ErrInvalidAggregation, got %v", q, err)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/milad/spectral/internal/domain"
+	"github.com/milad/spectral/internal/repo"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	ErrInvalidBatch = errors.New("invalid batch")
+	// ErrReadOnly is returned by writes when the repository does not accept them.
+	ErrReadOnly = errors.New("repository is read-only")
+	// ErrIdempotencyConflict is returned when an idempotency key is reused for a different batch.
+	ErrIdempotencyConflict = repo.ErrIdempotencyConflict
+)
+
+const (
+	MaxAppendBatchSize     = MaxPageSize
+	MaxIdempotencyKeyBytes = 128
+)
+
+type AppendResult struct {
+	// Accepted is the number of readings inserted (or, for a replayed batch,
+	// the number inserted by the original request).
+	Accepted int
+	Rejected []RowError
+	// Replayed is true if the idempotency key matched an earlier, identical batch.
+	Replayed bool
+}
+
+// RowError describes why the reading at Index (in request order) was rejected.
+type RowError struct {
+	Index int
+	Err   error
+}
+
+// AppendReadings validates readings and inserts the valid ones. Invalid rows
+// are reported individually and do not prevent the rest of the batch from being
+// inserted. Readings without a meter ID are assigned to domain.DefaultMeterID.
+//
+// Retrying a batch with the same idempotencyKey is safe: the readings are only
+// inserted once and the original result is returned with Replayed set.
+func (s *MeterUsageService) AppendReadings(ctx context.Context, idempotencyKey string, readings []domain.Reading) (AppendResult, error) {
+	return s.AppendDecodedReadings(ctx, idempotencyKey, readings, nil)
+}
+
+// AppendDecodedReadings is AppendReadings for a transport that could not
+// decode every row: if decodeErrs[i] is non-nil, readings[i] holds what could
+// be decoded and is rejected with that error. Such rows still count towards
+// the batch size and its idempotency fingerprint, so a key reused for a batch
+// that differs only in its undecodable rows is still a conflict.
+func (s *MeterUsageService) AppendDecodedReadings(ctx context.Context, idempotencyKey string, readings []domain.Reading, decodeErrs []error) (_ AppendResult, err error) {
+	ctx, span := startSpan(ctx, "MeterUsageService.AppendReadings", attribute.Int("readings", len(readings)))
+	defer func() { endSpan(span, err) }()
+
+	w, ok := s.repo.(repo.WritableReadingRepository)
+	if !ok {
+		return AppendResult{}, ErrReadOnly
+	}
+	if len(readings) == 0 {
+		return AppendResult{}, fmt.Errorf("%w: no readings", ErrInvalidBatch)
+	}
+	if len(readings) > s.limits.MaxAppendBatchSize {
+		return AppendResult{}, fmt.Errorf("%w: too many readings (max %d)", ErrInvalidBatch, s.limits.MaxAppendBatchSize)
+	}
+	if len(idempotencyKey) > MaxIdempotencyKeyBytes {
+		return AppendResult{}, fmt.Errorf("%w: idempotency key too long (max %d bytes)", ErrInvalidBatch, MaxIdempotencyKeyBytes)
+	}
+
+	var (
+		res        AppendResult
+		normalized = make([]domain.Reading, len(readings))
+		rejected   = make([]bool, len(readings))
+		valid      = make([]domain.Reading, 0, len(readings))
+	)
+	for i, r := range readings {
+		if r.MeterID == "" {
+			r.MeterID = domain.DefaultMeterID
+		}
+		normalized[i] = r
+		err := r.Validate()
+		if i < len(decodeErrs) && decodeErrs[i] != nil {
+			err = decodeErrs[i]
+		}
+		if err != nil {
+			res.Rejected = append(res.Rejected, RowError{Index: i, Err: err})
+			rejected[i] = true
+			continue
+		}
+		valid = append(valid, r)
+	}
+	res.Accepted = len(valid)
+	// A batch with no valid rows is still recorded under its key, so that a
+	// retry with different rows is a conflict too.
+	if len(valid) == 0 && idempotencyKey == "" {
+		return res, nil
+	}
+
+	replayed, err := repoAppend(ctx, w, idempotencyKey, fingerprintBatch(normalized, rejected), valid)
+	if err != nil {
+		return AppendResult{}, err
+	}
+	// Validation is deterministic, so a replay reproduces the original result.
+	res.Replayed = replayed
+	return res, nil
+}
+
+// fingerprintBatch identifies a batch by content so that an idempotency key
+// reused for different readings can be detected. It covers every row as
+// received, after meter IDs are defaulted, rejected rows included.
+func fingerprintBatch(readings []domain.Reading, rejected []bool) string {
+	h := sha256.New()
+	var buf [8]byte
+	for i, r := range readings {
+		h.Write([]byte(r.MeterID))
+		h.Write([]byte{0})
+		binary.BigEndian.PutUint64(buf[:], uint64(r.Time.UnixNano()))
+		h.Write(buf[:])
+		binary.BigEndian.PutUint64(buf[:], math.Float64bits(r.MeterUsage))
+		h.Write(buf[:])
+		if rejected[i] {
+			h.Write([]byte{1})
+		} else {
+			h.Write([]byte{0})
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
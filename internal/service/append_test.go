@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/milad/spectral/internal/domain"
+	"github.com/milad/spectral/internal/repo"
+	"github.com/milad/spectral/internal/repo/csvrepo"
+)
+
+func TestMeterUsageService_AppendReadings_ReportsRowErrors(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := csvrepo.New(nil)
+	svc := NewMeterUsageService(r)
+
+	res, err := svc.AppendReadings(context.Background(), "", []domain.Reading{
+		{MeterID: "a", Time: base, MeterUsage: 1},
+		{MeterID: "a", Time: base.Add(15 * time.Minute), MeterUsage: math.NaN()},
+		{MeterID: "a", MeterUsage: 2},
+		{Time: base.Add(30 * time.Minute), MeterUsage: math.Inf(1)},
+		{Time: base.Add(45 * time.Minute), MeterUsage: 3},
+	})
+	if err != nil {
+		t.Fatalf("AppendReadings: %v", err)
+	}
+	if got, want := res.Accepted, 2; got != want {
+		t.Fatalf("accepted=%d want %d", got, want)
+	}
+	if got, want := len(res.Rejected), 3; got != want {
+		t.Fatalf("len(rejected)=%d want %d", got, want)
+	}
+	for i, want := range []int{1, 2, 3} {
+		if res.Rejected[i].Index != want || !errors.Is(res.Rejected[i].Err, domain.ErrInvalidReading) {
+			t.Fatalf("rejected[%d]=%+v want index %d with ErrInvalidReading", i, res.Rejected[i], want)
+		}
+	}
+
+	got, err := svc.ListReadings(context.Background(), nil, nil, nil)
+	if err != nil {
+		t.Fatalf("ListReadings: %v", err)
+	}
+	if len(got) != 2 || got[1].MeterID != domain.DefaultMeterID {
+		t.Fatalf("unexpected stored readings: %+v", got)
+	}
+}
+
+func TestMeterUsageService_AppendReadings_Idempotent(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	svc := NewMeterUsageService(csvrepo.New(nil))
+	batch := []domain.Reading{
+		{Time: base, MeterUsage: 1},
+		{Time: base.Add(15 * time.Minute), MeterUsage: math.NaN()},
+	}
+
+	first, err := svc.AppendReadings(context.Background(), "batch-1", batch)
+	if err != nil {
+		t.Fatalf("AppendReadings: %v", err)
+	}
+	retry, err := svc.AppendReadings(context.Background(), "batch-1", batch)
+	if err != nil {
+		t.Fatalf("AppendReadings (retry): %v", err)
+	}
+	if !retry.Replayed || retry.Accepted != first.Accepted || len(retry.Rejected) != len(first.Rejected) {
+		t.Fatalf("retry=%+v want replay of %+v", retry, first)
+	}
+
+	got, err := svc.ListReadings(context.Background(), nil, nil, nil)
+	if err != nil {
+		t.Fatalf("ListReadings: %v", err)
+	}
+	if got, want := len(got), 1; got != want {
+		t.Fatalf("len(readings)=%d want %d", got, want)
+	}
+
+	_, err = svc.AppendReadings(context.Background(), "batch-1", []domain.Reading{{Time: base, MeterUsage: 2}})
+	if !errors.Is(err, ErrIdempotencyConflict) {
+		t.Fatalf("expected ErrIdempotencyConflict, got %v", err)
+	}
+}
+
+func TestMeterUsageService_AppendReadings_FingerprintsBatchAsReceived(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	svc := NewMeterUsageService(csvrepo.New(nil))
+	batch := []domain.Reading{
+		{Time: base, MeterUsage: 1},
+		{Time: base.Add(15 * time.Minute), MeterUsage: math.NaN()},
+	}
+	if _, err := svc.AppendReadings(context.Background(), "batch-1", batch); err != nil {
+		t.Fatalf("AppendReadings: %v", err)
+	}
+
+	// The default meter ID is part of the batch as stored.
+	named := []domain.Reading{
+		{MeterID: domain.DefaultMeterID, Time: base, MeterUsage: 1},
+		{MeterID: domain.DefaultMeterID, Time: base.Add(15 * time.Minute), MeterUsage: math.NaN()},
+	}
+	res, err := svc.AppendReadings(context.Background(), "batch-1", named)
+	if err != nil || !res.Replayed {
+		t.Fatalf("AppendReadings with default meter ID: res=%+v err=%v want replay", res, err)
+	}
+
+	// So are rejected rows.
+	for name, readings := range map[string][]domain.Reading{
+		"rejected row changed": {batch[0], {Time: base.Add(30 * time.Minute), MeterUsage: math.NaN()}},
+		"rejected row dropped": batch[:1],
+	} {
+		_, err := svc.AppendReadings(context.Background(), "batch-1", readings)
+		if !errors.Is(err, ErrIdempotencyConflict) {
+			t.Fatalf("%s: expected ErrIdempotencyConflict, got %v", name, err)
+		}
+	}
+
+	// And rows the transport could not decode, whatever was decoded of them.
+	valid := []domain.Reading{{Time: base, MeterUsage: 1}, {Time: base.Add(15 * time.Minute), MeterUsage: 2}}
+	if _, err := svc.AppendReadings(context.Background(), "batch-2", valid); err != nil {
+		t.Fatalf("AppendReadings: %v", err)
+	}
+	_, err = svc.AppendDecodedReadings(context.Background(), "batch-2", valid, []error{nil, errors.New("invalid time")})
+	if !errors.Is(err, ErrIdempotencyConflict) {
+		t.Fatalf("row not decoded: expected ErrIdempotencyConflict, got %v", err)
+	}
+}
+
+// readOnlyRepo hides the write methods of the wrapped repository.
+type readOnlyRepo struct{ repo.ReadingRepository }
+
+func TestMeterUsageService_AppendReadings_RecordsKeyWithoutValidRows(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	svc := NewMeterUsageService(csvrepo.New(nil))
+	invalid := []domain.Reading{{Time: base, MeterUsage: math.NaN()}}
+
+	if _, err := svc.AppendReadings(context.Background(), "batch-1", invalid); err != nil {
+		t.Fatalf("AppendReadings: %v", err)
+	}
+	retry, err := svc.AppendReadings(context.Background(), "batch-1", invalid)
+	if err != nil || !retry.Replayed {
+		t.Fatalf("retry=%+v err=%v want a replay", retry, err)
+	}
+	_, err = svc.AppendReadings(context.Background(), "batch-1", []domain.Reading{{Time: base, MeterUsage: 1}})
+	if !errors.Is(err, ErrIdempotencyConflict) {
+		t.Fatalf("err=%v want %v", err, ErrIdempotencyConflict)
+	}
+}
+
+func TestMeterUsageService_AppendReadings_ReadOnlyRepo(t *testing.T) {
+	t.Parallel()
+
+	svc := NewMeterUsageService(readOnlyRepo{csvrepo.New(nil)})
+	_, err := svc.AppendReadings(context.Background(), "", []domain.Reading{{Time: time.Now(), MeterUsage: 1}})
+	if !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("expected ErrReadOnly, got %v", err)
+	}
+}
+
+func TestMeterUsageService_AppendReadings_RejectsInvalidBatch(t *testing.T) {
+	t.Parallel()
+
+	svc := NewMeterUsageService(csvrepo.New(nil))
+	if _, err := svc.AppendReadings(context.Background(), "", nil); !errors.Is(err, ErrInvalidBatch) {
+		t.Fatalf("expected ErrInvalidBatch, got %v", err)
+	}
+	big := make([]domain.Reading, MaxAppendBatchSize+1)
+	if _, err := svc.AppendReadings(context.Background(), "", big); !errors.Is(err, ErrInvalidBatch) {
+		t.Fatalf("expected ErrInvalidBatch, got %v", err)
+	}
+}
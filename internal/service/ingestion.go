@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/milad/spectral/internal/domain"
+	"github.com/milad/spectral/internal/repo"
+)
+
+// ErrNoIngestionReport is returned by IngestionReport when the repository was
+// not loaded from files and keeps no report.
+var ErrNoIngestionReport = errors.New("repository keeps no ingestion report")
+
+// IngestionReportFilter narrows the issues of an ingestion report. The counts
+// always cover every issue.
+type IngestionReportFilter struct {
+	// Categories keeps only issues in these categories, if not empty.
+	Categories []domain.IngestionCategory
+	// MaxIssues limits the number of issues returned, if positive.
+	MaxIssues int
+}
+
+// IngestionReport describes the input rejected by the last load of the
+// repository's files, so that data owners can fix their sources.
+func (s *MeterUsageService) IngestionReport(ctx context.Context, filter IngestionReportFilter) (_ domain.IngestionReport, err error) {
+	ctx, span := startSpan(ctx, "MeterUsageService.IngestionReport")
+	defer func() { endSpan(span, err) }()
+
+	ir, ok := s.repo.(repo.IngestionReporter)
+	if !ok {
+		return domain.IngestionReport{}, ErrNoIngestionReport
+	}
+	rep, err := repoIngestionReport(ctx, ir)
+	if err != nil {
+		return domain.IngestionReport{}, err
+	}
+
+	if len(filter.Categories) > 0 {
+		rep.Issues = slices.DeleteFunc(slices.Clone(rep.Issues), func(i domain.IngestionIssue) bool {
+			return !slices.Contains(filter.Categories, i.Category)
+		})
+	}
+	if filter.MaxIssues > 0 && len(rep.Issues) > filter.MaxIssues {
+		rep.Issues = rep.Issues[:filter.MaxIssues]
+		rep.IssuesTruncated = true
+	}
+	return rep, nil
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/milad/spectral/internal/domain"
+	"github.com/milad/spectral/internal/repo/csvrepo"
+)
+
+// reportingRepo serves a fixed ingestion report.
+type reportingRepo struct {
+	*csvrepo.Repo
+	report domain.IngestionReport
+}
+
+func (r reportingRepo) IngestionReport(context.Context) (domain.IngestionReport, error) {
+	return r.report, nil
+}
+
+func TestMeterUsageService_IngestionReport_Filters(t *testing.T) {
+	t.Parallel()
+
+	issues := []domain.IngestionIssue{
+		{Row: 2, Category: domain.IngestInvalidTime},
+		{Row: 3, Category: domain.IngestInvalidUsage},
+		{Row: 4, Category: domain.IngestInvalidTime},
+		{Row: 5, Category: domain.IngestMissingMeterID},
+	}
+	r := reportingRepo{Repo: csvrepo.New(nil), report: domain.IngestionReport{Rejected: 4, Issues: issues}}
+	svc := NewMeterUsageService(r)
+
+	cases := map[string]struct {
+		filter        IngestionReportFilter
+		wantRows      []int
+		wantTruncated bool
+	}{
+		"all":            {wantRows: []int{2, 3, 4, 5}},
+		"category":       {filter: IngestionReportFilter{Categories: []domain.IngestionCategory{domain.IngestInvalidTime}}, wantRows: []int{2, 4}},
+		"categories":     {filter: IngestionReportFilter{Categories: []domain.IngestionCategory{domain.IngestInvalidUsage, domain.IngestMissingMeterID}}, wantRows: []int{3, 5}},
+		"max issues":     {filter: IngestionReportFilter{MaxIssues: 3}, wantRows: []int{2, 3, 4}, wantTruncated: true},
+		"max not hit":    {filter: IngestionReportFilter{MaxIssues: 4}, wantRows: []int{2, 3, 4, 5}},
+		"category + max": {filter: IngestionReportFilter{Categories: []domain.IngestionCategory{domain.IngestInvalidTime}, MaxIssues: 1}, wantRows: []int{2}, wantTruncated: true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			rep, err := svc.IngestionReport(context.Background(), tc.filter)
+			if err != nil {
+				t.Fatalf("IngestionReport: %v", err)
+			}
+			var rows []int
+			for _, i := range rep.Issues {
+				rows = append(rows, i.Row)
+			}
+			if !slices.Equal(rows, tc.wantRows) {
+				t.Fatalf("rows=%v want %v", rows, tc.wantRows)
+			}
+			if rep.IssuesTruncated != tc.wantTruncated {
+				t.Fatalf("truncated=%v want %v", rep.IssuesTruncated, tc.wantTruncated)
+			}
+			if rep.Rejected != 4 {
+				t.Fatalf("rejected=%d want 4", rep.Rejected)
+			}
+		})
+	}
+
+	// Filtering must not alter the repository's report.
+	if got := len(r.report.Issues); got != len(issues) || r.report.Issues[1].Row != 3 {
+		t.Fatalf("report modified: %v", r.report.Issues)
+	}
+}
+
+func TestMeterUsageService_IngestionReport_NotKept(t *testing.T) {
+	t.Parallel()
+
+	svc := NewMeterUsageService(readOnlyRepo{csvrepo.New(nil)})
+	if _, err := svc.IngestionReport(context.Background(), IngestionReportFilter{}); !errors.Is(err, ErrNoIngestionReport) {
+		t.Fatalf("expected ErrNoIngestionReport, got %v", err)
+	}
+}
@@ -1,13 +1,17 @@
 package service
 
 import (
+	"cmp"
 	"context"
+	"crypto/rand"
 	"errors"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/milad/spectral/internal/domain"
 	"github.com/milad/spectral/internal/repo"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var ErrInvalidTimeRange = errors.New("invalid time range")
@@ -15,7 +19,7 @@ var ErrInvalidPagination = errors.New("invalid pagination")
 
 const (
 	// MaxUnpagedRange is a guardrail against accidentally returning huge responses
-	// when pagination is not used.
+	// when pagination is not used. It also bounds a quality report.
 	MaxUnpagedRange = 31 * 24 * time.Hour
 	MaxPageSize     = 5_000
 )
@@ -25,75 +29,139 @@ type ListReadingsPageResult struct {
 	NextPageToken string
 }
 
+// Limits bounds the size of a single request. The constants above are the
+// defaults.
+type Limits struct {
+	MaxPageSize        int
+	MaxUnpagedRange    time.Duration
+	MaxAppendBatchSize int
+	MaxStreamChunkSize int
+}
+
+// DefaultLimits returns the limits used unless WithLimits is given.
+func DefaultLimits() Limits {
+	return Limits{
+		MaxPageSize:        MaxPageSize,
+		MaxUnpagedRange:    MaxUnpagedRange,
+		MaxAppendBatchSize: MaxAppendBatchSize,
+		MaxStreamChunkSize: MaxStreamChunkSize,
+	}
+}
+
 type MeterUsageService struct {
-	repo repo.ReadingRepository
+	repo         repo.ReadingRepository
+	pageTokenKey []byte
+	limits       Limits
+	quality      QualityOptions
+}
+
+// Option configures a MeterUsageService.
+type Option func(*MeterUsageService)
+
+// WithPageTokenKey sets the key used to sign page tokens. Servers behind the
+// same endpoint must share a key for tokens to work across them. By default a
+// random key is generated, so tokens do not survive a restart.
+func WithPageTokenKey(key []byte) Option {
+	return func(s *MeterUsageService) {
+		s.pageTokenKey = slices.Clone(key)
+	}
 }
 
-func NewMeterUsageService(r repo.ReadingRepository) *MeterUsageService {
-	return &MeterUsageService{repo: r}
+// WithLimits replaces the default request limits. Zero fields keep their
+// default.
+func WithLimits(l Limits) Option {
+	return func(s *MeterUsageService) {
+		d := DefaultLimits()
+		s.limits = Limits{
+			MaxPageSize:        cmp.Or(l.MaxPageSize, d.MaxPageSize),
+			MaxUnpagedRange:    cmp.Or(l.MaxUnpagedRange, d.MaxUnpagedRange),
+			MaxAppendBatchSize: cmp.Or(l.MaxAppendBatchSize, d.MaxAppendBatchSize),
+			MaxStreamChunkSize: cmp.Or(l.MaxStreamChunkSize, d.MaxStreamChunkSize),
+		}
+	}
 }
 
-func (s *MeterUsageService) ListReadings(ctx context.Context, startInclusive *time.Time, endExclusive *time.Time) ([]domain.Reading, error) {
-	res, err := s.ListReadingsPage(ctx, startInclusive, endExclusive, 0, "")
+func NewMeterUsageService(r repo.ReadingRepository, opts ...Option) *MeterUsageService {
+	s := &MeterUsageService{repo: r, limits: DefaultLimits(), quality: DefaultQualityOptions()}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if len(s.pageTokenKey) == 0 {
+		s.pageTokenKey = make([]byte, 32)
+		_, _ = rand.Read(s.pageTokenKey) // never fails, see crypto/rand.Read
+	}
+	return s
+}
+
+func (s *MeterUsageService) ListReadings(ctx context.Context, startInclusive *time.Time, endExclusive *time.Time, meterIDs []string) ([]domain.Reading, error) {
+	res, err := s.ListReadingsPage(ctx, startInclusive, endExclusive, meterIDs, 0, "")
 	return res.Readings, err
 }
 
+// ListReadingsPage lists readings in [start, end). If meterIDs is non-empty, only
+// readings for those meters are returned. Readings carry their quality flags,
+// checked against the readings around them, in the range or not.
 func (s *MeterUsageService) ListReadingsPage(
 	ctx context.Context,
 	startInclusive *time.Time,
 	endExclusive *time.Time,
+	meterIDs []string,
 	pageSize int,
 	pageToken string,
-) (ListReadingsPageResult, error) {
+) (_ ListReadingsPageResult, err error) {
+	ctx, span := startSpan(ctx, "MeterUsageService.ListReadingsPage",
+		attribute.Int("page_size", pageSize),
+		attribute.Bool("page_token", pageToken != ""),
+	)
+	defer func() { endSpan(span, err) }()
+
 	if startInclusive != nil && endExclusive != nil {
 		// Keep it strict and predictable: [start, end) where start must be < end.
 		if !startInclusive.Before(*endExclusive) {
 			return ListReadingsPageResult{}, fmt.Errorf("%w: start must be before end", ErrInvalidTimeRange)
 		}
-		if pageSize <= 0 && endExclusive.Sub(*startInclusive) > MaxUnpagedRange {
-			return ListReadingsPageResult{}, fmt.Errorf("%w: range too large without pagination (max %s)", ErrInvalidTimeRange, MaxUnpagedRange)
+		if pageSize <= 0 && endExclusive.Sub(*startInclusive) > s.limits.MaxUnpagedRange {
+			return ListReadingsPageResult{}, fmt.Errorf("%w: range too large without pagination (max %s)", ErrInvalidTimeRange, s.limits.MaxUnpagedRange)
 		}
 	}
 
 	if pageSize < 0 {
 		return ListReadingsPageResult{}, fmt.Errorf("%w: page_size must be >= 0", ErrInvalidPagination)
 	}
-	if pageSize > MaxPageSize {
-		return ListReadingsPageResult{}, fmt.Errorf("%w: page_size too large (max %d)", ErrInvalidPagination, MaxPageSize)
+	if pageSize > s.limits.MaxPageSize {
+		return ListReadingsPageResult{}, fmt.Errorf("%w: page_size too large (max %d)", ErrInvalidPagination, s.limits.MaxPageSize)
 	}
-	cursor, err := parseCursorToken(pageSize, pageToken)
-	if err != nil {
-		return ListReadingsPageResult{}, err
-	}
-	effectiveStart := startInclusive
-	if cursor != nil && (effectiveStart == nil || cursor.After(*effectiveStart)) {
-		effectiveStart = cursor
-	}
-
-	readings, err := s.repo.List(ctx, effectiveStart, endExclusive)
-	if err != nil {
-		return ListReadingsPageResult{}, err
-	}
-	if cursor != nil {
-		// Cursor is exclusive: skip any reading at or before the cursor.
-		i := 0
-		for i < len(readings) && !readings[i].Time.After(*cursor) {
-			i++
+	query := queryFingerprint(startInclusive, endExclusive, meterIDs)
+	var cursor *repo.Position
+	if pageToken != "" {
+		if pageSize <= 0 {
+			return ListReadingsPageResult{}, fmt.Errorf("%w: page_token requires page_size", ErrInvalidPagination)
+		}
+		pos, err := s.decodePageToken(pageToken, query)
+		if err != nil {
+			return ListReadingsPageResult{}, err
 		}
-		readings = readings[i:]
+		cursor = &pos
 	}
 
 	// Unpaged behavior (backwards compatible): return everything.
 	if pageSize == 0 {
-		if pageToken != "" {
-			return ListReadingsPageResult{}, fmt.Errorf("%w: page_token requires page_size", ErrInvalidPagination)
+		readings, err := s.repoList(ctx, startInclusive, endExclusive, meterIDs)
+		if err != nil {
+			return ListReadingsPageResult{}, err
 		}
+		readings, err = s.withQuality(ctx, readings, meterIDs, nil)
 		return ListReadingsPageResult{
 			Readings:      readings,
 			NextPageToken: "",
-		}, nil
+		}, err
 	}
 
+	// One reading more than the page tells whether there is a next page.
+	readings, err := s.listAfter(ctx, startInclusive, endExclusive, meterIDs, cursor, pageSize+1)
+	if err != nil {
+		return ListReadingsPageResult{}, err
+	}
 	if len(readings) == 0 {
 		return ListReadingsPageResult{
 			Readings:      nil,
@@ -101,14 +169,15 @@ func (s *MeterUsageService) ListReadingsPage(
 		}, nil
 	}
 
-	end := pageSize
-	if end > len(readings) {
-		end = len(readings)
-	}
+	end := min(pageSize, len(readings))
 	page := readings[:end]
 	next := ""
 	if end < len(readings) {
-		next = page[len(page)-1].Time.UTC().Format(time.RFC3339Nano)
+		next = s.encodePageToken(lastPosition(page, cursor), query)
+	}
+	page, err = s.withQuality(ctx, page, meterIDs, nil)
+	if err != nil {
+		return ListReadingsPageResult{}, err
 	}
 	return ListReadingsPageResult{
 		Readings:      page,
@@ -116,22 +185,48 @@ func (s *MeterUsageService) ListReadingsPage(
 	}, nil
 }
 
-func parseCursorToken(pageSize int, pageToken string) (*time.Time, error) {
-	if pageToken == "" {
-		return nil, nil
+// listAfter returns up to limit readings in [start, end) after the position
+// after (nil for the first). Repositories that cannot read a page at a time
+// list the rest of the range instead.
+func (s *MeterUsageService) listAfter(
+	ctx context.Context,
+	startInclusive *time.Time,
+	endExclusive *time.Time,
+	meterIDs []string,
+	after *repo.Position,
+	limit int,
+) ([]domain.Reading, error) {
+	if p, ok := s.repo.(repo.PagedReadingRepository); ok {
+		return repoListAfter(ctx, p, startInclusive, endExclusive, meterIDs, after, limit)
 	}
-	if pageSize <= 0 {
-		return nil, fmt.Errorf("%w: page_token requires page_size", ErrInvalidPagination)
+	if after != nil && (startInclusive == nil || after.Time.After(*startInclusive)) {
+		startInclusive = &after.Time
 	}
-	t, err := time.Parse(time.RFC3339, pageToken)
+	readings, err := s.repoList(ctx, startInclusive, endExclusive, meterIDs)
 	if err != nil {
-		// allow nano timestamps too (RFC3339Nano is a superset)
-		t2, err2 := time.Parse(time.RFC3339Nano, pageToken)
-		if err2 != nil {
-			return nil, fmt.Errorf("%w: invalid page_token", ErrInvalidPagination)
-		}
-		t = t2
+		return nil, err
+	}
+	if after != nil {
+		// The position is exclusive: resume right after the reading it names.
+		readings = readings[repo.SkipThrough(readings, *after):]
 	}
-	tt := t.UTC()
-	return &tt, nil
+	if limit > 0 && limit < len(readings) {
+		readings = readings[:limit]
+	}
+	return readings, nil
+}
+
+func (s *MeterUsageService) ListMeters(ctx context.Context) ([]domain.Meter, error) {
+	ctx, span := startSpan(ctx, "MeterUsageService.ListMeters")
+	meters, err := s.repoListMeters(ctx)
+	endSpan(span, err)
+	return meters, err
+}
+
+// Dataset describes the data currently being served.
+func (s *MeterUsageService) Dataset(ctx context.Context) (domain.Dataset, error) {
+	ctx, span := startSpan(ctx, "MeterUsageService.Dataset")
+	ds, err := s.repoDataset(ctx)
+	endSpan(span, err)
+	return ds, err
 }
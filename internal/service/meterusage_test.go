@@ -19,7 +19,7 @@ func TestMeterUsageService_RejectsInvalidRange(t *testing.T) {
 	t0 := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
 	t1 := t0
 
-	_, err := svc.ListReadings(context.Background(), &t0, &t1)
+	_, err := svc.ListReadings(context.Background(), &t0, &t1, nil)
 	if err == nil {
 		t.Fatalf("expected error, got nil")
 	}
@@ -39,7 +39,7 @@ func TestMeterUsageService_Pagination_CursorToken(t *testing.T) {
 	})
 	svc := NewMeterUsageService(r)
 
-	res1, err := svc.ListReadingsPage(context.Background(), nil, nil, 2, "")
+	res1, err := svc.ListReadingsPage(context.Background(), nil, nil, nil, 2, "")
 	if err != nil {
 		t.Fatalf("ListReadingsPage: %v", err)
 	}
@@ -50,7 +50,7 @@ func TestMeterUsageService_Pagination_CursorToken(t *testing.T) {
 		t.Fatalf("expected next page token")
 	}
 
-	res2, err := svc.ListReadingsPage(context.Background(), nil, nil, 2, res1.NextPageToken)
+	res2, err := svc.ListReadingsPage(context.Background(), nil, nil, nil, 2, res1.NextPageToken)
 	if err != nil {
 		t.Fatalf("ListReadingsPage: %v", err)
 	}
@@ -74,7 +74,7 @@ func TestMeterUsageService_RejectsTooLargeUnpagedRange(t *testing.T) {
 	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
 	end := start.Add(MaxUnpagedRange + time.Hour)
 
-	_, err := svc.ListReadings(context.Background(), &start, &end)
+	_, err := svc.ListReadings(context.Background(), &start, &end, nil)
 	if err == nil {
 		t.Fatalf("expected error, got nil")
 	}
@@ -83,7 +83,28 @@ func TestMeterUsageService_RejectsTooLargeUnpagedRange(t *testing.T) {
 	}
 
 	// With pagination, the same range is allowed.
-	if _, err := svc.ListReadingsPage(context.Background(), &start, &end, 100, ""); err != nil {
+	if _, err := svc.ListReadingsPage(context.Background(), &start, &end, nil, 100, ""); err != nil {
 		t.Fatalf("expected paged request to pass, got %v", err)
 	}
 }
+
+func TestMeterUsageService_WithLimits(t *testing.T) {
+	t.Parallel()
+
+	r := csvrepo.New([]domain.Reading{})
+	svc := NewMeterUsageService(r, WithLimits(Limits{MaxPageSize: 10, MaxUnpagedRange: time.Hour}))
+
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(2 * time.Hour)
+	if _, err := svc.ListReadings(context.Background(), &start, &end, nil); !errors.Is(err, ErrInvalidTimeRange) {
+		t.Fatalf("expected ErrInvalidTimeRange for a range over the configured limit, got %v", err)
+	}
+	if _, err := svc.ListReadingsPage(context.Background(), &start, &end, nil, 11, ""); !errors.Is(err, ErrInvalidPagination) {
+		t.Fatalf("expected ErrInvalidPagination for a page over the configured limit, got %v", err)
+	}
+	// Unset limits keep their defaults.
+	err := svc.StreamReadings(context.Background(), nil, nil, nil, MaxStreamChunkSize, func([]domain.Reading) error { return nil })
+	if err != nil {
+		t.Fatalf("expected the default stream chunk limit, got %v", err)
+	}
+}
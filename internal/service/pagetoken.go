@@ -0,0 +1,125 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/milad/spectral/internal/domain"
+	"github.com/milad/spectral/internal/repo"
+)
+
+// pageTokenVersion is bumped whenever the token payload changes; tokens of any
+// other version are rejected rather than misread.
+const pageTokenVersion = 1
+
+// pageTokenMACBytes is the length of the truncated HMAC-SHA256 in a token.
+const pageTokenMACBytes = 16
+
+// pageTokenPayload is the signed content of a page token.
+type pageTokenPayload struct {
+	Version int    `json:"v"`
+	Time    int64  `json:"t"` // UnixNano
+	MeterID string `json:"m"`
+	Seq     int    `json:"s"`
+	Query   string `json:"q"` // queryFingerprint of the request that produced the token
+}
+
+// encodePageToken returns an opaque token of the form payload.mac, both
+// base64url-encoded.
+func (s *MeterUsageService) encodePageToken(pos repo.Position, query string) string {
+	payload, _ := json.Marshal(pageTokenPayload{
+		Version: pageTokenVersion,
+		Time:    pos.Time.UnixNano(),
+		MeterID: pos.MeterID,
+		Seq:     pos.Seq,
+		Query:   query,
+	})
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(payload) + "." + enc.EncodeToString(s.pageTokenMAC(payload))
+}
+
+// decodePageToken verifies a token and checks that it was issued for the same
+// query parameters.
+func (s *MeterUsageService) decodePageToken(token, query string) (repo.Position, error) {
+	invalid := fmt.Errorf("%w: invalid page_token", ErrInvalidPagination)
+
+	rawPayload, rawMAC, ok := strings.Cut(token, ".")
+	if !ok {
+		return repo.Position{}, invalid
+	}
+	enc := base64.RawURLEncoding
+	payload, err := enc.DecodeString(rawPayload)
+	if err != nil {
+		return repo.Position{}, invalid
+	}
+	mac, err := enc.DecodeString(rawMAC)
+	if err != nil || !hmac.Equal(mac, s.pageTokenMAC(payload)) {
+		return repo.Position{}, invalid
+	}
+
+	var p pageTokenPayload
+	if err := json.Unmarshal(payload, &p); err != nil || p.Version != pageTokenVersion || p.Seq < 0 {
+		return repo.Position{}, invalid
+	}
+	if p.Query != query {
+		return repo.Position{}, fmt.Errorf("%w: page_token was issued for different query parameters", ErrInvalidPagination)
+	}
+	return repo.Position{Time: time.Unix(0, p.Time).UTC(), MeterID: p.MeterID, Seq: p.Seq}, nil
+}
+
+// PageTokenKeyID identifies the page-token key: servers with the same key
+// report the same ID. It is a MAC of a fixed message, so it reveals nothing
+// about the key.
+func (s *MeterUsageService) PageTokenKeyID() string {
+	h := hmac.New(sha256.New, s.pageTokenKey)
+	h.Write([]byte("page-token-key-id"))
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+func (s *MeterUsageService) pageTokenMAC(payload []byte) []byte {
+	h := hmac.New(sha256.New, s.pageTokenKey)
+	h.Write(payload)
+	return h.Sum(nil)[:pageTokenMACBytes]
+}
+
+// queryFingerprint identifies the filters a page token may be used with. The
+// page size is deliberately left out, so clients may change it between pages.
+func queryFingerprint(startInclusive, endExclusive *time.Time, meterIDs []string) string {
+	bound := func(t *time.Time) string {
+		if t == nil {
+			return "-"
+		}
+		return strconv.FormatInt(t.UnixNano(), 10)
+	}
+	ids := slices.Clone(meterIDs)
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s", bound(startInclusive), bound(endExclusive), strings.Join(ids, "\x00"))
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// lastPosition returns the position of the last reading on a non-empty page
+// that follows cursor (nil for the first page).
+func lastPosition(page []domain.Reading, cursor *repo.Position) repo.Position {
+	last := page[len(page)-1]
+	seq := 0
+	i := len(page) - 2
+	for ; i >= 0 && page[i].Time.Equal(last.Time) && page[i].MeterID == last.MeterID; i-- {
+		seq++
+	}
+	if i < 0 && cursor != nil && cursor.Time.Equal(last.Time) && cursor.MeterID == last.MeterID {
+		// The run of duplicates started on an earlier page.
+		seq += cursor.Seq + 1
+	}
+	return repo.Position{Time: last.Time, MeterID: last.MeterID, Seq: seq}
+}
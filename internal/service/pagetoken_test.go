@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/milad/spectral/internal/domain"
+	"github.com/milad/spectral/internal/repo/csvrepo"
+)
+
+// listAllPages follows page tokens until the last page.
+func listAllPages(t *testing.T, svc *MeterUsageService, meterIDs []string, pageSize int, between func()) []domain.Reading {
+	t.Helper()
+	var (
+		out   []domain.Reading
+		token string
+	)
+	for i := 0; ; i++ {
+		if i > 100 {
+			t.Fatalf("too many pages")
+		}
+		res, err := svc.ListReadingsPage(context.Background(), nil, nil, meterIDs, pageSize, token)
+		if err != nil {
+			t.Fatalf("ListReadingsPage(page %d): %v", i, err)
+		}
+		out = append(out, res.Readings...)
+		if res.NextPageToken == "" {
+			return out
+		}
+		token = res.NextPageToken
+		if between != nil {
+			between()
+		}
+	}
+}
+
+func TestMeterUsageService_Pagination_DuplicateTimestamps(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	readings := []domain.Reading{
+		{MeterID: "a", Time: base, MeterUsage: 1},
+		{MeterID: "a", Time: base.Add(15 * time.Minute), MeterUsage: 2},
+		{MeterID: "a", Time: base.Add(15 * time.Minute), MeterUsage: 3},
+		{MeterID: "a", Time: base.Add(15 * time.Minute), MeterUsage: 4},
+		{MeterID: "b", Time: base.Add(15 * time.Minute), MeterUsage: 5},
+		{MeterID: "b", Time: base.Add(15 * time.Minute), MeterUsage: 6},
+		{MeterID: "c", Time: base.Add(15 * time.Minute), MeterUsage: 7},
+		{MeterID: "a", Time: base.Add(30 * time.Minute), MeterUsage: 8},
+	}
+	svc := NewMeterUsageService(csvrepo.New(readings))
+
+	for pageSize := 1; pageSize <= len(readings)+1; pageSize++ {
+		got := listAllPages(t, svc, nil, pageSize, nil)
+		if len(got) != len(readings) {
+			t.Fatalf("page_size=%d: got %d readings want %d", pageSize, len(got), len(readings))
+		}
+		for i := range got {
+			if got[i].MeterUsage != readings[i].MeterUsage {
+				t.Fatalf("page_size=%d: reading %d has usage %v want %v", pageSize, i, got[i].MeterUsage, readings[i].MeterUsage)
+			}
+		}
+	}
+}
+
+func TestMeterUsageService_Pagination_ReadsOnlyThePage(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	var readings []domain.Reading
+	for i := range 10 {
+		readings = append(readings, domain.Reading{MeterID: "a", Time: base.Add(time.Duration(i) * 15 * time.Minute), MeterUsage: float64(i)})
+	}
+	r := &pagedRepo{Repo: csvrepo.New(readings)}
+	svc := NewMeterUsageService(r)
+
+	if got := listAllPages(t, svc, nil, 4, nil); len(got) != len(readings) {
+		t.Fatalf("got %d readings want %d", len(got), len(readings))
+	}
+	// Each page reads one reading more, to tell whether another page follows.
+	if len(r.limits) != 3 || r.limits[0] != 5 || r.limits[1] != 5 || r.limits[2] != 5 {
+		t.Fatalf("ListAfter limits=%v want [5 5 5]", r.limits)
+	}
+}
+
+func TestMeterUsageService_Pagination_StableAcrossAppends(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := csvrepo.New([]domain.Reading{
+		{MeterID: "b", Time: base, MeterUsage: 1},
+		{MeterID: "b", Time: base, MeterUsage: 2},
+		{MeterID: "b", Time: base, MeterUsage: 3},
+		{MeterID: "c", Time: base, MeterUsage: 4},
+	})
+	svc := NewMeterUsageService(r)
+
+	// Insert readings that sort before and among the ones not yet returned.
+	appended := false
+	got := listAllPages(t, svc, nil, 1, func() {
+		if appended {
+			return
+		}
+		appended = true
+		if _, err := r.Append(context.Background(), "", "", []domain.Reading{
+			{MeterID: "a", Time: base, MeterUsage: 10},
+			{MeterID: "b", Time: base, MeterUsage: 11},
+		}); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	})
+
+	seen := map[float64]int{}
+	for _, rd := range got {
+		seen[rd.MeterUsage]++
+	}
+	for _, usage := range []float64{1, 2, 3, 4} {
+		if seen[usage] != 1 {
+			t.Fatalf("reading with usage %v returned %d times, want 1 (got %+v)", usage, seen[usage], got)
+		}
+	}
+}
+
+func TestMeterUsageService_Pagination_RejectsBadTokens(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := csvrepo.New([]domain.Reading{
+		{MeterID: "a", Time: base, MeterUsage: 1},
+		{MeterID: "a", Time: base.Add(15 * time.Minute), MeterUsage: 2},
+		{MeterID: "a", Time: base.Add(30 * time.Minute), MeterUsage: 3},
+	})
+	svc := NewMeterUsageService(r, WithPageTokenKey([]byte("key-1")))
+	ctx := context.Background()
+
+	res, err := svc.ListReadingsPage(ctx, nil, nil, []string{"a"}, 1, "")
+	if err != nil {
+		t.Fatalf("ListReadingsPage: %v", err)
+	}
+	token := res.NextPageToken
+
+	// The page size may change between pages.
+	if _, err := svc.ListReadingsPage(ctx, nil, nil, []string{"a"}, 2, token); err != nil {
+		t.Fatalf("different page_size: %v", err)
+	}
+
+	other := NewMeterUsageService(r, WithPageTokenKey([]byte("key-2")))
+	tampered := strings.Replace(token, token[:4], "AAAA", 1)
+	start := base.Add(-time.Hour)
+	cases := map[string]func() error{
+		"different meters": func() error {
+			_, err := svc.ListReadingsPage(ctx, nil, nil, []string{"a", "b"}, 1, token)
+			return err
+		},
+		"different start": func() error {
+			_, err := svc.ListReadingsPage(ctx, &start, nil, []string{"a"}, 1, token)
+			return err
+		},
+		"tampered": func() error {
+			_, err := svc.ListReadingsPage(ctx, nil, nil, []string{"a"}, 1, tampered)
+			return err
+		},
+		"raw timestamp": func() error {
+			_, err := svc.ListReadingsPage(ctx, nil, nil, []string{"a"}, 1, "2019-01-01T00:15:00Z")
+			return err
+		},
+		"other key": func() error {
+			_, err := other.ListReadingsPage(ctx, nil, nil, []string{"a"}, 1, token)
+			return err
+		},
+	}
+	for name, call := range cases {
+		if err := call(); !errors.Is(err, ErrInvalidPagination) {
+			t.Fatalf("%s: expected ErrInvalidPagination, got %v", name, err)
+		}
+	}
+}
+
+func TestMeterUsageService_PageTokenKeyID(t *testing.T) {
+	t.Parallel()
+
+	r := csvrepo.New(nil)
+	a := NewMeterUsageService(r, WithPageTokenKey([]byte("key-1"))).PageTokenKeyID()
+	if got := NewMeterUsageService(r, WithPageTokenKey([]byte("key-1"))).PageTokenKeyID(); got != a {
+		t.Fatalf("same key: id=%q want %q", got, a)
+	}
+	if got := NewMeterUsageService(r, WithPageTokenKey([]byte("key-2"))).PageTokenKeyID(); got == a {
+		t.Fatalf("different keys: both ids %q", got)
+	}
+	if strings.Contains(a, "key-1") || len(a) != 16 {
+		t.Fatalf("id=%q want 16 hex digits", a)
+	}
+}
@@ -0,0 +1,355 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/milad/spectral/internal/domain"
+	"github.com/milad/spectral/internal/repo"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// MaxQualityItems bounds the gaps and anomalies a quality report lists per
+// meter; all of them are counted.
+const MaxQualityItems = 1_000
+
+// minSpikeSample is the fewest readings, the one checked included, a spike is
+// judged against.
+const minSpikeSample = 5
+
+// QualityOptions tunes the checks behind domain.QualityFlags.
+type QualityOptions struct {
+	// Interval is the expected time between two readings of a meter. Readings
+	// further apart, rounded to whole intervals, have a gap between them.
+	Interval time.Duration
+	// SpikeWindow is how far before and after a reading the readings it is
+	// compared to lie.
+	SpikeWindow time.Duration
+	// SpikeThreshold is the modified z-score (distance to the median of the
+	// window, in median absolute deviations) above which a reading is a spike.
+	SpikeThreshold float64
+}
+
+// DefaultQualityOptions returns the options used unless WithQuality is given.
+func DefaultQualityOptions() QualityOptions {
+	return QualityOptions{
+		Interval:       15 * time.Minute,
+		SpikeWindow:    3 * time.Hour,
+		SpikeThreshold: 5,
+	}
+}
+
+// WithQuality replaces the default quality options. A zero Interval keeps the
+// default; a zero SpikeWindow or SpikeThreshold disables spike detection.
+func WithQuality(q QualityOptions) Option {
+	return func(s *MeterUsageService) {
+		if q.Interval <= 0 {
+			q.Interval = DefaultQualityOptions().Interval
+		}
+		s.quality = q
+	}
+}
+
+// QualityReport summarizes the gaps and anomalies of readings in [start, end),
+// both required and at most Limits.MaxUnpagedRange apart. If meterIDs is
+// non-empty, only those meters are reported. Gaps are reported by the reading
+// that ends them, so a gap that starts before start is included, and one still
+// open at end is not.
+func (s *MeterUsageService) QualityReport(
+	ctx context.Context,
+	startInclusive *time.Time,
+	endExclusive *time.Time,
+	meterIDs []string,
+) (_ domain.QualityReport, err error) {
+	ctx, span := startSpan(ctx, "MeterUsageService.QualityReport", attribute.Int("meter_ids", len(meterIDs)))
+	defer func() { endSpan(span, err) }()
+
+	switch {
+	case startInclusive == nil || endExclusive == nil:
+		return domain.QualityReport{}, fmt.Errorf("%w: start and end are required", ErrInvalidTimeRange)
+	case !startInclusive.Before(*endExclusive):
+		return domain.QualityReport{}, fmt.Errorf("%w: start must be before end", ErrInvalidTimeRange)
+	case endExclusive.Sub(*startInclusive) > s.limits.MaxUnpagedRange:
+		return domain.QualityReport{}, fmt.Errorf("%w: range too large (max %s)", ErrInvalidTimeRange, s.limits.MaxUnpagedRange)
+	}
+	readings, err := s.repoList(ctx, startInclusive, endExclusive, meterIDs)
+	if err != nil {
+		return domain.QualityReport{}, err
+	}
+	checked, err := s.checkQuality(ctx, readings, meterIDs, nil)
+	if err != nil {
+		return domain.QualityReport{}, err
+	}
+
+	rep := domain.QualityReport{Interval: s.quality.Interval, Meters: []domain.MeterQuality{}}
+	byMeter := map[string]*domain.MeterQuality{}
+	for _, r := range readings {
+		mq := byMeter[r.MeterID]
+		if mq == nil {
+			mq = &domain.MeterQuality{MeterID: r.MeterID}
+			byMeter[r.MeterID] = mq
+		}
+		mq.Readings++
+		q := checked[qualityKeyOf(r)]
+		if q.flags == 0 {
+			continue
+		}
+		if q.flags&domain.QualityGapBefore != 0 {
+			mq.MissingIntervals += q.missing
+			if len(mq.Gaps) < MaxQualityItems {
+				mq.Gaps = append(mq.Gaps, domain.Gap{Start: q.gapStart, End: r.Time, Missing: q.missing})
+			} else {
+				mq.Truncated = true
+			}
+		}
+		if q.flags&^domain.QualityGapBefore == 0 {
+			continue
+		}
+		if q.flags&domain.QualityDuplicate != 0 {
+			mq.Duplicates++
+		}
+		if q.flags&domain.QualityNegative != 0 {
+			mq.Negatives++
+		}
+		if q.flags&domain.QualitySpike != 0 {
+			mq.Spikes++
+		}
+		if len(mq.Anomalies) < MaxQualityItems {
+			r.Quality = q.flags
+			mq.Anomalies = append(mq.Anomalies, r)
+		} else {
+			mq.Truncated = true
+		}
+	}
+	for _, mq := range byMeter {
+		rep.Meters = append(rep.Meters, *mq)
+	}
+	slices.SortFunc(rep.Meters, func(a, b domain.MeterQuality) int {
+		return cmp.Compare(a.MeterID, b.MeterID)
+	})
+	return rep, nil
+}
+
+// withQuality returns copies of readings, as returned by the repository for
+// meterIDs, with their Quality set. carried is passed on to checkQuality.
+func (s *MeterUsageService) withQuality(ctx context.Context, readings []domain.Reading, meterIDs []string, carried map[string]domain.Reading) ([]domain.Reading, error) {
+	if len(readings) == 0 {
+		return readings, nil
+	}
+	checked, err := s.checkQuality(ctx, readings, meterIDs, carried)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]domain.Reading, len(readings))
+	for i, r := range readings {
+		r.Quality = checked[qualityKeyOf(r)].flags
+		out[i] = r
+	}
+	return out, nil
+}
+
+// readingQuality is what checkQuality finds about a reading.
+type readingQuality struct {
+	flags domain.QualityFlags
+	// For QualityGapBefore, the time of the meter's previous reading and the
+	// number of readings missing since.
+	gapStart time.Time
+	missing  int
+}
+
+// qualityKey identifies a reading for checkQuality's results. Readings equal
+// in all fields get the same flags.
+type qualityKey struct {
+	meterID string
+	time    int64
+	usage   float64
+}
+
+func qualityKeyOf(r domain.Reading) qualityKey {
+	return qualityKey{meterID: r.MeterID, time: r.Time.UnixNano(), usage: r.MeterUsage}
+}
+
+// checkQuality checks readings, a sorted run of the repository's readings for
+// meterIDs, against the readings around them. carried, if not nil, holds the
+// last reading of each meter in the run that ends where readings start; it
+// saves looking up the reading before a gap.
+//
+// Only the padding on either side of the run is listed. The readings at its
+// first and last times are listed again too, since a page can start or end
+// between readings at the same time.
+func (s *MeterUsageService) checkQuality(ctx context.Context, readings []domain.Reading, meterIDs []string, carried map[string]domain.Reading) (map[qualityKey]readingQuality, error) {
+	if len(readings) == 0 {
+		return nil, nil
+	}
+	o := s.quality
+	// Look back far enough to find the previous reading of a meter unless
+	// there is a gap before it, and to see the whole window of every reading.
+	lookback := max(o.SpikeWindow, 2*o.Interval)
+	first, last := readings[0].Time, readings[len(readings)-1].Time
+	from := first.Add(-lookback)
+	to := last.Add(o.SpikeWindow + time.Nanosecond)
+	afterFirst := first.Add(time.Nanosecond)
+	before, err := s.repoList(ctx, &from, &afterFirst, meterIDs)
+	if err != nil {
+		return nil, err
+	}
+	afterStart := last
+	if !last.After(first) {
+		afterStart = afterFirst
+	}
+	after, err := s.repoList(ctx, &afterStart, &to, meterIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// The repository's slices may be views of its data: copy, never append.
+	series := map[string][]domain.Reading{}
+	for _, r := range before {
+		series[r.MeterID] = append(series[r.MeterID], r)
+	}
+	for _, r := range readings {
+		if r.Time.After(first) && r.Time.Before(last) {
+			series[r.MeterID] = append(series[r.MeterID], r)
+		}
+	}
+	for _, r := range after {
+		series[r.MeterID] = append(series[r.MeterID], r)
+	}
+	// A meter with no reading in the lookback either starts with readings or
+	// has a gap before them: find out which.
+	lookedUp := map[string]bool{}
+	for _, r := range readings {
+		rs := series[r.MeterID]
+		if lookedUp[r.MeterID] || (len(rs) > 0 && rs[0].Time.Before(readings[0].Time)) {
+			continue
+		}
+		lookedUp[r.MeterID] = true
+		prev, ok := carried[r.MeterID]
+		if !ok || !prev.Time.Before(from) {
+			if prev, ok, err = s.previousReading(ctx, r.MeterID, from); err != nil {
+				return nil, err
+			}
+		}
+		if ok {
+			series[r.MeterID] = append([]domain.Reading{prev}, rs...)
+		}
+	}
+
+	checked := make(map[qualityKey]readingQuality, len(before)+len(readings)+len(after))
+	for _, rs := range series {
+		o.checkSeries(rs, checked)
+	}
+	return checked, nil
+}
+
+// previousReading returns the last reading of meterID before t; ok is false if
+// there is none. Without a SeekingReadingRepository it lists the meter's
+// readings up to t.
+func (s *MeterUsageService) previousReading(ctx context.Context, meterID string, t time.Time) (_ domain.Reading, ok bool, err error) {
+	if sr, ok := s.repo.(repo.SeekingReadingRepository); ok {
+		return repoLastBefore(ctx, sr, meterID, t)
+	}
+	meters, err := s.repoListMeters(ctx)
+	if err != nil {
+		return domain.Reading{}, false, err
+	}
+	i, ok := slices.BinarySearchFunc(meters, meterID, func(m domain.Meter, id string) int {
+		return cmp.Compare(m.ID, id)
+	})
+	if !ok || !meters[i].FirstReading.Before(t) {
+		return domain.Reading{}, false, nil
+	}
+	earlier, err := s.repoList(ctx, &meters[i].FirstReading, &t, []string{meterID})
+	if err != nil || len(earlier) == 0 {
+		return domain.Reading{}, false, err
+	}
+	return earlier[len(earlier)-1], true, nil
+}
+
+// checkSeries records the quality of the readings of one meter, in time
+// order, in checked.
+func (o QualityOptions) checkSeries(rs []domain.Reading, checked map[qualityKey]readingQuality) {
+	var window []float64
+	lo, hi := 0, 0
+	for i, r := range rs {
+		var q readingQuality
+		if r.MeterUsage < 0 {
+			q.flags |= domain.QualityNegative
+		}
+		if (i > 0 && rs[i-1].Time.Equal(r.Time)) || (i+1 < len(rs) && rs[i+1].Time.Equal(r.Time)) {
+			q.flags |= domain.QualityDuplicate
+		}
+		if p := previousTime(rs, i); !p.IsZero() {
+			if missing := int(math.Round(float64(r.Time.Sub(p))/float64(o.Interval))) - 1; missing > 0 {
+				q.flags |= domain.QualityGapBefore
+				q.gapStart, q.missing = p, missing
+			}
+		}
+
+		if o.SpikeWindow > 0 && o.SpikeThreshold > 0 {
+			for lo < i && r.Time.Sub(rs[lo].Time) > o.SpikeWindow {
+				lo++
+			}
+			for hi < len(rs) && rs[hi].Time.Sub(r.Time) <= o.SpikeWindow {
+				hi++
+			}
+			window = window[:0]
+			for _, w := range rs[lo:hi] {
+				window = append(window, w.MeterUsage)
+			}
+			if len(window) >= minSpikeSample && math.Abs(modifiedZScore(r.MeterUsage, window)) > o.SpikeThreshold {
+				q.flags |= domain.QualitySpike
+			}
+		}
+		checked[qualityKeyOf(r)] = q
+	}
+}
+
+// previousTime returns the time of the last reading before rs[i] with an
+// earlier time, or the zero time.
+func previousTime(rs []domain.Reading, i int) time.Time {
+	for j := i - 1; j >= 0; j-- {
+		if rs[j].Time.Before(rs[i].Time) {
+			return rs[j].Time
+		}
+	}
+	return time.Time{}
+}
+
+// modifiedZScore returns how far v lies from the median of sample, which
+// includes v, in units of the median absolute deviation (Iglewicz and
+// Hoaglin). When more than half the sample is equal the mean absolute
+// deviation stands in for it.
+func modifiedZScore(v float64, sample []float64) float64 {
+	sorted := slices.Clone(sample)
+	slices.Sort(sorted)
+	med := median(sorted)
+	for i, x := range sample {
+		sorted[i] = math.Abs(x - med)
+	}
+	slices.Sort(sorted)
+	if mad := median(sorted); mad > 0 {
+		return 0.6745 * (v - med) / mad
+	}
+	var sum float64
+	for _, d := range sorted {
+		sum += d
+	}
+	if sum == 0 {
+		return 0
+	}
+	return (v - med) / (1.253314 * sum / float64(len(sorted)))
+}
+
+// median returns the median of sorted, which must not be empty.
+func median(sorted []float64) float64 {
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
@@ -0,0 +1,328 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/milad/spectral/internal/domain"
+	"github.com/milad/spectral/internal/repo"
+	"github.com/milad/spectral/internal/repo/csvrepo"
+)
+
+// qualitySeries returns n readings of meter a every 15 minutes from base, all
+// with usage 10 but those in usage.
+func qualitySeries(base time.Time, n int, usage map[int]float64) []domain.Reading {
+	rs := make([]domain.Reading, 0, n)
+	for i := range n {
+		u, ok := usage[i]
+		if !ok {
+			u = 10 + float64(i%3)
+		}
+		rs = append(rs, domain.Reading{MeterID: "a", Time: base.Add(time.Duration(i) * 15 * time.Minute), MeterUsage: u})
+	}
+	return rs
+}
+
+func TestMeterUsageService_ListReadings_QualityFlags(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	rs := qualitySeries(base, 40, map[int]float64{10: 95, 20: -1})
+	rs = append(rs, domain.Reading{MeterID: "a", Time: rs[5].Time, MeterUsage: 11})
+	rs = slices.Delete(rs, 30, 33) // 3 readings missing before rs[33]
+	svc := NewMeterUsageService(csvrepo.New(rs))
+
+	got, err := svc.ListReadings(context.Background(), nil, nil, nil)
+	if err != nil {
+		t.Fatalf("ListReadings: %v", err)
+	}
+	flagged := map[time.Time]domain.QualityFlags{}
+	for _, r := range got {
+		if r.Quality != 0 {
+			flagged[r.Time] |= r.Quality
+		}
+	}
+	at := func(i int) time.Time { return base.Add(time.Duration(i) * 15 * time.Minute) }
+	want := map[time.Time]domain.QualityFlags{
+		at(5):  domain.QualityDuplicate,
+		at(10): domain.QualitySpike,
+		at(20): domain.QualityNegative | domain.QualitySpike,
+		at(33): domain.QualityGapBefore,
+	}
+	if len(flagged) != len(want) {
+		t.Fatalf("flagged=%v want %v", flagged, want)
+	}
+	for tm, f := range want {
+		if flagged[tm] != f {
+			t.Fatalf("flags at %s=%v want %v", tm.Format(time.Kitchen), flagged[tm].Names(), f.Names())
+		}
+	}
+
+	// The repository's readings are left alone.
+	plain, _ := csvrepo.New(rs).List(context.Background(), nil, nil, nil)
+	for _, r := range plain {
+		if r.Quality != 0 {
+			t.Fatalf("repository reading flagged: %+v", r)
+		}
+	}
+}
+
+func TestMeterUsageService_QualityFlagsLookBeyondThePage(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	rs := qualitySeries(base, 4, nil)
+	// Readings resume a day later: the first one follows a gap that started
+	// long before any page that holds it.
+	rs = append(rs, qualitySeries(base.Add(24*time.Hour), 4, nil)...)
+	rs = append(rs, domain.Reading{MeterID: "b", Time: base.Add(24 * time.Hour), MeterUsage: 1})
+	svc := NewMeterUsageService(csvrepo.New(rs))
+
+	start := base.Add(23 * time.Hour)
+	res, err := svc.ListReadingsPage(context.Background(), &start, nil, nil, 2, "")
+	if err != nil {
+		t.Fatalf("ListReadingsPage: %v", err)
+	}
+	if got, want := res.Readings[0], (domain.Reading{MeterID: "a", Time: base.Add(24 * time.Hour), MeterUsage: 10, Quality: domain.QualityGapBefore}); got != want {
+		t.Fatalf("got %+v want %+v", got, want)
+	}
+	// Meter b starts there: nothing is missing before its first reading.
+	if got := res.Readings[1]; got.MeterID != "b" || got.Quality != 0 {
+		t.Fatalf("got %+v, want meter b unflagged", got)
+	}
+
+	// The next reading is checked against the one on the page before.
+	res, err = svc.ListReadingsPage(context.Background(), &start, nil, nil, 1, res.NextPageToken)
+	if err != nil {
+		t.Fatalf("ListReadingsPage: %v", err)
+	}
+	if got := res.Readings[0]; got.Quality != 0 {
+		t.Fatalf("got %+v, want no flags", got)
+	}
+}
+
+// seekingRepo counts the LastBefore lookups made through it.
+type seekingRepo struct {
+	*csvrepo.Repo
+	lookups int
+}
+
+func (r *seekingRepo) LastBefore(ctx context.Context, meterID string, t time.Time) (domain.Reading, bool, error) {
+	r.lookups++
+	return r.Repo.LastBefore(ctx, meterID, t)
+}
+
+// listingRepo hides every optional capability of the repository it wraps.
+type listingRepo struct {
+	repo.ReadingRepository
+}
+
+func TestMeterUsageService_StreamReadings_CarriesReadingsAcrossGaps(t *testing.T) {
+	t.Parallel()
+
+	// A reading every day: each one follows a gap beyond the lookback.
+	base := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	var rs []domain.Reading
+	for i := range 10 {
+		rs = append(rs, domain.Reading{MeterID: "a", Time: base.Add(time.Duration(i) * 24 * time.Hour), MeterUsage: 10})
+	}
+	seeking := &seekingRepo{Repo: csvrepo.New(rs)}
+
+	for name, r := range map[string]repo.ReadingRepository{
+		"seeking": seeking,
+		"listing": listingRepo{csvrepo.New(rs)},
+	} {
+		svc := NewMeterUsageService(r)
+		var got []domain.QualityFlags
+		err := svc.StreamReadings(context.Background(), nil, nil, nil, 1, func(chunk []domain.Reading) error {
+			for _, r := range chunk {
+				got = append(got, r.Quality)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s: StreamReadings: %v", name, err)
+		}
+		if len(got) != len(rs) || got[0] != 0 {
+			t.Fatalf("%s: flags=%v want the first reading unflagged", name, got)
+		}
+		for i, q := range got[1:] {
+			if q != domain.QualityGapBefore {
+				t.Fatalf("%s: reading %d flags=%v want %v", name, i+1, q, domain.QualityGapBefore)
+			}
+		}
+	}
+	// Only the first chunk has no earlier chunk to take the reading from.
+	if seeking.lookups != 1 {
+		t.Fatalf("LastBefore lookups=%d want 1", seeking.lookups)
+	}
+}
+
+func TestMeterUsageService_StreamReadings_QualityFlagsAcrossChunks(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	rs := qualitySeries(base, 20, nil)
+	rs = append(rs, domain.Reading{MeterID: "a", Time: rs[9].Time, MeterUsage: 12})
+	svc := NewMeterUsageService(csvrepo.New(rs))
+
+	var dups int
+	err := svc.StreamReadings(context.Background(), nil, nil, nil, 10, func(chunk []domain.Reading) error {
+		for _, r := range chunk {
+			if r.Quality&domain.QualityDuplicate != 0 {
+				dups++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamReadings: %v", err)
+	}
+	if got, want := dups, 2; got != want {
+		t.Fatalf("duplicates=%d want %d", got, want)
+	}
+}
+
+func TestMeterUsageService_QualityReport(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	rs := qualitySeries(base, 40, map[int]float64{25: -3})
+	rs = slices.Delete(rs, 30, 32)
+	rs = slices.Delete(rs, 10, 15)
+	rs = append(rs, domain.Reading{MeterID: "b", Time: base, MeterUsage: 1})
+	svc := NewMeterUsageService(csvrepo.New(rs))
+	at := func(i int) time.Time { return base.Add(time.Duration(i) * 15 * time.Minute) }
+
+	start, end := base, at(40)
+	rep, err := svc.QualityReport(context.Background(), &start, &end, nil)
+	if err != nil {
+		t.Fatalf("QualityReport: %v", err)
+	}
+	if rep.Interval != 15*time.Minute || len(rep.Meters) != 2 || rep.Meters[0].MeterID != "a" || rep.Meters[1].MeterID != "b" {
+		t.Fatalf("unexpected report: %+v", rep)
+	}
+	a := rep.Meters[0]
+	if a.Readings != 33 || a.MissingIntervals != 7 || a.Negatives != 1 || a.Duplicates != 0 || a.Truncated {
+		t.Fatalf("unexpected meter: %+v", a)
+	}
+	wantGaps := []domain.Gap{{Start: at(9), End: at(15), Missing: 5}, {Start: at(29), End: at(32), Missing: 2}}
+	if !slices.Equal(a.Gaps, wantGaps) {
+		t.Fatalf("gaps=%v want %v", a.Gaps, wantGaps)
+	}
+	if len(a.Anomalies) != 1 || !a.Anomalies[0].Time.Equal(at(25)) || a.Anomalies[0].Quality&domain.QualityNegative == 0 {
+		t.Fatalf("anomalies=%+v", a.Anomalies)
+	}
+
+	// A gap is reported by the reading that ends it, whatever the range.
+	start = at(20)
+	rep, err = svc.QualityReport(context.Background(), &start, &end, []string{"a"})
+	if err != nil {
+		t.Fatalf("QualityReport: %v", err)
+	}
+	if len(rep.Meters) != 1 || !slices.Equal(rep.Meters[0].Gaps, wantGaps[1:]) {
+		t.Fatalf("unexpected report: %+v", rep)
+	}
+
+	far := start.Add(MaxUnpagedRange + time.Hour)
+	for name, r := range map[string][2]*time.Time{
+		"reversed":  {&end, &start},
+		"no start":  {nil, &end},
+		"no end":    {&start, nil},
+		"too large": {&start, &far},
+	} {
+		if _, err := svc.QualityReport(context.Background(), r[0], r[1], nil); !errors.Is(err, ErrInvalidTimeRange) {
+			t.Fatalf("%s: expected ErrInvalidTimeRange, got %v", name, err)
+		}
+	}
+}
+
+// countingRepo counts the readings listed through it.
+type countingRepo struct {
+	*csvrepo.Repo
+	listed int
+}
+
+func (r *countingRepo) List(ctx context.Context, startInclusive, endExclusive *time.Time, meterIDs []string) ([]domain.Reading, error) {
+	rs, err := r.Repo.List(ctx, startInclusive, endExclusive, meterIDs)
+	r.listed += len(rs)
+	return rs, err
+}
+
+func TestMeterUsageService_QualityReport_ListsOnlyThePadding(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := &countingRepo{Repo: csvrepo.New(qualitySeries(base, 4*24*30, nil))}
+	svc := NewMeterUsageService(r)
+
+	start, end := base.Add(24*time.Hour), base.Add(29*24*time.Hour)
+	rep, err := svc.QualityReport(context.Background(), &start, &end, nil)
+	if err != nil {
+		t.Fatalf("QualityReport: %v", err)
+	}
+	// The range, then 3h of padding on either side and the readings at its
+	// first and last times again.
+	inRange := rep.Meters[0].Readings
+	if want := inRange + 2*12 + 2; r.listed != want {
+		t.Fatalf("listed %d readings want %d for %d in range", r.listed, want, inRange)
+	}
+}
+
+func TestWithQuality_DisablesSpikes(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := csvrepo.New(qualitySeries(base, 20, map[int]float64{10: 500}))
+	end := base.Add(24 * time.Hour)
+	cases := map[string]struct {
+		opts QualityOptions
+		want int
+	}{
+		"default":      {opts: DefaultQualityOptions(), want: 1},
+		"no window":    {opts: QualityOptions{SpikeThreshold: 5}, want: 0},
+		"no threshold": {opts: QualityOptions{SpikeWindow: time.Hour}, want: 0},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			rep, err := NewMeterUsageService(r, WithQuality(tc.opts)).QualityReport(context.Background(), &base, &end, nil)
+			if err != nil {
+				t.Fatalf("QualityReport: %v", err)
+			}
+			if got := rep.Meters[0].Spikes; got != tc.want {
+				t.Fatalf("spikes=%d want %d", got, tc.want)
+			}
+			if got, want := rep.Interval, 15*time.Minute; got != want {
+				t.Fatalf("interval=%s want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestModifiedZScore(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		v      float64
+		sample []float64
+		want   float64
+	}{
+		"median":      {v: 3, sample: []float64{1, 2, 3, 4, 5}, want: 0},
+		"outlier":     {v: 50, sample: []float64{1, 2, 3, 4, 50}, want: 0.6745 * 47},
+		"flat":        {v: 7, sample: []float64{7, 7, 7, 7}, want: 0},
+		"mostly flat": {v: 12, sample: []float64{2, 2, 2, 2, 12}, want: 10 / (1.253314 * 2)},
+		"below":       {v: -8, sample: []float64{2, 2, 2, 2, -8}, want: -10 / (1.253314 * 2)},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			if got := modifiedZScore(tc.v, tc.sample); got < tc.want-1e-9 || got > tc.want+1e-9 {
+				t.Fatalf("modifiedZScore=%v want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/milad/spectral/internal/domain"
+	"github.com/milad/spectral/internal/repo"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	DefaultStreamChunkSize = 1_000
+	MaxStreamChunkSize     = MaxPageSize
+)
+
+// StreamReadings calls emit with consecutive chunks of readings in [start, end),
+// in time order. Unlike ListReadingsPage there is no cap on the range: chunks
+// are read from the repository one at a time, as they are sent. Streaming
+// stops at the first emit error or when ctx is done.
+//
+// Readings carry their quality flags, checked against the readings around
+// them whichever chunk those fall in. The last reading of each meter is
+// carried from one chunk to the next rather than looked up again.
+func (s *MeterUsageService) StreamReadings(
+	ctx context.Context,
+	startInclusive *time.Time,
+	endExclusive *time.Time,
+	meterIDs []string,
+	chunkSize int,
+	emit func([]domain.Reading) error,
+) (err error) {
+	ctx, span := startSpan(ctx, "MeterUsageService.StreamReadings", attribute.Int("chunk_size", chunkSize))
+	defer func() { endSpan(span, err) }()
+
+	if startInclusive != nil && endExclusive != nil && !startInclusive.Before(*endExclusive) {
+		return fmt.Errorf("%w: start must be before end", ErrInvalidTimeRange)
+	}
+	if chunkSize < 0 {
+		return fmt.Errorf("%w: chunk_size must be >= 0", ErrInvalidPagination)
+	}
+	if chunkSize > s.limits.MaxStreamChunkSize {
+		return fmt.Errorf("%w: chunk_size too large (max %d)", ErrInvalidPagination, s.limits.MaxStreamChunkSize)
+	}
+	if chunkSize == 0 {
+		chunkSize = min(DefaultStreamChunkSize, s.limits.MaxStreamChunkSize)
+	}
+
+	var after *repo.Position
+	last := map[string]domain.Reading{}
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		readings, err := s.listAfter(ctx, startInclusive, endExclusive, meterIDs, after, chunkSize)
+		if err != nil || len(readings) == 0 {
+			return err
+		}
+		pos := lastPosition(readings, after)
+		after = &pos
+		chunk, err := s.withQuality(ctx, readings, meterIDs, last)
+		if err != nil {
+			return err
+		}
+		for _, r := range readings {
+			last[r.MeterID] = r
+		}
+		if err := emit(chunk); err != nil {
+			return err
+		}
+		if len(readings) < chunkSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/milad/spectral/internal/domain"
+	"github.com/milad/spectral/internal/repo"
+	"github.com/milad/spectral/internal/repo/csvrepo"
+)
+
+func TestMeterUsageService_StreamReadings_Chunks(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	var readings []domain.Reading
+	for i := 0; i < 5; i++ {
+		readings = append(readings, domain.Reading{Time: base.Add(time.Duration(i) * 15 * time.Minute), MeterUsage: float64(i)})
+	}
+	svc := NewMeterUsageService(csvrepo.New(readings))
+
+	// A range larger than MaxUnpagedRange is fine for streams.
+	end := base.Add(MaxUnpagedRange + time.Hour)
+
+	var sizes []int
+	var last time.Time
+	err := svc.StreamReadings(context.Background(), &base, &end, nil, 2, func(chunk []domain.Reading) error {
+		sizes = append(sizes, len(chunk))
+		for _, r := range chunk {
+			if r.Time.Before(last) {
+				t.Fatalf("readings out of order: %s after %s", r.Time, last)
+			}
+			last = r.Time
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamReadings: %v", err)
+	}
+	if len(sizes) != 3 || sizes[0] != 2 || sizes[1] != 2 || sizes[2] != 1 {
+		t.Fatalf("chunk sizes=%v want [2 2 1]", sizes)
+	}
+}
+
+func TestMeterUsageService_StreamReadings_StopsOnCancel(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	svc := NewMeterUsageService(csvrepo.New([]domain.Reading{
+		{Time: base, MeterUsage: 1},
+		{Time: base.Add(15 * time.Minute), MeterUsage: 2},
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := svc.StreamReadings(ctx, nil, nil, nil, 1, func([]domain.Reading) error {
+		calls++
+		cancel()
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("emit called %d times after cancel, want 1", calls)
+	}
+}
+
+func TestMeterUsageService_StreamReadings_RejectsInvalidChunkSize(t *testing.T) {
+	t.Parallel()
+
+	svc := NewMeterUsageService(csvrepo.New([]domain.Reading{}))
+	err := svc.StreamReadings(context.Background(), nil, nil, nil, MaxStreamChunkSize+1, func([]domain.Reading) error { return nil })
+	if !errors.Is(err, ErrInvalidPagination) {
+		t.Fatalf("expected ErrInvalidPagination, got %v", err)
+	}
+}
+
+// pagedRepo records the pages read through ListAfter.
+type pagedRepo struct {
+	*csvrepo.Repo
+	limits []int
+}
+
+func (r *pagedRepo) ListAfter(ctx context.Context, startInclusive, endExclusive *time.Time, meterIDs []string, after *repo.Position, limit int) ([]domain.Reading, error) {
+	r.limits = append(r.limits, limit)
+	return r.Repo.ListAfter(ctx, startInclusive, endExclusive, meterIDs, after, limit)
+}
+
+func TestMeterUsageService_StreamReadings_ReadsPageByPage(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	var readings []domain.Reading
+	for i := 0; i < 5; i++ {
+		// Duplicates span chunk boundaries.
+		readings = append(readings, domain.Reading{Time: base.Add(time.Duration(i/3) * 15 * time.Minute), MeterUsage: float64(i)})
+	}
+	r := &pagedRepo{Repo: csvrepo.New(readings)}
+	svc := NewMeterUsageService(r)
+
+	var got []float64
+	err := svc.StreamReadings(context.Background(), nil, nil, nil, 2, func(chunk []domain.Reading) error {
+		if len(r.limits) != len(got)/2+1 {
+			t.Fatalf("%d pages read before chunk %d was sent", len(r.limits), len(got)/2+1)
+		}
+		for _, rd := range chunk {
+			got = append(got, rd.MeterUsage)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamReadings: %v", err)
+	}
+	if len(got) != 5 {
+		t.Fatalf("streamed %v want 5 readings", got)
+	}
+	for i, v := range got {
+		if v != float64(i) {
+			t.Fatalf("streamed %v want readings in insertion order", got)
+		}
+	}
+	for _, l := range r.limits {
+		if l != 2 {
+			t.Fatalf("ListAfter limits=%v want 2 each", r.limits)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/milad/spectral/internal/domain"
+	"github.com/milad/spectral/internal/repo"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/milad/spectral/internal/service")
+
+// startSpan starts a span for a service or repository operation.
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan records err, if any, and ends span.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// The methods below are the service's only way into the repository, so every
+// repository call shows up as a child span of the service operation.
+
+func (s *MeterUsageService) repoList(ctx context.Context, startInclusive, endExclusive *time.Time, meterIDs []string) ([]domain.Reading, error) {
+	ctx, span := startSpan(ctx, "ReadingRepository.List", attribute.Int("meter_ids", len(meterIDs)))
+	readings, err := s.repo.List(ctx, startInclusive, endExclusive, meterIDs)
+	span.SetAttributes(attribute.Int("readings", len(readings)))
+	endSpan(span, err)
+	return readings, err
+}
+
+func repoListAfter(ctx context.Context, p repo.PagedReadingRepository, startInclusive, endExclusive *time.Time, meterIDs []string, after *repo.Position, limit int) ([]domain.Reading, error) {
+	ctx, span := startSpan(ctx, "ReadingRepository.ListAfter",
+		attribute.Int("meter_ids", len(meterIDs)),
+		attribute.Bool("after", after != nil),
+		attribute.Int("limit", limit),
+	)
+	readings, err := p.ListAfter(ctx, startInclusive, endExclusive, meterIDs, after, limit)
+	span.SetAttributes(attribute.Int("readings", len(readings)))
+	endSpan(span, err)
+	return readings, err
+}
+
+func repoLastBefore(ctx context.Context, sr repo.SeekingReadingRepository, meterID string, t time.Time) (domain.Reading, bool, error) {
+	ctx, span := startSpan(ctx, "ReadingRepository.LastBefore")
+	r, ok, err := sr.LastBefore(ctx, meterID, t)
+	span.SetAttributes(attribute.Bool("found", ok))
+	endSpan(span, err)
+	return r, ok, err
+}
+
+func (s *MeterUsageService) repoListMeters(ctx context.Context) ([]domain.Meter, error) {
+	ctx, span := startSpan(ctx, "ReadingRepository.ListMeters")
+	meters, err := s.repo.ListMeters(ctx)
+	endSpan(span, err)
+	return meters, err
+}
+
+func (s *MeterUsageService) repoDataset(ctx context.Context) (domain.Dataset, error) {
+	ctx, span := startSpan(ctx, "ReadingRepository.Dataset")
+	ds, err := s.repo.Dataset(ctx)
+	endSpan(span, err)
+	return ds, err
+}
+
+func repoAppend(ctx context.Context, w repo.WritableReadingRepository, idempotencyKey, fingerprint string, readings []domain.Reading) (bool, error) {
+	ctx, span := startSpan(ctx, "ReadingRepository.Append", attribute.Int("readings", len(readings)))
+	replayed, err := w.Append(ctx, idempotencyKey, fingerprint, readings)
+	span.SetAttributes(attribute.Bool("replayed", replayed))
+	endSpan(span, err)
+	return replayed, err
+}
+
+func repoIngestionReport(ctx context.Context, r repo.IngestionReporter) (domain.IngestionReport, error) {
+	ctx, span := startSpan(ctx, "ReadingRepository.IngestionReport")
+	rep, err := r.IngestionReport(ctx)
+	endSpan(span, err)
+	return rep, err
+}
@@ -0,0 +1,91 @@
+package telemetry
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// RequestIDMetadataKey is the gRPC metadata key carrying the request ID that
+// the HTTP gateway returns in X-Request-Id.
+const RequestIDMetadataKey = "x-request-id"
+
+// requestIDAttribute is the span attribute holding the request ID.
+const requestIDAttribute = "request.id"
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID in ctx, or "".
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestIDAttr returns the span attribute for a request ID.
+func RequestIDAttr(id string) attribute.KeyValue {
+	return attribute.String(requestIDAttribute, id)
+}
+
+func outgoingWithRequestID(ctx context.Context) context.Context {
+	if id := RequestIDFromContext(ctx); id != "" {
+		return metadata.AppendToOutgoingContext(ctx, RequestIDMetadataKey, id)
+	}
+	return ctx
+}
+
+// UnaryClientRequestID sends the request ID in ctx as gRPC metadata.
+func UnaryClientRequestID() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(outgoingWithRequestID(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientRequestID is UnaryClientRequestID for streaming calls.
+func StreamClientRequestID() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(outgoingWithRequestID(ctx), desc, cc, method, opts...)
+	}
+}
+
+// incomingRequestID moves the request ID from incoming metadata into the
+// context and onto the current span.
+func incomingRequestID(ctx context.Context) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	vals := md.Get(RequestIDMetadataKey)
+	if len(vals) == 0 || vals[0] == "" {
+		return ctx
+	}
+	trace.SpanFromContext(ctx).SetAttributes(RequestIDAttr(vals[0]))
+	return WithRequestID(ctx, vals[0])
+}
+
+// UnaryServerRequestID makes the caller's request ID available through
+// RequestIDFromContext to the handler and to later interceptors.
+func UnaryServerRequestID() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		return handler(incomingRequestID(ctx), req)
+	}
+}
+
+// StreamServerRequestID is UnaryServerRequestID for streaming calls.
+func StreamServerRequestID() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &contextStream{ServerStream: ss, ctx: incomingRequestID(ss.Context())})
+	}
+}
+
+// contextStream overrides the context of a server stream.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context { return s.ctx }
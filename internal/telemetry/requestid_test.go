@@ -0,0 +1,52 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestUnaryClientRequestID_SendsMetadata(t *testing.T) {
+	t.Parallel()
+
+	var sent metadata.MD
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		sent, _ = metadata.FromOutgoingContext(ctx)
+		return nil
+	}
+
+	ic := UnaryClientRequestID()
+	if err := ic(WithRequestID(context.Background(), "abc"), "/m", nil, nil, nil, invoker); err != nil {
+		t.Fatalf("invoke: %v", err)
+	}
+	if got, want := sent.Get(RequestIDMetadataKey), []string{"abc"}; len(got) != 1 || got[0] != want[0] {
+		t.Fatalf("metadata=%v want %v", got, want)
+	}
+
+	sent = nil
+	if err := ic(context.Background(), "/m", nil, nil, nil, invoker); err != nil {
+		t.Fatalf("invoke: %v", err)
+	}
+	if got := sent.Get(RequestIDMetadataKey); len(got) != 0 {
+		t.Fatalf("expected no request id without one in ctx, got %v", got)
+	}
+}
+
+func TestUnaryServerRequestID_ReadsMetadata(t *testing.T) {
+	t.Parallel()
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(RequestIDMetadataKey, "abc"))
+	var got string
+	handler := func(ctx context.Context, req any) (any, error) {
+		got = RequestIDFromContext(ctx)
+		return nil, nil
+	}
+	if _, err := UnaryServerRequestID()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/m"}, handler); err != nil {
+		t.Fatalf("handler: %v", err)
+	}
+	if want := "abc"; got != want {
+		t.Fatalf("request id=%q want %q", got, want)
+	}
+}
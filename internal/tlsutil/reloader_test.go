@@ -0,0 +1,241 @@
+package tlsutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create CA: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse CA: %v", err)
+	}
+	return testCA{cert: cert, key: key}
+}
+
+// writeCA writes the CA certificate to dir/name.
+func (ca testCA) writeCA(t *testing.T, dir, name string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	writePEM(t, path, "CERTIFICATE", ca.cert.Raw)
+	return path
+}
+
+// issue writes a leaf certificate for cn (also used as DNS SAN) and its key
+// to dir/name.crt and dir/name.key.
+func (ca testCA) issue(t *testing.T, dir, name, cn string, usage x509.ExtKeyUsage) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("create cert: %v", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	certFile, keyFile = filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "PRIVATE KEY", keyDER)
+	return certFile, keyFile
+}
+
+func writePEM(t *testing.T, path, typ string, der []byte) {
+	t.Helper()
+	// Rewrites within the same mtime tick must still look like a change.
+	prev, _ := os.Stat(path)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+	if prev != nil {
+		later := prev.ModTime().Add(time.Second)
+		if err := os.Chtimes(path, later, later); err != nil {
+			t.Fatalf("chtimes: %v", err)
+		}
+	}
+}
+
+// handshake connects a client and server over loopback TCP and returns the
+// server certificate's CN as seen by the client. (net.Pipe is unbuffered and
+// deadlocks when one side sends an alert the other is not reading.)
+func handshake(t *testing.T, serverCfg, clientCfg *tls.Config) (string, error) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	srvErr := make(chan error, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			srvErr <- err
+			return
+		}
+		defer conn.Close()
+		srvErr <- tls.Server(conn, serverCfg).Handshake()
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	c := tls.Client(conn, clientCfg)
+	if err := c.Handshake(); err != nil {
+		return "", err
+	}
+	// With TLS 1.3 the server checks the client certificate after the client
+	// has finished its side of the handshake.
+	if err := <-srvErr; err != nil {
+		return "", err
+	}
+	return c.ConnectionState().PeerCertificates[0].Subject.CommonName, nil
+}
+
+func TestReloader_MutualTLS(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	caFile := ca.writeCA(t, dir, "ca.crt")
+	srvCert, srvKey := ca.issue(t, dir, "server", "grpc.internal", x509.ExtKeyUsageServerAuth)
+	cliCert, cliKey := ca.issue(t, dir, "client", "gateway", x509.ExtKeyUsageClientAuth)
+
+	server, err := NewReloader(Files{Cert: srvCert, Key: srvKey, CA: caFile})
+	if err != nil {
+		t.Fatalf("server reloader: %v", err)
+	}
+	serverCfg, err := server.ServerConfig()
+	if err != nil {
+		t.Fatalf("ServerConfig: %v", err)
+	}
+	client, err := NewReloader(Files{Cert: cliCert, Key: cliKey, CA: caFile})
+	if err != nil {
+		t.Fatalf("client reloader: %v", err)
+	}
+	anonymous, err := NewReloader(Files{CA: caFile})
+	if err != nil {
+		t.Fatalf("anonymous reloader: %v", err)
+	}
+
+	if cn, err := handshake(t, serverCfg, client.ClientConfig("grpc.internal")); err != nil || cn != "grpc.internal" {
+		t.Fatalf("mTLS handshake: cn=%q err=%v", cn, err)
+	}
+	if _, err := handshake(t, serverCfg, anonymous.ClientConfig("grpc.internal")); err == nil {
+		t.Fatalf("expected a client without certificate to be rejected")
+	}
+	if _, err := handshake(t, serverCfg, client.ClientConfig("other.internal")); err == nil {
+		t.Fatalf("expected a server name mismatch to be rejected")
+	}
+
+	other := newTestCA(t)
+	strangerCert, strangerKey := other.issue(t, dir, "stranger", "gateway", x509.ExtKeyUsageClientAuth)
+	stranger, err := NewReloader(Files{Cert: strangerCert, Key: strangerKey, CA: caFile})
+	if err != nil {
+		t.Fatalf("stranger reloader: %v", err)
+	}
+	if _, err := handshake(t, serverCfg, stranger.ClientConfig("grpc.internal")); err == nil {
+		t.Fatalf("expected a client certificate from another CA to be rejected")
+	}
+}
+
+func TestReloader_ReloadsChangedFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	caFile := ca.writeCA(t, dir, "ca.crt")
+	srvCert, srvKey := ca.issue(t, dir, "server", "grpc.internal", x509.ExtKeyUsageServerAuth)
+
+	server, err := NewReloader(Files{Cert: srvCert, Key: srvKey})
+	if err != nil {
+		t.Fatalf("server reloader: %v", err)
+	}
+	serverCfg, err := server.ServerConfig()
+	if err != nil {
+		t.Fatalf("ServerConfig: %v", err)
+	}
+	client, err := NewReloader(Files{CA: caFile})
+	if err != nil {
+		t.Fatalf("client reloader: %v", err)
+	}
+
+	if changed, err := server.Reload(); changed || err != nil {
+		t.Fatalf("unchanged files: changed=%v err=%v", changed, err)
+	}
+
+	// Only the certificate of the pair replaced: keep serving the old one.
+	keyPEM, err := os.ReadFile(srvKey)
+	if err != nil {
+		t.Fatalf("read key: %v", err)
+	}
+	ca.issue(t, dir, "server", "grpc.internal", x509.ExtKeyUsageServerAuth)
+	if err := os.WriteFile(srvKey, keyPEM, 0o600); err != nil {
+		t.Fatalf("restore key: %v", err)
+	}
+	if changed, err := server.Reload(); changed || err == nil {
+		t.Fatalf("mismatched pair: changed=%v err=%v", changed, err)
+	}
+	if _, err := handshake(t, serverCfg, client.ClientConfig("grpc.internal")); err != nil {
+		t.Fatalf("handshake after failed reload: %v", err)
+	}
+
+	// Both files replaced: the new certificate is served without a restart.
+	ca.issue(t, dir, "server", "rotated.internal", x509.ExtKeyUsageServerAuth)
+	if changed, err := server.Reload(); !changed || err != nil {
+		t.Fatalf("rotated pair: changed=%v err=%v", changed, err)
+	}
+	if cn, err := handshake(t, serverCfg, client.ClientConfig("rotated.internal")); err != nil || cn != "rotated.internal" {
+		t.Fatalf("handshake after rotation: cn=%q err=%v", cn, err)
+	}
+}
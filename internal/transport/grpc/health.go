@@ -0,0 +1,75 @@
+package grpcserver
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	meterusagev1 "github.com/milad/spectral/gen/go/proto/meterusage/v1"
+	"github.com/milad/spectral/internal/domain"
+	"github.com/milad/spectral/internal/logging"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// DatasetSource is the part of the service the health reporter looks at.
+type DatasetSource interface {
+	Dataset(ctx context.Context) (domain.Dataset, error)
+}
+
+// ReportHealth keeps hs in step with the data being served until ctx is done:
+// the server as a whole ("") and MeterUsageService are SERVING while the
+// dataset holds at least minRows readings, and NOT_SERVING otherwise, e.g.
+// before a missing CSV file appears. The dataset is checked every interval,
+// which also updates the meterusage_dataset_* gauges.
+func ReportHealth(ctx context.Context, src DatasetSource, hs *health.Server, minRows int, interval time.Duration) {
+	h := healthReporter{src: src, hs: hs, minRows: minRows}
+	h.check(ctx)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			h.check(ctx)
+		}
+	}
+}
+
+type healthReporter struct {
+	src     DatasetSource
+	hs      *health.Server
+	minRows int
+
+	status healthpb.HealthCheckResponse_ServingStatus
+}
+
+func (h *healthReporter) check(ctx context.Context) {
+	ds, err := h.src.Dataset(ctx)
+	if ctx.Err() != nil {
+		return
+	}
+	if err == nil {
+		observeDataset(ds)
+	}
+	status := healthpb.HealthCheckResponse_SERVING
+	if err != nil || ds.Rows < h.minRows {
+		status = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	if status == h.status {
+		return
+	}
+	h.status = status
+	h.hs.SetServingStatus("", status)
+	h.hs.SetServingStatus(meterusagev1.MeterUsageService_ServiceDesc.ServiceName, status)
+
+	switch {
+	case err != nil:
+		slog.Warn("not serving: dataset unavailable", logging.Err(err))
+	case status == healthpb.HealthCheckResponse_SERVING:
+		slog.Info("serving", "version", ds.Version, "rows", ds.Rows)
+	default:
+		slog.Warn("not serving: too few readings", "rows", ds.Rows, "min_rows", h.minRows)
+	}
+}
@@ -0,0 +1,72 @@
+package grpcserver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	meterusagev1 "github.com/milad/spectral/gen/go/proto/meterusage/v1"
+	"github.com/milad/spectral/internal/repo/csvrepo"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestReportHealth_ServesOnceTheDatasetLoads(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "meterusage.csv")
+	repo := csvrepo.NewPending(path)
+	hs := health.NewServer()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ReportHealth(ctx, repo, hs, 2, 5*time.Millisecond)
+
+	statusOf := func(service string) healthpb.HealthCheckResponse_ServingStatus {
+		res, err := hs.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			return healthpb.HealthCheckResponse_SERVICE_UNKNOWN
+		}
+		return res.GetStatus()
+	}
+	waitFor := func(want healthpb.HealthCheckResponse_ServingStatus) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for statusOf("") != want || statusOf(meterusagev1.MeterUsageService_ServiceDesc.ServiceName) != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("status=%s want %s", statusOf(""), want)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	reload := func(csv string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(csv), 0o600); err != nil {
+			t.Fatalf("write csv: %v", err)
+		}
+		if _, err := repo.Reload(ctx); err != nil {
+			t.Fatalf("Reload: %v", err)
+		}
+	}
+
+	waitFor(healthpb.HealthCheckResponse_NOT_SERVING)
+
+	// One reading is below the threshold.
+	reload("time,meterusage\n2019-01-01 00:15:00,1\n")
+	time.Sleep(20 * time.Millisecond)
+	waitFor(healthpb.HealthCheckResponse_NOT_SERVING)
+
+	reload("time,meterusage\n2019-01-01 00:15:00,1\n2019-01-01 00:30:00,2\n")
+	waitFor(healthpb.HealthCheckResponse_SERVING)
+
+	// The dataset gauges follow the health checks.
+	ds, _ := repo.Dataset(ctx)
+	if got := testutil.ToFloat64(datasetRows); got != 2 {
+		t.Fatalf("meterusage_dataset_rows=%v want 2", got)
+	}
+	if got := testutil.ToFloat64(datasetVersion); got != float64(ds.Version) {
+		t.Fatalf("meterusage_dataset_version=%v want %d", got, ds.Version)
+	}
+}
@@ -0,0 +1,111 @@
+package grpcserver
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/milad/spectral/internal/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// The interceptors below are meant to be chained in this order, after the
+// request ID interceptors and before any authorization:
+//
+//	grpc.ChainUnaryInterceptor(
+//		telemetry.UnaryServerRequestID(),
+//		grpcserver.UnaryObserve(sampler),
+//		grpcserver.UnaryRecover(),
+//	)
+//
+// so that calls rejected by authorization and recovered panics are both
+// counted and logged.
+
+// UnaryObserve records the latency and status code of every call and writes
+// an access log line for the calls kept by sampler (nil keeps all of them).
+func UnaryObserve(sampler *logging.Sampler) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		observeCall(ctx, sampler, info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// StreamObserve is UnaryObserve for streaming calls. The latency covers the
+// whole stream.
+func StreamObserve(sampler *logging.Sampler) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		observeCall(ss.Context(), sampler, info.FullMethod, start, err)
+		return err
+	}
+}
+
+func observeCall(ctx context.Context, sampler *logging.Sampler, method string, start time.Time, err error) {
+	dur := time.Since(start)
+	code := status.Code(err)
+	observeGRPCCall(method, code, dur)
+
+	// Keep health checks quiet, like /healthz on the gateway.
+	if strings.HasPrefix(method, "/grpc.health.v1.Health/") || !sampler.Keep(err != nil) {
+		return
+	}
+	peerAddr := "-"
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		peerAddr = p.Addr.String()
+	}
+	attrs := []any{
+		logging.KeyGRPCMethod, method,
+		logging.KeyGRPCCode, code.String(),
+		logging.KeyDuration, float64(dur.Microseconds()) / 1000,
+		logging.KeyPeer, peerAddr,
+	}
+	if err != nil {
+		attrs = append(attrs, logging.KeyError, status.Convert(err).Message())
+	}
+	slog.InfoContext(ctx, "grpc request", attrs...)
+}
+
+// UnaryRecover turns a panic in a handler into a codes.Internal error instead
+// of crashing the process.
+func UnaryRecover() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				err = recovered(ctx, info.FullMethod, rec)
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// StreamRecover is UnaryRecover for streaming calls. Messages sent before the
+// panic have already reached the client, which then sees the stream fail.
+func StreamRecover() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				err = recovered(ss.Context(), info.FullMethod, rec)
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+func recovered(ctx context.Context, method string, rec any) error {
+	grpcServerPanicsTotal.WithLabelValues(method).Inc()
+	slog.ErrorContext(ctx, "panic handling request",
+		logging.KeyGRPCMethod, method,
+		"panic", fmt.Sprint(rec),
+		"stack", string(debug.Stack()),
+	)
+	return status.Error(codes.Internal, "internal error")
+}
@@ -0,0 +1,81 @@
+package grpcserver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryRecover_ReturnsInternal(t *testing.T) {
+	t.Parallel()
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Panics/Unary"}
+	panics := func(context.Context, any) (any, error) { panic("boom") }
+
+	_, err := UnaryRecover()(context.Background(), nil, info, panics)
+	if got, want := status.Code(err), codes.Internal; got != want {
+		t.Fatalf("code=%s want %s", got, want)
+	}
+	if got, want := testutil.ToFloat64(grpcServerPanicsTotal.WithLabelValues(info.FullMethod)), 1.0; got != want {
+		t.Fatalf("panics=%v want %v", got, want)
+	}
+}
+
+func TestStreamRecover_ReturnsInternal(t *testing.T) {
+	t.Parallel()
+
+	info := &grpc.StreamServerInfo{FullMethod: "/test.Panics/Stream"}
+	panics := func(any, grpc.ServerStream) error { panic("boom") }
+
+	err := StreamRecover()(nil, &fakeServerStream{ctx: context.Background()}, info, panics)
+	if got, want := status.Code(err), codes.Internal; got != want {
+		t.Fatalf("code=%s want %s", got, want)
+	}
+}
+
+func TestUnaryObserve_RecordsCode(t *testing.T) {
+	t.Parallel()
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Observe/Unary"}
+	chain := func(ctx context.Context, req any, handler grpc.UnaryHandler) (any, error) {
+		// Observe wraps Recover, so a recovered panic is seen as Internal.
+		return UnaryObserve(nil)(ctx, req, info, func(ctx context.Context, req any) (any, error) {
+			return UnaryRecover()(ctx, req, info, handler)
+		})
+	}
+
+	if _, err := chain(context.Background(), nil, func(context.Context, any) (any, error) {
+		return nil, status.Error(codes.NotFound, "missing")
+	}); status.Code(err) != codes.NotFound {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := chain(context.Background(), nil, func(context.Context, any) (any, error) { panic("boom") }); status.Code(err) != codes.Internal {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	for _, code := range []codes.Code{codes.NotFound, codes.Internal} {
+		var m dto.Metric
+		if err := grpcServerHandlingSeconds.WithLabelValues(info.FullMethod, code.String()).(prometheus.Histogram).Write(&m); err != nil {
+			t.Fatalf("read histogram: %v", err)
+		}
+		if got, want := m.GetHistogram().GetSampleCount(), uint64(1); got != want {
+			t.Fatalf("%s: observations=%d want %d", code, got, want)
+		}
+	}
+}
+
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeServerStream) Context() context.Context     { return s.ctx }
+func (s *fakeServerStream) SetHeader(metadata.MD) error  { return nil }
+func (s *fakeServerStream) SendHeader(metadata.MD) error { return nil }
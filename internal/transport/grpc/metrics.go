@@ -0,0 +1,54 @@
+package grpcserver
+
+import (
+	"time"
+
+	"github.com/milad/spectral/internal/domain"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc/codes"
+)
+
+var (
+	grpcServerHandlingSeconds = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "grpc_server_handling_seconds",
+			Help:    "Latency of gRPC calls handled by the server, by method and status code.",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"method", "code"},
+	)
+	grpcServerPanicsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "grpc_server_panics_total",
+			Help: "Total number of panics recovered while handling gRPC calls.",
+		},
+		[]string{"method"},
+	)
+
+	// The dataset gauges are updated by ReportHealth.
+	datasetVersion = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "meterusage_dataset_version",
+		Help: "Version of the dataset being served; increases on every reload or append.",
+	})
+	datasetRows = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "meterusage_dataset_rows",
+		Help: "Number of readings in the dataset being served.",
+	})
+	datasetUpdateTimestampSeconds = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "meterusage_dataset_update_timestamp_seconds",
+		Help: "Unix time at which the dataset last changed.",
+	})
+)
+
+func observeGRPCCall(method string, code codes.Code, dur time.Duration) {
+	grpcServerHandlingSeconds.WithLabelValues(method, code.String()).Observe(dur.Seconds())
+}
+
+func observeDataset(ds domain.Dataset) {
+	datasetVersion.Set(float64(ds.Version))
+	datasetRows.Set(float64(ds.Rows))
+	if !ds.UpdatedAt.IsZero() {
+		datasetUpdateTimestampSeconds.Set(float64(ds.UpdatedAt.UnixNano()) / 1e9)
+	}
+}
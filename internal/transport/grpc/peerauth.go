@@ -0,0 +1,68 @@
+package grpcserver
+
+import (
+	"context"
+	"strings"
+
+	meterusagev1 "github.com/milad/spectral/gen/go/proto/meterusage/v1"
+	"github.com/milad/spectral/internal/tlsutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// ClientAllowlist restricts MeterUsageService to peers whose verified TLS
+// client certificate names one of a set of identities (a URI SAN, DNS SAN or
+// subject CN). Other services on the same server, such as health checks, are
+// not affected.
+type ClientAllowlist struct {
+	allowed map[string]bool
+}
+
+func NewClientAllowlist(identities []string) *ClientAllowlist {
+	a := &ClientAllowlist{allowed: make(map[string]bool, len(identities))}
+	for _, id := range identities {
+		a.allowed[id] = true
+	}
+	return a
+}
+
+func (a *ClientAllowlist) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := a.check(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func (a *ClientAllowlist) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := a.check(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func (a *ClientAllowlist) check(ctx context.Context, fullMethod string) error {
+	if !strings.HasPrefix(fullMethod, "/"+meterusagev1.MeterUsageService_ServiceDesc.ServiceName+"/") {
+		return nil
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "client certificate required")
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return status.Error(codes.Unauthenticated, "client certificate required")
+	}
+	for _, id := range tlsutil.Identities(info.State.VerifiedChains[0][0]) {
+		if a.allowed[id] {
+			return nil
+		}
+	}
+	return status.Error(codes.PermissionDenied, "client certificate is not allowed to call this service")
+}
@@ -0,0 +1,52 @@
+package grpcserver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/url"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func withClientCert(cert *x509.Certificate) context.Context {
+	var state tls.ConnectionState
+	if cert != nil {
+		state.VerifiedChains = [][]*x509.Certificate{{cert}}
+	}
+	return peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{State: state}})
+}
+
+func TestClientAllowlist(t *testing.T) {
+	t.Parallel()
+
+	spiffe, _ := url.Parse("spiffe://example.org/gateway")
+	allow := NewClientAllowlist([]string{"spiffe://example.org/gateway", "gateway.internal"})
+	unary := allow.UnaryInterceptor()
+	ok := func(context.Context, any) (any, error) { return "ok", nil }
+	listReadings := &grpc.UnaryServerInfo{FullMethod: "/meterusage.v1.MeterUsageService/ListReadings"}
+
+	for name, tc := range map[string]struct {
+		ctx  context.Context
+		info *grpc.UnaryServerInfo
+		want codes.Code
+	}{
+		"uri san":       {withClientCert(&x509.Certificate{URIs: []*url.URL{spiffe}}), listReadings, codes.OK},
+		"common name":   {withClientCert(&x509.Certificate{Subject: pkix.Name{CommonName: "gateway.internal"}}), listReadings, codes.OK},
+		"other client":  {withClientCert(&x509.Certificate{DNSNames: []string{"batch.internal"}}), listReadings, codes.PermissionDenied},
+		"no cert":       {withClientCert(nil), listReadings, codes.Unauthenticated},
+		"no peer":       {context.Background(), listReadings, codes.Unauthenticated},
+		"other service": {withClientCert(nil), &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}, codes.OK},
+	} {
+		_, err := unary(tc.ctx, nil, tc.info, ok)
+		if got := status.Code(err); got != tc.want {
+			t.Fatalf("%s: code=%s want %s (err=%v)", name, got, tc.want, err)
+		}
+	}
+}
@@ -3,13 +3,17 @@ package grpcserver
 import (
 	"context"
 	"errors"
+	"fmt"
+	"math"
 	"time"
 
 	meterusagev1 "github.com/milad/spectral/gen/go/proto/meterusage/v1"
 	"github.com/milad/spectral/internal/domain"
 	"github.com/milad/spectral/internal/service"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -31,12 +35,9 @@ func (s *Server) ListReadings(ctx context.Context, req *meterusagev1.ListReading
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	res, err := s.svc.ListReadingsPage(ctx, start, end, int(req.GetPageSize()), req.GetPageToken())
+	res, err := s.svc.ListReadingsPage(ctx, start, end, req.GetMeterIds(), int(req.GetPageSize()), req.GetPageToken())
 	if err != nil {
-		if errors.Is(err, service.ErrInvalidTimeRange) || errors.Is(err, service.ErrInvalidPagination) {
-			return nil, status.Error(codes.InvalidArgument, err.Error())
-		}
-		return nil, status.Error(codes.Internal, "internal error")
+		return nil, toStatusError(err)
 	}
 
 	out := make([]*meterusagev1.Reading, 0, len(res.Readings))
@@ -49,11 +50,315 @@ func (s *Server) ListReadings(ctx context.Context, req *meterusagev1.ListReading
 	}, nil
 }
 
+func (s *Server) AggregateReadings(ctx context.Context, req *meterusagev1.AggregateReadingsRequest) (*meterusagev1.AggregateReadingsResponse, error) {
+	if req == nil {
+		return nil, status.Error(codes.InvalidArgument, "request is required")
+	}
+	start, end, err := fromProtoRange(req.GetStart(), req.GetEnd())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	q := service.AggregateQuery{Start: start, End: end, MeterIDs: req.GetMeterIds()}
+	if tz := req.GetTimeZone(); tz != "" {
+		// "Local" would silently depend on the server's configuration.
+		if q.Location, err = time.LoadLocation(tz); err != nil || tz == "Local" {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid time_zone %q", tz)
+		}
+	}
+
+	switch b := req.GetBucket().(type) {
+	case *meterusagev1.AggregateReadingsRequest_BucketWidth:
+		if err := b.BucketWidth.CheckValid(); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		q.Width = b.BucketWidth.AsDuration()
+		if q.Width <= 0 {
+			return nil, status.Error(codes.InvalidArgument, "bucket_width must be positive")
+		}
+	case *meterusagev1.AggregateReadingsRequest_CalendarInterval:
+		switch b.CalendarInterval {
+		case meterusagev1.CalendarInterval_CALENDAR_INTERVAL_DAY:
+			q.Calendar = service.CalendarDay
+		case meterusagev1.CalendarInterval_CALENDAR_INTERVAL_WEEK:
+			q.Calendar = service.CalendarWeek
+		case meterusagev1.CalendarInterval_CALENDAR_INTERVAL_MONTH:
+			q.Calendar = service.CalendarMonth
+		default:
+			return nil, status.Error(codes.InvalidArgument, "invalid calendar_interval")
+		}
+	default:
+		return nil, status.Error(codes.InvalidArgument, "bucket_width or calendar_interval is required")
+	}
+
+	for _, f := range req.GetFunctions() {
+		fn, ok := aggregateFuncs[f]
+		if !ok {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid aggregate function %s", f)
+		}
+		q.Funcs = append(q.Funcs, fn)
+	}
+
+	switch req.GetEmptyBuckets() {
+	case meterusagev1.EmptyBuckets_EMPTY_BUCKETS_UNSPECIFIED, meterusagev1.EmptyBuckets_EMPTY_BUCKETS_SKIP:
+		q.EmptyBuckets = service.EmptyBucketsSkip
+	case meterusagev1.EmptyBuckets_EMPTY_BUCKETS_NULL:
+		q.EmptyBuckets = service.EmptyBucketsNull
+	case meterusagev1.EmptyBuckets_EMPTY_BUCKETS_ZERO:
+		q.EmptyBuckets = service.EmptyBucketsZero
+	default:
+		return nil, status.Error(codes.InvalidArgument, "invalid empty_buckets")
+	}
+
+	buckets, err := s.svc.AggregateReadings(ctx, q)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+
+	out := make([]*meterusagev1.Bucket, 0, len(buckets))
+	for _, b := range buckets {
+		out = append(out, &meterusagev1.Bucket{
+			Start: timestamppb.New(b.Start),
+			End:   timestamppb.New(b.End),
+			Count: b.Count,
+			Sum:   b.Sum,
+			Avg:   b.Avg,
+			Min:   b.Min,
+			Max:   b.Max,
+		})
+	}
+	return &meterusagev1.AggregateReadingsResponse{Buckets: out}, nil
+}
+
+func (s *Server) StreamReadings(req *meterusagev1.StreamReadingsRequest, stream grpc.ServerStreamingServer[meterusagev1.StreamReadingsResponse]) error {
+	if req == nil {
+		return status.Error(codes.InvalidArgument, "request is required")
+	}
+	start, end, err := fromProtoRange(req.GetStart(), req.GetEnd())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// Send blocks while the client's flow-control window is full and fails once
+	// the stream's context is canceled, so a slow or departed client stops the
+	// service from producing further chunks.
+	err = s.svc.StreamReadings(stream.Context(), start, end, req.GetMeterIds(), int(req.GetChunkSize()), func(chunk []domain.Reading) error {
+		out := make([]*meterusagev1.Reading, 0, len(chunk))
+		for _, r := range chunk {
+			out = append(out, toProtoReading(r))
+		}
+		return stream.Send(&meterusagev1.StreamReadingsResponse{Readings: out})
+	})
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return err
+		}
+		return toStatusError(err)
+	}
+	return nil
+}
+
+func (s *Server) AppendReadings(ctx context.Context, req *meterusagev1.AppendReadingsRequest) (*meterusagev1.AppendReadingsResponse, error) {
+	if req == nil {
+		return nil, status.Error(codes.InvalidArgument, "request is required")
+	}
+
+	// Malformed timestamps are row errors, like any other invalid reading. The
+	// rows are still passed on, so that they are part of the batch fingerprint.
+	var (
+		readings   = make([]domain.Reading, 0, len(req.GetReadings()))
+		decodeErrs = make([]error, len(req.GetReadings()))
+	)
+	for i, r := range req.GetReadings() {
+		if err := r.GetTime().CheckValid(); err != nil {
+			decodeErrs[i] = fmt.Errorf("invalid time: %w", err)
+		}
+		readings = append(readings, domain.Reading{
+			MeterID:    r.GetMeterId(),
+			Time:       r.GetTime().AsTime().UTC(),
+			MeterUsage: r.GetMeterUsage(),
+		})
+	}
+
+	res, err := s.svc.AppendDecodedReadings(ctx, req.GetIdempotencyKey(), readings, decodeErrs)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	rowErrors := make([]*meterusagev1.RowError, 0, len(res.Rejected))
+	for _, re := range res.Rejected {
+		rowErrors = append(rowErrors, &meterusagev1.RowError{Index: int32(re.Index), Message: re.Err.Error()})
+	}
+
+	return &meterusagev1.AppendReadingsResponse{
+		AcceptedCount: int32(res.Accepted),
+		RowErrors:     rowErrors,
+		Replayed:      res.Replayed,
+	}, nil
+}
+
+func (s *Server) ListMeters(ctx context.Context, req *meterusagev1.ListMetersRequest) (*meterusagev1.ListMetersResponse, error) {
+	meters, err := s.svc.ListMeters(ctx)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+
+	out := make([]*meterusagev1.Meter, 0, len(meters))
+	for _, m := range meters {
+		out = append(out, &meterusagev1.Meter{
+			Id:               m.ID,
+			ReadingCount:     int64(m.ReadingCount),
+			FirstReadingTime: timestamppb.New(m.FirstReading),
+			LastReadingTime:  timestamppb.New(m.LastReading),
+		})
+	}
+	return &meterusagev1.ListMetersResponse{Meters: out}, nil
+}
+
+func (s *Server) GetDataset(ctx context.Context, req *meterusagev1.GetDatasetRequest) (*meterusagev1.GetDatasetResponse, error) {
+	ds, err := s.svc.Dataset(ctx)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return &meterusagev1.GetDatasetResponse{Dataset: &meterusagev1.Dataset{
+		Version:    ds.Version,
+		RowCount:   int64(ds.Rows),
+		Checksum:   ds.Checksum,
+		UpdateTime: timestamppb.New(ds.UpdatedAt),
+	}, PageTokenKeyId: s.svc.PageTokenKeyID()}, nil
+}
+
+func (s *Server) GetIngestionReport(ctx context.Context, req *meterusagev1.GetIngestionReportRequest) (*meterusagev1.GetIngestionReportResponse, error) {
+	filter := service.IngestionReportFilter{MaxIssues: int(min(req.GetMaxIssues(), math.MaxInt32))}
+	for _, c := range req.GetCategories() {
+		filter.Categories = append(filter.Categories, domain.IngestionCategory(c))
+	}
+	rep, err := s.svc.IngestionReport(ctx, filter)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+
+	out := &meterusagev1.IngestionReport{
+		Source:          rep.Source,
+		Applied:         rep.Applied,
+		FileCount:       int32(rep.Files),
+		AcceptedCount:   int64(rep.Accepted),
+		RejectedCount:   int64(rep.Rejected),
+		IssuesTruncated: rep.IssuesTruncated,
+	}
+	// A repository that has not attempted a load yet has no load time.
+	if !rep.LoadedAt.IsZero() {
+		out.LoadTime = timestamppb.New(rep.LoadedAt)
+	}
+	for _, c := range rep.Categories {
+		out.Categories = append(out.Categories, &meterusagev1.IngestionCategoryCount{Category: string(c.Category), Count: int64(c.Count)})
+	}
+	for _, i := range rep.Issues {
+		out.Issues = append(out.Issues, &meterusagev1.IngestionIssue{
+			File:     i.File,
+			Row:      int64(i.Row),
+			Values:   i.Values,
+			Category: string(i.Category),
+			Message:  i.Message,
+		})
+	}
+	return &meterusagev1.GetIngestionReportResponse{Report: out}, nil
+}
+
+func (s *Server) GetQualityReport(ctx context.Context, req *meterusagev1.GetQualityReportRequest) (*meterusagev1.GetQualityReportResponse, error) {
+	if req == nil {
+		return nil, status.Error(codes.InvalidArgument, "request is required")
+	}
+	start, end, err := fromProtoRange(req.GetStart(), req.GetEnd())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	rep, err := s.svc.QualityReport(ctx, start, end, req.GetMeterIds())
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+
+	out := &meterusagev1.QualityReport{
+		Interval: durationpb.New(rep.Interval),
+		Meters:   make([]*meterusagev1.MeterQuality, 0, len(rep.Meters)),
+	}
+	for _, m := range rep.Meters {
+		mq := &meterusagev1.MeterQuality{
+			MeterId:        m.MeterID,
+			ReadingCount:   int64(m.Readings),
+			MissingCount:   int64(m.MissingIntervals),
+			DuplicateCount: int64(m.Duplicates),
+			NegativeCount:  int64(m.Negatives),
+			SpikeCount:     int64(m.Spikes),
+			Truncated:      m.Truncated,
+		}
+		for _, g := range m.Gaps {
+			mq.Gaps = append(mq.Gaps, &meterusagev1.Gap{
+				Start:        timestamppb.New(g.Start),
+				End:          timestamppb.New(g.End),
+				MissingCount: int64(g.Missing),
+			})
+		}
+		for _, r := range m.Anomalies {
+			mq.Anomalies = append(mq.Anomalies, toProtoReading(r))
+		}
+		out.Meters = append(out.Meters, mq)
+	}
+	return &meterusagev1.GetQualityReportResponse{Report: out}, nil
+}
+
+var aggregateFuncs = map[meterusagev1.AggregateFunction]service.AggregateFunc{
+	meterusagev1.AggregateFunction_AGGREGATE_FUNCTION_SUM:   service.AggregateSum,
+	meterusagev1.AggregateFunction_AGGREGATE_FUNCTION_AVG:   service.AggregateAvg,
+	meterusagev1.AggregateFunction_AGGREGATE_FUNCTION_MIN:   service.AggregateMin,
+	meterusagev1.AggregateFunction_AGGREGATE_FUNCTION_MAX:   service.AggregateMax,
+	meterusagev1.AggregateFunction_AGGREGATE_FUNCTION_COUNT: service.AggregateCount,
+}
+
+// toStatusError maps service errors to gRPC status errors. Validation errors
+// carry their message; anything else is reported as an opaque internal error.
+func toStatusError(err error) error {
+	switch {
+	case errors.Is(err, service.ErrInvalidTimeRange),
+		errors.Is(err, service.ErrInvalidPagination),
+		errors.Is(err, service.ErrInvalidAggregation),
+		errors.Is(err, service.ErrInvalidBatch):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrIdempotencyConflict):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, service.ErrReadOnly),
+		errors.Is(err, service.ErrNoIngestionReport):
+		return status.Error(codes.Unimplemented, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	default:
+		return status.Error(codes.Internal, "internal error")
+	}
+}
+
+var qualityFlags = []struct {
+	flag  domain.QualityFlags
+	proto meterusagev1.QualityFlag
+}{
+	{domain.QualityDuplicate, meterusagev1.QualityFlag_QUALITY_FLAG_DUPLICATE},
+	{domain.QualityNegative, meterusagev1.QualityFlag_QUALITY_FLAG_NEGATIVE},
+	{domain.QualitySpike, meterusagev1.QualityFlag_QUALITY_FLAG_SPIKE},
+	{domain.QualityGapBefore, meterusagev1.QualityFlag_QUALITY_FLAG_GAP_BEFORE},
+}
+
 func toProtoReading(r domain.Reading) *meterusagev1.Reading {
-	return &meterusagev1.Reading{
+	out := &meterusagev1.Reading{
 		Time:       timestamppb.New(r.Time),
 		MeterUsage: r.MeterUsage,
+		MeterId:    r.MeterID,
+	}
+	for _, q := range qualityFlags {
+		if r.Quality&q.flag != 0 {
+			out.QualityFlags = append(out.QualityFlags, q.proto)
+		}
 	}
+	return out
 }
 
 func fromProtoRange(start, end *timestamppb.Timestamp) (*time.Time, *time.Time, error) {
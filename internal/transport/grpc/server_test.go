@@ -2,17 +2,26 @@ package grpcserver
 
 import (
 	"context"
+	"io"
+	"math"
 	"net"
+	"os"
+	"path/filepath"
+	"slices"
 	"testing"
 	"time"
+	_ "time/tzdata" // tests must not depend on the host's zoneinfo
 
 	meterusagev1 "github.com/milad/spectral/gen/go/proto/meterusage/v1"
 	"github.com/milad/spectral/internal/domain"
 	"github.com/milad/spectral/internal/repo/csvrepo"
 	"github.com/milad/spectral/internal/service"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -159,3 +168,401 @@ func TestServer_ListReadings_Pagination(t *testing.T) {
 		t.Fatalf("expected empty next page token, got %q", resp2.NextPageToken)
 	}
 }
+
+func TestServer_AggregateReadings(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	repo := csvrepo.New([]domain.Reading{
+		{Time: base.Add(15 * time.Minute), MeterUsage: 1},
+		{Time: base.Add(30 * time.Minute), MeterUsage: 2},
+		{Time: base.Add(75 * time.Minute), MeterUsage: 3},
+	})
+	svc := service.NewMeterUsageService(repo)
+	srv := New(svc)
+
+	lis := bufconn.Listen(1024 * 1024)
+	g := grpc.NewServer()
+	meterusagev1.RegisterMeterUsageServiceServer(g, srv)
+	go func() { _ = g.Serve(lis) }()
+	t.Cleanup(g.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	client := meterusagev1.NewMeterUsageServiceClient(conn)
+
+	resp, err := client.AggregateReadings(context.Background(), &meterusagev1.AggregateReadingsRequest{
+		Bucket:    &meterusagev1.AggregateReadingsRequest_BucketWidth{BucketWidth: durationpb.New(time.Hour)},
+		Functions: []meterusagev1.AggregateFunction{meterusagev1.AggregateFunction_AGGREGATE_FUNCTION_SUM},
+	})
+	if err != nil {
+		t.Fatalf("AggregateReadings: %v", err)
+	}
+	if got, want := len(resp.Buckets), 2; got != want {
+		t.Fatalf("len(buckets)=%d want %d", got, want)
+	}
+	if got, want := resp.Buckets[0].GetSum(), 3.0; got != want {
+		t.Fatalf("sum=%v want %v", got, want)
+	}
+	if resp.Buckets[0].Count != nil {
+		t.Fatalf("expected count to be unset, got %d", resp.Buckets[0].GetCount())
+	}
+
+	_, err = client.AggregateReadings(context.Background(), &meterusagev1.AggregateReadingsRequest{})
+	if got, want := status.Code(err), codes.InvalidArgument; got != want {
+		t.Fatalf("code=%s want %s", got, want)
+	}
+
+	resp, err = client.AggregateReadings(context.Background(), &meterusagev1.AggregateReadingsRequest{
+		Bucket:   &meterusagev1.AggregateReadingsRequest_CalendarInterval{CalendarInterval: meterusagev1.CalendarInterval_CALENDAR_INTERVAL_DAY},
+		TimeZone: "Asia/Tokyo",
+	})
+	if err != nil {
+		t.Fatalf("AggregateReadings(tz): %v", err)
+	}
+	// Midnight in Tokyo (UTC+9) is 15:00 UTC on the previous day.
+	if got, want := resp.Buckets[0].GetStart().AsTime(), time.Date(2018, 12, 31, 15, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Fatalf("start=%s want %s", got, want)
+	}
+
+	_, err = client.AggregateReadings(context.Background(), &meterusagev1.AggregateReadingsRequest{
+		Bucket:   &meterusagev1.AggregateReadingsRequest_CalendarInterval{CalendarInterval: meterusagev1.CalendarInterval_CALENDAR_INTERVAL_DAY},
+		TimeZone: "Mars/Olympus_Mons",
+	})
+	if got, want := status.Code(err), codes.InvalidArgument; got != want {
+		t.Fatalf("code=%s want %s", got, want)
+	}
+}
+
+func TestServer_ListReadings_FiltersMeters(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	repo := csvrepo.New([]domain.Reading{
+		{MeterID: "a", Time: base.Add(15 * time.Minute), MeterUsage: 1},
+		{MeterID: "b", Time: base.Add(15 * time.Minute), MeterUsage: 2},
+		{MeterID: "a", Time: base.Add(30 * time.Minute), MeterUsage: 3},
+	})
+	svc := service.NewMeterUsageService(repo)
+	srv := New(svc)
+
+	lis := bufconn.Listen(1024 * 1024)
+	g := grpc.NewServer()
+	meterusagev1.RegisterMeterUsageServiceServer(g, srv)
+	go func() { _ = g.Serve(lis) }()
+	t.Cleanup(g.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	client := meterusagev1.NewMeterUsageServiceClient(conn)
+
+	resp, err := client.ListReadings(context.Background(), &meterusagev1.ListReadingsRequest{MeterIds: []string{"a"}})
+	if err != nil {
+		t.Fatalf("ListReadings: %v", err)
+	}
+	if got, want := len(resp.Readings), 2; got != want {
+		t.Fatalf("len(readings)=%d want %d", got, want)
+	}
+	for _, r := range resp.Readings {
+		if r.MeterId != "a" {
+			t.Fatalf("meter id=%q want %q", r.MeterId, "a")
+		}
+	}
+
+	meters, err := client.ListMeters(context.Background(), &meterusagev1.ListMetersRequest{})
+	if err != nil {
+		t.Fatalf("ListMeters: %v", err)
+	}
+	if got, want := len(meters.Meters), 2; got != want {
+		t.Fatalf("len(meters)=%d want %d", got, want)
+	}
+	if got, want := meters.Meters[0].ReadingCount, int64(2); got != want {
+		t.Fatalf("reading count=%d want %d", got, want)
+	}
+
+	ds, err := client.GetDataset(context.Background(), &meterusagev1.GetDatasetRequest{})
+	if err != nil {
+		t.Fatalf("GetDataset: %v", err)
+	}
+	if got, want := ds.GetDataset().GetRowCount(), int64(3); got != want {
+		t.Fatalf("row count=%d want %d", got, want)
+	}
+	if ds.GetDataset().GetVersion() == 0 {
+		t.Fatalf("expected a non-zero dataset version")
+	}
+}
+
+func TestServer_StreamReadings(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	repo := csvrepo.New([]domain.Reading{
+		{Time: base.Add(45 * time.Minute), MeterUsage: 3},
+		{Time: base.Add(15 * time.Minute), MeterUsage: 1},
+		{Time: base.Add(30 * time.Minute), MeterUsage: 2},
+	})
+	svc := service.NewMeterUsageService(repo)
+	srv := New(svc)
+
+	lis := bufconn.Listen(1024 * 1024)
+	g := grpc.NewServer()
+	meterusagev1.RegisterMeterUsageServiceServer(g, srv)
+	go func() { _ = g.Serve(lis) }()
+	t.Cleanup(g.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	client := meterusagev1.NewMeterUsageServiceClient(conn)
+
+	stream, err := client.StreamReadings(context.Background(), &meterusagev1.StreamReadingsRequest{ChunkSize: 2})
+	if err != nil {
+		t.Fatalf("StreamReadings: %v", err)
+	}
+	var (
+		chunks int
+		got    []float64
+	)
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+		chunks++
+		for _, r := range msg.Readings {
+			got = append(got, r.MeterUsage)
+		}
+	}
+	if chunks != 2 {
+		t.Fatalf("chunks=%d want 2", chunks)
+	}
+	if len(got) != 3 || got[0] != 1 || got[1] != 2 || got[2] != 3 {
+		t.Fatalf("usages=%v want [1 2 3]", got)
+	}
+
+	bad, err := client.StreamReadings(context.Background(), &meterusagev1.StreamReadingsRequest{ChunkSize: -1})
+	if err != nil {
+		t.Fatalf("StreamReadings: %v", err)
+	}
+	if _, err := bad.Recv(); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("code=%s want %s", status.Code(err), codes.InvalidArgument)
+	}
+}
+
+func TestServer_AppendReadings(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	svc := service.NewMeterUsageService(csvrepo.New(nil))
+	srv := New(svc)
+
+	lis := bufconn.Listen(1024 * 1024)
+	g := grpc.NewServer()
+	meterusagev1.RegisterMeterUsageServiceServer(g, srv)
+	go func() { _ = g.Serve(lis) }()
+	t.Cleanup(g.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	client := meterusagev1.NewMeterUsageServiceClient(conn)
+
+	req := &meterusagev1.AppendReadingsRequest{
+		IdempotencyKey: "k",
+		Readings: []*meterusagev1.Reading{
+			{Time: timestamppb.New(base), MeterUsage: 1},
+			{Time: &timestamppb.Timestamp{Seconds: 1, Nanos: -1}, MeterUsage: 2},
+			{Time: timestamppb.New(base.Add(15 * time.Minute)), MeterUsage: math.NaN()},
+		},
+	}
+	resp, err := client.AppendReadings(context.Background(), req)
+	if err != nil {
+		t.Fatalf("AppendReadings: %v", err)
+	}
+	if got, want := resp.AcceptedCount, int32(1); got != want {
+		t.Fatalf("accepted=%d want %d", got, want)
+	}
+	if len(resp.RowErrors) != 2 || resp.RowErrors[0].Index != 1 || resp.RowErrors[1].Index != 2 {
+		t.Fatalf("unexpected row errors: %v", resp.RowErrors)
+	}
+
+	// Rows with malformed timestamps are part of the batch too.
+	req.Readings[1].Time = &timestamppb.Timestamp{Seconds: 2, Nanos: -1}
+	_, err = client.AppendReadings(context.Background(), req)
+	if got, want := status.Code(err), codes.AlreadyExists; got != want {
+		t.Fatalf("code=%s want %s after changing an undecodable row", got, want)
+	}
+
+	req.Readings[1].Time = &timestamppb.Timestamp{Seconds: 1, Nanos: -1}
+	req.Readings[0].MeterUsage = 99
+	_, err = client.AppendReadings(context.Background(), req)
+	if got, want := status.Code(err), codes.AlreadyExists; got != want {
+		t.Fatalf("code=%s want %s", got, want)
+	}
+}
+
+func TestServer_GetIngestionReport(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "meterusage.csv")
+	data := "time,meterusage\n2019-01-01 00:15:00,1\n2019-01-01 00:30:00,x\n2019-01-01 00:45:00,NaN\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// The rejected rows are also returned as an error; the repo still serves
+	// the rest.
+	r, err := csvrepo.NewFromFile(path)
+	if r == nil {
+		t.Fatalf("NewFromFile: %v", err)
+	}
+	srv := New(service.NewMeterUsageService(r))
+
+	lis := bufconn.Listen(1024 * 1024)
+	g := grpc.NewServer()
+	meterusagev1.RegisterMeterUsageServiceServer(g, srv)
+	go func() { _ = g.Serve(lis) }()
+	t.Cleanup(g.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	client := meterusagev1.NewMeterUsageServiceClient(conn)
+	resp, err := client.GetIngestionReport(context.Background(), &meterusagev1.GetIngestionReportRequest{
+		Categories: []string{string(domain.IngestInvalidUsage)},
+	})
+	if err != nil {
+		t.Fatalf("GetIngestionReport: %v", err)
+	}
+	rep := resp.GetReport()
+	if rep.GetSource() != path || !rep.GetApplied() || rep.GetAcceptedCount() != 1 || rep.GetRejectedCount() != 2 {
+		t.Fatalf("unexpected report: %v", rep)
+	}
+	if rep.GetLoadTime() == nil {
+		t.Fatal("load_time not set")
+	}
+	if got, want := len(rep.GetCategories()), 2; got != want {
+		t.Fatalf("categories=%d want %d", got, want)
+	}
+	if len(rep.GetIssues()) != 1 || rep.GetIssues()[0].GetRow() != 3 || rep.GetIssues()[0].GetCategory() != string(domain.IngestInvalidUsage) {
+		t.Fatalf("unexpected issues: %v", rep.GetIssues())
+	}
+
+	// A repo that never loaded files reports no load.
+	empty, err := New(service.NewMeterUsageService(csvrepo.New(nil))).GetIngestionReport(context.Background(), &meterusagev1.GetIngestionReportRequest{})
+	if err != nil {
+		t.Fatalf("GetIngestionReport: %v", err)
+	}
+	if empty.GetReport().GetLoadTime() != nil {
+		t.Fatalf("load_time=%v want unset", empty.GetReport().GetLoadTime())
+	}
+}
+
+func TestServer_QualityFlagsAndReport(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	repo := csvrepo.New([]domain.Reading{
+		{MeterID: "a", Time: base, MeterUsage: 1},
+		{MeterID: "a", Time: base.Add(15 * time.Minute), MeterUsage: -2},
+		{MeterID: "a", Time: base.Add(15 * time.Minute), MeterUsage: 3},
+		{MeterID: "a", Time: base.Add(time.Hour), MeterUsage: 4},
+	})
+	srv := New(service.NewMeterUsageService(repo))
+
+	lis := bufconn.Listen(1024 * 1024)
+	g := grpc.NewServer()
+	meterusagev1.RegisterMeterUsageServiceServer(g, srv)
+	go func() { _ = g.Serve(lis) }()
+	t.Cleanup(g.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	client := meterusagev1.NewMeterUsageServiceClient(conn)
+	list, err := client.ListReadings(context.Background(), &meterusagev1.ListReadingsRequest{})
+	if err != nil {
+		t.Fatalf("ListReadings: %v", err)
+	}
+	want := [][]meterusagev1.QualityFlag{
+		nil,
+		{meterusagev1.QualityFlag_QUALITY_FLAG_DUPLICATE, meterusagev1.QualityFlag_QUALITY_FLAG_NEGATIVE},
+		{meterusagev1.QualityFlag_QUALITY_FLAG_DUPLICATE},
+		{meterusagev1.QualityFlag_QUALITY_FLAG_GAP_BEFORE},
+	}
+	for i, r := range list.GetReadings() {
+		if got := r.GetQualityFlags(); !slices.Equal(got, want[i]) {
+			t.Fatalf("reading %d: flags=%v want %v", i, got, want[i])
+		}
+	}
+
+	resp, err := client.GetQualityReport(context.Background(), &meterusagev1.GetQualityReportRequest{
+		Start: timestamppb.New(base),
+		End:   timestamppb.New(base.Add(24 * time.Hour)),
+	})
+	if err != nil {
+		t.Fatalf("GetQualityReport: %v", err)
+	}
+	rep := resp.GetReport()
+	if got, want := rep.GetInterval().AsDuration(), 15*time.Minute; got != want {
+		t.Fatalf("interval=%s want %s", got, want)
+	}
+	if len(rep.GetMeters()) != 1 {
+		t.Fatalf("meters=%v", rep.GetMeters())
+	}
+	m := rep.GetMeters()[0]
+	if m.GetReadingCount() != 4 || m.GetMissingCount() != 2 || m.GetDuplicateCount() != 2 || m.GetNegativeCount() != 1 || len(m.GetAnomalies()) != 2 {
+		t.Fatalf("unexpected meter: %v", m)
+	}
+	if len(m.GetGaps()) != 1 || !m.GetGaps()[0].GetEnd().AsTime().Equal(base.Add(time.Hour)) {
+		t.Fatalf("unexpected gaps: %v", m.GetGaps())
+	}
+
+	end := timestamppb.New(base)
+	for _, req := range []*meterusagev1.GetQualityReportRequest{{Start: end, End: end}, {}} {
+		_, err = client.GetQualityReport(context.Background(), req)
+		if got, want := status.Code(err), codes.InvalidArgument; got != want {
+			t.Fatalf("%v: code=%s want %s", req, got, want)
+		}
+	}
+}
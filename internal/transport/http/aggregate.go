@@ -0,0 +1,138 @@
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	meterusagev1 "github.com/milad/spectral/gen/go/proto/meterusage/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var calendarIntervals = map[string]meterusagev1.CalendarInterval{
+	"day":   meterusagev1.CalendarInterval_CALENDAR_INTERVAL_DAY,
+	"week":  meterusagev1.CalendarInterval_CALENDAR_INTERVAL_WEEK,
+	"month": meterusagev1.CalendarInterval_CALENDAR_INTERVAL_MONTH,
+}
+
+var aggregateFunctions = map[string]meterusagev1.AggregateFunction{
+	"sum":   meterusagev1.AggregateFunction_AGGREGATE_FUNCTION_SUM,
+	"avg":   meterusagev1.AggregateFunction_AGGREGATE_FUNCTION_AVG,
+	"min":   meterusagev1.AggregateFunction_AGGREGATE_FUNCTION_MIN,
+	"max":   meterusagev1.AggregateFunction_AGGREGATE_FUNCTION_MAX,
+	"count": meterusagev1.AggregateFunction_AGGREGATE_FUNCTION_COUNT,
+}
+
+var emptyBucketModes = map[string]meterusagev1.EmptyBuckets{
+	"":     meterusagev1.EmptyBuckets_EMPTY_BUCKETS_SKIP,
+	"skip": meterusagev1.EmptyBuckets_EMPTY_BUCKETS_SKIP,
+	"null": meterusagev1.EmptyBuckets_EMPTY_BUCKETS_NULL,
+	"zero": meterusagev1.EmptyBuckets_EMPTY_BUCKETS_ZERO,
+}
+
+// handleAggregateReadings returns per-bucket aggregates of readings in [start, end).
+//
+// Query params:
+//   - `bucket` (required): a Go duration such as `15m` or `1h`, or one of `day`, `week`, `month`
+//   - `functions`: comma-separated subset of sum,avg,min,max,count (default: all)
+//   - `empty`: how to report empty buckets: skip (default), null or zero
+//   - `meter_id`: restrict to these meters (repeated or comma-separated); their readings are combined
+//   - `tz`: IANA time zone for day/week/month boundaries and the rendered times (default UTC)
+func (s *Server) handleAggregateReadings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeAPIError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		return
+	}
+
+	start, end, loc, ok := parseTimeRange(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+
+	req := &meterusagev1.AggregateReadingsRequest{}
+	if loc != time.UTC {
+		req.TimeZone = loc.String()
+	}
+	if start != nil {
+		req.Start = timestamppb.New(*start)
+	}
+	if end != nil {
+		req.End = timestamppb.New(*end)
+	}
+	req.MeterIds = parseMeterIDs(r)
+
+	bucket := strings.ToLower(q.Get("bucket"))
+	if bucket == "" {
+		writeAPIError(w, http.StatusBadRequest, "invalid_argument", "bucket is required")
+		return
+	}
+	if ci, ok := calendarIntervals[bucket]; ok {
+		req.Bucket = &meterusagev1.AggregateReadingsRequest_CalendarInterval{CalendarInterval: ci}
+	} else {
+		d, err := time.ParseDuration(bucket)
+		if err != nil || d <= 0 {
+			writeAPIError(w, http.StatusBadRequest, "invalid_argument", "invalid bucket")
+			return
+		}
+		req.Bucket = &meterusagev1.AggregateReadingsRequest_BucketWidth{BucketWidth: durationpb.New(d)}
+	}
+
+	if v := q.Get("functions"); v != "" {
+		for _, name := range strings.Split(v, ",") {
+			fn, ok := aggregateFunctions[strings.ToLower(strings.TrimSpace(name))]
+			if !ok {
+				writeAPIError(w, http.StatusBadRequest, "invalid_argument", "invalid functions")
+				return
+			}
+			req.Functions = append(req.Functions, fn)
+		}
+	}
+
+	empty, ok := emptyBucketModes[strings.ToLower(q.Get("empty"))]
+	if !ok {
+		writeAPIError(w, http.StatusBadRequest, "invalid_argument", "invalid empty")
+		return
+	}
+	req.EmptyBuckets = empty
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeouts.Upstream)
+	defer cancel()
+	grpcStart := time.Now()
+	resp, err := s.client.AggregateReadings(ctx, req)
+	grpcDur := time.Since(grpcStart)
+	if err != nil {
+		writeUpstreamError(w, "AggregateReadings", err, grpcDur)
+		return
+	}
+	observeUpstreamGRPC("AggregateReadings", codes.OK.String(), grpcDur)
+
+	out := make([]bucketJSON, 0, len(resp.GetBuckets()))
+	for _, b := range resp.GetBuckets() {
+		if err := b.GetStart().CheckValid(); err != nil {
+			writeAPIError(w, http.StatusBadGateway, "upstream_error", "upstream returned invalid timestamp")
+			return
+		}
+		if err := b.GetEnd().CheckValid(); err != nil {
+			writeAPIError(w, http.StatusBadGateway, "upstream_error", "upstream returned invalid timestamp")
+			return
+		}
+		out = append(out, bucketJSON{
+			Start: formatTimeIn(b.GetStart().AsTime(), loc),
+			End:   formatTimeIn(b.GetEnd().AsTime(), loc),
+			Count: b.Count,
+			Sum:   b.Sum,
+			Avg:   b.Avg,
+			Min:   b.Min,
+			Max:   b.Max,
+
+			functions: req.Functions,
+		})
+	}
+
+	_ = writeJSON(w, http.StatusOK, aggregateReadingsResponseJSON{Buckets: out})
+}
@@ -0,0 +1,103 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	meterusagev1 "github.com/milad/spectral/gen/go/proto/meterusage/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// maxAppendBodyBytes bounds POST /api/readings request bodies.
+const maxAppendBodyBytes = 8 << 20
+
+// handleAppendReadings inserts readings posted as JSON:
+//
+//	{"readings": [{"meterId": "site-a", "time": "2019-01-01T00:15:00Z", "meterUsage": 55.09}]}
+//
+// Rows are validated individually; invalid rows are listed under `rejected`
+// (by index) while the valid ones are inserted. An `Idempotency-Key` header
+// makes retries safe.
+//
+// Every row is forwarded, including those the gateway can already tell are
+// invalid, so that they are part of the batch the key is recorded for.
+// Such rows go upstream without a time or with a NaN usage, which the
+// service rejects; their message is the gateway's own.
+func (s *Server) handleAppendReadings(w http.ResponseWriter, r *http.Request) {
+	var body appendReadingsRequestJSON
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAppendBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&body); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeAPIError(w, http.StatusRequestEntityTooLarge, "invalid_argument", "request body too large")
+			return
+		}
+		writeAPIError(w, http.StatusBadRequest, "invalid_argument", "invalid JSON body")
+		return
+	}
+	if len(body.Readings) == 0 {
+		writeAPIError(w, http.StatusBadRequest, "invalid_argument", "readings are required")
+		return
+	}
+
+	var (
+		req      = &meterusagev1.AppendReadingsRequest{IdempotencyKey: r.Header.Get("Idempotency-Key")}
+		messages = map[int]string{} // rows the gateway rejects, by index
+	)
+	for i, row := range body.Readings {
+		rd := &meterusagev1.Reading{MeterId: row.MeterID, MeterUsage: math.NaN()}
+		if t, err := parseOptionalRFC3339(row.Time); err != nil || t == nil {
+			messages[i] = "invalid time"
+		} else {
+			rd.Time = timestamppb.New(*t)
+		}
+		if row.MeterUsage != nil {
+			rd.MeterUsage = *row.MeterUsage
+		} else if _, ok := messages[i]; !ok {
+			messages[i] = "missing meterUsage"
+		}
+		req.Readings = append(req.Readings, rd)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeouts.Upstream)
+	defer cancel()
+	grpcStart := time.Now()
+	resp, err := s.client.AppendReadings(ctx, req)
+	grpcDur := time.Since(grpcStart)
+	if err != nil {
+		writeUpstreamError(w, "AppendReadings", err, grpcDur)
+		return
+	}
+	observeUpstreamGRPC("AppendReadings", codes.OK.String(), grpcDur)
+	if s.cache != nil && resp.GetAcceptedCount() > 0 {
+		s.cache.invalidate()
+	}
+
+	out := appendReadingsResponseJSON{
+		Accepted: int(resp.GetAcceptedCount()),
+		Rejected: make([]rowErrorJSON, 0, len(resp.GetRowErrors())),
+		Replayed: resp.GetReplayed(),
+	}
+	for _, re := range resp.GetRowErrors() {
+		i := int(re.GetIndex())
+		if i < 0 || i >= len(req.Readings) {
+			writeAPIError(w, http.StatusBadGateway, "upstream_error", "upstream returned invalid row index")
+			return
+		}
+		msg, ok := messages[i]
+		if !ok {
+			msg = re.GetMessage()
+		}
+		out.Rejected = append(out.Rejected, rowErrorJSON{Index: i, Message: msg})
+	}
+	sort.Slice(out.Rejected, func(i, j int) bool { return out.Rejected[i].Index < out.Rejected[j].Index })
+
+	_ = writeJSON(w, http.StatusOK, out)
+}
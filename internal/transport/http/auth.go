@@ -0,0 +1,58 @@
+package httpserver
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/milad/spectral/internal/auth"
+	"github.com/milad/spectral/internal/logging"
+)
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithAuthenticator requires callers of /api/ endpoints to authenticate with
+// a. Without it the API is open to anyone who can reach the listener.
+func WithAuthenticator(a auth.Authenticator) Option {
+	return func(s *Server) { s.auth = a }
+}
+
+// requiresAuth reports whether path is protected. Health checks, metrics and
+// the static UI stay open so that probes and scrapers need no credentials.
+func requiresAuth(path string) bool {
+	return strings.HasPrefix(path, "/api/")
+}
+
+// requiredScope is the scope a principal needs for the request: writes need
+// readings:write, everything else readings:read.
+func requiredScope(r *http.Request) string {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		return auth.ScopeRead
+	default:
+		return auth.ScopeWrite
+	}
+}
+
+// authenticate checks the request's credentials and scope. On failure it
+// writes a 401 or 403 API error and returns ok=false.
+func (s *Server) authenticate(w http.ResponseWriter, r *http.Request) (p auth.Principal, ok bool) {
+	p, err := s.auth.Authenticate(r)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="meterusage"`)
+		msg := "missing credentials: send an X-API-Key header or an Authorization: Bearer token"
+		if !errors.Is(err, auth.ErrNoCredentials) {
+			msg = err.Error()
+			slog.InfoContext(r.Context(), "authentication failed", logging.KeyMethod, r.Method, logging.KeyPath, r.URL.Path, logging.Err(err))
+		}
+		writeAPIError(w, http.StatusUnauthorized, "unauthenticated", msg)
+		return auth.Principal{}, false
+	}
+	if scope := requiredScope(r); !p.HasScope(scope) {
+		writeAPIError(w, http.StatusForbidden, "permission_denied", "missing scope "+scope)
+		return p, false
+	}
+	return p, true
+}
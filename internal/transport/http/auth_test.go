@@ -0,0 +1,117 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	meterusagev1 "github.com/milad/spectral/gen/go/proto/meterusage/v1"
+	"github.com/milad/spectral/internal/auth"
+	"google.golang.org/grpc"
+)
+
+// principalClient records the principal of the last ListReadings call.
+type principalClient struct {
+	*fakeClient
+	principal auth.Principal
+}
+
+func (c *principalClient) ListReadings(ctx context.Context, in *meterusagev1.ListReadingsRequest, opts ...grpc.CallOption) (*meterusagev1.ListReadingsResponse, error) {
+	c.principal, _ = auth.PrincipalFromContext(ctx)
+	return c.fakeClient.ListReadings(ctx, in, opts...)
+}
+
+func newAuthServer(t *testing.T, client MeterUsageClient) *Server {
+	t.Helper()
+	keys, err := auth.NewAPIKeys([]auth.APIKey{
+		{Name: "dashboard", SHA256: auth.HashAPIKey("read-key")},
+		{Name: "ingest", SHA256: auth.HashAPIKey("write-key"), Scopes: []string{auth.ScopeRead, auth.ScopeWrite}},
+	})
+	if err != nil {
+		t.Fatalf("NewAPIKeys: %v", err)
+	}
+	return New(client, WithAuthenticator(keys))
+}
+
+func TestHTTP_Auth_RejectsMissingAndInvalidCredentials(t *testing.T) {
+	t.Parallel()
+
+	srv := newAuthServer(t, &fakeClient{resp: &meterusagev1.ListReadingsResponse{}})
+
+	for _, key := range []string{"", "wrong-key"} {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/readings", nil)
+		if key != "" {
+			req.Header.Set(auth.APIKeyHeader, key)
+		}
+		srv.ServeHTTP(rr, req)
+
+		if got, want := rr.Code, http.StatusUnauthorized; got != want {
+			t.Fatalf("key=%q: status=%d want %d", key, got, want)
+		}
+		if rr.Header().Get("WWW-Authenticate") == "" {
+			t.Fatalf("key=%q: expected a WWW-Authenticate header", key)
+		}
+		var body apiErrorJSON
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		if body.Code != "unauthenticated" || body.RequestID == "" {
+			t.Fatalf("key=%q: unexpected error body %+v", key, body)
+		}
+	}
+}
+
+func TestHTTP_Auth_ChecksScopes(t *testing.T) {
+	t.Parallel()
+
+	srv := newAuthServer(t, &fakeClient{appendResp: &meterusagev1.AppendReadingsResponse{AcceptedCount: 1}})
+	body := `{"readings": [{"time": "2019-01-01T00:15:00Z", "meterUsage": 1}]}`
+
+	for key, want := range map[string]int{"read-key": http.StatusForbidden, "write-key": http.StatusOK} {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/readings", strings.NewReader(body))
+		req.Header.Set(auth.APIKeyHeader, key)
+		srv.ServeHTTP(rr, req)
+
+		if got := rr.Code; got != want {
+			t.Fatalf("key=%q: status=%d want %d, body=%s", key, got, want, rr.Body.String())
+		}
+	}
+}
+
+func TestHTTP_Auth_AttachesPrincipal(t *testing.T) {
+	t.Parallel()
+
+	pc := &principalClient{fakeClient: &fakeClient{resp: &meterusagev1.ListReadingsResponse{}}}
+	srv := newAuthServer(t, pc)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/readings", nil)
+	req.Header.Set(auth.APIKeyHeader, "read-key")
+	srv.ServeHTTP(rr, req)
+
+	if got, want := rr.Code, http.StatusOK; got != want {
+		t.Fatalf("status=%d want %d, body=%s", got, want, rr.Body.String())
+	}
+	if got, want := pc.principal.Subject, "dashboard"; got != want {
+		t.Fatalf("principal=%q want %q", got, want)
+	}
+}
+
+func TestHTTP_Auth_HealthAndMetricsStayOpen(t *testing.T) {
+	t.Parallel()
+
+	srv := newAuthServer(t, &fakeClient{})
+	for _, path := range []string{"/healthz", "/metrics"} {
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+
+		if got, want := rr.Code, http.StatusOK; got != want {
+			t.Fatalf("%s: status=%d want %d", path, got, want)
+		}
+	}
+}
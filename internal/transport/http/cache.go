@@ -0,0 +1,382 @@
+package httpserver
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	meterusagev1 "github.com/milad/spectral/gen/go/proto/meterusage/v1"
+	"golang.org/x/sync/singleflight"
+	"google.golang.org/grpc/status"
+)
+
+// CacheLimits bounds the response cache; see WithCache.
+type CacheLimits struct {
+	// MaxEntries and MaxBytes bound the number and the total size of cached
+	// responses; the least recently used are evicted first.
+	MaxEntries int
+	MaxBytes   int
+	// TTL is how long a response is served from the cache, even if the
+	// dataset has not changed.
+	TTL time.Duration
+	// VersionTTL is how long the upstream dataset version is trusted before
+	// it is asked for again, and so how long a change made other than through
+	// this gateway can go unnoticed. Zero asks on every request.
+	VersionTTL time.Duration
+}
+
+// WithCache keeps JSON pages of /api/readings in memory. Entries are tied to
+// the dataset checksum reported by the upstream's GetDataset and dropped as
+// soon as it changes; concurrent requests for a page that is not cached
+// share a single upstream call. The cache is disabled unless MaxEntries,
+// MaxBytes and TTL are all positive.
+func WithCache(l CacheLimits) Option {
+	return func(s *Server) {
+		if l.MaxEntries > 0 && l.MaxBytes > 0 && l.TTL > 0 {
+			s.cache = newResponseCache(l, time.Now)
+		}
+	}
+}
+
+// cacheEntryOverhead approximates the memory used by an entry besides its key
+// and body, so that many small pages still count against MaxBytes.
+const cacheEntryOverhead = 256
+
+// page is an encoded JSON response with its validators.
+type page struct {
+	body         []byte
+	etag         string
+	lastModified time.Time // zero if unknown
+	dataset      datasetStamp
+}
+
+// datasetStamp identifies the upstream dataset a page was built from by its
+// checksum, which replicas serving the same readings share. Their versions
+// and update times differ, so with several backends those would change
+// from one GetDataset call to the next. An upstream that reports no checksum
+// is identified by its version.
+type datasetStamp struct {
+	id      string
+	updated time.Time // for Last-Modified only
+}
+
+func (v datasetStamp) equal(o datasetStamp) bool {
+	return v.id == o.id
+}
+
+type cacheEntry struct {
+	key     string
+	page    *page
+	size    int
+	expires time.Time
+}
+
+type responseCache struct {
+	limits CacheLimits
+	now    func() time.Time
+	flight singleflight.Group
+
+	mu      sync.Mutex
+	lru     *list.List // of *cacheEntry, most recently used first
+	entries map[string]*list.Element
+	bytes   int
+	dataset datasetStamp
+	checked time.Time // when dataset was last confirmed; zero forces a fetch
+}
+
+func newResponseCache(limits CacheLimits, now func() time.Time) *responseCache {
+	return &responseCache{
+		limits:  limits,
+		now:     now,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// version returns the dataset stamp if it was confirmed within VersionTTL.
+func (c *responseCache) version() (datasetStamp, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.checked.IsZero() || c.now().Sub(c.checked) >= c.limits.VersionTTL {
+		return datasetStamp{}, false
+	}
+	return c.dataset, true
+}
+
+// setVersion records the dataset the upstream reported, dropping every entry
+// if it changed.
+func (c *responseCache) setVersion(v datasetStamp) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !v.equal(c.dataset) {
+		httpCacheEvictionsTotal.WithLabelValues("version").Add(float64(c.lru.Len()))
+		c.lru.Init()
+		clear(c.entries)
+		c.bytes = 0
+		c.observeSize()
+	}
+	c.dataset = v
+	c.checked = c.now()
+}
+
+// invalidate makes the next request ask the upstream for the dataset version,
+// e.g. after an append through this gateway.
+func (c *responseCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checked = time.Time{}
+}
+
+// get returns the page cached under key, which includes the stamp of the
+// dataset the page must be built from (see pageKey).
+func (c *responseCache) get(key string) (*page, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*cacheEntry)
+	if !c.now().Before(e.expires) {
+		c.remove(el, "expired")
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	return e.page, true
+}
+
+// add caches p unless it was built from a dataset that has since been
+// replaced or is too large to ever fit.
+func (c *responseCache) add(key string, p *page) {
+	size := len(key) + len(p.body) + cacheEntryOverhead
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !p.dataset.equal(c.dataset) || size > c.limits.MaxBytes {
+		return
+	}
+	if el, ok := c.entries[key]; ok {
+		c.remove(el, "replaced")
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, page: p, size: size, expires: c.now().Add(c.limits.TTL)})
+	c.bytes += size
+	for c.lru.Len() > c.limits.MaxEntries || c.bytes > c.limits.MaxBytes {
+		c.remove(c.lru.Back(), "capacity")
+	}
+	c.observeSize()
+}
+
+func (c *responseCache) remove(el *list.Element, reason string) {
+	e := c.lru.Remove(el).(*cacheEntry)
+	delete(c.entries, e.key)
+	c.bytes -= e.size
+	httpCacheEvictionsTotal.WithLabelValues(reason).Inc()
+	c.observeSize()
+}
+
+func (c *responseCache) observeSize() {
+	httpCacheEntries.Set(float64(c.lru.Len()))
+	httpCacheBytes.Set(float64(c.bytes))
+}
+
+// invalidUpstreamReply is an upstream response the gateway cannot render.
+type invalidUpstreamReply string
+
+func (e invalidUpstreamReply) Error() string { return string(e) }
+
+// serveJSONPage writes the JSON value returned by fetch, taking it from the
+// cache if one is configured and holds a current copy for key. fetch records
+// its upstream calls itself, so that a call shared by several requests is
+// counted once.
+func (s *Server) serveJSONPage(w http.ResponseWriter, r *http.Request, key string, fetch func(context.Context) (any, error)) {
+	p, err := s.loadPage(r.Context(), key, fetch)
+	if err != nil {
+		var invalid invalidUpstreamReply
+		if errors.As(err, &invalid) {
+			writeAPIError(w, http.StatusBadGateway, "upstream_error", invalid.Error())
+			return
+		}
+		writeUpstreamStatus(w, err)
+		return
+	}
+	writePage(w, r, p)
+}
+
+func (s *Server) loadPage(ctx context.Context, key string, fetch func(context.Context) (any, error)) (*page, error) {
+	if s.cache == nil {
+		return buildPage(ctx, fetch, datasetStamp{})
+	}
+	v, err := s.datasetVersion(ctx)
+	if err != nil {
+		// Without a version, a cached page could be stale.
+		httpCacheRequestsTotal.WithLabelValues("bypass").Inc()
+		return buildPage(ctx, fetch, datasetStamp{})
+	}
+	key = pageKey(v, key)
+	if p, ok := s.cache.get(key); ok {
+		httpCacheRequestsTotal.WithLabelValues("hit").Inc()
+		return p, nil
+	}
+
+	led := false
+	ch := s.cache.flight.DoChan(key, func() (any, error) {
+		led = true
+		// The call is shared: it must not fail because the request that
+		// happened to start it went away.
+		p, err := buildPage(context.WithoutCancel(ctx), fetch, v)
+		if err == nil {
+			s.cache.add(key, p)
+		}
+		return p, err
+	})
+	select {
+	case res := <-ch:
+		if led {
+			httpCacheRequestsTotal.WithLabelValues("miss").Inc()
+		} else {
+			httpCacheRequestsTotal.WithLabelValues("coalesced").Inc()
+		}
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(*page), nil
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+// datasetVersion returns the upstream dataset version, asking for it at most
+// once per VersionTTL. Requests that find it stale wait for a single
+// GetDataset call.
+func (s *Server) datasetVersion(ctx context.Context) (datasetStamp, error) {
+	if v, ok := s.cache.version(); ok {
+		return v, nil
+	}
+	ch := s.cache.flight.DoChan("\x00dataset", func() (any, error) {
+		ds, err := s.fetchDataset(context.WithoutCancel(ctx))
+		if err != nil {
+			return nil, err
+		}
+		v := versionOf(ds)
+		s.cache.setVersion(v)
+		return v, nil
+	})
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return datasetStamp{}, res.Err
+		}
+		return res.Val.(datasetStamp), nil
+	case <-ctx.Done():
+		return datasetStamp{}, ctx.Err()
+	}
+}
+
+// pageKey is the cache key of the page for key built from dataset v.
+func pageKey(v datasetStamp, key string) string {
+	return v.id + "\x00" + key
+}
+
+func versionOf(ds *meterusagev1.Dataset) datasetStamp {
+	v := datasetStamp{id: "checksum:" + ds.GetChecksum()}
+	if ds.GetChecksum() == "" {
+		v.id = "version:" + strconv.FormatUint(ds.GetVersion(), 10)
+	}
+	if ds.GetUpdateTime().CheckValid() == nil {
+		v.updated = ds.GetUpdateTime().AsTime()
+	}
+	return v
+}
+
+func buildPage(ctx context.Context, fetch func(context.Context) (any, error), v datasetStamp) (*page, error) {
+	body, err := fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(body); err != nil {
+		return nil, invalidUpstreamReply(fmt.Sprintf("upstream returned unencodable data: %v", err))
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	return &page{
+		body:         buf.Bytes(),
+		etag:         `"` + hex.EncodeToString(sum[:16]) + `"`,
+		lastModified: v.updated,
+		dataset:      v,
+	}, nil
+}
+
+// writePage writes p, or 304 Not Modified if the request's validators match
+// it. Clients are asked to revalidate on every use, which is cheap when the
+// page is cached.
+func writePage(w http.ResponseWriter, r *http.Request, p *page) {
+	h := w.Header()
+	h.Set("ETag", p.etag)
+	h.Set("Cache-Control", "private, no-cache")
+	if !p.lastModified.IsZero() {
+		h.Set("Last-Modified", p.lastModified.UTC().Format(http.TimeFormat))
+	}
+	if notModified(r, p) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	h.Set("Content-Type", "application/json; charset=utf-8")
+	h.Set("Content-Length", strconv.Itoa(len(p.body)))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(p.body)
+}
+
+// notModified evaluates If-None-Match or, only in its absence,
+// If-Modified-Since (RFC 9110, section 13.2.2).
+func notModified(r *http.Request, p *page) bool {
+	if inm := r.Header.Values("If-None-Match"); len(inm) > 0 {
+		return etagMatches(strings.Join(inm, ","), p.etag)
+	}
+	ims := r.Header.Get("If-Modified-Since")
+	if ims == "" || p.lastModified.IsZero() {
+		return false
+	}
+	t, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	return !p.lastModified.Truncate(time.Second).After(t)
+}
+
+// etagMatches reports whether the If-None-Match list contains etag, using the
+// weak comparison that header calls for.
+func etagMatches(list, etag string) bool {
+	for _, tag := range strings.Split(list, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// readingsPageKey identifies a page of readings by the request it is built
+// from rather than by its query string, so that equivalent queries (reordered
+// params, another spelling of the same instant, repeated rather than
+// comma-separated meter IDs) share an entry.
+func readingsPageKey(req *meterusagev1.ListReadingsRequest, loc *time.Location) string {
+	var start, end string
+	if req.GetStart() != nil {
+		start = formatTime(req.GetStart().AsTime())
+	}
+	if req.GetEnd() != nil {
+		end = formatTime(req.GetEnd().AsTime())
+	}
+	ids := slices.Compact(slices.Sorted(slices.Values(req.GetMeterIds())))
+	return fmt.Sprintf("readings\x00%s\x00%s\x00%s\x00%d\x00%q\x00%q", start, end, loc, req.GetPageSize(), req.GetPageToken(), ids)
+}
@@ -0,0 +1,363 @@
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	meterusagev1 "github.com/milad/spectral/gen/go/proto/meterusage/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// countingClient counts ListReadings and GetDataset calls and may hold
+// ListReadings until release is closed. It is safe for concurrent use.
+type countingClient struct {
+	*fakeClient
+	release chan struct{} // nil: do not block
+
+	mu       sync.Mutex
+	version  uint64
+	checksum string
+	updated  time.Time
+	lists    int
+	datasets int
+}
+
+func newCountingClient() *countingClient {
+	t0 := time.Date(2019, 1, 1, 0, 15, 0, 0, time.UTC)
+	return &countingClient{
+		fakeClient: &fakeClient{
+			resp: &meterusagev1.ListReadingsResponse{
+				Readings: []*meterusagev1.Reading{{MeterId: "m1", Time: timestamppb.New(t0), MeterUsage: 1.5}},
+			},
+			appendResp: &meterusagev1.AppendReadingsResponse{AcceptedCount: 1},
+		},
+		version: 1,
+		updated: time.Date(2019, 1, 2, 3, 4, 5, 600, time.UTC),
+	}
+}
+
+func (c *countingClient) ListReadings(ctx context.Context, in *meterusagev1.ListReadingsRequest, _ ...grpc.CallOption) (*meterusagev1.ListReadingsResponse, error) {
+	c.mu.Lock()
+	c.lists++
+	c.mu.Unlock()
+	if c.release != nil {
+		<-c.release
+	}
+	return c.resp, nil
+}
+
+func (c *countingClient) GetDataset(ctx context.Context, in *meterusagev1.GetDatasetRequest, _ ...grpc.CallOption) (*meterusagev1.GetDatasetResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.datasets++
+	return &meterusagev1.GetDatasetResponse{Dataset: &meterusagev1.Dataset{
+		Version:    c.version,
+		Checksum:   c.checksum,
+		UpdateTime: timestamppb.New(c.updated),
+	}}, nil
+}
+
+func (c *countingClient) counts() (lists, datasets int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lists, c.datasets
+}
+
+func (c *countingClient) setVersion(v uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.version = v
+}
+
+// setDataset replaces the whole dataset the client reports.
+func (c *countingClient) setDataset(version uint64, checksum string, updated time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.version, c.checksum, c.updated = version, checksum, updated
+}
+
+func get(srv http.Handler, target string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestHTTP_Cache_EquivalentQueriesShareAnEntry(t *testing.T) {
+	t.Parallel()
+
+	fc := newCountingClient()
+	srv := New(fc, WithCache(CacheLimits{MaxEntries: 10, MaxBytes: 1 << 20, TTL: time.Minute, VersionTTL: time.Minute}))
+
+	first := get(srv, "/api/readings?start=2019-01-01T00:00:00Z&meter_id=b,a")
+	second := get(srv, "/api/readings?meter_id=a&meter_id=b&meter_id=a&start=2019-01-01T01:00:00%2B01:00")
+	if first.Code != http.StatusOK || second.Code != http.StatusOK {
+		t.Fatalf("status=%d,%d want 200, body=%s", first.Code, second.Code, first.Body.String())
+	}
+	if got, want := second.Body.String(), first.Body.String(); got != want {
+		t.Fatalf("cached body=%q want %q", got, want)
+	}
+	if got, want := second.Header().Get("ETag"), first.Header().Get("ETag"); got == "" || got != want {
+		t.Fatalf("ETag=%q want %q", got, want)
+	}
+	if lists, datasets := fc.counts(); lists != 1 || datasets != 1 {
+		t.Fatalf("upstream calls: ListReadings=%d GetDataset=%d want 1 and 1", lists, datasets)
+	}
+
+	// A different page is a different entry.
+	get(srv, "/api/readings?start=2019-01-01T00:00:00Z&meter_id=a")
+	if lists, _ := fc.counts(); lists != 2 {
+		t.Fatalf("ListReadings=%d want 2", lists)
+	}
+}
+
+func TestHTTP_Cache_DatasetVersionChangeInvalidates(t *testing.T) {
+	t.Parallel()
+
+	fc := newCountingClient()
+	// VersionTTL 0 asks for the version on every request.
+	srv := New(fc, WithCache(CacheLimits{MaxEntries: 10, MaxBytes: 1 << 20, TTL: time.Minute}))
+
+	get(srv, "/api/readings")
+	get(srv, "/api/readings")
+	if lists, datasets := fc.counts(); lists != 1 || datasets != 2 {
+		t.Fatalf("upstream calls: ListReadings=%d GetDataset=%d want 1 and 2", lists, datasets)
+	}
+
+	fc.setVersion(2)
+	get(srv, "/api/readings")
+	if lists, _ := fc.counts(); lists != 2 {
+		t.Fatalf("ListReadings=%d want 2 after the dataset changed", lists)
+	}
+}
+
+func TestHTTP_Cache_DatasetChangeAtSameVersionInvalidates(t *testing.T) {
+	t.Parallel()
+
+	// An upstream that restarted can report a version that was already seen
+	// for other data.
+	fc := newCountingClient()
+	srv := New(fc, WithCache(CacheLimits{MaxEntries: 10, MaxBytes: 1 << 20, TTL: time.Minute}))
+	updated := time.Date(2019, 1, 2, 3, 4, 5, 600, time.UTC)
+
+	fc.setDataset(1, "abc", updated)
+	get(srv, "/api/readings")
+	get(srv, "/api/readings")
+	if lists, _ := fc.counts(); lists != 1 {
+		t.Fatalf("ListReadings=%d want 1", lists)
+	}
+
+	fc.setDataset(1, "def", updated)
+	get(srv, "/api/readings")
+	if lists, _ := fc.counts(); lists != 2 {
+		t.Fatalf("ListReadings=%d want 2 after the checksum changed", lists)
+	}
+}
+
+// replicasClient answers GetDataset for each of datasets in turn, like
+// backends behind one target that serve the same readings.
+type replicasClient struct {
+	*countingClient
+	datasets []*meterusagev1.Dataset
+	n        int
+}
+
+func (c *replicasClient) GetDataset(ctx context.Context, in *meterusagev1.GetDatasetRequest, opts ...grpc.CallOption) (*meterusagev1.GetDatasetResponse, error) {
+	c.mu.Lock()
+	ds := c.datasets[c.n%len(c.datasets)]
+	c.n++
+	c.mu.Unlock()
+	return &meterusagev1.GetDatasetResponse{Dataset: ds}, nil
+}
+
+func TestHTTP_Cache_ReplicasShareEntries(t *testing.T) {
+	t.Parallel()
+
+	// Replicas loaded the same files at different times: only the checksum
+	// is the same.
+	fc := &replicasClient{countingClient: newCountingClient(), datasets: []*meterusagev1.Dataset{
+		{Version: 100, Checksum: "abc", UpdateTime: timestamppb.New(time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC))},
+		{Version: 200, Checksum: "abc", UpdateTime: timestamppb.New(time.Date(2019, 1, 2, 3, 4, 7, 0, time.UTC))},
+	}}
+	srv := New(fc, WithCache(CacheLimits{MaxEntries: 10, MaxBytes: 1 << 20, TTL: time.Minute}))
+
+	for range 4 {
+		if got, want := get(srv, "/api/readings").Code, http.StatusOK; got != want {
+			t.Fatalf("status=%d want %d", got, want)
+		}
+	}
+	if lists, _ := fc.counts(); lists != 1 {
+		t.Fatalf("ListReadings=%d want 1 across replicas", lists)
+	}
+}
+
+func TestHTTP_Cache_HealthChecksDoNotTouchTheCache(t *testing.T) {
+	t.Parallel()
+
+	fc := newCountingClient()
+	srv := New(fc, WithCache(CacheLimits{MaxEntries: 10, MaxBytes: 1 << 20, TTL: time.Minute, VersionTTL: time.Hour}))
+
+	get(srv, "/api/readings")
+	// The change is picked up once VersionTTL has passed, not by the probes.
+	fc.setDataset(2, "def", time.Date(2019, 1, 3, 0, 0, 0, 0, time.UTC))
+	for _, path := range []string{"/healthz", "/readyz", "/metrics"} {
+		get(srv, path)
+	}
+	get(srv, "/api/readings")
+	if lists, _ := fc.counts(); lists != 1 {
+		t.Fatalf("ListReadings=%d want 1", lists)
+	}
+}
+
+func TestHTTP_Cache_AppendInvalidates(t *testing.T) {
+	t.Parallel()
+
+	fc := newCountingClient()
+	srv := New(fc, WithCache(CacheLimits{MaxEntries: 10, MaxBytes: 1 << 20, TTL: time.Minute, VersionTTL: time.Hour}))
+
+	get(srv, "/api/readings")
+	fc.setVersion(2)
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/readings",
+		strings.NewReader(`{"readings":[{"meterId":"m1","time":"2019-01-01T00:30:00Z","meterUsage":1}]}`)))
+	if got, want := rr.Code, http.StatusOK; got != want {
+		t.Fatalf("append status=%d want %d, body=%s", got, want, rr.Body.String())
+	}
+
+	get(srv, "/api/readings")
+	if lists, datasets := fc.counts(); lists != 2 || datasets != 2 {
+		t.Fatalf("upstream calls: ListReadings=%d GetDataset=%d want 2 and 2", lists, datasets)
+	}
+}
+
+func TestHTTP_Cache_CoalescesConcurrentRequests(t *testing.T) {
+	t.Parallel()
+
+	fc := newCountingClient()
+	fc.release = make(chan struct{})
+	srv := New(fc, WithCache(CacheLimits{MaxEntries: 10, MaxBytes: 1 << 20, TTL: time.Minute, VersionTTL: time.Minute}))
+
+	const n = 8
+	codes := make(chan int, n)
+	for range n {
+		go func() { codes <- get(srv, "/api/readings?page_size=10").Code }()
+	}
+	// Let every request reach the cache before the upstream answers; those
+	// arriving later are served from the cache, with the same outcome.
+	time.Sleep(50 * time.Millisecond)
+	close(fc.release)
+	for range n {
+		if got, want := <-codes, http.StatusOK; got != want {
+			t.Fatalf("status=%d want %d", got, want)
+		}
+	}
+	if lists, _ := fc.counts(); lists != 1 {
+		t.Fatalf("ListReadings=%d want 1", lists)
+	}
+}
+
+func TestHTTP_ListReadings_ConditionalRequests(t *testing.T) {
+	t.Parallel()
+
+	fc := newCountingClient()
+	srv := New(fc, WithCache(CacheLimits{MaxEntries: 10, MaxBytes: 1 << 20, TTL: time.Minute, VersionTTL: time.Minute}))
+
+	rr := get(srv, "/api/readings")
+	etag, lastModified := rr.Header().Get("ETag"), rr.Header().Get("Last-Modified")
+	if !strings.HasPrefix(etag, `"`) {
+		t.Fatalf("ETag=%q want a strong entity tag", etag)
+	}
+	if got, want := lastModified, "Wed, 02 Jan 2019 03:04:05 GMT"; got != want {
+		t.Fatalf("Last-Modified=%q want %q", got, want)
+	}
+
+	for name, tc := range map[string]struct {
+		header []string
+		want   int
+	}{
+		"matching etag":         {[]string{"If-None-Match", `"other", ` + etag}, http.StatusNotModified},
+		"weak matching etag":    {[]string{"If-None-Match", "W/" + etag}, http.StatusNotModified},
+		"any etag":              {[]string{"If-None-Match", "*"}, http.StatusNotModified},
+		"other etag":            {[]string{"If-None-Match", `"other"`}, http.StatusOK},
+		"not modified since":    {[]string{"If-Modified-Since", lastModified}, http.StatusNotModified},
+		"modified since":        {[]string{"If-Modified-Since", "Tue, 01 Jan 2019 00:00:00 GMT"}, http.StatusOK},
+		"etag takes precedence": {[]string{"If-None-Match", `"other"`, "If-Modified-Since", lastModified}, http.StatusOK},
+	} {
+		rr := get(srv, "/api/readings", tc.header...)
+		if got := rr.Code; got != tc.want {
+			t.Fatalf("%s: status=%d want %d", name, got, tc.want)
+		}
+		if tc.want == http.StatusNotModified && rr.Body.Len() != 0 {
+			t.Fatalf("%s: 304 with a body: %q", name, rr.Body.String())
+		}
+		if got := rr.Header().Get("ETag"); got != etag {
+			t.Fatalf("%s: ETag=%q want %q", name, got, etag)
+		}
+	}
+}
+
+func TestHTTP_ListReadings_ETagWithoutCache(t *testing.T) {
+	t.Parallel()
+
+	srv := New(newCountingClient())
+	rr := get(srv, "/api/readings")
+	etag := rr.Header().Get("ETag")
+	if etag == "" || rr.Header().Get("Last-Modified") != "" {
+		t.Fatalf("ETag=%q Last-Modified=%q want only an ETag", etag, rr.Header().Get("Last-Modified"))
+	}
+	if got, want := get(srv, "/api/readings", "If-None-Match", etag).Code, http.StatusNotModified; got != want {
+		t.Fatalf("status=%d want %d", got, want)
+	}
+}
+
+func TestResponseCache_Bounds(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := newResponseCache(CacheLimits{MaxEntries: 2, MaxBytes: 2*cacheEntryOverhead + 100, TTL: time.Minute}, func() time.Time { return now })
+	stamp := datasetStamp{id: "checksum:abc"}
+	c.setVersion(stamp)
+	p := func(body string) *page { return &page{body: []byte(body), dataset: stamp} }
+
+	c.add("a", p("1"))
+	c.add("b", p("2"))
+	c.get("a") // a is now the most recently used
+	c.add("c", p("3"))
+	if _, ok := c.get("b"); ok {
+		t.Fatalf("b should have been evicted as least recently used")
+	}
+	if _, ok := c.get("a"); !ok {
+		t.Fatalf("a should still be cached")
+	}
+
+	c.add("big", p(strings.Repeat("x", 99)))
+	if got, want := c.lru.Len(), 1; got != want {
+		t.Fatalf("entries=%d want %d after exceeding MaxBytes", got, want)
+	}
+	c.add("huge", p(strings.Repeat("x", 2*cacheEntryOverhead+100)))
+	if _, ok := c.get("huge"); ok {
+		t.Fatalf("an entry larger than MaxBytes should not be cached")
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := c.get("big"); ok {
+		t.Fatalf("big should have expired")
+	}
+	if got, want := c.bytes, 0; got != want {
+		t.Fatalf("bytes=%d want %d", got, want)
+	}
+
+	c.add("old", &page{dataset: datasetStamp{id: "checksum:def"}})
+	if _, ok := c.entries["old"]; ok {
+		t.Fatalf("a page built from a replaced dataset should not be cached")
+	}
+}
@@ -0,0 +1,129 @@
+package httpserver
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	meterusagev1 "github.com/milad/spectral/gen/go/proto/meterusage/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// datasetTimeout is kept short: health checks and scrapes must not hang on a
+// slow upstream.
+const datasetTimeout = time.Second
+
+// fetchDataset asks the upstream which dataset it is serving, and records the
+// page-token key ID of the backend that answered.
+func (s *Server) fetchDataset(ctx context.Context) (*meterusagev1.Dataset, error) {
+	ctx, cancel := context.WithTimeout(ctx, datasetTimeout)
+	defer cancel()
+	var p peer.Peer
+	grpcStart := time.Now()
+	resp, err := s.client.GetDataset(ctx, &meterusagev1.GetDatasetRequest{}, grpc.Peer(&p))
+	grpcDur := time.Since(grpcStart)
+	if err != nil {
+		observeUpstreamGRPC("GetDataset", status.Code(err).String(), grpcDur)
+		return nil, err
+	}
+	observeUpstreamGRPC("GetDataset", codes.OK.String(), grpcDur)
+
+	if id := resp.GetPageTokenKeyId(); id != "" && p.Addr != nil {
+		if bad := s.keys.observe(p.Addr.String(), id); len(bad) > 0 {
+			slog.ErrorContext(ctx, "upstream backends sign page tokens with different keys; paging fails across them",
+				"backends", bad)
+		}
+	}
+	return resp.GetDataset(), nil
+}
+
+// handleHealthz reports liveness of the gateway itself. It does not call the
+// upstream: an unreachable upstream does not make the gateway unhealthy, and
+// is what /readyz is for.
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	_ = writeJSON(w, http.StatusOK, healthzJSON{Status: "ok"})
+}
+
+// WithUpstreamHealth makes /readyz also ask the upstream's grpc.health.v1
+// service whether it is SERVING.
+func WithUpstreamHealth(c healthpb.HealthClient) Option {
+	return func(s *Server) { s.health = c }
+}
+
+// handleReadyz reports whether the gateway can serve data: the upstream must be
+// SERVING (with WithUpstreamHealth) and serve a dataset with at least one row,
+// and its backends must share a page-token key. It answers 503 otherwise, so
+// that load balancers route around the gateway while its upstream is down or
+// still loading.
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	resp := readyzJSON{Status: "ready"}
+	ready := true
+
+	if s.health != nil {
+		resp.Upstream = s.upstreamReady(r.Context())
+		ready = resp.Upstream.Status == healthpb.HealthCheckResponse_SERVING.String()
+	}
+
+	ds, err := s.fetchDataset(r.Context())
+	switch {
+	case err != nil:
+		resp.Dataset = datasetReadyJSON{Status: "unavailable", Error: status.Code(err).String()}
+		ready = false
+	default:
+		resp.Dataset = datasetReadyJSON{
+			Status:   "ok",
+			Version:  ds.GetVersion(),
+			RowCount: ds.GetRowCount(),
+			Checksum: ds.GetChecksum(),
+		}
+		if ds.GetUpdateTime().CheckValid() == nil {
+			resp.Dataset.UpdateTime = formatTime(ds.GetUpdateTime().AsTime())
+			resp.Dataset.AgeSeconds = time.Since(ds.GetUpdateTime().AsTime()).Seconds()
+		}
+		if ds.GetRowCount() == 0 {
+			resp.Dataset.Status = "empty"
+			ready = false
+		}
+	}
+
+	if bad := s.keys.mismatch(); len(bad) > 0 {
+		resp.PageTokenKey = &pageTokenKeyJSON{Status: "mismatch", Backends: bad}
+		ready = false
+	}
+
+	code := http.StatusOK
+	if !ready {
+		resp.Status = "not_ready"
+		code = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	_ = writeJSON(w, code, resp)
+}
+
+// upstreamReady checks the health of the upstream server as a whole.
+func (s *Server) upstreamReady(ctx context.Context) *upstreamReadyJSON {
+	ctx, cancel := context.WithTimeout(ctx, datasetTimeout)
+	defer cancel()
+	grpcStart := time.Now()
+	resp, err := s.health.Check(ctx, &healthpb.HealthCheckRequest{})
+	observeUpstreamGRPC("Check", status.Code(err).String(), time.Since(grpcStart))
+	if err != nil {
+		return &upstreamReadyJSON{Status: "UNREACHABLE", Error: status.Code(err).String()}
+	}
+	return &upstreamReadyJSON{Status: resp.GetStatus().String()}
+}
@@ -1,20 +1,31 @@
 package httpserver
 
 import (
+	"bytes"
 	"context"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"io"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
 	meterusagev1 "github.com/milad/spectral/gen/go/proto/meterusage/v1"
 	"github.com/milad/spectral/internal/domain"
+	"github.com/milad/spectral/internal/greenbutton"
 	"github.com/milad/spectral/internal/repo/csvrepo"
 	"github.com/milad/spectral/internal/service"
+	"github.com/milad/spectral/internal/telemetry"
 	grpcserver "github.com/milad/spectral/internal/transport/grpc"
+	"github.com/parquet-go/parquet-go"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/test/bufconn"
@@ -125,8 +136,8 @@ func TestHTTP_ToGRPC_EndToEnd_CursorPagination(t *testing.T) {
 	if got, want := len(page1.Readings), 2; got != want {
 		t.Fatalf("len=%d want %d", got, want)
 	}
-	if page1.NextPageToken != "2019-01-01T00:30:00Z" {
-		t.Fatalf("nextPageToken=%q want %q", page1.NextPageToken, "2019-01-01T00:30:00Z")
+	if page1.NextPageToken == "" {
+		t.Fatalf("expected nextPageToken")
 	}
 
 	rr2 := httptest.NewRecorder()
@@ -154,3 +165,337 @@ func TestHTTP_ToGRPC_EndToEnd_CursorPagination(t *testing.T) {
 		t.Fatalf("expected empty nextPageToken, got %q", page2.NextPageToken)
 	}
 }
+
+func TestHTTP_ToGRPC_EndToEnd_StreamNDJSON(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	var readings []domain.Reading
+	for i := 0; i < 5; i++ {
+		readings = append(readings, domain.Reading{Time: base.Add(time.Duration(i+1) * 15 * time.Minute), MeterUsage: float64(i)})
+	}
+	svc := service.NewMeterUsageService(csvrepo.New(readings))
+	api := grpcserver.New(svc)
+
+	lis := bufconn.Listen(1024 * 1024)
+	g := grpc.NewServer()
+	meterusagev1.RegisterMeterUsageServiceServer(g, api)
+	go func() { _ = g.Serve(lis) }()
+	t.Cleanup(g.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	httpSrv := New(meterusagev1.NewMeterUsageServiceClient(conn))
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/readings/stream?chunk_size=2", nil)
+	httpSrv.ServeHTTP(rr, req)
+
+	if got, want := rr.Code, http.StatusOK; got != want {
+		t.Fatalf("status=%d want %d, body=%s", got, want, rr.Body.String())
+	}
+	if got, want := rr.Header().Get("Content-Type"), "application/x-ndjson"; got != want {
+		t.Fatalf("content-type=%q want %q", got, want)
+	}
+	if !rr.Flushed {
+		t.Fatalf("expected response to be flushed")
+	}
+
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	if got, want := len(lines), 5; got != want {
+		t.Fatalf("lines=%d want %d: %s", got, want, rr.Body.String())
+	}
+	for i, line := range lines {
+		var r readingJSON
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("line %d: unmarshal: %v", i, err)
+		}
+		if r.MeterUsage != float64(i) {
+			t.Fatalf("line %d: meterUsage=%v want %v", i, r.MeterUsage, float64(i))
+		}
+	}
+
+	// Validation errors surface before any line is written, as regular API errors.
+	rr = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/api/readings/stream?chunk_size=1000000", nil)
+	httpSrv.ServeHTTP(rr, req)
+	if got, want := rr.Code, http.StatusBadRequest; got != want {
+		t.Fatalf("status=%d want %d, body=%s", got, want, rr.Body.String())
+	}
+}
+
+func TestHTTP_ToGRPC_EndToEnd_AppendThenList(t *testing.T) {
+	t.Parallel()
+
+	svc := service.NewMeterUsageService(csvrepo.New(nil))
+	api := grpcserver.New(svc)
+
+	lis := bufconn.Listen(1024 * 1024)
+	g := grpc.NewServer()
+	meterusagev1.RegisterMeterUsageServiceServer(g, api)
+	go func() { _ = g.Serve(lis) }()
+	t.Cleanup(g.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	httpSrv := New(meterusagev1.NewMeterUsageServiceClient(conn))
+
+	body := `{"readings": [
+		{"meterId": "site-a", "time": "2019-01-01T00:30:00Z", "meterUsage": 2.2},
+		{"meterId": "site-a", "time": "2019-01-01T00:15:00Z", "meterUsage": 1.1}
+	]}`
+	for i := 0; i < 2; i++ { // the second request is a retry
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/readings", strings.NewReader(body))
+		req.Header.Set("Idempotency-Key", "import-42")
+		httpSrv.ServeHTTP(rr, req)
+
+		if got, want := rr.Code, http.StatusOK; got != want {
+			t.Fatalf("status=%d want %d, body=%s", got, want, rr.Body.String())
+		}
+		var res appendReadingsResponseJSON
+		if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		if res.Accepted != 2 || len(res.Rejected) != 0 || res.Replayed != (i == 1) {
+			t.Fatalf("attempt %d: unexpected response %+v", i, res)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	httpSrv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/readings", nil))
+	var got listReadingsResponseJSON
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(got.Readings) != 2 || got.Readings[0].Time != "2019-01-01T00:15:00Z" || got.Readings[1].MeterID != "site-a" {
+		t.Fatalf("unexpected readings: %#v", got.Readings)
+	}
+}
+
+func TestHTTP_ToGRPC_EndToEnd_AppendKeyCoversInvalidRows(t *testing.T) {
+	t.Parallel()
+
+	svc := service.NewMeterUsageService(csvrepo.New(nil))
+	api := grpcserver.New(svc)
+
+	lis := bufconn.Listen(1024 * 1024)
+	g := grpc.NewServer()
+	meterusagev1.RegisterMeterUsageServiceServer(g, api)
+	go func() { _ = g.Serve(lis) }()
+	t.Cleanup(g.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	httpSrv := New(meterusagev1.NewMeterUsageServiceClient(conn))
+	post := func(key, body string) int {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/readings", strings.NewReader(body))
+		req.Header.Set("Idempotency-Key", key)
+		httpSrv.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	for name, tc := range map[string]struct{ first, retry string }{
+		"invalid rows differ": {
+			first: `{"readings": [{"time": "2019-01-01T00:15:00Z", "meterUsage": 1}, {"time": "yesterday", "meterUsage": 2}]}`,
+			retry: `{"readings": [{"time": "2019-01-01T00:15:00Z", "meterUsage": 1}, {"time": "2019-01-01T00:30:00Z"}]}`,
+		},
+		"all rows invalid": {
+			first: `{"readings": [{"time": "yesterday", "meterUsage": 1}]}`,
+			retry: `{"readings": [{"time": "2019-01-01T00:15:00Z", "meterUsage": 1}]}`,
+		},
+	} {
+		if got, want := post(name, tc.first), http.StatusOK; got != want {
+			t.Fatalf("%s: status=%d want %d", name, got, want)
+		}
+		if got, want := post(name, tc.first), http.StatusOK; got != want {
+			t.Fatalf("%s: replay status=%d want %d", name, got, want)
+		}
+		if got, want := post(name, tc.retry), http.StatusConflict; got != want {
+			t.Fatalf("%s: retry status=%d want %d", name, got, want)
+		}
+	}
+}
+
+func TestHTTP_ToGRPC_EndToEnd_ExportFormats(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	var readings []domain.Reading
+	for i := 0; i < 5; i++ {
+		readings = append(readings, domain.Reading{MeterID: "m", Time: base.Add(time.Duration(i) * 15 * time.Minute), MeterUsage: float64(i) + 0.5})
+	}
+	svc := service.NewMeterUsageService(csvrepo.New(readings))
+	api := grpcserver.New(svc)
+
+	lis := bufconn.Listen(1024 * 1024)
+	g := grpc.NewServer()
+	meterusagev1.RegisterMeterUsageServiceServer(g, api)
+	go func() { _ = g.Serve(lis) }()
+	t.Cleanup(g.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	httpSrv := New(meterusagev1.NewMeterUsageServiceClient(conn))
+
+	// page_size=2 makes the export walk three upstream pages.
+	get := func(query, accept string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/readings?page_size=2"+query, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rr := httptest.NewRecorder()
+		httpSrv.ServeHTTP(rr, req)
+		if got, want := rr.Code, http.StatusOK; got != want {
+			t.Fatalf("status=%d want %d, body=%s", got, want, rr.Body.String())
+		}
+		return rr
+	}
+
+	rr := get("&format=csv", "")
+	if got, want := rr.Header().Get("Content-Type"), "text/csv"; got != want {
+		t.Fatalf("content-type=%q want %q", got, want)
+	}
+	rows, err := csv.NewReader(rr.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("read csv: %v", err)
+	}
+	if got, want := len(rows), 6; got != want {
+		t.Fatalf("csv rows=%d want %d (header + 5)", got, want)
+	}
+	if got, want := strings.Join(rows[0], ","), "time,meter_id,meterusage"; got != want {
+		t.Fatalf("header=%q want %q", got, want)
+	}
+	if got, want := strings.Join(rows[5], ","), "2019-01-01T01:00:00Z,m,4.5"; got != want {
+		t.Fatalf("last row=%q want %q", got, want)
+	}
+
+	rr = get("", "application/x-ndjson")
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	if got, want := len(lines), 5; got != want {
+		t.Fatalf("ndjson lines=%d want %d: %s", got, want, rr.Body.String())
+	}
+
+	rr = get("", "application/vnd.apache.parquet")
+	body := rr.Body.Bytes()
+	pr := parquet.NewGenericReader[parquetReading](bytes.NewReader(body))
+	defer pr.Close()
+	got := make([]parquetReading, 10)
+	n, err := pr.Read(got)
+	if err != nil && !errors.Is(err, io.EOF) {
+		t.Fatalf("read parquet: %v", err)
+	}
+	if n != 5 {
+		t.Fatalf("parquet rows=%d want 5", n)
+	}
+	for i, r := range got[:n] {
+		if !r.Time.Equal(readings[i].Time) || r.MeterID != "m" || r.MeterUsage != readings[i].MeterUsage {
+			t.Fatalf("parquet row %d = %+v, want %+v", i, r, readings[i])
+		}
+	}
+
+	rr = get("&tz=America/New_York", "application/atom+xml")
+	if got, want := rr.Header().Get("Content-Disposition"), `attachment; filename="readings.xml"`; got != want {
+		t.Fatalf("content-disposition=%q want %q", got, want)
+	}
+	if !strings.Contains(rr.Body.String(), "<tzOffset>-18000</tzOffset>") {
+		t.Fatalf("feed without the LocalTimeParameters of tz: %s", rr.Body.String())
+	}
+	fed, err := greenbutton.Parse(rr.Body)
+	if err != nil {
+		t.Fatalf("parse green button: %v", err)
+	}
+	if got, want := len(fed), 5; got != want {
+		t.Fatalf("green button readings=%d want %d", got, want)
+	}
+	for i, r := range fed {
+		if !r.Time.Equal(readings[i].Time) || r.MeterID != "m" || r.MeterUsage != readings[i].MeterUsage {
+			t.Fatalf("green button reading %d = %+v, want %+v", i, r, readings[i])
+		}
+	}
+}
+
+func TestHTTP_ToGRPC_EndToEnd_PropagatesTraceAndRequestID(t *testing.T) {
+	t.Parallel()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	repo := csvrepo.New([]domain.Reading{
+		{Time: time.Date(2019, 1, 1, 0, 15, 0, 0, time.UTC), MeterUsage: 1.1},
+	})
+	api := grpcserver.New(service.NewMeterUsageService(repo))
+
+	var gotReqID, gotTraceID string
+	record := func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		gotReqID, gotTraceID = telemetry.RequestIDFromContext(ctx), telemetry.TraceID(ctx)
+		return handler(ctx, req)
+	}
+
+	lis := bufconn.Listen(1024 * 1024)
+	g := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(telemetry.UnaryServerRequestID(), record),
+	)
+	meterusagev1.RegisterMeterUsageServiceServer(g, api)
+	go func() { _ = g.Serve(lis) }()
+	t.Cleanup(g.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithChainUnaryInterceptor(telemetry.UnaryClientRequestID()),
+	)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	httpSrv := New(meterusagev1.NewMeterUsageServiceClient(conn))
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/api/readings", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	rr := httptest.NewRecorder()
+	httpSrv.ServeHTTP(rr, req)
+
+	if got, want := rr.Code, http.StatusOK; got != want {
+		t.Fatalf("status=%d want %d, body=%s", got, want, rr.Body.String())
+	}
+	if got, want := gotReqID, rr.Header().Get("X-Request-Id"); got == "" || got != want {
+		t.Fatalf("upstream request id=%q want %q", got, want)
+	}
+	if got, want := gotTraceID, traceID; got != want {
+		t.Fatalf("upstream trace id=%q want %q", got, want)
+	}
+}
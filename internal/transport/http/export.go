@@ -0,0 +1,285 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	meterusagev1 "github.com/milad/spectral/gen/go/proto/meterusage/v1"
+	"github.com/milad/spectral/internal/greenbutton"
+	"github.com/milad/spectral/internal/logging"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// exportPageSize is the upstream page size used when exporting, unless the
+// request sets page_size.
+const exportPageSize = 5_000
+
+type exportFormat struct {
+	name        string // value of the format query param
+	contentType string
+	extension   string
+	newWriter   func(w io.Writer, loc *time.Location) rowWriter
+}
+
+// exportFormats are listed in order of preference for Accept: */*. JSON has no
+// writer: it keeps the paged response shape of handleListReadings.
+var exportFormats = []exportFormat{
+	{name: "json", contentType: "application/json"},
+	{name: "csv", contentType: "text/csv", extension: "csv", newWriter: newCSVRowWriter},
+	{name: "ndjson", contentType: "application/x-ndjson", extension: "ndjson", newWriter: newNDJSONRowWriter},
+	{name: "parquet", contentType: "application/vnd.apache.parquet", extension: "parquet", newWriter: newParquetRowWriter},
+	{name: "greenbutton", contentType: "application/atom+xml", extension: "xml", newWriter: newGreenButtonRowWriter},
+}
+
+// rowWriter encodes readings for an export.
+type rowWriter interface {
+	WriteReading(meterID string, t time.Time, meterUsage float64) error
+	// EndPage is called after every upstream page, so the encoder can flush
+	// (or, for Parquet, close a row group).
+	EndPage() error
+	// Close completes the file after the last page.
+	Close() error
+}
+
+// negotiateFormat picks the response format from the `format` query param or,
+// if that is absent, the Accept header. ok is false (and an error has been
+// written) if no supported format was acceptable.
+func negotiateFormat(w http.ResponseWriter, r *http.Request) (exportFormat, bool) {
+	if name := r.URL.Query().Get("format"); name != "" {
+		for _, f := range exportFormats {
+			if f.name == name {
+				return f, true
+			}
+		}
+		writeAPIError(w, http.StatusBadRequest, "invalid_argument", "format must be one of json, csv, ndjson, parquet, greenbutton")
+		return exportFormat{}, false
+	}
+
+	accept := r.Header.Values("Accept")
+	if len(accept) == 0 {
+		return exportFormats[0], true
+	}
+	for _, mediaType := range acceptedMediaTypes(strings.Join(accept, ",")) {
+		for _, f := range exportFormats {
+			if mediaTypeMatches(mediaType, f.contentType) {
+				return f, true
+			}
+		}
+	}
+	writeAPIError(w, http.StatusNotAcceptable, "not_acceptable",
+		"supported media types: application/json, text/csv, application/x-ndjson, application/vnd.apache.parquet, application/atom+xml")
+	return exportFormat{}, false
+}
+
+// acceptedMediaTypes returns the media ranges of an Accept header, most
+// preferred first, without those with q=0.
+func acceptedMediaTypes(header string) []string {
+	type ranged struct {
+		mediaType string
+		q         float64
+	}
+	var ranges []ranged
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, ranged{mediaType: mediaType, q: q})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+
+	out := make([]string, 0, len(ranges))
+	for _, r := range ranges {
+		out = append(out, r.mediaType)
+	}
+	return out
+}
+
+func mediaTypeMatches(mediaRange, contentType string) bool {
+	if mediaRange == "*/*" || mediaRange == contentType {
+		return true
+	}
+	typ, _, _ := strings.Cut(contentType, "/")
+	return mediaRange == typ+"/*"
+}
+
+// exportReadings writes all readings in [start, end) in the given format,
+// fetching them page by page from the upstream so that memory use does not
+// grow with the size of the range.
+//
+// If the upstream fails after the response has started, NDJSON ends with an
+// `error` line (as for /api/readings/stream); CSV, Parquet and Green Button
+// have no way to carry an error, so the connection is aborted to make the
+// truncation visible.
+func (s *Server) exportReadings(w http.ResponseWriter, r *http.Request, f exportFormat, req *meterusagev1.ListReadingsRequest, loc *time.Location) {
+	if req.GetPageToken() != "" {
+		writeAPIError(w, http.StatusBadRequest, "invalid_argument", "page_token is not supported for "+f.name+" exports, which include all pages")
+		return
+	}
+	if req.GetPageSize() == 0 {
+		req.PageSize = exportPageSize
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeouts.Stream)
+	defer cancel()
+
+	rc := http.NewResponseController(w)
+	var rw rowWriter
+	for {
+		grpcStart := time.Now()
+		pageCtx, pageCancel := context.WithTimeout(ctx, s.timeouts.Upstream)
+		resp, err := s.client.ListReadings(pageCtx, req)
+		pageCancel()
+		grpcDur := time.Since(grpcStart)
+		if err != nil {
+			if rw == nil {
+				writeUpstreamError(w, "ListReadings", err, grpcDur)
+				return
+			}
+			observeUpstreamGRPC("ListReadings", status.Code(err).String(), grpcDur)
+			abortExport(ctx, w, f, rw, "upstream error", err)
+			return
+		}
+		observeUpstreamGRPC("ListReadings", codes.OK.String(), grpcDur)
+
+		if rw == nil {
+			w.Header().Set("Content-Type", f.contentType)
+			w.Header().Set("Content-Disposition", `attachment; filename="readings.`+f.extension+`"`)
+			w.WriteHeader(http.StatusOK)
+			rw = f.newWriter(w, loc)
+		}
+		_ = rc.SetWriteDeadline(time.Now().Add(s.timeouts.StreamWrite))
+		for _, rr := range resp.GetReadings() {
+			if err := rr.GetTime().CheckValid(); err != nil {
+				abortExport(ctx, w, f, rw, "upstream returned invalid timestamp", err)
+				return
+			}
+			if err := rw.WriteReading(rr.GetMeterId(), rr.GetTime().AsTime(), rr.GetMeterUsage()); err != nil {
+				return // client went away
+			}
+		}
+		if err := rw.EndPage(); err != nil {
+			return
+		}
+		_ = rc.Flush()
+
+		if resp.GetNextPageToken() == "" {
+			break
+		}
+		req.PageToken = resp.GetNextPageToken()
+	}
+	if err := rw.Close(); err != nil {
+		return
+	}
+	_ = rc.Flush()
+}
+
+func abortExport(ctx context.Context, w http.ResponseWriter, f exportFormat, rw rowWriter, message string, err error) {
+	reqID := w.Header().Get("X-Request-Id")
+	slog.WarnContext(ctx, "export aborted", "format", f.name, "reason", message, logging.Err(err))
+	if nd, ok := rw.(*ndjsonRowWriter); ok {
+		_ = nd.enc.Encode(streamErrorJSON{Error: apiErrorJSON{
+			Code:      "upstream_error",
+			Message:   message,
+			RequestID: reqID,
+		}})
+		return
+	}
+	panic(http.ErrAbortHandler)
+}
+
+// listReadingsRequest builds the upstream request shared by JSON listing and exports.
+func listReadingsRequest(start, end *time.Time, meterIDs []string, pageSize int, pageToken string) *meterusagev1.ListReadingsRequest {
+	req := &meterusagev1.ListReadingsRequest{
+		PageSize:  int32(pageSize),
+		PageToken: pageToken,
+		MeterIds:  meterIDs,
+	}
+	if start != nil {
+		req.Start = timestamppb.New(*start)
+	}
+	if end != nil {
+		req.End = timestamppb.New(*end)
+	}
+	return req
+}
+
+type csvRowWriter struct {
+	w      *csv.Writer
+	loc    *time.Location
+	header bool
+}
+
+func newCSVRowWriter(w io.Writer, loc *time.Location) rowWriter {
+	return &csvRowWriter{w: csv.NewWriter(w), loc: loc}
+}
+
+// writeHeader writes the header row once, so that even an empty export is
+// self-describing.
+func (c *csvRowWriter) writeHeader() error {
+	if c.header {
+		return nil
+	}
+	c.header = true
+	return c.w.Write([]string{"time", "meter_id", "meterusage"})
+}
+
+func (c *csvRowWriter) WriteReading(meterID string, t time.Time, meterUsage float64) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	return c.w.Write([]string{formatTimeIn(t, c.loc), meterID, strconv.FormatFloat(meterUsage, 'f', -1, 64)})
+}
+
+func (c *csvRowWriter) EndPage() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvRowWriter) Close() error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	return c.EndPage()
+}
+
+type ndjsonRowWriter struct {
+	enc *json.Encoder
+	loc *time.Location
+}
+
+func newNDJSONRowWriter(w io.Writer, loc *time.Location) rowWriter {
+	return &ndjsonRowWriter{enc: json.NewEncoder(w), loc: loc}
+}
+
+func (n *ndjsonRowWriter) WriteReading(meterID string, t time.Time, meterUsage float64) error {
+	return n.enc.Encode(readingJSON{MeterID: meterID, Time: formatTimeIn(t, n.loc), MeterUsage: meterUsage})
+}
+
+func (n *ndjsonRowWriter) EndPage() error { return nil }
+func (n *ndjsonRowWriter) Close() error   { return nil }
+
+// newGreenButtonRowWriter renders a Green Button (ESPI) feed; loc sets its
+// LocalTimeParameters.
+func newGreenButtonRowWriter(w io.Writer, loc *time.Location) rowWriter {
+	return greenbutton.NewWriter(w, loc)
+}
@@ -11,6 +11,13 @@ import (
 // MeterUsageClient is the small subset of the gRPC client we need, to keep tests simple.
 type MeterUsageClient interface {
 	ListReadings(ctx context.Context, in *meterusagev1.ListReadingsRequest, opts ...grpc.CallOption) (*meterusagev1.ListReadingsResponse, error)
+	AggregateReadings(ctx context.Context, in *meterusagev1.AggregateReadingsRequest, opts ...grpc.CallOption) (*meterusagev1.AggregateReadingsResponse, error)
+	StreamReadings(ctx context.Context, in *meterusagev1.StreamReadingsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[meterusagev1.StreamReadingsResponse], error)
+	AppendReadings(ctx context.Context, in *meterusagev1.AppendReadingsRequest, opts ...grpc.CallOption) (*meterusagev1.AppendReadingsResponse, error)
+	ListMeters(ctx context.Context, in *meterusagev1.ListMetersRequest, opts ...grpc.CallOption) (*meterusagev1.ListMetersResponse, error)
+	GetDataset(ctx context.Context, in *meterusagev1.GetDatasetRequest, opts ...grpc.CallOption) (*meterusagev1.GetDatasetResponse, error)
+	GetIngestionReport(ctx context.Context, in *meterusagev1.GetIngestionReportRequest, opts ...grpc.CallOption) (*meterusagev1.GetIngestionReportResponse, error)
+	GetQualityReport(ctx context.Context, in *meterusagev1.GetQualityReportRequest, opts ...grpc.CallOption) (*meterusagev1.GetQualityReportResponse, error)
 }
 
 func parseOptionalRFC3339(v string) (*time.Time, error) {
@@ -29,3 +36,22 @@ func parseOptionalRFC3339(v string) (*time.Time, error) {
 	tt := t.UTC()
 	return &tt, nil
 }
+
+// localTimeLayouts are accepted by parseOptionalTime in addition to RFC3339.
+var localTimeLayouts = []string{"2006-01-02T15:04:05", "2006-01-02"}
+
+// parseOptionalTime parses an RFC3339 instant or a local date/date-time
+// without an offset, which is interpreted in loc. The result is in UTC.
+func parseOptionalTime(v string, loc *time.Location) (*time.Time, error) {
+	t, err := parseOptionalRFC3339(v)
+	if err == nil {
+		return t, nil
+	}
+	for _, layout := range localTimeLayouts {
+		if lt, lerr := time.ParseInLocation(layout, v, loc); lerr == nil {
+			lt = lt.UTC()
+			return &lt, nil
+		}
+	}
+	return nil, err
+}
@@ -1,10 +1,13 @@
 package httpserver
 
 import (
+	"cmp"
 	"context"
 	"crypto/rand"
 	"encoding/hex"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"runtime/debug"
 	"strconv"
@@ -12,34 +15,109 @@ import (
 	"time"
 
 	meterusagev1 "github.com/milad/spectral/gen/go/proto/meterusage/v1"
+	"github.com/milad/spectral/internal/auth"
+	"github.com/milad/spectral/internal/logging"
+	"github.com/milad/spectral/internal/upstream"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/status"
-	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type Server struct {
-	client MeterUsageClient
-	mux    *http.ServeMux
+	client    MeterUsageClient
+	mux       *http.ServeMux
+	auth      auth.Authenticator    // nil: API is unauthenticated
+	limiter   *rateLimiter          // nil: no rate limits
+	accessLog *logging.Sampler      // nil: every request is logged
+	cache     *responseCache        // nil: responses are not cached
+	health    healthpb.HealthClient // nil: /readyz only checks the dataset
+	keys      *pageTokenKeys
+	timeouts  Timeouts
 }
 
-func New(client MeterUsageClient) *Server {
+// Timeouts bounds the upstream calls and writes made for a request.
+type Timeouts struct {
+	// Upstream bounds each unary gRPC call.
+	Upstream time.Duration
+	// Stream bounds a whole streaming response or export; it replaces
+	// Upstream there.
+	Stream time.Duration
+	// StreamWrite is re-armed before every chunk of a streaming response so
+	// that long streams are not cut off by the server-wide WriteTimeout.
+	StreamWrite time.Duration
+}
+
+// DefaultTimeouts returns the timeouts used unless WithTimeouts is given.
+func DefaultTimeouts() Timeouts {
+	return Timeouts{
+		Upstream:    5 * time.Second,
+		Stream:      10 * time.Minute,
+		StreamWrite: 15 * time.Second,
+	}
+}
+
+func New(client MeterUsageClient, opts ...Option) *Server {
 	s := &Server{
-		client: client,
-		mux:    http.NewServeMux(),
+		client:   client,
+		mux:      http.NewServeMux(),
+		keys:     newPageTokenKeys(time.Now),
+		timeouts: DefaultTimeouts(),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
 	s.routes()
 	return s
 }
 
+// WithAccessLogSampler logs only the successful requests kept by sampler.
+// Failed requests are always logged.
+func WithAccessLogSampler(sampler *logging.Sampler) Option {
+	return func(s *Server) { s.accessLog = sampler }
+}
+
+// WithTimeouts replaces the default timeouts. Zero fields keep their default.
+func WithTimeouts(t Timeouts) Option {
+	return func(s *Server) {
+		d := DefaultTimeouts()
+		s.timeouts = Timeouts{
+			Upstream:    cmp.Or(t.Upstream, d.Upstream),
+			Stream:      cmp.Or(t.Stream, d.Stream),
+			StreamWrite: cmp.Or(t.StreamWrite, d.StreamWrite),
+		}
+	}
+}
+
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
 	reqID := newRequestID()
 
 	w.Header().Set("X-Request-Id", reqID)
 	rr := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	principal := "-"
+	r, span := startServerSpan(r, reqID)
 	defer func() {
-		if rec := recover(); rec != nil {
+		ctx := r.Context()
+		rec := recover()
+		if rec == http.ErrAbortHandler {
+			// The handler gave up on a response it had started (see
+			// abortExport): record it and let net/http drop the connection, so
+			// the client sees the body cut short rather than cleanly ended.
+			dur := time.Since(start)
+			observeHTTPRequest(r, rr.status, dur)
+			abortServerSpan(span, rr.status)
+			slog.WarnContext(ctx, "http request aborted",
+				logging.KeyMethod, r.Method,
+				logging.KeyPath, r.URL.Path,
+				logging.KeyRoute, routeLabel(r.URL.Path),
+				logging.KeyStatus, rr.status,
+				logging.KeyDuration, float64(dur.Microseconds())/1000,
+				logging.KeyPrincipal, principal,
+			)
+			panic(rec)
+		}
+		if rec != nil {
 			rr.status = http.StatusInternalServerError
 
 			// Best-effort response. If headers/body were already written, we can
@@ -52,53 +130,96 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				}
 			}
 
-			log.Printf("panic handling %s %s req_id=%s: %v\n%s",
-				r.Method, r.URL.Path, reqID, rec, debug.Stack(),
+			slog.ErrorContext(ctx, "panic handling request",
+				logging.KeyMethod, r.Method,
+				logging.KeyPath, r.URL.Path,
+				logging.KeyPrincipal, principal,
+				"panic", fmt.Sprint(rec),
+				"stack", string(debug.Stack()),
 			)
 		}
 
 		dur := time.Since(start)
 		observeHTTPRequest(r, rr.status, dur)
+		endServerSpan(span, rr.status)
 
 		// Keep health checks + metrics endpoint quiet.
-		if r.URL.Path != "/healthz" && r.URL.Path != "/metrics" {
-			log.Printf("%s %s -> %d (%s) req_id=%s",
-				r.Method, r.URL.Path, rr.status, dur.Truncate(time.Millisecond), reqID,
+		if r.URL.Path != "/healthz" && r.URL.Path != "/readyz" && r.URL.Path != "/metrics" && s.accessLog.Keep(rr.status >= 400) {
+			slog.InfoContext(ctx, "http request",
+				logging.KeyMethod, r.Method,
+				logging.KeyPath, r.URL.Path,
+				logging.KeyRoute, routeLabel(r.URL.Path),
+				logging.KeyStatus, rr.status,
+				logging.KeyDuration, float64(dur.Microseconds())/1000,
+				logging.KeyPrincipal, principal,
 			)
 		}
 	}()
 
+	if s.auth != nil && requiresAuth(r.URL.Path) {
+		if s.limiter != nil && !s.rateLimitAuthAttempt(rr, r) {
+			return
+		}
+		p, ok := s.authenticate(rr, r)
+		if p.Subject != "" {
+			principal = p.Subject
+		}
+		if !ok {
+			if s.limiter != nil {
+				s.chargeFailedAuth(r)
+			}
+			return
+		}
+		r = r.WithContext(auth.WithPrincipal(r.Context(), p))
+	}
+	if s.limiter != nil && strings.HasPrefix(r.URL.Path, "/api/") && !s.rateLimit(rr, r) {
+		return
+	}
+
 	s.mux.ServeHTTP(rr, r)
 }
 
 func (s *Server) routes() {
-	s.mux.HandleFunc("/api/readings", s.handleListReadings)
+	s.mux.HandleFunc("/api/readings", s.handleReadings)
+	s.mux.HandleFunc("/api/readings/aggregate", s.handleAggregateReadings)
+	s.mux.HandleFunc("/api/readings/stream", s.handleStreamReadings)
+	s.mux.HandleFunc("/api/meters", s.handleListMeters)
+	s.mux.HandleFunc("/api/ingestion/report", s.handleIngestionReport)
+	s.mux.HandleFunc("/api/quality", s.handleQualityReport)
 	s.mux.HandleFunc("/healthz", s.handleHealthz)
+	s.mux.HandleFunc("/readyz", s.handleReadyz)
 	s.mux.Handle("/metrics", promhttp.Handler())
 	s.mux.HandleFunc("/", s.handleIndex)
 }
 
-// handleListReadings returns JSON readings filtered by [start, end) if provided.
-// Query params `start` and `end` must be RFC3339 (UTC recommended).
-func (s *Server) handleListReadings(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		w.Header().Set("Allow", http.MethodGet)
+func (s *Server) handleReadings(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.handleListReadings(w, r)
+	case http.MethodPost:
+		s.handleAppendReadings(w, r)
+	default:
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodPost)
 		writeAPIError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
-		return
 	}
+}
 
-	start, err := parseOptionalRFC3339(r.URL.Query().Get("start"))
-	if err != nil {
-		writeAPIError(w, http.StatusBadRequest, "invalid_argument", "invalid start")
-		return
-	}
-	end, err := parseOptionalRFC3339(r.URL.Query().Get("end"))
-	if err != nil {
-		writeAPIError(w, http.StatusBadRequest, "invalid_argument", "invalid end")
+// handleListReadings returns readings filtered by [start, end) if provided.
+// Query params `start` and `end` must be RFC3339 (UTC recommended). Readings can
+// be restricted to specific meters with `meter_id` (repeated or comma-separated).
+//
+// The response is paged JSON by default; CSV, NDJSON and Parquet (chosen with
+// `format` or the Accept header) are exported in full, see exportReadings.
+// JSON pages carry validators for conditional requests and may be served from
+// the cache, see WithCache.
+func (s *Server) handleListReadings(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Vary", "Accept")
+	start, end, loc, ok := parseTimeRange(w, r)
+	if !ok {
 		return
 	}
-	if start != nil && end != nil && !start.Before(*end) {
-		writeAPIError(w, http.StatusBadRequest, "invalid_argument", "invalid range: start must be before end")
+	format, ok := negotiateFormat(w, r)
+	if !ok {
 		return
 	}
 
@@ -117,77 +238,106 @@ func (s *Server) handleListReadings(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	req := &meterusagev1.ListReadingsRequest{}
-	if start != nil {
-		req.Start = timestamppb.New(*start)
-	}
-	if end != nil {
-		req.End = timestamppb.New(*end)
-	}
-	if pageSize != 0 {
-		req.PageSize = int32(pageSize)
+	req := listReadingsRequest(start, end, parseMeterIDs(r), pageSize, pageToken)
+	if format.newWriter != nil {
+		s.exportReadings(w, r, format, req, loc)
+		return
 	}
-	if pageToken != "" {
-		req.PageToken = pageToken
+
+	s.serveJSONPage(w, r, readingsPageKey(req, loc), func(ctx context.Context) (any, error) {
+		ctx, cancel := context.WithTimeout(ctx, s.timeouts.Upstream)
+		defer cancel()
+		grpcStart := time.Now()
+		resp, err := s.client.ListReadings(ctx, req)
+		grpcDur := time.Since(grpcStart)
+		observeUpstreamGRPC("ListReadings", status.Code(err).String(), grpcDur)
+		if err != nil {
+			return nil, err
+		}
+
+		out := make([]readingJSON, 0, len(resp.GetReadings()))
+		for _, rr := range resp.GetReadings() {
+			ts := rr.GetTime()
+			if ts == nil {
+				return nil, invalidUpstreamReply("upstream returned invalid reading")
+			}
+			if err := ts.CheckValid(); err != nil {
+				return nil, invalidUpstreamReply("upstream returned invalid timestamp")
+			}
+			out = append(out, readingJSON{
+				MeterID:    rr.GetMeterId(),
+				Time:       formatTimeIn(ts.AsTime(), loc),
+				MeterUsage: rr.GetMeterUsage(),
+				Quality:    qualityNames(rr.GetQualityFlags()),
+			})
+		}
+		return listReadingsResponseJSON{
+			Readings:      out,
+			NextPageToken: resp.GetNextPageToken(),
+		}, nil
+	})
+}
+
+// handleListMeters returns the meters known to the backend.
+func (s *Server) handleListMeters(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeAPIError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		return
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeouts.Upstream)
 	defer cancel()
 	grpcStart := time.Now()
-	resp, err := s.client.ListReadings(ctx, req)
+	resp, err := s.client.ListMeters(ctx, &meterusagev1.ListMetersRequest{})
 	grpcDur := time.Since(grpcStart)
 	if err != nil {
-		code := codes.Unknown.String()
-		if st, ok := status.FromError(err); ok {
-			code = st.Code().String()
-			if st.Code() == codes.InvalidArgument {
-				observeUpstreamGRPC("ListReadings", code, grpcDur)
-				writeAPIError(w, http.StatusBadRequest, "invalid_argument", st.Message())
-				return
-			}
-			if st.Code() == codes.DeadlineExceeded {
-				observeUpstreamGRPC("ListReadings", code, grpcDur)
-				writeAPIError(w, http.StatusGatewayTimeout, "upstream_timeout", "upstream timeout")
-				return
-			}
-		}
-		observeUpstreamGRPC("ListReadings", code, grpcDur)
-		writeAPIError(w, http.StatusBadGateway, "upstream_error", "upstream error")
+		writeUpstreamError(w, "ListMeters", err, grpcDur)
 		return
 	}
-	observeUpstreamGRPC("ListReadings", codes.OK.String(), grpcDur)
+	observeUpstreamGRPC("ListMeters", codes.OK.String(), grpcDur)
 
-	out := make([]readingJSON, 0, len(resp.GetReadings()))
-	for _, rr := range resp.GetReadings() {
-		ts := rr.GetTime()
-		if ts == nil {
-			writeAPIError(w, http.StatusBadGateway, "upstream_error", "upstream returned invalid reading")
-			return
-		}
-		if err := ts.CheckValid(); err != nil {
+	out := make([]meterJSON, 0, len(resp.GetMeters()))
+	for _, m := range resp.GetMeters() {
+		if m.GetFirstReadingTime().CheckValid() != nil || m.GetLastReadingTime().CheckValid() != nil {
 			writeAPIError(w, http.StatusBadGateway, "upstream_error", "upstream returned invalid timestamp")
 			return
 		}
-		t := ts.AsTime()
-		out = append(out, readingJSON{
-			Time:       formatTime(t),
-			MeterUsage: rr.GetMeterUsage(),
+		out = append(out, meterJSON{
+			ID:               m.GetId(),
+			ReadingCount:     m.GetReadingCount(),
+			FirstReadingTime: formatTime(m.GetFirstReadingTime().AsTime()),
+			LastReadingTime:  formatTime(m.GetLastReadingTime().AsTime()),
 		})
 	}
 
-	_ = writeJSON(w, http.StatusOK, listReadingsResponseJSON{
-		Readings:      out,
-		NextPageToken: resp.GetNextPageToken(),
-	})
+	_ = writeJSON(w, http.StatusOK, listMetersResponseJSON{Meters: out})
 }
 
-func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		w.Header().Set("Allow", http.MethodGet)
-		w.WriteHeader(http.StatusMethodNotAllowed)
+// writeUpstreamError records a failed upstream call and maps it to an API error.
+func writeUpstreamError(w http.ResponseWriter, method string, err error, dur time.Duration) {
+	observeUpstreamGRPC(method, status.Code(err).String(), dur)
+	writeUpstreamStatus(w, err)
+}
+
+// writeUpstreamStatus maps the error of an upstream call to an API error.
+func writeUpstreamStatus(w http.ResponseWriter, err error) {
+	if errors.Is(err, upstream.ErrCircuitOpen) {
+		writeAPIError(w, http.StatusServiceUnavailable, "upstream_unavailable", "upstream unavailable")
 		return
 	}
-	w.WriteHeader(http.StatusOK)
+	switch status.Code(err) {
+	case codes.InvalidArgument:
+		writeAPIError(w, http.StatusBadRequest, "invalid_argument", status.Convert(err).Message())
+	case codes.DeadlineExceeded:
+		writeAPIError(w, http.StatusGatewayTimeout, "upstream_timeout", "upstream timeout")
+	case codes.AlreadyExists:
+		writeAPIError(w, http.StatusConflict, "conflict", status.Convert(err).Message())
+	case codes.Unimplemented:
+		writeAPIError(w, http.StatusNotImplemented, "not_implemented", status.Convert(err).Message())
+	default:
+		writeAPIError(w, http.StatusBadGateway, "upstream_error", "upstream error")
+	}
 }
 
 func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
@@ -228,6 +378,12 @@ func (r *statusRecorder) Write(p []byte) (int, error) {
 	return r.ResponseWriter.Write(p)
 }
 
+// Unwrap lets http.ResponseController reach the underlying writer (e.g. for
+// flushing streamed responses).
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
 func newRequestID() string {
 	var b [6]byte // 12 hex chars
 	if _, err := rand.Read(b[:]); err != nil {
@@ -245,6 +401,54 @@ func writeAPIError(w http.ResponseWriter, status int, code, message string) {
 	})
 }
 
+// parseTimeRange parses the optional `start`, `end` and `tz` query params,
+// writing an API error and returning ok=false if they are invalid.
+//
+// `tz` is an IANA time zone name (default UTC). Bounds may be RFC3339 instants
+// or local dates/date-times without an offset (`2019-01-01`,
+// `2019-01-01T06:00:00`), which are interpreted in tz. Responses render times
+// in tz.
+func parseTimeRange(w http.ResponseWriter, r *http.Request) (start, end *time.Time, loc *time.Location, ok bool) {
+	loc = time.UTC
+	if tz := r.URL.Query().Get("tz"); tz != "" {
+		var err error
+		// "Local" would silently depend on the server's configuration.
+		if loc, err = time.LoadLocation(tz); err != nil || tz == "Local" {
+			writeAPIError(w, http.StatusBadRequest, "invalid_argument", "invalid tz")
+			return nil, nil, nil, false
+		}
+	}
+	start, err := parseOptionalTime(r.URL.Query().Get("start"), loc)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_argument", "invalid start")
+		return nil, nil, nil, false
+	}
+	end, err = parseOptionalTime(r.URL.Query().Get("end"), loc)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_argument", "invalid end")
+		return nil, nil, nil, false
+	}
+	if start != nil && end != nil && !start.Before(*end) {
+		writeAPIError(w, http.StatusBadRequest, "invalid_argument", "invalid range: start must be before end")
+		return nil, nil, nil, false
+	}
+	return start, end, loc, true
+}
+
+// parseMeterIDs collects `meter_id` query params, which may be repeated and/or
+// comma-separated. It returns nil if none are set.
+func parseMeterIDs(r *http.Request) []string {
+	var ids []string
+	for _, v := range r.URL.Query()["meter_id"] {
+		for _, id := range strings.Split(v, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
 func parseOptionalInt(v string) (int, error) {
 	if v == "" {
 		return 0, nil
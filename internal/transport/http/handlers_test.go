@@ -3,15 +3,24 @@ package httpserver
 import (
 	"context"
 	"encoding/json"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strings"
 	"testing"
 	"time"
+	_ "time/tzdata" // tests must not depend on the host's zoneinfo
 
 	meterusagev1 "github.com/milad/spectral/gen/go/proto/meterusage/v1"
+	"github.com/milad/spectral/internal/upstream"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -19,6 +28,22 @@ type fakeClient struct {
 	resp *meterusagev1.ListReadingsResponse
 	err  error
 	req  *meterusagev1.ListReadingsRequest
+
+	aggResp *meterusagev1.AggregateReadingsResponse
+	aggReq  *meterusagev1.AggregateReadingsRequest
+
+	metersResp *meterusagev1.ListMetersResponse
+
+	appendResp *meterusagev1.AppendReadingsResponse
+	appendReq  *meterusagev1.AppendReadingsRequest
+
+	datasetResp *meterusagev1.GetDatasetResponse
+
+	ingestionResp *meterusagev1.GetIngestionReportResponse
+	ingestionReq  *meterusagev1.GetIngestionReportRequest
+
+	qualityResp *meterusagev1.GetQualityReportResponse
+	qualityReq  *meterusagev1.GetQualityReportRequest
 }
 
 func (f *fakeClient) ListReadings(ctx context.Context, in *meterusagev1.ListReadingsRequest, _ ...grpc.CallOption) (*meterusagev1.ListReadingsResponse, error) {
@@ -26,6 +51,44 @@ func (f *fakeClient) ListReadings(ctx context.Context, in *meterusagev1.ListRead
 	return f.resp, f.err
 }
 
+func (f *fakeClient) AggregateReadings(ctx context.Context, in *meterusagev1.AggregateReadingsRequest, _ ...grpc.CallOption) (*meterusagev1.AggregateReadingsResponse, error) {
+	f.aggReq = in
+	return f.aggResp, f.err
+}
+
+func (f *fakeClient) StreamReadings(ctx context.Context, in *meterusagev1.StreamReadingsRequest, _ ...grpc.CallOption) (grpc.ServerStreamingClient[meterusagev1.StreamReadingsResponse], error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return nil, status.Error(codes.Unimplemented, "not implemented by fake")
+}
+
+func (f *fakeClient) AppendReadings(ctx context.Context, in *meterusagev1.AppendReadingsRequest, _ ...grpc.CallOption) (*meterusagev1.AppendReadingsResponse, error) {
+	f.appendReq = in
+	return f.appendResp, f.err
+}
+
+func (f *fakeClient) ListMeters(ctx context.Context, in *meterusagev1.ListMetersRequest, _ ...grpc.CallOption) (*meterusagev1.ListMetersResponse, error) {
+	return f.metersResp, f.err
+}
+
+func (f *fakeClient) GetDataset(ctx context.Context, in *meterusagev1.GetDatasetRequest, _ ...grpc.CallOption) (*meterusagev1.GetDatasetResponse, error) {
+	if f.datasetResp == nil && f.err == nil {
+		return nil, status.Error(codes.Unimplemented, "not implemented by fake")
+	}
+	return f.datasetResp, f.err
+}
+
+func (f *fakeClient) GetIngestionReport(ctx context.Context, in *meterusagev1.GetIngestionReportRequest, _ ...grpc.CallOption) (*meterusagev1.GetIngestionReportResponse, error) {
+	f.ingestionReq = in
+	return f.ingestionResp, f.err
+}
+
+func (f *fakeClient) GetQualityReport(ctx context.Context, in *meterusagev1.GetQualityReportRequest, _ ...grpc.CallOption) (*meterusagev1.GetQualityReportResponse, error) {
+	f.qualityReq = in
+	return f.qualityResp, f.err
+}
+
 func TestHTTP_ListReadings_OK_PreservesOrder(t *testing.T) {
 	t.Parallel()
 
@@ -117,6 +180,21 @@ func TestHTTP_ListReadings_MapsUpstreamUnavailableToBadGateway(t *testing.T) {
 	}
 }
 
+func TestHTTP_ListReadings_MapsOpenCircuitToServiceUnavailable(t *testing.T) {
+	t.Parallel()
+
+	srv := New(&fakeClient{err: upstream.ErrCircuitOpen})
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/readings", nil))
+
+	if got, want := rr.Code, http.StatusServiceUnavailable; got != want {
+		t.Fatalf("status=%d want %d", got, want)
+	}
+	if !strings.Contains(rr.Body.String(), `"upstream_unavailable"`) {
+		t.Fatalf("unexpected body: %s", rr.Body.String())
+	}
+}
+
 func TestHTTP_ListReadings_PageTokenRequiresPageSize(t *testing.T) {
 	t.Parallel()
 
@@ -130,6 +208,754 @@ func TestHTTP_ListReadings_PageTokenRequiresPageSize(t *testing.T) {
 	}
 }
 
+func TestHTTP_ListReadings_MeterFilter(t *testing.T) {
+	t.Parallel()
+
+	fc := &fakeClient{resp: &meterusagev1.ListReadingsResponse{}}
+	srv := New(fc)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/readings?meter_id=a,b&meter_id=c", nil)
+	srv.ServeHTTP(rr, req)
+
+	if got, want := rr.Code, http.StatusOK; got != want {
+		t.Fatalf("status=%d want %d, body=%s", got, want, rr.Body.String())
+	}
+	if got := fc.req.GetMeterIds(); len(got) != 3 || got[0] != "a" || got[1] != "b" || got[2] != "c" {
+		t.Fatalf("meter_ids=%v want [a b c]", got)
+	}
+}
+
+func TestHTTP_ListReadings_ContentNegotiation(t *testing.T) {
+	t.Parallel()
+
+	t0 := time.Date(2019, 1, 1, 0, 15, 0, 0, time.UTC)
+	fc := &fakeClient{
+		resp: &meterusagev1.ListReadingsResponse{
+			Readings: []*meterusagev1.Reading{{MeterId: "m", Time: timestamppb.New(t0), MeterUsage: 1.5}},
+		},
+	}
+	srv := New(fc)
+
+	cases := []struct {
+		name, query, accept string
+		wantStatus          int
+		wantType            string
+	}{
+		{name: "default", wantStatus: http.StatusOK, wantType: "application/json; charset=utf-8"},
+		{name: "browser", accept: "text/html,application/xhtml+xml,*/*;q=0.8", wantStatus: http.StatusOK, wantType: "application/json; charset=utf-8"},
+		{name: "q values", accept: "text/csv;q=0.5, application/x-ndjson", wantStatus: http.StatusOK, wantType: "application/x-ndjson"},
+		{name: "format wins", query: "&format=csv", accept: "application/json", wantStatus: http.StatusOK, wantType: "text/csv"},
+		{name: "unknown format", query: "&format=xml", wantStatus: http.StatusBadRequest},
+		{name: "not acceptable", accept: "image/png", wantStatus: http.StatusNotAcceptable},
+		{name: "export rejects page_token", query: "&format=csv&page_size=1&page_token=abc", wantStatus: http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/readings?meter_id=m"+tc.query, nil)
+		if tc.accept != "" {
+			req.Header.Set("Accept", tc.accept)
+		}
+		srv.ServeHTTP(rr, req)
+
+		if got := rr.Code; got != tc.wantStatus {
+			t.Fatalf("%s: status=%d want %d, body=%s", tc.name, got, tc.wantStatus, rr.Body.String())
+		}
+		if tc.wantType != "" && rr.Header().Get("Content-Type") != tc.wantType {
+			t.Fatalf("%s: content-type=%q want %q", tc.name, rr.Header().Get("Content-Type"), tc.wantType)
+		}
+	}
+}
+
+// failingPagesClient serves one page of readings, then fails.
+type failingPagesClient struct {
+	*fakeClient
+	calls int
+}
+
+func (f *failingPagesClient) ListReadings(ctx context.Context, in *meterusagev1.ListReadingsRequest, _ ...grpc.CallOption) (*meterusagev1.ListReadingsResponse, error) {
+	f.calls++
+	if f.calls > 1 {
+		return nil, status.Error(codes.Unavailable, "backend went away")
+	}
+	return &meterusagev1.ListReadingsResponse{
+		Readings:      []*meterusagev1.Reading{{MeterId: "m", Time: timestamppb.New(time.Unix(0, 0)), MeterUsage: 1}},
+		NextPageToken: "next",
+	}, nil
+}
+
+func TestHTTP_Export_UpstreamErrorAbortsResponse(t *testing.T) {
+	t.Parallel()
+
+	for _, format := range []string{"csv", "parquet", "greenbutton"} {
+		ts := httptest.NewServer(New(&failingPagesClient{fakeClient: &fakeClient{}}))
+		resp, err := http.Get(ts.URL + "/api/readings?format=" + format)
+		if err != nil {
+			ts.Close()
+			t.Fatalf("%s: GET: %v", format, err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		ts.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: status=%d want %d", format, resp.StatusCode, http.StatusOK)
+		}
+		if err == nil {
+			t.Fatalf("%s: read %d bytes without error, want the body cut short", format, len(body))
+		}
+	}
+}
+
+func TestHTTP_AppendReadings_MapsRowErrors(t *testing.T) {
+	t.Parallel()
+
+	fc := &fakeClient{
+		appendResp: &meterusagev1.AppendReadingsResponse{
+			AcceptedCount: 1,
+			RowErrors: []*meterusagev1.RowError{
+				{Index: 1, Message: "invalid time: invalid nil Timestamp"},
+				{Index: 2, Message: "invalid reading"},
+			},
+		},
+	}
+	srv := New(fc)
+
+	body := `{"readings": [
+		{"meterId": "a", "time": "2019-01-01T00:15:00Z", "meterUsage": 1.5},
+		{"meterId": "a", "time": "yesterday", "meterUsage": 2},
+		{"meterId": "a", "time": "2019-01-01T00:30:00Z", "meterUsage": 3}
+	]}`
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/readings", strings.NewReader(body))
+	req.Header.Set("Idempotency-Key", "batch-1")
+	srv.ServeHTTP(rr, req)
+
+	if got, want := rr.Code, http.StatusOK; got != want {
+		t.Fatalf("status=%d want %d, body=%s", got, want, rr.Body.String())
+	}
+	if got, want := fc.appendReq.GetIdempotencyKey(), "batch-1"; got != want {
+		t.Fatalf("idempotency key=%q want %q", got, want)
+	}
+	// The row with a bad time is forwarded too, without a time.
+	if got, want := len(fc.appendReq.GetReadings()), 3; got != want {
+		t.Fatalf("upstream readings=%d want %d", got, want)
+	}
+	if fc.appendReq.GetReadings()[1].GetTime() != nil {
+		t.Fatalf("upstream row 1 time=%v want nil", fc.appendReq.GetReadings()[1].GetTime())
+	}
+
+	var got appendReadingsResponseJSON
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	want := []rowErrorJSON{{Index: 1, Message: "invalid time"}, {Index: 2, Message: "invalid reading"}}
+	if got.Accepted != 1 || !slices.Equal(got.Rejected, want) {
+		t.Fatalf("unexpected response: %+v", got)
+	}
+}
+
+func TestHTTP_AppendReadings_MapsConflict(t *testing.T) {
+	t.Parallel()
+
+	fc := &fakeClient{err: status.Error(codes.AlreadyExists, "idempotency key reused with a different batch")}
+	srv := New(fc)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/readings",
+		strings.NewReader(`{"readings": [{"time": "2019-01-01T00:15:00Z", "meterUsage": 1}]}`))
+	srv.ServeHTTP(rr, req)
+
+	if got, want := rr.Code, http.StatusConflict; got != want {
+		t.Fatalf("status=%d want %d", got, want)
+	}
+}
+
+func TestHTTP_AppendReadings_InvalidBody(t *testing.T) {
+	t.Parallel()
+
+	for _, body := range []string{"", "not json", `{"readings": []}`, `{"rows": []}`} {
+		srv := New(&fakeClient{})
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/readings", strings.NewReader(body))
+		srv.ServeHTTP(rr, req)
+
+		if got, want := rr.Code, http.StatusBadRequest; got != want {
+			t.Fatalf("%q: status=%d want %d", body, got, want)
+		}
+	}
+}
+
+func TestHTTP_ListMeters(t *testing.T) {
+	t.Parallel()
+
+	t0 := time.Date(2019, 1, 1, 0, 15, 0, 0, time.UTC)
+	fc := &fakeClient{
+		metersResp: &meterusagev1.ListMetersResponse{
+			Meters: []*meterusagev1.Meter{
+				{Id: "site-a", ReadingCount: 2, FirstReadingTime: timestamppb.New(t0), LastReadingTime: timestamppb.New(t0.Add(15 * time.Minute))},
+			},
+		},
+	}
+	srv := New(fc)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/meters", nil)
+	srv.ServeHTTP(rr, req)
+
+	if got, want := rr.Code, http.StatusOK; got != want {
+		t.Fatalf("status=%d want %d, body=%s", got, want, rr.Body.String())
+	}
+
+	var got listMetersResponseJSON
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(got.Meters) != 1 || got.Meters[0].ID != "site-a" || got.Meters[0].ReadingCount != 2 {
+		t.Fatalf("unexpected meters: %#v", got.Meters)
+	}
+	if got.Meters[0].FirstReadingTime != formatTime(t0) {
+		t.Fatalf("firstReadingTime=%q want %q", got.Meters[0].FirstReadingTime, formatTime(t0))
+	}
+}
+
+func TestHTTP_IngestionReport(t *testing.T) {
+	t.Parallel()
+
+	t0 := time.Date(2019, 1, 1, 0, 15, 0, 0, time.UTC)
+	fc := &fakeClient{
+		ingestionResp: &meterusagev1.GetIngestionReportResponse{
+			Report: &meterusagev1.IngestionReport{
+				Source:        "data/meterusage.csv",
+				LoadTime:      timestamppb.New(t0),
+				Applied:       true,
+				FileCount:     1,
+				AcceptedCount: 10,
+				RejectedCount: 2,
+				Categories: []*meterusagev1.IngestionCategoryCount{
+					{Category: "invalid_usage", Count: 2},
+				},
+				Issues: []*meterusagev1.IngestionIssue{
+					{File: "data/meterusage.csv", Row: 3, Values: []string{"2019-01-01 00:30", "x"}, Category: "invalid_usage", Message: "invalid usage"},
+				},
+				IssuesTruncated: true,
+			},
+		},
+	}
+	srv := New(fc)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/ingestion/report?category=invalid_usage,invalid_time&category=malformed_row&max_issues=1", nil)
+	srv.ServeHTTP(rr, req)
+
+	if got, want := rr.Code, http.StatusOK; got != want {
+		t.Fatalf("status=%d want %d, body=%s", got, want, rr.Body.String())
+	}
+	if got, want := fc.ingestionReq.GetCategories(), []string{"invalid_usage", "invalid_time", "malformed_row"}; !slices.Equal(got, want) {
+		t.Fatalf("categories=%v want %v", got, want)
+	}
+	if got, want := fc.ingestionReq.GetMaxIssues(), uint32(1); got != want {
+		t.Fatalf("max_issues=%d want %d", got, want)
+	}
+
+	var got ingestionReportJSON
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got.LoadTime != formatTime(t0) || !got.Applied || got.AcceptedCount != 10 || got.RejectedCount != 2 || !got.IssuesTruncated {
+		t.Fatalf("unexpected report: %#v", got)
+	}
+	if len(got.Categories) != 1 || got.Categories[0] != (ingestionCountJSON{Category: "invalid_usage", Count: 2}) {
+		t.Fatalf("categories=%#v", got.Categories)
+	}
+	if len(got.Issues) != 1 || got.Issues[0].Row != 3 || len(got.Issues[0].Values) != 2 {
+		t.Fatalf("issues=%#v", got.Issues)
+	}
+}
+
+func TestHTTP_IngestionReport_Errors(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		method string
+		target string
+		err    error
+		want   int
+	}{
+		"post":               {method: http.MethodPost, target: "/api/ingestion/report", want: http.StatusMethodNotAllowed},
+		"negative max":       {method: http.MethodGet, target: "/api/ingestion/report?max_issues=-1", want: http.StatusBadRequest},
+		"non-numeric max":    {method: http.MethodGet, target: "/api/ingestion/report?max_issues=x", want: http.StatusBadRequest},
+		"no report upstream": {method: http.MethodGet, target: "/api/ingestion/report", err: status.Error(codes.Unimplemented, "no report"), want: http.StatusNotImplemented},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			srv := New(&fakeClient{err: tc.err})
+			rr := httptest.NewRecorder()
+			srv.ServeHTTP(rr, httptest.NewRequest(tc.method, tc.target, nil))
+			if got := rr.Code; got != tc.want {
+				t.Fatalf("status=%d want %d, body=%s", got, tc.want, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestHTTP_ListReadings_QualityFlags(t *testing.T) {
+	t.Parallel()
+
+	t0 := time.Date(2019, 1, 1, 0, 15, 0, 0, time.UTC)
+	fc := &fakeClient{
+		resp: &meterusagev1.ListReadingsResponse{
+			Readings: []*meterusagev1.Reading{
+				{Time: timestamppb.New(t0), MeterUsage: 1},
+				{Time: timestamppb.New(t0.Add(time.Hour)), MeterUsage: -2, QualityFlags: []meterusagev1.QualityFlag{
+					meterusagev1.QualityFlag_QUALITY_FLAG_NEGATIVE,
+					meterusagev1.QualityFlag_QUALITY_FLAG_GAP_BEFORE,
+				}},
+			},
+		},
+	}
+	srv := New(fc)
+
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/readings", nil))
+	if got, want := rr.Code, http.StatusOK; got != want {
+		t.Fatalf("status=%d want %d, body=%s", got, want, rr.Body.String())
+	}
+	if strings.Count(rr.Body.String(), `"quality"`) != 1 {
+		t.Fatalf("quality must be omitted for unflagged readings: %s", rr.Body.String())
+	}
+	var got listReadingsResponseJSON
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if want := []string{"negative", "gap_before"}; !slices.Equal(got.Readings[1].Quality, want) {
+		t.Fatalf("quality=%v want %v", got.Readings[1].Quality, want)
+	}
+}
+
+func TestHTTP_QualityReport(t *testing.T) {
+	t.Parallel()
+
+	t0 := time.Date(2019, 1, 1, 0, 15, 0, 0, time.UTC)
+	fc := &fakeClient{
+		qualityResp: &meterusagev1.GetQualityReportResponse{
+			Report: &meterusagev1.QualityReport{
+				Interval: durationpb.New(15 * time.Minute),
+				Meters: []*meterusagev1.MeterQuality{{
+					MeterId:      "site-a",
+					ReadingCount: 10,
+					MissingCount: 3,
+					SpikeCount:   1,
+					Gaps:         []*meterusagev1.Gap{{Start: timestamppb.New(t0), End: timestamppb.New(t0.Add(time.Hour)), MissingCount: 3}},
+					Anomalies: []*meterusagev1.Reading{
+						{MeterId: "site-a", Time: timestamppb.New(t0.Add(2 * time.Hour)), MeterUsage: 900, QualityFlags: []meterusagev1.QualityFlag{meterusagev1.QualityFlag_QUALITY_FLAG_SPIKE}},
+					},
+				}},
+			},
+		},
+	}
+	srv := New(fc)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/quality?start=2019-01-01T00:00:00&end=2019-01-02T00:00:00&tz=Europe/Berlin&meter_id=site-a", nil)
+	srv.ServeHTTP(rr, req)
+	if got, want := rr.Code, http.StatusOK; got != want {
+		t.Fatalf("status=%d want %d, body=%s", got, want, rr.Body.String())
+	}
+	if got, want := fc.qualityReq.GetStart().AsTime(), time.Date(2018, 12, 31, 23, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Fatalf("start=%s want %s", got, want)
+	}
+	if got, want := fc.qualityReq.GetMeterIds(), []string{"site-a"}; !slices.Equal(got, want) {
+		t.Fatalf("meter_ids=%v want %v", got, want)
+	}
+
+	var got qualityReportJSON
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got.IntervalSeconds != 900 || len(got.Meters) != 1 {
+		t.Fatalf("unexpected report: %#v", got)
+	}
+	m := got.Meters[0]
+	if m.MeterID != "site-a" || m.ReadingCount != 10 || m.MissingCount != 3 || m.SpikeCount != 1 {
+		t.Fatalf("unexpected meter: %#v", m)
+	}
+	if len(m.Gaps) != 1 || m.Gaps[0].Start != "2019-01-01T01:15:00+01:00" || m.Gaps[0].MissingCount != 3 {
+		t.Fatalf("gaps=%#v", m.Gaps)
+	}
+	if len(m.Anomalies) != 1 || !slices.Equal(m.Anomalies[0].Quality, []string{"spike"}) {
+		t.Fatalf("anomalies=%#v", m.Anomalies)
+	}
+
+	for target, want := range map[string]int{
+		"/api/quality?start=2019-01-02T00:00:00Z&end=2019-01-01T00:00:00Z": http.StatusBadRequest,
+		"/api/quality?tz=Local": http.StatusBadRequest,
+	} {
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, target, nil))
+		if rr.Code != want {
+			t.Fatalf("%s: status=%d want %d", target, rr.Code, want)
+		}
+	}
+}
+
+// datasetCountingClient counts GetDataset calls.
+type datasetCountingClient struct {
+	*fakeClient
+	calls int
+}
+
+func (c *datasetCountingClient) GetDataset(ctx context.Context, in *meterusagev1.GetDatasetRequest, opts ...grpc.CallOption) (*meterusagev1.GetDatasetResponse, error) {
+	c.calls++
+	return c.fakeClient.GetDataset(ctx, in, opts...)
+}
+
+func TestHTTP_HealthzAndMetrics_DoNotCallUpstream(t *testing.T) {
+	t.Parallel()
+
+	fc := &datasetCountingClient{fakeClient: &fakeClient{err: status.Error(codes.Unavailable, "down")}}
+	srv := New(fc)
+
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if got, want := rr.Code, http.StatusOK; got != want {
+		t.Fatalf("status=%d want %d", got, want)
+	}
+	var got healthzJSON
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got.Status != "ok" {
+		t.Fatalf("unexpected body: %s", rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if got, want := rr.Code, http.StatusOK; got != want {
+		t.Fatalf("/metrics: status=%d want %d", got, want)
+	}
+	if fc.calls != 0 {
+		t.Fatalf("GetDataset calls=%d want 0", fc.calls)
+	}
+}
+
+// fakeHealth answers grpc.health.v1 checks with status, or err.
+type fakeHealth struct {
+	healthpb.HealthClient
+	status healthpb.HealthCheckResponse_ServingStatus
+	err    error
+}
+
+func (f fakeHealth) Check(context.Context, *healthpb.HealthCheckRequest, ...grpc.CallOption) (*healthpb.HealthCheckResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &healthpb.HealthCheckResponse{Status: f.status}, nil
+}
+
+func TestHTTP_Readyz(t *testing.T) {
+	t.Parallel()
+
+	updated := time.Now().Add(-time.Minute)
+	loaded := &meterusagev1.GetDatasetResponse{
+		Dataset: &meterusagev1.Dataset{Version: 3, RowCount: 42, Checksum: "abc", UpdateTime: timestamppb.New(updated)},
+	}
+	for name, tc := range map[string]struct {
+		client   *fakeClient
+		health   healthpb.HealthClient
+		code     int
+		upstream string
+		dataset  string
+	}{
+		"ready":                {&fakeClient{datasetResp: loaded}, fakeHealth{status: healthpb.HealthCheckResponse_SERVING}, http.StatusOK, "SERVING", "ok"},
+		"without health check": {&fakeClient{datasetResp: loaded}, nil, http.StatusOK, "", "ok"},
+		"upstream not serving": {&fakeClient{datasetResp: loaded}, fakeHealth{status: healthpb.HealthCheckResponse_NOT_SERVING}, http.StatusServiceUnavailable, "NOT_SERVING", "ok"},
+		"upstream unreachable": {&fakeClient{err: status.Error(codes.Unavailable, "down")}, fakeHealth{err: status.Error(codes.Unavailable, "down")}, http.StatusServiceUnavailable, "UNREACHABLE", "unavailable"},
+		"empty dataset":        {&fakeClient{datasetResp: &meterusagev1.GetDatasetResponse{Dataset: &meterusagev1.Dataset{Version: 1}}}, fakeHealth{status: healthpb.HealthCheckResponse_SERVING}, http.StatusServiceUnavailable, "SERVING", "empty"},
+	} {
+		opts := []Option{}
+		if tc.health != nil {
+			opts = append(opts, WithUpstreamHealth(tc.health))
+		}
+		rr := httptest.NewRecorder()
+		New(tc.client, opts...).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+		if got := rr.Code; got != tc.code {
+			t.Fatalf("%s: status=%d want %d, body=%s", name, got, tc.code, rr.Body.String())
+		}
+		var got readyzJSON
+		if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+			t.Fatalf("%s: unmarshal: %v", name, err)
+		}
+		if want := map[bool]string{true: "ready", false: "not_ready"}[tc.code == http.StatusOK]; got.Status != want {
+			t.Fatalf("%s: status=%q want %q", name, got.Status, want)
+		}
+		upstream := ""
+		if got.Upstream != nil {
+			upstream = got.Upstream.Status
+		}
+		if upstream != tc.upstream {
+			t.Fatalf("%s: upstream=%q want %q", name, upstream, tc.upstream)
+		}
+		if got.Dataset.Status != tc.dataset {
+			t.Fatalf("%s: dataset=%q want %q", name, got.Dataset.Status, tc.dataset)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	New(&fakeClient{datasetResp: loaded}).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var got readyzJSON
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if d := got.Dataset; d.Version != 3 || d.RowCount != 42 || d.Checksum != "abc" || d.UpdateTime != formatTime(updated) || d.AgeSeconds < 60 || d.AgeSeconds > 120 {
+		t.Fatalf("unexpected dataset: %#v", d)
+	}
+}
+
+// backendsClient answers GetDataset from the given backends in turn, each
+// reporting its page-token key ID and address like a balanced connection.
+type backendsClient struct {
+	*fakeClient
+	backends [][2]string // address, key ID
+	calls    int
+}
+
+func (c *backendsClient) GetDataset(ctx context.Context, in *meterusagev1.GetDatasetRequest, opts ...grpc.CallOption) (*meterusagev1.GetDatasetResponse, error) {
+	b := c.backends[c.calls%len(c.backends)]
+	c.calls++
+	for _, opt := range opts {
+		if p, ok := opt.(grpc.PeerCallOption); ok {
+			p.PeerAddr.Addr = &net.TCPAddr{IP: net.ParseIP(b[0]), Port: 9090}
+		}
+	}
+	return &meterusagev1.GetDatasetResponse{
+		Dataset:        &meterusagev1.Dataset{Version: 1, RowCount: 1},
+		PageTokenKeyId: b[1],
+	}, nil
+}
+
+func TestHTTP_Readyz_PageTokenKeyMismatch(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		backends [][2]string
+		interval time.Duration // between checks
+		code     int
+	}{
+		"shared key":         {[][2]string{{"10.0.0.1", "k1"}, {"10.0.0.2", "k1"}, {"10.0.0.1", "k1"}}, time.Second, http.StatusOK},
+		"different keys":     {[][2]string{{"10.0.0.1", "k1"}, {"10.0.0.2", "k2"}, {"10.0.0.1", "k1"}}, time.Second, http.StatusServiceUnavailable},
+		"backend replaced":   {[][2]string{{"10.0.0.1", "k1"}, {"10.0.0.1", "k1"}, {"10.0.0.1", "k1"}, {"10.0.0.2", "k2"}, {"10.0.0.2", "k2"}, {"10.0.0.2", "k2"}}, 2 * keyIDWindow / 3, http.StatusOK},
+		"old server unknown": {[][2]string{{"10.0.0.1", ""}, {"10.0.0.2", "k2"}, {"10.0.0.1", ""}}, time.Second, http.StatusOK},
+	} {
+		now := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+		s := New(&backendsClient{fakeClient: &fakeClient{}, backends: tc.backends})
+		s.keys = newPageTokenKeys(func() time.Time { return now })
+
+		var rr *httptest.ResponseRecorder
+		for range tc.backends {
+			rr = httptest.NewRecorder()
+			s.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			now = now.Add(tc.interval)
+		}
+		if got := rr.Code; got != tc.code {
+			t.Fatalf("%s: status=%d want %d, body=%s", name, got, tc.code, rr.Body.String())
+		}
+		var got readyzJSON
+		if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+			t.Fatalf("%s: unmarshal: %v", name, err)
+		}
+		if tc.code == http.StatusOK {
+			if got.PageTokenKey != nil {
+				t.Fatalf("%s: pageTokenKey=%+v want none", name, got.PageTokenKey)
+			}
+			continue
+		}
+		want := []string{"10.0.0.1:9090", "10.0.0.2:9090"}
+		if k := got.PageTokenKey; k == nil || k.Status != "mismatch" || !slices.Equal(k.Backends, want) {
+			t.Fatalf("%s: pageTokenKey=%+v want mismatch of %v", name, k, want)
+		}
+	}
+}
+
+// streamClient serves StreamReadings from msgs, then waits for the call to be
+// canceled, like a stream the server keeps open.
+type streamClient struct {
+	*fakeClient
+	msgs []*meterusagev1.StreamReadingsResponse
+}
+
+func (c *streamClient) StreamReadings(ctx context.Context, in *meterusagev1.StreamReadingsRequest, _ ...grpc.CallOption) (grpc.ServerStreamingClient[meterusagev1.StreamReadingsResponse], error) {
+	return &fakeStream{ctx: ctx, msgs: c.msgs}, nil
+}
+
+type fakeStream struct {
+	grpc.ClientStream
+	ctx  context.Context
+	msgs []*meterusagev1.StreamReadingsResponse
+}
+
+func (s *fakeStream) Recv() (*meterusagev1.StreamReadingsResponse, error) {
+	if len(s.msgs) > 0 {
+		msg := s.msgs[0]
+		s.msgs = s.msgs[1:]
+		return msg, nil
+	}
+	<-s.ctx.Done()
+	return nil, status.FromContextError(s.ctx.Err()).Err()
+}
+
+// Not parallel: it reads a counter that other tests also add to.
+func TestHTTP_StreamReadings_InvalidTimestampCancelsUpstream(t *testing.T) {
+	t0 := time.Date(2019, 1, 1, 0, 15, 0, 0, time.UTC)
+	fc := &streamClient{fakeClient: &fakeClient{}, msgs: []*meterusagev1.StreamReadingsResponse{
+		{Readings: []*meterusagev1.Reading{{MeterId: "a", Time: timestamppb.New(t0), MeterUsage: 1}}},
+		{Readings: []*meterusagev1.Reading{{MeterId: "a", Time: &timestamppb.Timestamp{Seconds: -1 << 62}, MeterUsage: 2}}},
+	}}
+	canceled := grpcUpstreamRequestsTotal.WithLabelValues("StreamReadings", codes.Canceled.String())
+	before := testutil.ToFloat64(canceled)
+
+	rr := httptest.NewRecorder()
+	New(fc).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/readings/stream", nil))
+
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[1], "upstream returned invalid timestamp") {
+		t.Fatalf("body=%q want a reading and an error line", rr.Body.String())
+	}
+	if got := testutil.ToFloat64(canceled) - before; got != 1 {
+		t.Fatalf("StreamReadings calls recorded as Canceled: %v want 1", got)
+	}
+}
+
+func TestHTTP_AggregateReadings_BuildsRequest(t *testing.T) {
+	t.Parallel()
+
+	t0 := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	sum := 3.3
+	fc := &fakeClient{
+		aggResp: &meterusagev1.AggregateReadingsResponse{
+			Buckets: []*meterusagev1.Bucket{
+				{Start: timestamppb.New(t0), End: timestamppb.New(t0.Add(time.Hour)), Sum: &sum},
+			},
+		},
+	}
+	srv := New(fc)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/readings/aggregate?bucket=1h&functions=sum,max&empty=zero", nil)
+	srv.ServeHTTP(rr, req)
+
+	if got, want := rr.Code, http.StatusOK; got != want {
+		t.Fatalf("status=%d want %d, body=%s", got, want, rr.Body.String())
+	}
+	if got, want := fc.aggReq.GetBucketWidth().AsDuration(), time.Hour; got != want {
+		t.Fatalf("bucket width=%s want %s", got, want)
+	}
+	if got, want := len(fc.aggReq.GetFunctions()), 2; got != want {
+		t.Fatalf("len(functions)=%d want %d", got, want)
+	}
+	if got, want := fc.aggReq.GetEmptyBuckets(), meterusagev1.EmptyBuckets_EMPTY_BUCKETS_ZERO; got != want {
+		t.Fatalf("empty buckets=%s want %s", got, want)
+	}
+
+	var got aggregateReadingsResponseJSON
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(got.Buckets) != 1 || got.Buckets[0].Sum == nil || *got.Buckets[0].Sum != sum {
+		t.Fatalf("unexpected buckets: %#v", got.Buckets)
+	}
+	// Requested aggregates without a value are null; the others are omitted.
+	if got, want := strings.TrimSpace(rr.Body.String()), `{"buckets":[{"start":"2019-01-01T00:00:00Z","end":"2019-01-01T01:00:00Z","sum":3.3,"max":null}]}`; got != want {
+		t.Fatalf("body=%s want %s", got, want)
+	}
+}
+
+func TestHTTP_AggregateReadings_CalendarBucket(t *testing.T) {
+	t.Parallel()
+
+	fc := &fakeClient{aggResp: &meterusagev1.AggregateReadingsResponse{}}
+	srv := New(fc)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/readings/aggregate?bucket=month", nil)
+	srv.ServeHTTP(rr, req)
+
+	if got, want := rr.Code, http.StatusOK; got != want {
+		t.Fatalf("status=%d want %d, body=%s", got, want, rr.Body.String())
+	}
+	if got, want := fc.aggReq.GetCalendarInterval(), meterusagev1.CalendarInterval_CALENDAR_INTERVAL_MONTH; got != want {
+		t.Fatalf("calendar interval=%s want %s", got, want)
+	}
+}
+
+func TestHTTP_AggregateReadings_TimeZone(t *testing.T) {
+	t.Parallel()
+
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("load location: %v", err)
+	}
+	dayStart := time.Date(2019, 11, 3, 0, 0, 0, 0, ny)
+	fc := &fakeClient{aggResp: &meterusagev1.AggregateReadingsResponse{
+		Buckets: []*meterusagev1.Bucket{
+			{Start: timestamppb.New(dayStart), End: timestamppb.New(time.Date(2019, 11, 4, 0, 0, 0, 0, ny))},
+		},
+	}}
+	srv := New(fc)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/readings/aggregate?bucket=day&tz=America/New_York&start=2019-11-03", nil)
+	srv.ServeHTTP(rr, req)
+
+	if got, want := rr.Code, http.StatusOK; got != want {
+		t.Fatalf("status=%d want %d, body=%s", got, want, rr.Body.String())
+	}
+	if got, want := fc.aggReq.GetTimeZone(), "America/New_York"; got != want {
+		t.Fatalf("time_zone=%q want %q", got, want)
+	}
+	if got, want := fc.aggReq.GetStart().AsTime(), dayStart.UTC(); !got.Equal(want) {
+		t.Fatalf("start=%s want %s", got, want)
+	}
+
+	var got aggregateReadingsResponseJSON
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(got.Buckets) != 1 {
+		t.Fatalf("unexpected buckets: %#v", got.Buckets)
+	}
+	if got, want := got.Buckets[0].Start, "2019-11-03T00:00:00-04:00"; got != want {
+		t.Fatalf("bucket start=%q want %q", got, want)
+	}
+	if got, want := got.Buckets[0].End, "2019-11-04T00:00:00-05:00"; got != want {
+		t.Fatalf("bucket end=%q want %q", got, want)
+	}
+}
+
+func TestHTTP_AggregateReadings_InvalidParams(t *testing.T) {
+	t.Parallel()
+
+	for _, q := range []string{
+		"",
+		"?bucket=fortnight",
+		"?bucket=-1h",
+		"?bucket=1h&functions=median",
+		"?bucket=1h&empty=fill",
+		"?bucket=1h&tz=Bad/Zone",
+		"?bucket=1h&tz=Local",
+	} {
+		srv := New(&fakeClient{})
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/readings/aggregate"+q, nil)
+		srv.ServeHTTP(rr, req)
+
+		if got, want := rr.Code, http.StatusBadRequest; got != want {
+			t.Fatalf("%q: status=%d want %d", q, got, want)
+		}
+	}
+}
+
 func TestHTTP_Index(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,74 @@
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	meterusagev1 "github.com/milad/spectral/gen/go/proto/meterusage/v1"
+	"google.golang.org/grpc/codes"
+)
+
+// handleIngestionReport returns the upstream's report of the input rejected by
+// its last load. Issues can be narrowed with `category` (repeated or
+// comma-separated) and `max_issues`; the counts always cover all of them.
+func (s *Server) handleIngestionReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeAPIError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		return
+	}
+	maxIssues, err := parseOptionalInt(r.URL.Query().Get("max_issues"))
+	if err != nil || maxIssues < 0 {
+		writeAPIError(w, http.StatusBadRequest, "invalid_argument", "max_issues must be a non-negative integer")
+		return
+	}
+	req := &meterusagev1.GetIngestionReportRequest{MaxIssues: uint32(maxIssues)}
+	for _, v := range r.URL.Query()["category"] {
+		for _, c := range strings.Split(v, ",") {
+			if c = strings.TrimSpace(c); c != "" {
+				req.Categories = append(req.Categories, c)
+			}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeouts.Upstream)
+	defer cancel()
+	grpcStart := time.Now()
+	resp, err := s.client.GetIngestionReport(ctx, req)
+	grpcDur := time.Since(grpcStart)
+	if err != nil {
+		writeUpstreamError(w, "GetIngestionReport", err, grpcDur)
+		return
+	}
+	observeUpstreamGRPC("GetIngestionReport", codes.OK.String(), grpcDur)
+
+	rep := resp.GetReport()
+	out := ingestionReportJSON{
+		Source:          rep.GetSource(),
+		Applied:         rep.GetApplied(),
+		FileCount:       rep.GetFileCount(),
+		AcceptedCount:   rep.GetAcceptedCount(),
+		RejectedCount:   rep.GetRejectedCount(),
+		Categories:      make([]ingestionCountJSON, 0, len(rep.GetCategories())),
+		Issues:          make([]ingestionIssueJSON, 0, len(rep.GetIssues())),
+		IssuesTruncated: rep.GetIssuesTruncated(),
+	}
+	if rep.GetLoadTime().CheckValid() == nil {
+		out.LoadTime = formatTime(rep.GetLoadTime().AsTime())
+	}
+	for _, c := range rep.GetCategories() {
+		out.Categories = append(out.Categories, ingestionCountJSON{Category: c.GetCategory(), Count: c.GetCount()})
+	}
+	for _, i := range rep.GetIssues() {
+		out.Issues = append(out.Issues, ingestionIssueJSON{
+			File:     i.GetFile(),
+			Row:      i.GetRow(),
+			Values:   i.GetValues(),
+			Category: i.GetCategory(),
+			Message:  i.GetMessage(),
+		})
+	}
+	_ = writeJSON(w, http.StatusOK, out)
+}
@@ -3,12 +3,20 @@ package httpserver
 import (
 	"encoding/json"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
+
+	meterusagev1 "github.com/milad/spectral/gen/go/proto/meterusage/v1"
 )
 
 type readingJSON struct {
+	MeterID    string  `json:"meterId,omitempty"`
 	Time       string  `json:"time"`
 	MeterUsage float64 `json:"meterUsage"`
+	// Quality lists what looks wrong about the reading, e.g. "spike"; it is
+	// omitted if nothing does.
+	Quality []string `json:"quality,omitempty"`
 }
 
 type listReadingsResponseJSON struct {
@@ -16,18 +24,212 @@ type listReadingsResponseJSON struct {
 	NextPageToken string        `json:"nextPageToken,omitempty"`
 }
 
+// bucketJSON writes the aggregates that were requested and omits the others.
+// A requested aggregate that is undefined, as the avg of an empty bucket is,
+// is written as null.
+type bucketJSON struct {
+	Start string   `json:"start"`
+	End   string   `json:"end"`
+	Count *int64   `json:"count"`
+	Sum   *float64 `json:"sum"`
+	Avg   *float64 `json:"avg"`
+	Min   *float64 `json:"min"`
+	Max   *float64 `json:"max"`
+	// functions are the requested aggregates; empty means all of them.
+	functions []meterusagev1.AggregateFunction
+}
+
+func (b bucketJSON) MarshalJSON() ([]byte, error) {
+	fields := []struct {
+		name string
+		fn   meterusagev1.AggregateFunction
+		v    any
+	}{
+		{"start", meterusagev1.AggregateFunction_AGGREGATE_FUNCTION_UNSPECIFIED, b.Start},
+		{"end", meterusagev1.AggregateFunction_AGGREGATE_FUNCTION_UNSPECIFIED, b.End},
+		{"count", meterusagev1.AggregateFunction_AGGREGATE_FUNCTION_COUNT, b.Count},
+		{"sum", meterusagev1.AggregateFunction_AGGREGATE_FUNCTION_SUM, b.Sum},
+		{"avg", meterusagev1.AggregateFunction_AGGREGATE_FUNCTION_AVG, b.Avg},
+		{"min", meterusagev1.AggregateFunction_AGGREGATE_FUNCTION_MIN, b.Min},
+		{"max", meterusagev1.AggregateFunction_AGGREGATE_FUNCTION_MAX, b.Max},
+	}
+	out := []byte{'{'}
+	for _, f := range fields {
+		if f.fn != meterusagev1.AggregateFunction_AGGREGATE_FUNCTION_UNSPECIFIED &&
+			len(b.functions) > 0 && !slices.Contains(b.functions, f.fn) {
+			continue
+		}
+		v, err := json.Marshal(f.v)
+		if err != nil {
+			return nil, err
+		}
+		if len(out) > 1 {
+			out = append(out, ',')
+		}
+		out = append(out, '"')
+		out = append(out, f.name...)
+		out = append(out, '"', ':')
+		out = append(out, v...)
+	}
+	return append(out, '}'), nil
+}
+
+type aggregateReadingsResponseJSON struct {
+	Buckets []bucketJSON `json:"buckets"`
+}
+
+// appendReadingJSON is an input row for POST /api/readings. MeterUsage is a
+// pointer so that a missing value can be told apart from 0.
+type appendReadingJSON struct {
+	MeterID    string   `json:"meterId"`
+	Time       string   `json:"time"`
+	MeterUsage *float64 `json:"meterUsage"`
+}
+
+type appendReadingsRequestJSON struct {
+	Readings []appendReadingJSON `json:"readings"`
+}
+
+type rowErrorJSON struct {
+	Index   int    `json:"index"`
+	Message string `json:"message"`
+}
+
+type appendReadingsResponseJSON struct {
+	Accepted int            `json:"accepted"`
+	Rejected []rowErrorJSON `json:"rejected"`
+	Replayed bool           `json:"replayed,omitempty"`
+}
+
+type meterJSON struct {
+	ID               string `json:"id"`
+	ReadingCount     int64  `json:"readingCount"`
+	FirstReadingTime string `json:"firstReadingTime"`
+	LastReadingTime  string `json:"lastReadingTime"`
+}
+
+type listMetersResponseJSON struct {
+	Meters []meterJSON `json:"meters"`
+}
+
+type ingestionReportJSON struct {
+	Source          string               `json:"source"`
+	LoadTime        string               `json:"loadTime,omitempty"`
+	Applied         bool                 `json:"applied"`
+	FileCount       int32                `json:"fileCount"`
+	AcceptedCount   int64                `json:"acceptedCount"`
+	RejectedCount   int64                `json:"rejectedCount"`
+	Categories      []ingestionCountJSON `json:"categories"`
+	Issues          []ingestionIssueJSON `json:"issues"`
+	IssuesTruncated bool                 `json:"issuesTruncated"`
+}
+
+type ingestionCountJSON struct {
+	Category string `json:"category"`
+	Count    int64  `json:"count"`
+}
+
+type ingestionIssueJSON struct {
+	File     string   `json:"file"`
+	Row      int64    `json:"row,omitempty"`
+	Values   []string `json:"values,omitempty"`
+	Category string   `json:"category"`
+	Message  string   `json:"message"`
+}
+
+type qualityReportJSON struct {
+	IntervalSeconds float64            `json:"intervalSeconds"`
+	Meters          []meterQualityJSON `json:"meters"`
+}
+
+type meterQualityJSON struct {
+	MeterID        string        `json:"meterId"`
+	ReadingCount   int64         `json:"readingCount"`
+	MissingCount   int64         `json:"missingCount"`
+	DuplicateCount int64         `json:"duplicateCount"`
+	NegativeCount  int64         `json:"negativeCount"`
+	SpikeCount     int64         `json:"spikeCount"`
+	Gaps           []gapJSON     `json:"gaps"`
+	Anomalies      []readingJSON `json:"anomalies"`
+	Truncated      bool          `json:"truncated"`
+}
+
+type gapJSON struct {
+	Start        string `json:"start"`
+	End          string `json:"end"`
+	MissingCount int64  `json:"missingCount"`
+}
+
+type healthzJSON struct {
+	Status string `json:"status"`
+}
+
+// readyzJSON is the body of /readyz. Status is "ready" or "not_ready".
+type readyzJSON struct {
+	Status   string             `json:"status"`
+	Upstream *upstreamReadyJSON `json:"upstream,omitempty"`
+	Dataset  datasetReadyJSON   `json:"dataset"`
+	// PageTokenKey lists the upstream backends that sign page tokens with a
+	// different key than another backend answering alongside them.
+	PageTokenKey *pageTokenKeyJSON `json:"pageTokenKey,omitempty"`
+}
+
+type pageTokenKeyJSON struct {
+	// Status is "mismatch".
+	Status   string   `json:"status"`
+	Backends []string `json:"backends"`
+}
+
+type upstreamReadyJSON struct {
+	// Status is the grpc.health.v1 serving status, or UNREACHABLE.
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type datasetReadyJSON struct {
+	// Status is "ok", "empty" or "unavailable".
+	Status     string  `json:"status"`
+	Error      string  `json:"error,omitempty"`
+	Version    uint64  `json:"version,omitempty"`
+	RowCount   int64   `json:"rowCount"`
+	Checksum   string  `json:"checksum,omitempty"`
+	UpdateTime string  `json:"updateTime,omitempty"`
+	AgeSeconds float64 `json:"ageSeconds,omitempty"`
+}
+
 type apiErrorJSON struct {
 	Code      string `json:"code"`
 	Message   string `json:"message"`
 	RequestID string `json:"requestId,omitempty"`
 }
 
+// streamErrorJSON is the trailing line of an NDJSON stream that failed midway.
+type streamErrorJSON struct {
+	Error apiErrorJSON `json:"error"`
+}
+
 func writeJSON(w http.ResponseWriter, status int, v any) error {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.WriteHeader(status)
 	return json.NewEncoder(w).Encode(v)
 }
 
+// qualityNames renders quality flags as the lowercase names of the enum
+// values, e.g. QUALITY_FLAG_GAP_BEFORE as "gap_before".
+func qualityNames(flags []meterusagev1.QualityFlag) []string {
+	var names []string
+	for _, f := range flags {
+		names = append(names, strings.ToLower(strings.TrimPrefix(f.String(), "QUALITY_FLAG_")))
+	}
+	return names
+}
+
 func formatTime(t time.Time) string {
 	return t.UTC().Format(time.RFC3339Nano)
 }
+
+// formatTimeIn renders t with the offset of loc, for responses to requests
+// with a `tz` param.
+func formatTimeIn(t time.Time, loc *time.Location) string {
+	return t.In(loc).Format(time.RFC3339Nano)
+}
@@ -17,6 +17,13 @@ var (
 		},
 		[]string{"route", "method", "status"},
 	)
+	httpRequestsThrottledTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "http_requests_throttled_total",
+			Help: "Total number of HTTP requests rejected with 429 by the rate limiter.",
+		},
+		[]string{"route", "method"},
+	)
 	httpRequestDurationSeconds = promauto.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Name:    "http_request_duration_seconds",
@@ -41,6 +48,29 @@ var (
 		},
 		[]string{"method"},
 	)
+
+	httpCacheRequestsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "http_cache_requests_total",
+			Help: "Cacheable HTTP requests by result: hit, miss, coalesced (joined an identical upstream call in flight) or bypass (dataset version unknown).",
+		},
+		[]string{"result"},
+	)
+	httpCacheEvictionsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "http_cache_evictions_total",
+			Help: "Responses dropped from the cache, by reason: capacity, expired, replaced or version (the dataset changed).",
+		},
+		[]string{"reason"},
+	)
+	httpCacheEntries = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "http_cache_entries",
+		Help: "Number of responses in the cache.",
+	})
+	httpCacheBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "http_cache_bytes",
+		Help: "Approximate memory used by the cached responses.",
+	})
 )
 
 func observeHTTPRequest(r *http.Request, status int, dur time.Duration) {
@@ -62,8 +92,20 @@ func routeLabel(path string) string {
 		return "index"
 	case "/api/readings":
 		return "api_readings"
+	case "/api/readings/aggregate":
+		return "api_readings_aggregate"
+	case "/api/readings/stream":
+		return "api_readings_stream"
+	case "/api/meters":
+		return "api_meters"
+	case "/api/ingestion/report":
+		return "api_ingestion_report"
+	case "/api/quality":
+		return "api_quality"
 	case "/healthz":
 		return "healthz"
+	case "/readyz":
+		return "readyz"
 	case "/metrics":
 		return "metrics"
 	default:
@@ -0,0 +1,73 @@
+package httpserver
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// keyIDWindow is how long a backend's page-token key ID counts after the
+// backend last answered.
+const keyIDWindow = time.Minute
+
+// pageTokenKeys checks that the upstream backends sign page tokens with the
+// same key, from the key IDs they report in GetDataset. A page token is only
+// accepted by a backend with the key that signed it, so with several backends
+// behind the target, paging fails whenever the next page lands on another
+// one. Backends that answered alongside each other must report the same ID;
+// one that replaced another, as after a restart on a new address, may not.
+type pageTokenKeys struct {
+	now func() time.Time
+
+	mu       sync.Mutex
+	backends map[string]*backendKey // by address
+}
+
+type backendKey struct {
+	id          string
+	first, last time.Time // when the backend first and last reported id
+}
+
+func newPageTokenKeys(now func() time.Time) *pageTokenKeys {
+	return &pageTokenKeys{now: now, backends: map[string]*backendKey{}}
+}
+
+// observe records that the backend at addr reported id, and returns the
+// addresses of backends that disagree, if any.
+func (k *pageTokenKeys) observe(addr, id string) []string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	now := k.now()
+	b := k.backends[addr]
+	if b == nil || b.id != id || now.Sub(b.last) > keyIDWindow {
+		b = &backendKey{id: id, first: now}
+		k.backends[addr] = b
+	}
+	b.last = now
+	return k.mismatchLocked(now)
+}
+
+// mismatch returns the addresses of backends that disagree, if any.
+func (k *pageTokenKeys) mismatch() []string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.mismatchLocked(k.now())
+}
+
+func (k *pageTokenKeys) mismatchLocked(now time.Time) []string {
+	var out []string
+	for addr, a := range k.backends {
+		if now.Sub(a.last) > keyIDWindow {
+			delete(k.backends, addr)
+			continue
+		}
+		for _, b := range k.backends {
+			if a.id != b.id && now.Sub(b.last) <= keyIDWindow && !a.first.After(b.last) && !b.first.After(a.last) {
+				out = append(out, addr)
+				break
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
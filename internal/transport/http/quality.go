@@ -0,0 +1,88 @@
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	meterusagev1 "github.com/milad/spectral/gen/go/proto/meterusage/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// handleQualityReport summarizes the gaps and anomalies of the readings in a
+// range, per meter. It takes the `start`, `end`, `tz` and `meter_id` params of
+// /api/readings.
+func (s *Server) handleQualityReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeAPIError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		return
+	}
+	start, end, loc, ok := parseTimeRange(w, r)
+	if !ok {
+		return
+	}
+	req := &meterusagev1.GetQualityReportRequest{MeterIds: parseMeterIDs(r)}
+	if start != nil {
+		req.Start = timestamppb.New(*start)
+	}
+	if end != nil {
+		req.End = timestamppb.New(*end)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeouts.Upstream)
+	defer cancel()
+	grpcStart := time.Now()
+	resp, err := s.client.GetQualityReport(ctx, req)
+	grpcDur := time.Since(grpcStart)
+	if err != nil {
+		writeUpstreamError(w, "GetQualityReport", err, grpcDur)
+		return
+	}
+	observeUpstreamGRPC("GetQualityReport", codes.OK.String(), grpcDur)
+
+	rep := resp.GetReport()
+	out := qualityReportJSON{
+		IntervalSeconds: rep.GetInterval().AsDuration().Seconds(),
+		Meters:          make([]meterQualityJSON, 0, len(rep.GetMeters())),
+	}
+	for _, m := range rep.GetMeters() {
+		mq := meterQualityJSON{
+			MeterID:        m.GetMeterId(),
+			ReadingCount:   m.GetReadingCount(),
+			MissingCount:   m.GetMissingCount(),
+			DuplicateCount: m.GetDuplicateCount(),
+			NegativeCount:  m.GetNegativeCount(),
+			SpikeCount:     m.GetSpikeCount(),
+			Gaps:           make([]gapJSON, 0, len(m.GetGaps())),
+			Anomalies:      make([]readingJSON, 0, len(m.GetAnomalies())),
+			Truncated:      m.GetTruncated(),
+		}
+		for _, g := range m.GetGaps() {
+			if g.GetStart().CheckValid() != nil || g.GetEnd().CheckValid() != nil {
+				writeAPIError(w, http.StatusBadGateway, "upstream_error", "upstream returned invalid timestamp")
+				return
+			}
+			mq.Gaps = append(mq.Gaps, gapJSON{
+				Start:        formatTimeIn(g.GetStart().AsTime(), loc),
+				End:          formatTimeIn(g.GetEnd().AsTime(), loc),
+				MissingCount: g.GetMissingCount(),
+			})
+		}
+		for _, a := range m.GetAnomalies() {
+			if a.GetTime().CheckValid() != nil {
+				writeAPIError(w, http.StatusBadGateway, "upstream_error", "upstream returned invalid timestamp")
+				return
+			}
+			mq.Anomalies = append(mq.Anomalies, readingJSON{
+				MeterID:    a.GetMeterId(),
+				Time:       formatTimeIn(a.GetTime().AsTime(), loc),
+				MeterUsage: a.GetMeterUsage(),
+				Quality:    qualityNames(a.GetQualityFlags()),
+			})
+		}
+		out.Meters = append(out.Meters, mq)
+	}
+	_ = writeJSON(w, http.StatusOK, out)
+}
@@ -0,0 +1,254 @@
+package httpserver
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/milad/spectral/internal/auth"
+)
+
+// RateLimit is a token bucket: clients may make Burst requests at once and
+// then Rate requests per second on average.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// DefaultRateLimitRoute is the key of the limit applied to /api/ routes that
+// have none of their own.
+const DefaultRateLimitRoute = "*"
+
+// ParseRateLimits parses a comma-separated list of ROUTE=RATE:BURST entries,
+// e.g. "*=10:20,/api/readings/aggregate=1:5". ROUTE is a request path or
+// DefaultRateLimitRoute; RATE is in requests per second.
+func ParseRateLimits(spec string) (map[string]RateLimit, error) {
+	limits := make(map[string]RateLimit)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		route, limit, ok := strings.Cut(entry, "=")
+		rate, burst, ok2 := strings.Cut(limit, ":")
+		if !ok || !ok2 || route == "" {
+			return nil, fmt.Errorf("rate limit %q: want ROUTE=RATE:BURST", entry)
+		}
+		if route != DefaultRateLimitRoute && !strings.HasPrefix(route, "/api/") {
+			return nil, fmt.Errorf("rate limit %q: route must be %q or start with /api/", entry, DefaultRateLimitRoute)
+		}
+		r, err := strconv.ParseFloat(rate, 64)
+		if err != nil || !(r > 0) || math.IsInf(r, 0) {
+			return nil, fmt.Errorf("rate limit %q: rate must be a positive number", entry)
+		}
+		b, err := strconv.Atoi(burst)
+		if err != nil || b < 1 {
+			return nil, fmt.Errorf("rate limit %q: burst must be a positive integer", entry)
+		}
+		if _, dup := limits[route]; dup {
+			return nil, fmt.Errorf("rate limit for %q set twice", route)
+		}
+		limits[route] = RateLimit{Rate: r, Burst: b}
+	}
+	return limits, nil
+}
+
+// WithRateLimits limits /api/ requests per client and route. limits is keyed
+// by request path, with DefaultRateLimitRoute for all other /api/ paths;
+// routes without a limit are not limited. Clients are identified by their
+// authenticated principal or, for anonymous requests, their IP address.
+// Requests that fail authentication count against their IP address.
+func WithRateLimits(limits map[string]RateLimit) Option {
+	return func(s *Server) {
+		if len(limits) > 0 {
+			s.limiter = newRateLimiter(limits, time.Now)
+		}
+	}
+}
+
+// rateLimitSweepInterval is how often idle buckets are dropped.
+const rateLimitSweepInterval = time.Minute
+
+type rateLimiter struct {
+	limits map[string]RateLimit
+	now    func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[bucketKey]*tokenBucket
+	lastSweep time.Time
+}
+
+type bucketKey struct {
+	route  string // routeLabel, so unknown paths share one bucket
+	client string
+}
+
+type tokenBucket struct {
+	limit  RateLimit
+	tokens float64
+	last   time.Time
+}
+
+// limitDecision is the outcome of taking a token, used for the response headers.
+type limitDecision struct {
+	allowed    bool
+	limit      RateLimit
+	remaining  int
+	reset      time.Duration // until the bucket is full again
+	retryAfter time.Duration // until the next token, if not allowed
+}
+
+func newRateLimiter(limits map[string]RateLimit, now func() time.Time) *rateLimiter {
+	return &rateLimiter{
+		limits:    limits,
+		now:       now,
+		buckets:   make(map[bucketKey]*tokenBucket),
+		lastSweep: now(),
+	}
+}
+
+// take removes a token from the client's bucket for the request's route. ok
+// is false if the route is not limited.
+func (l *rateLimiter) take(r *http.Request, client string) (d limitDecision, ok bool) {
+	return l.decide(r, client, true)
+}
+
+// peek reports whether take would allow the request, without taking a token.
+func (l *rateLimiter) peek(r *http.Request, client string) (d limitDecision, ok bool) {
+	return l.decide(r, client, false)
+}
+
+func (l *rateLimiter) decide(r *http.Request, client string, consume bool) (d limitDecision, ok bool) {
+	limit, ok := l.limits[r.URL.Path]
+	if !ok {
+		if limit, ok = l.limits[DefaultRateLimitRoute]; !ok {
+			return limitDecision{}, false
+		}
+	}
+
+	now := l.now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.lastSweep) >= rateLimitSweepInterval {
+		l.sweepLocked(now)
+	}
+
+	key := bucketKey{route: routeLabel(r.URL.Path), client: client}
+	b := l.buckets[key]
+	if b == nil {
+		b = &tokenBucket{limit: limit, tokens: float64(limit.Burst), last: now}
+		l.buckets[key] = b
+	}
+	b.refill(now)
+
+	d = limitDecision{limit: limit}
+	if b.tokens >= 1 {
+		if consume {
+			b.tokens--
+		}
+		d.allowed = true
+	} else {
+		d.retryAfter = seconds((1 - b.tokens) / limit.Rate)
+	}
+	d.remaining = int(b.tokens)
+	d.reset = seconds((float64(limit.Burst) - b.tokens) / limit.Rate)
+	return d, true
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(float64(b.limit.Burst), b.tokens+elapsed*b.limit.Rate)
+	}
+	b.last = now
+}
+
+// sweepLocked drops buckets that have refilled completely; recreating them is
+// equivalent, and this keeps one-off clients from accumulating.
+func (l *rateLimiter) sweepLocked(now time.Time) {
+	for k, b := range l.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.limit.Burst) {
+			delete(l.buckets, k)
+		}
+	}
+	l.lastSweep = now
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// rateLimitClient identifies the caller for rate limiting.
+func rateLimitClient(r *http.Request) string {
+	if p, ok := auth.PrincipalFromContext(r.Context()); ok {
+		return p.Method + ":" + p.Subject
+	}
+	return ipClient(r)
+}
+
+// ipClient identifies the caller by IP address, whatever credentials it sent.
+func ipClient(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// rateLimit applies the limiter to r, setting the RateLimit-* headers
+// (draft-ietf-httpapi-ratelimit-headers). If the client is over its limit it
+// writes a 429 and returns false.
+func (s *Server) rateLimit(w http.ResponseWriter, r *http.Request) bool {
+	d, limited := s.limiter.take(r, rateLimitClient(r))
+	return s.applyLimit(w, r, d, limited)
+}
+
+// rateLimitAuthAttempt is checked before credentials are: failed attempts are
+// charged to the caller's IP address (see chargeFailedAuth), and an address
+// that has used up its limit that way gets a 429 without its credentials
+// being looked at, so keys cannot be guessed faster than the limit allows.
+func (s *Server) rateLimitAuthAttempt(w http.ResponseWriter, r *http.Request) bool {
+	d, limited := s.limiter.peek(r, ipClient(r))
+	if !limited || d.allowed {
+		return true
+	}
+	return s.applyLimit(w, r, d, limited)
+}
+
+// chargeFailedAuth takes a token from the bucket of the caller's IP address
+// for a request that failed authentication.
+func (s *Server) chargeFailedAuth(r *http.Request) {
+	s.limiter.take(r, ipClient(r))
+}
+
+// applyLimit sets the RateLimit-* headers for d and, if d does not allow the
+// request, writes a 429 and returns false.
+func (s *Server) applyLimit(w http.ResponseWriter, r *http.Request, d limitDecision, limited bool) bool {
+	if !limited {
+		return true
+	}
+
+	h := w.Header()
+	window := math.Ceil(float64(d.limit.Burst) / d.limit.Rate)
+	h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%.0f", d.limit.Burst, window))
+	h.Set("RateLimit-Limit", strconv.Itoa(d.limit.Burst))
+	h.Set("RateLimit-Remaining", strconv.Itoa(d.remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(d.reset)))
+	if d.allowed {
+		return true
+	}
+
+	h.Set("Retry-After", strconv.Itoa(max(1, ceilSeconds(d.retryAfter))))
+	httpRequestsThrottledTotal.WithLabelValues(routeLabel(r.URL.Path), r.Method).Inc()
+	writeAPIError(w, http.StatusTooManyRequests, "rate_limited", "rate limit exceeded; retry later")
+	return false
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
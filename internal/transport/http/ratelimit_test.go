@@ -0,0 +1,176 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	meterusagev1 "github.com/milad/spectral/gen/go/proto/meterusage/v1"
+	"github.com/milad/spectral/internal/auth"
+)
+
+func TestParseRateLimits(t *testing.T) {
+	t.Parallel()
+
+	limits, err := ParseRateLimits("*=10:20, /api/readings/aggregate=0.5:2")
+	if err != nil {
+		t.Fatalf("ParseRateLimits: %v", err)
+	}
+	if got, want := limits["*"], (RateLimit{Rate: 10, Burst: 20}); got != want {
+		t.Fatalf("default=%+v want %+v", got, want)
+	}
+	if got, want := limits["/api/readings/aggregate"], (RateLimit{Rate: 0.5, Burst: 2}); got != want {
+		t.Fatalf("aggregate=%+v want %+v", got, want)
+	}
+
+	for _, spec := range []string{"*=10", "*=0:1", "*=1:0", "/healthz=1:1", "*=1:1,*=2:2", "*=x:1"} {
+		if _, err := ParseRateLimits(spec); err == nil {
+			t.Fatalf("%q: expected an error", spec)
+		}
+	}
+}
+
+func TestRateLimiter_RefillsOverTime(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newRateLimiter(map[string]RateLimit{"*": {Rate: 2, Burst: 3}}, func() time.Time { return now })
+	req := httptest.NewRequest(http.MethodGet, "/api/readings", nil)
+
+	for i := 0; i < 3; i++ {
+		if d, _ := l.take(req, "a"); !d.allowed {
+			t.Fatalf("request %d: expected burst to be allowed", i)
+		}
+	}
+	d, _ := l.take(req, "a")
+	if d.allowed {
+		t.Fatalf("expected the 4th request to be throttled")
+	}
+	if got, want := d.retryAfter, 500*time.Millisecond; got != want {
+		t.Fatalf("retryAfter=%s want %s", got, want)
+	}
+	if d, _ := l.take(req, "b"); !d.allowed {
+		t.Fatalf("expected another client to have its own bucket")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if d, _ := l.take(req, "a"); !d.allowed || d.remaining != 0 {
+		t.Fatalf("after refill: allowed=%v remaining=%d", d.allowed, d.remaining)
+	}
+
+	now = now.Add(rateLimitSweepInterval)
+	l.take(req, "c")
+	if got, want := len(l.buckets), 1; got != want {
+		t.Fatalf("buckets after sweep=%d want %d", got, want)
+	}
+}
+
+func TestHTTP_RateLimit_Returns429(t *testing.T) {
+	t.Parallel()
+
+	srv := New(&fakeClient{resp: &meterusagev1.ListReadingsResponse{}},
+		WithRateLimits(map[string]RateLimit{"/api/readings": {Rate: 1, Burst: 2}}))
+
+	get := func(path, remoteAddr string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = remoteAddr
+		srv.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for i := 0; i < 2; i++ {
+		rr := get("/api/readings", "10.0.0.1:1234")
+		if got, want := rr.Code, http.StatusOK; got != want {
+			t.Fatalf("request %d: status=%d want %d", i, got, want)
+		}
+		if got, want := rr.Header().Get("RateLimit-Limit"), "2"; got != want {
+			t.Fatalf("RateLimit-Limit=%q want %q", got, want)
+		}
+	}
+
+	rr := get("/api/readings", "10.0.0.1:5678")
+	if got, want := rr.Code, http.StatusTooManyRequests; got != want {
+		t.Fatalf("status=%d want %d", got, want)
+	}
+	if got, want := rr.Header().Get("Retry-After"), "1"; got != want {
+		t.Fatalf("Retry-After=%q want %q", got, want)
+	}
+	if got, want := rr.Header().Get("RateLimit-Remaining"), "0"; got != want {
+		t.Fatalf("RateLimit-Remaining=%q want %q", got, want)
+	}
+	var body apiErrorJSON
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if body.Code != "rate_limited" || body.RequestID == "" {
+		t.Fatalf("unexpected error body %+v", body)
+	}
+
+	if got, want := get("/api/readings", "10.0.0.2:1234").Code, http.StatusOK; got != want {
+		t.Fatalf("other IP: status=%d want %d", got, want)
+	}
+	if rr := get("/api/meters", "10.0.0.1:1234"); rr.Header().Get("RateLimit-Limit") != "" {
+		t.Fatalf("expected a route without a limit to be unlimited")
+	}
+}
+
+func TestRateLimitClient_PrefersPrincipal(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/readings", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	if got, want := rateLimitClient(req), "ip:10.0.0.1"; got != want {
+		t.Fatalf("anonymous client=%q want %q", got, want)
+	}
+	req = req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{Subject: "dashboard", Method: "api_key"}))
+	if got, want := rateLimitClient(req), "api_key:dashboard"; got != want {
+		t.Fatalf("authenticated client=%q want %q", got, want)
+	}
+}
+
+func TestHTTP_RateLimit_ChargesFailedAuthToIP(t *testing.T) {
+	t.Parallel()
+
+	keys, err := auth.NewAPIKeys([]auth.APIKey{{Name: "dashboard", SHA256: auth.HashAPIKey("read-key")}})
+	if err != nil {
+		t.Fatalf("NewAPIKeys: %v", err)
+	}
+	srv := New(&fakeClient{resp: &meterusagev1.ListReadingsResponse{}},
+		WithAuthenticator(keys),
+		WithRateLimits(map[string]RateLimit{"/api/readings": {Rate: 1, Burst: 2}}))
+
+	get := func(key, remoteAddr string) int {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/readings", nil)
+		req.Header.Set(auth.APIKeyHeader, key)
+		req.RemoteAddr = remoteAddr
+		srv.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	for i := 0; i < 2; i++ {
+		if got, want := get("guess", "10.0.0.1:1234"), http.StatusUnauthorized; got != want {
+			t.Fatalf("attempt %d: status=%d want %d", i, got, want)
+		}
+	}
+	// The address has used up its limit: its credentials are not checked, even
+	// valid ones.
+	for _, key := range []string{"guess", "read-key"} {
+		if got, want := get(key, "10.0.0.1:1234"), http.StatusTooManyRequests; got != want {
+			t.Fatalf("key %q after failed attempts: status=%d want %d", key, got, want)
+		}
+	}
+
+	// Authenticated requests use the principal's bucket, not the address's.
+	for i := 0; i < 2; i++ {
+		if got, want := get("read-key", "10.0.0.2:1234"), http.StatusOK; got != want {
+			t.Fatalf("request %d: status=%d want %d", i, got, want)
+		}
+	}
+	if got, want := get("guess", "10.0.0.2:1234"), http.StatusUnauthorized; got != want {
+		t.Fatalf("failed attempt after authenticated requests: status=%d want %d", got, want)
+	}
+}
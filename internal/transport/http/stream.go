@@ -0,0 +1,141 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	meterusagev1 "github.com/milad/spectral/gen/go/proto/meterusage/v1"
+	"github.com/milad/spectral/internal/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// handleStreamReadings streams readings in [start, end) as newline-delimited
+// JSON, one reading per line, flushing after every upstream chunk.
+//
+// Query params are the same as for /api/readings, except that pagination is
+// replaced by `chunk_size`. If the stream fails after the first line was
+// written, a final line with an `error` object is emitted.
+func (s *Server) handleStreamReadings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeAPIError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		return
+	}
+
+	start, end, loc, ok := parseTimeRange(w, r)
+	if !ok {
+		return
+	}
+	chunkSize, err := parseOptionalInt(r.URL.Query().Get("chunk_size"))
+	if err != nil || chunkSize < 0 {
+		writeAPIError(w, http.StatusBadRequest, "invalid_argument", "invalid chunk_size")
+		return
+	}
+
+	req := &meterusagev1.StreamReadingsRequest{
+		MeterIds:  parseMeterIDs(r),
+		ChunkSize: int32(chunkSize),
+	}
+	if start != nil {
+		req.Start = timestamppb.New(*start)
+	}
+	if end != nil {
+		req.End = timestamppb.New(*end)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeouts.Stream)
+	defer cancel()
+	grpcStart := time.Now()
+	stream, err := s.client.StreamReadings(ctx, req)
+	if err != nil {
+		writeUpstreamError(w, "StreamReadings", err, time.Since(grpcStart))
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	enc := json.NewEncoder(w)
+	started := false
+	for {
+		msg, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			if !started {
+				// Nothing written yet: report the failure as a regular API error.
+				writeUpstreamError(w, "StreamReadings", err, time.Since(grpcStart))
+				return
+			}
+			code := status.Code(err)
+			observeUpstreamGRPC("StreamReadings", code.String(), time.Since(grpcStart))
+			slog.WarnContext(r.Context(), "stream readings aborted", logging.KeyGRPCCode, code.String(), logging.Err(err))
+			_ = enc.Encode(streamErrorJSON{Error: apiErrorJSON{
+				Code:      "upstream_error",
+				Message:   "upstream error",
+				RequestID: w.Header().Get("X-Request-Id"),
+			}})
+			return
+		}
+
+		if !started {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+		_ = rc.SetWriteDeadline(time.Now().Add(s.timeouts.StreamWrite))
+		for _, rr := range msg.GetReadings() {
+			if err := rr.GetTime().CheckValid(); err != nil {
+				code := abandonStream(stream, cancel)
+				observeUpstreamGRPC("StreamReadings", code.String(), time.Since(grpcStart))
+				slog.WarnContext(r.Context(), "stream readings aborted: upstream returned invalid timestamp", logging.KeyGRPCCode, code.String(), logging.Err(err))
+				_ = enc.Encode(streamErrorJSON{Error: apiErrorJSON{
+					Code:      "upstream_error",
+					Message:   "upstream returned invalid timestamp",
+					RequestID: w.Header().Get("X-Request-Id"),
+				}})
+				return
+			}
+			if err := enc.Encode(readingJSON{
+				MeterID:    rr.GetMeterId(),
+				Time:       formatTimeIn(rr.GetTime().AsTime(), loc),
+				MeterUsage: rr.GetMeterUsage(),
+				Quality:    qualityNames(rr.GetQualityFlags()),
+			}); err != nil {
+				// The client went away.
+				observeUpstreamGRPC("StreamReadings", abandonStream(stream, cancel).String(), time.Since(grpcStart))
+				return
+			}
+		}
+		_ = rc.Flush()
+	}
+	observeUpstreamGRPC("StreamReadings", codes.OK.String(), time.Since(grpcStart))
+
+	if !started {
+		// Empty result: still a valid (empty) NDJSON body.
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// abandonStream cancels a stream that is not read to its end, and returns the
+// code it ended with: Canceled, unless it had already finished.
+func abandonStream(stream grpc.ServerStreamingClient[meterusagev1.StreamReadingsResponse], cancel context.CancelFunc) codes.Code {
+	cancel()
+	for {
+		_, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return codes.OK
+		}
+		if err != nil {
+			return status.Code(err)
+		}
+	}
+}
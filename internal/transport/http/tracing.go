@@ -0,0 +1,54 @@
+package httpserver
+
+import (
+	"net/http"
+
+	"github.com/milad/spectral/internal/telemetry"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/milad/spectral/internal/transport/http")
+
+// startServerSpan starts the span for an incoming request, continuing the
+// caller's trace if it sent a W3C traceparent. The returned request carries
+// the span and the request ID, which the gRPC client passes upstream.
+func startServerSpan(r *http.Request, reqID string) (*http.Request, trace.Span) {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx = telemetry.WithRequestID(ctx, reqID)
+
+	// Unknown paths share one span name to keep names low-cardinality.
+	name := r.Method
+	attrs := []attribute.KeyValue{
+		attribute.String("http.request.method", r.Method),
+		attribute.String("url.path", r.URL.Path),
+		telemetry.RequestIDAttr(reqID),
+	}
+	if routeLabel(r.URL.Path) != "other" {
+		name += " " + r.URL.Path
+		attrs = append(attrs, attribute.String("http.route", r.URL.Path))
+	}
+	ctx, span := tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attrs...))
+	return r.WithContext(ctx), span
+}
+
+// endServerSpan records the response status and ends span. Only server
+// errors mark the span as failed; 4xx are the client's problem.
+func endServerSpan(span trace.Span, status int) {
+	span.SetAttributes(attribute.Int("http.response.status_code", status))
+	if status >= 500 {
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+	span.End()
+}
+
+// abortServerSpan ends span as failed for a response that was cut off after
+// status had been sent.
+func abortServerSpan(span trace.Span, status int) {
+	span.SetAttributes(attribute.Int("http.response.status_code", status))
+	span.SetStatus(codes.Error, "response aborted")
+	span.End()
+}
@@ -0,0 +1,89 @@
+package upstream
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/balancer/leastrequest"
+	"google.golang.org/grpc/balancer/roundrobin"
+	_ "google.golang.org/grpc/health" // client-side health checking
+)
+
+// Load-balancing policies accepted in Balancing.Policy.
+const (
+	RoundRobin   = "round_robin"
+	LeastRequest = "least_request"
+)
+
+// Balancing configures how calls are spread over the gRPC backends.
+type Balancing struct {
+	// Policy is RoundRobin or LeastRequest. Least-request picks the less
+	// busy of two random backends, which suits calls of uneven cost such as
+	// exports next to small pages.
+	Policy string
+	// HealthCheck watches every backend with grpc.health.v1.Health and sends
+	// no calls to those not SERVING. Without it, only backends that cannot be
+	// connected to are avoided.
+	HealthCheck bool
+	// FileInterval is how often a file:// target is read again.
+	FileInterval time.Duration
+}
+
+// DefaultBalancing balances round-robin over health-checked backends.
+func DefaultBalancing() Balancing {
+	return Balancing{Policy: RoundRobin, HealthCheck: true, FileInterval: 5 * time.Second}
+}
+
+// Target turns the gateway's -grpc setting into a gRPC target. Besides
+// anything grpc.NewClient accepts (host:port, dns:///host:port, ...) it may be
+// a comma-separated list of host:port, or file:///path to a file listing one
+// host:port per line.
+func Target(spec string) string {
+	if !strings.Contains(spec, "://") && strings.Contains(spec, ",") {
+		return staticScheme + ":///" + spec
+	}
+	return spec
+}
+
+// DialOptions returns the options that apply b to a connection whose target
+// came from Target.
+func (b Balancing) DialOptions() ([]grpc.DialOption, error) {
+	sc, err := b.serviceConfig()
+	if err != nil {
+		return nil, err
+	}
+	return []grpc.DialOption{
+		grpc.WithResolvers(staticBuilder{}, fileBuilder{interval: b.FileInterval}),
+		grpc.WithDefaultServiceConfig(sc),
+	}, nil
+}
+
+type serviceConfig struct {
+	LoadBalancingConfig []map[string]any `json:"loadBalancingConfig"`
+	HealthCheckConfig   *struct {
+		ServiceName string `json:"serviceName"`
+	} `json:"healthCheckConfig,omitempty"`
+}
+
+func (b Balancing) serviceConfig() (string, error) {
+	var sc serviceConfig
+	switch b.Policy {
+	case RoundRobin:
+		sc.LoadBalancingConfig = []map[string]any{{roundrobin.Name: struct{}{}}}
+	case LeastRequest:
+		sc.LoadBalancingConfig = []map[string]any{{leastrequest.Name: map[string]int{"choiceCount": 2}}}
+	default:
+		return "", fmt.Errorf("load-balancing policy %q: must be %s or %s", b.Policy, RoundRobin, LeastRequest)
+	}
+	if b.HealthCheck {
+		// The empty service name is the health of the server as a whole.
+		sc.HealthCheckConfig = &struct {
+			ServiceName string `json:"serviceName"`
+		}{}
+	}
+	out, err := json.Marshal(sc)
+	return string(out), err
+}
@@ -0,0 +1,161 @@
+package upstream
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/peer"
+)
+
+// startBackend serves the health service on a local port.
+func startBackend(t *testing.T) (string, *health.Server) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	g := grpc.NewServer()
+	hs := health.NewServer()
+	healthpb.RegisterHealthServer(g, hs)
+	go func() { _ = g.Serve(lis) }()
+	t.Cleanup(g.Stop)
+	return lis.Addr().String(), hs
+}
+
+func dial(t *testing.T, target string, b Balancing) healthpb.HealthClient {
+	t.Helper()
+	opts, err := b.DialOptions()
+	if err != nil {
+		t.Fatalf("DialOptions: %v", err)
+	}
+	conn, err := grpc.NewClient(Target(target), append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))...)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return healthpb.NewHealthClient(conn)
+}
+
+// backendsUsed makes n calls and returns how many each backend served.
+func backendsUsed(t *testing.T, c healthpb.HealthClient, n int) map[string]int {
+	t.Helper()
+	used := make(map[string]int)
+	for range n {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		var p peer.Peer
+		_, err := c.Check(ctx, &healthpb.HealthCheckRequest{}, grpc.Peer(&p))
+		cancel()
+		if err != nil {
+			t.Fatalf("Check: %v", err)
+		}
+		used[p.Addr.String()]++
+	}
+	return used
+}
+
+// eventually retries cond for a few seconds, as balancers react to changes
+// asynchronously.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestBalancing_SpreadsCallsAndEjectsUnhealthyBackends(t *testing.T) {
+	t.Parallel()
+
+	for _, policy := range []string{RoundRobin, LeastRequest} {
+		a, healthA := startBackend(t)
+		b, _ := startBackend(t)
+		b2 := DefaultBalancing()
+		b2.Policy = policy
+		c := dial(t, a+","+b, b2)
+
+		eventually(t, policy+": both backends in use", func() bool {
+			used := backendsUsed(t, c, 20)
+			return used[a] > 0 && used[b] > 0
+		})
+
+		healthA.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+		eventually(t, policy+": the unhealthy backend ejected", func() bool {
+			return backendsUsed(t, c, 20)[a] == 0
+		})
+
+		healthA.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+		eventually(t, policy+": the backend back in use", func() bool {
+			return backendsUsed(t, c, 20)[a] > 0
+		})
+	}
+}
+
+func TestBalancing_FollowsTargetsFile(t *testing.T) {
+	t.Parallel()
+
+	a, _ := startBackend(t)
+	b, _ := startBackend(t)
+	path := filepath.Join(t.TempDir(), "targets")
+	write := func(addrs ...string) {
+		if err := os.WriteFile(path, []byte("# gRPC backends\n"+strings.Join(addrs, "\n")+"\n"), 0o600); err != nil {
+			t.Fatalf("write targets: %v", err)
+		}
+	}
+	write(a)
+
+	bal := DefaultBalancing()
+	bal.FileInterval = 10 * time.Millisecond
+	c := dial(t, "file://"+path, bal)
+	if used := backendsUsed(t, c, 5); used[a] != 5 {
+		t.Fatalf("used=%v want only %s", used, a)
+	}
+
+	write(a, b)
+	eventually(t, "the added backend in use", func() bool { return backendsUsed(t, c, 20)[b] > 0 })
+	write(b)
+	eventually(t, "the removed backend dropped", func() bool { return backendsUsed(t, c, 20)[a] == 0 })
+}
+
+func TestTarget(t *testing.T) {
+	t.Parallel()
+
+	for in, want := range map[string]string{
+		"grpc:9090":               "grpc:9090",
+		"a:9090, b:9090":          "static:///a:9090, b:9090",
+		"dns:///grpc:9090":        "dns:///grpc:9090",
+		"file:///etc/targets.txt": "file:///etc/targets.txt",
+	} {
+		if got := Target(in); got != want {
+			t.Fatalf("Target(%q)=%q want %q", in, got, want)
+		}
+	}
+}
+
+func TestParseAddresses(t *testing.T) {
+	t.Parallel()
+
+	got := parseAddresses("# comment\na:1, b:2\n\n  c:3 d:4\r\n")
+	if want := "a:1 b:2 c:3 d:4"; strings.Join(got, " ") != want {
+		t.Fatalf("parseAddresses=%q want %q", got, want)
+	}
+}
+
+func TestBalancing_RejectsUnknownPolicy(t *testing.T) {
+	t.Parallel()
+
+	if _, err := (Balancing{Policy: "random"}).DialOptions(); err == nil {
+		t.Fatalf("expected an error")
+	}
+}
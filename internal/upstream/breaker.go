@@ -0,0 +1,241 @@
+package upstream
+
+import (
+	"context"
+	"io"
+	"path"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrCircuitOpen is returned without calling the server while the breaker is
+// open. Its code is UNAVAILABLE; use errors.Is to tell it apart from an
+// UNAVAILABLE returned by the server.
+var ErrCircuitOpen = status.Error(codes.Unavailable, "circuit breaker open")
+
+// BreakerConfig controls when the circuit breaker opens.
+type BreakerConfig struct {
+	// FailureThreshold is the number of consecutive failed calls that opens
+	// the breaker; 0 disables it.
+	FailureThreshold int
+	// OpenTimeout is how long the breaker stays open before it lets a single
+	// trial call through. If that call succeeds the breaker closes, otherwise
+	// it opens again.
+	OpenTimeout time.Duration
+}
+
+// DefaultBreakerConfig opens after 5 consecutive failures for 10s.
+func DefaultBreakerConfig() BreakerConfig {
+	return BreakerConfig{FailureThreshold: 5, OpenTimeout: 10 * time.Second}
+}
+
+type breakerState int
+
+const (
+	stateClosed breakerState = iota
+	stateHalfOpen
+	stateOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case stateHalfOpen:
+		return "half_open"
+	case stateOpen:
+		return "open"
+	default:
+		return "closed"
+	}
+}
+
+// Breaker is a circuit breaker shared by every call on a connection. Only
+// failures that say the server is unhealthy count: UNAVAILABLE and
+// DEADLINE_EXCEEDED. Any other outcome, including INVALID_ARGUMENT and the
+// like, shows the server is answering and resets the count; cancellations by
+// the caller are ignored.
+type Breaker struct {
+	cfg BreakerConfig
+	now func() time.Time
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+	trial    bool // a half-open trial call is in flight
+}
+
+// NewBreaker returns a closed breaker. A nil *Breaker, or one with a zero
+// FailureThreshold, lets every call through.
+func NewBreaker(cfg BreakerConfig) *Breaker {
+	breakerStateGauge.Set(float64(stateClosed))
+	return &Breaker{cfg: cfg, now: time.Now}
+}
+
+// allow reports whether a call may go ahead. A call allowed while half-open
+// is the trial call.
+func (b *Breaker) allow() (ok, trial bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case stateOpen:
+		if b.now().Sub(b.openedAt) < b.cfg.OpenTimeout {
+			return false, false
+		}
+		b.setState(stateHalfOpen)
+		fallthrough
+	case stateHalfOpen:
+		if b.trial {
+			return false, false
+		}
+		b.trial = true
+		return true, true
+	default:
+		return true, false
+	}
+}
+
+// record updates the breaker with the outcome of an allowed call.
+func (b *Breaker) record(err error, trial bool) {
+	code := status.Code(err)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if trial {
+		b.trial = false
+	}
+	switch code {
+	case codes.Canceled:
+		return
+	case codes.Unavailable, codes.DeadlineExceeded:
+		b.failures++
+		if trial || (b.state == stateClosed && b.failures >= b.cfg.FailureThreshold) {
+			b.openedAt = b.now()
+			b.setState(stateOpen)
+		}
+	default:
+		b.failures = 0
+		if trial {
+			b.setState(stateClosed)
+		}
+	}
+}
+
+// setState must be called with b.mu held.
+func (b *Breaker) setState(s breakerState) {
+	if b.state == s {
+		return
+	}
+	b.state = s
+	breakerTransitionsTotal.WithLabelValues(s.String()).Inc()
+	breakerStateGauge.Set(float64(s))
+}
+
+func (b *Breaker) enabled() bool {
+	return b != nil && b.cfg.FailureThreshold > 0
+}
+
+// Unary returns an interceptor that fails calls with ErrCircuitOpen while b is
+// open. Placed after UnaryRetry, it sees every attempt.
+func (b *Breaker) Unary() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if !b.enabled() {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		ok, trial := b.allow()
+		if !ok {
+			breakerRejectedTotal.WithLabelValues(path.Base(method)).Inc()
+			return ErrCircuitOpen
+		}
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		b.record(err, trial)
+		return err
+	}
+}
+
+// Stream is Unary for streaming calls. A stream counts as one call, whose
+// outcome is how it ends: io.EOF from RecvMsg is a success, any other error a
+// failure (or, for a cancellation, neither). A half-open trial stream is
+// settled earlier, as a success, once the server sends headers or a first
+// message, so that a long stream does not keep other calls out until it ends.
+func (b *Breaker) Stream() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if !b.enabled() {
+			return streamer(ctx, desc, cc, method, opts...)
+		}
+		ok, trial := b.allow()
+		if !ok {
+			breakerRejectedTotal.WithLabelValues(path.Base(method)).Inc()
+			return nil, ErrCircuitOpen
+		}
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			b.record(err, trial)
+			return nil, err
+		}
+		s := &breakerStream{ClientStream: cs, b: b, serverStreams: desc.ServerStreams}
+		s.trial.Store(trial)
+		// A caller that gives up on a stream cancels ctx rather than reading
+		// it to the end; that must still end a trial.
+		s.stop = context.AfterFunc(ctx, func() { s.done(status.FromContextError(ctx.Err()).Err()) })
+		if trial {
+			go func() {
+				// Header returns no metadata, and no error, if the stream
+				// ended without headers; RecvMsg reports how.
+				if md, _ := cs.Header(); md != nil {
+					s.started()
+				}
+			}()
+		}
+		return s, nil
+	}
+}
+
+// breakerStream records the outcome of a stream once it ends.
+type breakerStream struct {
+	grpc.ClientStream
+	b             *Breaker
+	trial         atomic.Bool // the stream is a trial not yet settled
+	serverStreams bool
+	stop          func() bool
+	once          sync.Once
+}
+
+func (s *breakerStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == io.EOF:
+		s.end(nil)
+	case err != nil:
+		s.end(err)
+	case !s.serverStreams:
+		// The single response of a client-streaming call ends it.
+		s.end(nil)
+	default:
+		s.started()
+	}
+	return err
+}
+
+// started settles a trial stream as a success: the server is answering.
+func (s *breakerStream) started() {
+	if s.trial.CompareAndSwap(true, false) {
+		s.b.record(nil, true)
+	}
+}
+
+// end records a stream that ended on its own, so ctx no longer needs
+// watching. Only the caller's goroutine calls it, after Stream has set stop.
+func (s *breakerStream) end(err error) {
+	s.stop()
+	s.done(err)
+}
+
+func (s *breakerStream) done(err error) {
+	s.once.Do(func() {
+		s.b.record(err, s.trial.Swap(false))
+	})
+}
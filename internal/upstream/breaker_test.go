@@ -0,0 +1,228 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestBreaker_OpensAndRecovers(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := NewBreaker(BreakerConfig{FailureThreshold: 2, OpenTimeout: time.Second})
+	b.now = func() time.Time { return now }
+
+	var next error
+	calls := 0
+	call := func() error {
+		return b.Unary()(context.Background(), getDataset, nil, nil, nil, func(context.Context, string, any, any, *grpc.ClientConn, ...grpc.CallOption) error {
+			calls++
+			return next
+		})
+	}
+
+	next = errUnavailable
+	call()
+	// Answers other than unavailability reset the count.
+	next = status.Error(codes.NotFound, "no")
+	call()
+	next = errUnavailable
+	call()
+	if err := call(); errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("breaker opened before %d consecutive failures", b.cfg.FailureThreshold)
+	}
+	if err := call(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("err=%v want ErrCircuitOpen", err)
+	}
+	if got, want := calls, 4; got != want {
+		t.Fatalf("calls=%d want %d: an open breaker must not call the server", got, want)
+	}
+
+	// After OpenTimeout a failed trial opens it again...
+	now = now.Add(time.Second)
+	if err := call(); errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("breaker should let a trial call through")
+	}
+	if err := call(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("err=%v want ErrCircuitOpen after a failed trial", err)
+	}
+
+	// ...and a successful one closes it.
+	now = now.Add(time.Second)
+	next = nil
+	for range 3 {
+		if err := call(); err != nil {
+			t.Fatalf("err=%v want the breaker closed", err)
+		}
+	}
+}
+
+func TestBreaker_HalfOpenAllowsOneTrial(t *testing.T) {
+	t.Parallel()
+
+	b := NewBreaker(BreakerConfig{FailureThreshold: 1, OpenTimeout: time.Nanosecond})
+	b.record(errUnavailable, false)
+	time.Sleep(time.Millisecond)
+
+	if ok, trial := b.allow(); !ok || !trial {
+		t.Fatalf("allow=%v,%v want a trial call", ok, trial)
+	}
+	if ok, _ := b.allow(); ok {
+		t.Fatalf("a second call must wait for the trial")
+	}
+	// A cancelled trial says nothing about the server: another may be tried.
+	b.record(status.Error(codes.Canceled, "gone"), true)
+	if ok, trial := b.allow(); !ok || !trial {
+		t.Fatalf("allow=%v,%v want another trial call", ok, trial)
+	}
+}
+
+func TestBreaker_DisabledPassesThrough(t *testing.T) {
+	t.Parallel()
+
+	for _, b := range []*Breaker{nil, NewBreaker(BreakerConfig{})} {
+		for range 10 {
+			err := b.Unary()(context.Background(), getDataset, nil, nil, nil, func(context.Context, string, any, any, *grpc.ClientConn, ...grpc.CallOption) error {
+				return errUnavailable
+			})
+			if errors.Is(err, ErrCircuitOpen) {
+				t.Fatalf("a disabled breaker opened")
+			}
+		}
+	}
+}
+
+// scriptedStream is a ClientStream whose RecvMsg returns errs in turn and
+// whose Header returns header.
+type scriptedStream struct {
+	grpc.ClientStream
+	header metadata.MD
+	errs   []error
+}
+
+func (s *scriptedStream) Header() (metadata.MD, error) {
+	return s.header, nil
+}
+
+func (s *scriptedStream) RecvMsg(any) error {
+	err := s.errs[0]
+	s.errs = s.errs[1:]
+	return err
+}
+
+func TestBreaker_StreamCountsHowStreamsEnd(t *testing.T) {
+	t.Parallel()
+
+	const streamReadings = "/meterusage.v1.MeterUsageService/StreamReadings"
+	desc := &grpc.StreamDesc{ServerStreams: true}
+	b := NewBreaker(BreakerConfig{FailureThreshold: 2, OpenTimeout: time.Hour})
+
+	// stream opens a stream that ends with errs and reads it to its end.
+	stream := func(ctx context.Context, errs ...error) error {
+		cs, err := b.Stream()(ctx, desc, nil, streamReadings, func(context.Context, *grpc.StreamDesc, *grpc.ClientConn, string, ...grpc.CallOption) (grpc.ClientStream, error) {
+			return &scriptedStream{errs: errs}, nil
+		})
+		if err != nil {
+			return err
+		}
+		for {
+			if err := cs.RecvMsg(nil); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Streams that open fine but fail midway are failures...
+	stream(context.Background(), nil, errUnavailable)
+	// ...and one read to io.EOF is a success that resets the count.
+	stream(context.Background(), nil, io.EOF)
+	stream(context.Background(), errUnavailable)
+	if err := stream(context.Background(), nil, io.EOF); errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("breaker opened before %d consecutive failures", b.cfg.FailureThreshold)
+	}
+	stream(context.Background(), errUnavailable)
+	stream(context.Background(), nil, status.Error(codes.DeadlineExceeded, "slow"))
+	if err := stream(context.Background(), io.EOF); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("err=%v want ErrCircuitOpen after streams failed midway", err)
+	}
+}
+
+func TestBreaker_AbandonedTrialStreamEnds(t *testing.T) {
+	t.Parallel()
+
+	b := NewBreaker(BreakerConfig{FailureThreshold: 1, OpenTimeout: time.Nanosecond})
+	b.record(errUnavailable, false)
+	time.Sleep(time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err := b.Stream()(ctx, &grpc.StreamDesc{ServerStreams: true}, nil, "/s", func(context.Context, *grpc.StreamDesc, *grpc.ClientConn, string, ...grpc.CallOption) (grpc.ClientStream, error) {
+		return &scriptedStream{}, nil
+	})
+	if err != nil {
+		t.Fatalf("trial stream: %v", err)
+	}
+	if ok, _ := b.allow(); ok {
+		t.Fatalf("a call must wait for the trial stream to end")
+	}
+
+	// The caller gives up without reading the stream to its end.
+	cancel()
+	deadline := time.Now().Add(time.Second)
+	for {
+		if ok, trial := b.allow(); ok && trial {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("the trial was not released after its stream was cancelled")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestBreaker_TrialStreamSettlesWhenTheServerAnswers(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		stream *scriptedStream
+		recv   bool // read the first message
+	}{
+		"headers":       {stream: &scriptedStream{header: metadata.MD{}}},
+		"first message": {stream: &scriptedStream{errs: []error{nil}}, recv: true},
+	} {
+		b := NewBreaker(BreakerConfig{FailureThreshold: 1, OpenTimeout: time.Nanosecond})
+		b.record(errUnavailable, false)
+		time.Sleep(time.Millisecond)
+
+		cs, err := b.Stream()(context.Background(), &grpc.StreamDesc{ServerStreams: true}, nil, "/s", func(context.Context, *grpc.StreamDesc, *grpc.ClientConn, string, ...grpc.CallOption) (grpc.ClientStream, error) {
+			return tc.stream, nil
+		})
+		if err != nil {
+			t.Fatalf("%s: trial stream: %v", name, err)
+		}
+		if tc.recv {
+			if err := cs.RecvMsg(nil); err != nil {
+				t.Fatalf("%s: RecvMsg: %v", name, err)
+			}
+		}
+
+		// The stream is still open, but the breaker has closed.
+		deadline := time.Now().Add(time.Second)
+		for {
+			if ok, trial := b.allow(); ok && !trial {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("%s: the breaker did not close while the trial stream was open", name)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
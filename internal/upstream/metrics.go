@@ -0,0 +1,42 @@
+package upstream
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	retriesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "grpc_upstream_retries_total",
+			Help: "Upstream gRPC attempts retried, by method and the status code that caused the retry.",
+		},
+		[]string{"method", "code"},
+	)
+	hedgesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "grpc_upstream_hedges_total",
+			Help: "Hedged upstream gRPC attempts sent while earlier ones were still in flight.",
+		},
+		[]string{"method"},
+	)
+
+	breakerStateGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "grpc_upstream_circuit_breaker_state",
+		Help: "State of the upstream circuit breaker: 0 closed, 1 half-open, 2 open.",
+	})
+	breakerTransitionsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "grpc_upstream_circuit_breaker_transitions_total",
+			Help: "Changes of the upstream circuit breaker state, by new state.",
+		},
+		[]string{"state"},
+	)
+	breakerRejectedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "grpc_upstream_circuit_breaker_rejected_total",
+			Help: "Upstream gRPC calls failed fast because the circuit breaker was open.",
+		},
+		[]string{"method"},
+	)
+)
@@ -0,0 +1,146 @@
+package upstream
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/milad/spectral/internal/logging"
+	"google.golang.org/grpc/resolver"
+)
+
+const (
+	staticScheme = "static"
+	fileScheme   = "file"
+)
+
+// staticBuilder resolves static:///host1:port,host2:port to those addresses.
+type staticBuilder struct{}
+
+func (staticBuilder) Scheme() string { return staticScheme }
+
+func (staticBuilder) Build(target resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+	addrs := parseAddresses(target.Endpoint())
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("static target %q lists no addresses", target.Endpoint())
+	}
+	if err := cc.UpdateState(stateOf(addrs)); err != nil {
+		return nil, err
+	}
+	return nopResolver{}, nil
+}
+
+type nopResolver struct{}
+
+func (nopResolver) ResolveNow(resolver.ResolveNowOptions) {}
+func (nopResolver) Close()                                {}
+
+// fileBuilder resolves file:///path to the addresses listed in the file, and
+// follows changes to it.
+type fileBuilder struct {
+	interval time.Duration
+}
+
+func (fileBuilder) Scheme() string { return fileScheme }
+
+func (b fileBuilder) Build(target resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+	r := &fileResolver{
+		path:     target.URL.Path,
+		interval: b.interval,
+		cc:       cc,
+		now:      make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	r.read()
+	go r.watch()
+	return r, nil
+}
+
+type fileResolver struct {
+	path     string
+	interval time.Duration
+	cc       resolver.ClientConn
+	now      chan struct{} // ResolveNow
+	done     chan struct{} // Close
+
+	last []byte // content of the last good read
+}
+
+func (r *fileResolver) watch() {
+	t := time.NewTicker(r.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-r.now:
+		case <-r.done:
+			return
+		}
+		r.read()
+	}
+}
+
+// read sends the addresses in the file to the connection if they changed. A
+// file that cannot be read or lists no address is reported as an error,
+// which keeps the previous addresses if there were any.
+func (r *fileResolver) read() {
+	b, err := os.ReadFile(r.path)
+	if err == nil && bytes.Equal(b, r.last) {
+		return
+	}
+	var addrs []string
+	if err == nil {
+		if addrs = parseAddresses(string(b)); len(addrs) == 0 {
+			err = errors.New("no addresses")
+		}
+	}
+	if err != nil {
+		slog.Warn("read gRPC targets file", "path", r.path, logging.Err(err))
+		if r.last == nil {
+			r.cc.ReportError(fmt.Errorf("targets file %s: %w", r.path, err))
+		}
+		return
+	}
+	if err := r.cc.UpdateState(stateOf(addrs)); err != nil {
+		slog.Warn("apply gRPC targets file", "path", r.path, logging.Err(err))
+	} else {
+		slog.Info("gRPC targets updated", "path", r.path, "addresses", strings.Join(addrs, ","))
+	}
+	r.last = b
+}
+
+func (r *fileResolver) ResolveNow(resolver.ResolveNowOptions) {
+	select {
+	case r.now <- struct{}{}:
+	default:
+	}
+}
+
+func (r *fileResolver) Close() { close(r.done) }
+
+// parseAddresses splits a list of addresses separated by commas, whitespace
+// or newlines. Lines starting with # are comments.
+func parseAddresses(s string) []string {
+	var addrs []string
+	for _, line := range strings.Split(s, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		addrs = append(addrs, strings.FieldsFunc(line, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t' || r == '\r'
+		})...)
+	}
+	return addrs
+}
+
+func stateOf(addrs []string) resolver.State {
+	var s resolver.State
+	for _, a := range addrs {
+		s.Endpoints = append(s.Endpoints, resolver.Endpoint{Addresses: []resolver.Address{{Addr: a}}})
+	}
+	return s
+}
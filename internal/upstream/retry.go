@@ -0,0 +1,174 @@
+// Package upstream makes the gateway's calls to the gRPC servers resilient:
+// load balancing over health-checked backends, retries and hedging for
+// idempotent reads, and a circuit breaker that fails fast while the servers
+// are unhealthy.
+package upstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"path"
+	"slices"
+	"strconv"
+	"time"
+
+	meterusagev1 "github.com/milad/spectral/gen/go/proto/meterusage/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// IdempotentMethods are the unary methods that may safely be sent more than
+// once: the reads. AppendReadings is left out even though it takes an
+// idempotency key, as most batches are sent without one.
+var IdempotentMethods = []string{
+	meterusagev1.MeterUsageService_ListReadings_FullMethodName,
+	meterusagev1.MeterUsageService_AggregateReadings_FullMethodName,
+	meterusagev1.MeterUsageService_ListMeters_FullMethodName,
+	meterusagev1.MeterUsageService_GetDataset_FullMethodName,
+	meterusagev1.MeterUsageService_GetIngestionReport_FullMethodName,
+	meterusagev1.MeterUsageService_GetQualityReport_FullMethodName,
+}
+
+// RetryPolicy controls how a failed or slow idempotent call is sent again.
+type RetryPolicy struct {
+	// MaxAttempts counts the first attempt; 1 disables retries and hedging.
+	MaxAttempts int
+	// The n-th retry waits a random time below
+	// min(InitialBackoff * Multiplier^(n-1), MaxBackoff).
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	// RetryableCodes are the status codes worth another attempt. Any other
+	// failure is returned at once.
+	RetryableCodes []codes.Code
+	// HedgeDelay, if positive, sends another attempt whenever none has
+	// answered for that long, without cancelling the ones in flight; the
+	// first success wins. Failed attempts are still retried after a backoff.
+	HedgeDelay time.Duration
+}
+
+// DefaultRetryPolicy retries unavailable backends twice and does not hedge.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 50 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Multiplier:     2,
+		RetryableCodes: []codes.Code{codes.Unavailable},
+	}
+}
+
+// ParseCodes parses status code names such as "UNAVAILABLE".
+func ParseCodes(names []string) ([]codes.Code, error) {
+	out := make([]codes.Code, 0, len(names))
+	for _, name := range names {
+		var c codes.Code
+		if err := c.UnmarshalJSON([]byte(strconv.Quote(name))); err != nil {
+			return nil, fmt.Errorf("status code %q: %w", name, err)
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+// backoff returns the jittered wait before retry n (1-based).
+func (p RetryPolicy) backoff(n int) time.Duration {
+	d := float64(p.InitialBackoff)
+	for range n - 1 {
+		d *= p.Multiplier
+		if d >= float64(p.MaxBackoff) {
+			break
+		}
+	}
+	ceiling := time.Duration(min(d, float64(p.MaxBackoff)))
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling)
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	// An open breaker will stay open for a while; retrying only adds load
+	// once it lets a trial call through.
+	if errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+	return slices.Contains(p.RetryableCodes, status.Code(err))
+}
+
+type attemptResult struct {
+	reply proto.Message
+	err   error
+}
+
+// UnaryRetry applies p to the methods in IdempotentMethods; other calls pass
+// through unchanged. Every attempt shares the deadline of the call, so
+// retries never make a call take longer than its caller allowed.
+func UnaryRetry(p RetryPolicy) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		out, ok := reply.(proto.Message)
+		if p.MaxAttempts <= 1 || !ok || !slices.Contains(IdempotentMethods, method) {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		name := path.Base(method)
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel() // stops the attempts that lost
+
+		// Attempts run concurrently when hedging, so each decodes into its
+		// own message and the winner is copied into reply.
+		results := make(chan attemptResult, p.MaxAttempts)
+		launched, inFlight := 0, 0
+		launch := func() {
+			launched++
+			inFlight++
+			r := out.ProtoReflect().New().Interface()
+			go func() {
+				results <- attemptResult{reply: r, err: invoker(ctx, method, req, r, cc, opts...)}
+			}()
+		}
+		launch()
+
+		var lastErr error
+		var retry <-chan time.Time // fires when the pending retry is due
+		for inFlight > 0 || retry != nil {
+			var hedge <-chan time.Time
+			if p.HedgeDelay > 0 && launched < p.MaxAttempts && retry == nil {
+				hedge = time.After(p.HedgeDelay)
+			}
+			select {
+			case res := <-results:
+				inFlight--
+				if res.err == nil {
+					proto.Reset(out)
+					proto.Merge(out, res.reply)
+					return nil
+				}
+				lastErr = res.err
+				if !p.retryable(res.err) {
+					return res.err
+				}
+				if launched < p.MaxAttempts && retry == nil {
+					retriesTotal.WithLabelValues(name, status.Code(res.err).String()).Inc()
+					retry = time.After(p.backoff(launched))
+				}
+			case <-retry:
+				retry = nil
+				launch()
+			case <-hedge:
+				hedgesTotal.WithLabelValues(name).Inc()
+				launch()
+			case <-ctx.Done():
+				if lastErr != nil {
+					return lastErr
+				}
+				return status.FromContextError(ctx.Err()).Err()
+			}
+		}
+		return lastErr
+	}
+}
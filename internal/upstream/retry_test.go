@@ -0,0 +1,169 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	meterusagev1 "github.com/milad/spectral/gen/go/proto/meterusage/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const getDataset = meterusagev1.MeterUsageService_GetDataset_FullMethodName
+
+// scriptedInvoker answers attempt n with outcomes[n]; a nil error succeeds
+// with the attempt number as the dataset version. Attempts with a delay wait
+// for it or for their context.
+type scriptedInvoker struct {
+	outcomes []outcome
+
+	mu       sync.Mutex
+	attempts int
+}
+
+type outcome struct {
+	err   error
+	delay time.Duration
+}
+
+func (s *scriptedInvoker) invoke(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+	s.mu.Lock()
+	n := s.attempts
+	s.attempts++
+	s.mu.Unlock()
+	o := s.outcomes[n]
+	if o.delay > 0 {
+		select {
+		case <-time.After(o.delay):
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+	if o.err != nil {
+		return o.err
+	}
+	reply.(*meterusagev1.GetDatasetResponse).Dataset = &meterusagev1.Dataset{Version: uint64(n + 1)}
+	return nil
+}
+
+func (s *scriptedInvoker) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.attempts
+}
+
+func fastPolicy() RetryPolicy {
+	p := DefaultRetryPolicy()
+	p.InitialBackoff = time.Millisecond
+	p.MaxBackoff = 2 * time.Millisecond
+	return p
+}
+
+var errUnavailable = status.Error(codes.Unavailable, "down")
+
+func TestUnaryRetry_RetriesRetryableCodes(t *testing.T) {
+	t.Parallel()
+
+	inv := &scriptedInvoker{outcomes: []outcome{{err: errUnavailable}, {err: errUnavailable}, {}}}
+	reply := &meterusagev1.GetDatasetResponse{}
+	err := UnaryRetry(fastPolicy())(context.Background(), getDataset, &meterusagev1.GetDatasetRequest{}, reply, nil, inv.invoke)
+	if err != nil {
+		t.Fatalf("err=%v", err)
+	}
+	if got, want := reply.GetDataset().GetVersion(), uint64(3); got != want {
+		t.Fatalf("reply from attempt %d want %d", got, want)
+	}
+}
+
+func TestUnaryRetry_GivesUp(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		method   string
+		outcomes []outcome
+		want     codes.Code
+		attempts int
+	}{
+		"attempts exhausted": {getDataset, []outcome{{err: errUnavailable}, {err: errUnavailable}, {err: errUnavailable}}, codes.Unavailable, 3},
+		"not retryable":      {getDataset, []outcome{{err: status.Error(codes.InvalidArgument, "bad")}}, codes.InvalidArgument, 1},
+		"breaker open":       {getDataset, []outcome{{err: ErrCircuitOpen}}, codes.Unavailable, 1},
+		"not idempotent":     {meterusagev1.MeterUsageService_AppendReadings_FullMethodName, []outcome{{err: errUnavailable}}, codes.Unavailable, 1},
+	} {
+		inv := &scriptedInvoker{outcomes: tc.outcomes}
+		err := UnaryRetry(fastPolicy())(context.Background(), tc.method, &meterusagev1.GetDatasetRequest{}, &meterusagev1.GetDatasetResponse{}, nil, inv.invoke)
+		if got := status.Code(err); got != tc.want {
+			t.Fatalf("%s: code=%s want %s", name, got, tc.want)
+		}
+		if got := inv.count(); got != tc.attempts {
+			t.Fatalf("%s: attempts=%d want %d", name, got, tc.attempts)
+		}
+	}
+}
+
+func TestUnaryRetry_RespectsDeadline(t *testing.T) {
+	t.Parallel()
+
+	p := DefaultRetryPolicy()
+	p.InitialBackoff, p.MaxBackoff = time.Hour, time.Hour
+	inv := &scriptedInvoker{outcomes: []outcome{{err: errUnavailable}, {}}}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := UnaryRetry(p)(ctx, getDataset, &meterusagev1.GetDatasetRequest{}, &meterusagev1.GetDatasetResponse{}, nil, inv.invoke)
+	if !errors.Is(err, errUnavailable) {
+		t.Fatalf("err=%v want the last attempt's error", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("took %s, the backoff should have been cut short by the deadline", d)
+	}
+}
+
+func TestUnaryRetry_Hedging(t *testing.T) {
+	t.Parallel()
+
+	p := fastPolicy()
+	p.HedgeDelay = 10 * time.Millisecond
+	// The first attempt hangs; the hedged second one answers.
+	inv := &scriptedInvoker{outcomes: []outcome{{delay: time.Hour}, {}}}
+	reply := &meterusagev1.GetDatasetResponse{}
+	err := UnaryRetry(p)(context.Background(), getDataset, &meterusagev1.GetDatasetRequest{}, reply, nil, inv.invoke)
+	if err != nil {
+		t.Fatalf("err=%v", err)
+	}
+	if got, want := reply.GetDataset().GetVersion(), uint64(2); got != want {
+		t.Fatalf("reply from attempt %d want %d", got, want)
+	}
+	if got, want := inv.count(), 2; got != want {
+		t.Fatalf("attempts=%d want %d", got, want)
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	t.Parallel()
+
+	p := RetryPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 35 * time.Millisecond, Multiplier: 2}
+	for n, ceiling := range map[int]time.Duration{1: 10 * time.Millisecond, 2: 20 * time.Millisecond, 3: 35 * time.Millisecond, 10: 35 * time.Millisecond} {
+		for range 100 {
+			if d := p.backoff(n); d < 0 || d >= ceiling {
+				t.Fatalf("backoff(%d)=%s want within [0, %s)", n, d, ceiling)
+			}
+		}
+	}
+}
+
+func TestParseCodes(t *testing.T) {
+	t.Parallel()
+
+	got, err := ParseCodes([]string{"UNAVAILABLE", "RESOURCE_EXHAUSTED"})
+	if err != nil || len(got) != 2 || got[0] != codes.Unavailable || got[1] != codes.ResourceExhausted {
+		t.Fatalf("ParseCodes=%v, %v", got, err)
+	}
+	if _, err := ParseCodes([]string{"UNAVAILIBLE"}); err == nil {
+		t.Fatalf("expected an error for an unknown code")
+	}
+}